@@ -25,6 +25,19 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	File       string // name of the file the program was read from, if any
+
+	arena *Arena // allocated the program's nodes, nil if they were built directly
+}
+
+// NewProgram creates an empty program whose nodes come from arena.
+func NewProgram(arena *Arena) *Program {
+	return &Program{arena: arena}
+}
+
+// Arena returns the arena the program's nodes were allocated from, if any.
+func (p *Program) Arena() *Arena {
+	return p.arena
 }
 
 func (p *Program) TokenLiteral() string {
@@ -47,6 +60,12 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Set by Resolve when the identifier names a function or loop variable:
+	// the variable lives in slot Slot of the environment Depth levels up.
+	Resolved bool
+	Depth    int
+	Slot     int
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -83,9 +102,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
-	out.WriteString(" = ")
 
 	if ls.Value != nil {
+		out.WriteString(" = ")
 		out.WriteString(ls.Value.String())
 	}
 
@@ -210,6 +229,7 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // The { token
 	Statements []Statement
+	EndLine    int // line of the closing }, 0 if unknown
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -228,6 +248,7 @@ type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Scope      *Scope // set by Resolve
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -338,6 +359,7 @@ func (a *Assignment) String() string {
 type HashLiteral struct {
 	Token token.Token // The '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order, if known
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -394,6 +416,8 @@ type WhileStatement struct {
 	Token     token.Token // the 'while' token
 	Condition Expression
 	Body      *BlockStatement
+	Scope     *Scope // set by Resolve
+	BodyScope *Scope // set by Resolve; variables declared by one iteration
 }
 
 func (ws *WhileStatement) statementNode()       {}
@@ -502,6 +526,8 @@ type ForStatement struct {
 	Condition   Expression
 	Post        Statement
 	Body        *BlockStatement
+	Scope       *Scope // set by Resolve
+	BodyScope   *Scope // set by Resolve; variables declared by one iteration
 }
 
 func (fs *ForStatement) statementNode()       {}
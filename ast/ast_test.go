@@ -2,6 +2,8 @@ package ast
 
 import (
 	"1ylang/token"
+	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +28,228 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestResolve(t *testing.T) {
+	param := &Identifier{Value: "a"}
+	local := &Identifier{Value: "b"}
+	inner := &Identifier{Value: "a"}
+	global := &Identifier{Value: "g"}
+
+	// fn(a) { let b = fn() { a + g }; }
+	fn := &FunctionLiteral{
+		Parameters: []*Identifier{param},
+		Body: &BlockStatement{Statements: []Statement{
+			&LetStatement{Name: local, Value: &FunctionLiteral{
+				Body: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &InfixExpression{Left: inner, Operator: "+", Right: global}},
+				}},
+			}},
+		}},
+	}
+	Resolve(&Program{Statements: []Statement{&ExpressionStatement{Expression: fn}}})
+
+	if !param.Resolved || param.Depth != 0 || param.Slot != 0 {
+		t.Errorf("parameter resolved wrong. got=%+v", param)
+	}
+	if !local.Resolved || local.Depth != 0 || local.Slot != 1 {
+		t.Errorf("local resolved wrong. got=%+v", local)
+	}
+	if !inner.Resolved || inner.Depth != 1 || inner.Slot != 0 {
+		t.Errorf("captured parameter resolved wrong. got=%+v", inner)
+	}
+	if global.Resolved {
+		t.Errorf("top-level name should stay unresolved. got=%+v", global)
+	}
+}
+
+func TestResolveLoopBody(t *testing.T) {
+	counter := &Identifier{Value: "i"}
+	local := &Identifier{Value: "x"}
+	use := &Identifier{Value: "i"}
+
+	// for (let i = 0; ; ) { let x = i; }
+	loop := &ForStatement{
+		Init: &LetStatement{Name: counter, Value: &IntegerLiteral{}},
+		Body: &BlockStatement{Statements: []Statement{
+			&LetStatement{Name: local, Value: use},
+		}},
+	}
+	Resolve(&Program{Statements: []Statement{loop}})
+
+	if loop.Scope.Names["i"] != 0 || loop.BodyScope.Names["x"] != 0 || len(loop.BodyScope.Names) != 1 {
+		t.Errorf("wrong loop scopes. got=%v and %v", loop.Scope.Names, loop.BodyScope.Names)
+	}
+	if !use.Resolved || use.Depth != 1 || use.Slot != 0 {
+		t.Errorf("loop variable used in body resolved wrong. got=%+v", use)
+	}
+	if loop.BodyScope.Captured {
+		t.Errorf("body without function literals marked captured")
+	}
+
+	loop.Body.Statements = append(loop.Body.Statements, &ExpressionStatement{Expression: &FunctionLiteral{Body: &BlockStatement{}}})
+	Resolve(&Program{Statements: []Statement{loop}})
+	if !loop.BodyScope.Captured {
+		t.Errorf("body with a function literal not marked captured")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	// let f = fn(a) { if (a) { a + 1 } }
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "f"}, Value: &FunctionLiteral{
+			Parameters: []*Identifier{{Value: "a"}},
+			Body: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: &IfExpression{
+					Condition: &Identifier{Value: "a"},
+					Consequence: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &InfixExpression{
+							Left: &Identifier{Value: "a"}, Operator: "+", Right: &IntegerLiteral{Token: token.Token{Literal: "1"}},
+						}},
+					}},
+				}},
+			}},
+		}},
+	}}
+
+	var names []string
+	depth, maxDepth := 0, 0
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+
+	if got := strings.Join(names, " "); got != "f a a a" {
+		t.Errorf("identifiers visited wrong. got=%q", got)
+	}
+	if depth != 0 || maxDepth != 10 {
+		t.Errorf("wrong nesting. got depth=%d, max=%d", depth, maxDepth)
+	}
+
+	// Returning false skips the children of a node
+	count := 0
+	Inspect(program, func(node Node) bool {
+		if node != nil {
+			count++
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+	if count != 4 {
+		t.Errorf("wrong number of nodes outside the function. got=%d", count)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	key := &StringLiteral{Value: "x"}
+	hash := &HashLiteral{Pairs: map[Expression]Expression{key: &Identifier{Value: "x"}}, Keys: []Expression{key}}
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &CallExpression{
+			Function:  &Identifier{Value: "puts"},
+			Arguments: []Expression{&Identifier{Value: "x"}, hash},
+		}},
+	}}
+
+	// Rename x to y, leaving other names alone
+	result := Rewrite(program, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &Identifier{Token: token.Token{Literal: "y"}, Value: "y"}
+		}
+		if str, ok := node.(*StringLiteral); ok {
+			return &StringLiteral{Token: token.Token{Literal: str.Value + "!"}, Value: str.Value + "!"}
+		}
+		return node
+	})
+
+	if result != program {
+		t.Fatalf("program replaced. got=%T", result)
+	}
+	call := program.Statements[0].(*ExpressionStatement).Expression.(*CallExpression)
+	if call.Function.String() != "puts" || call.Arguments[0].String() != "y" {
+		t.Errorf("call rewritten wrong. got=%s", call)
+	}
+	if len(hash.Keys) != 1 || hash.Keys[0].String() != "x!" || hash.Pairs[hash.Keys[0]].String() != "y" {
+		t.Errorf("hash rewritten wrong. got=%s", hash)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("replacing a statement with an expression did not panic")
+		}
+	}()
+	Rewrite(program, func(node Node) Node {
+		if stmt, ok := node.(*ExpressionStatement); ok {
+			return stmt.Expression
+		}
+		return node
+	})
+}
+
+func TestJSON(t *testing.T) {
+	key := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: "k"}, Value: "k"}
+	program := &Program{File: "test.1y", Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+			Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Line: 1, Column: 5}, Value: "x"},
+			Value: &InfixExpression{
+				Token:    token.Token{Type: token.PLUS, Literal: "+", Line: 1, Column: 11},
+				Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1", Line: 1, Column: 9}, Value: big.NewInt(1)},
+				Operator: "+",
+				Right:    &FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: "2.5", Line: 1, Column: 13}, Value: big.NewFloat(2.5)},
+			},
+		},
+		&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return", Line: 2, Column: 1}},
+		&ExpressionStatement{Expression: &HashLiteral{
+			Pairs: map[Expression]Expression{key: &Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}}},
+			Keys:  []Expression{key},
+		}},
+	}}
+
+	data, err := MarshalJSON(program)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	for _, want := range []string{`"type":"Program"`, `"file":"test.1y"`, `"type":"InfixExpression"`, `"line":1,"column":13`, `"value":"2.5"`, `"value":false`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON does not contain %s. got=%s", want, data)
+		}
+	}
+
+	node, err := UnmarshalJSON(data)
+	if err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	decoded, ok := node.(*Program)
+	if !ok {
+		t.Fatalf("decoded node is not a Program. got=%T", node)
+	}
+	if decoded.String() != program.String() || decoded.File != "test.1y" {
+		t.Errorf("decoded program wrong. got=%q, want=%q", decoded.String(), program.String())
+	}
+	let := decoded.Statements[0].(*LetStatement)
+	if let.Name.Token.Column != 5 || let.Value.(*InfixExpression).Right.(*FloatLiteral).Value.Cmp(big.NewFloat(2.5)) != 0 {
+		t.Errorf("let statement decoded wrong. got=%+v", let)
+	}
+	if decoded.Statements[1].(*ReturnStatement).ReturnValue != nil {
+		t.Errorf("empty return decoded with a value")
+	}
+	again, _ := MarshalJSON(decoded)
+	if string(again) != string(data) {
+		t.Errorf("round trip changed the JSON.\ngot= %s\nwant=%s", again, data)
+	}
+
+	for _, bad := range []string{`{"type":"Nope"}`, `{"type":"LetStatement","name":{"type":"IntegerLiteral","value":"1"}}`, `{"type":"IntegerLiteral","value":"x"}`} {
+		if _, err := UnmarshalJSON([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) did not fail", bad)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package ast
+
+import (
+	"1ylang/token"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// jsonNode is the JSON form of a node. Every node has a type, named after
+// its Go type, and the token it starts at, which carries its position; the
+// other fields are set as the node type needs them.
+type jsonNode struct {
+	Type  string     `json:"type"`
+	Token *jsonToken `json:"token,omitempty"`
+	File  string     `json:"file,omitempty"`
+	Name  *jsonNode  `json:"name,omitempty"`
+
+	// Value is a scalar for literals and identifiers and a node otherwise
+	Value json.RawMessage `json:"value,omitempty"`
+
+	Operator    string       `json:"operator,omitempty"`
+	Left        *jsonNode    `json:"left,omitempty"`
+	Right       *jsonNode    `json:"right,omitempty"`
+	Index       *jsonNode    `json:"index,omitempty"`
+	Function    *jsonNode    `json:"function,omitempty"`
+	Path        *jsonNode    `json:"path,omitempty"`
+	Expression  *jsonNode    `json:"expression,omitempty"`
+	Init        *jsonNode    `json:"init,omitempty"`
+	Condition   *jsonNode    `json:"condition,omitempty"`
+	Post        *jsonNode    `json:"post,omitempty"`
+	Consequence *jsonNode    `json:"consequence,omitempty"`
+	Elifs       []jsonBranch `json:"elifs,omitempty"`
+	Alternative *jsonNode    `json:"alternative,omitempty"`
+	Body        *jsonNode    `json:"body,omitempty"`
+	Statements  []*jsonNode  `json:"statements,omitempty"`
+	Parameters  []*jsonNode  `json:"parameters,omitempty"`
+	Arguments   []*jsonNode  `json:"arguments,omitempty"`
+	Elements    []*jsonNode  `json:"elements,omitempty"`
+	Indices     []*jsonNode  `json:"indices,omitempty"`
+	Pairs       []jsonPair   `json:"pairs,omitempty"`
+	EndLine     int          `json:"endLine,omitempty"`
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line,omitempty"`
+	Column  int             `json:"column,omitempty"`
+}
+
+type jsonBranch struct {
+	Condition   *jsonNode `json:"condition"`
+	Consequence *jsonNode `json:"consequence"`
+}
+
+type jsonPair struct {
+	Key   *jsonNode `json:"key"`
+	Value *jsonNode `json:"value"`
+}
+
+// MarshalJSON encodes the tree rooted at node as JSON, so that tools outside
+// Go can read it. Each node is an object with a "type" naming its Go type,
+// such as "InfixExpression", a "token" holding the type, literal, line and
+// column of the token it starts at, and a field for each of its children.
+// The pairs of a hash literal are a list in source order.
+//
+// Annotations added by Resolve are not encoded.
+func MarshalJSON(node Node) ([]byte, error) {
+	n, err := toJSON(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+// UnmarshalJSON decodes a tree encoded by MarshalJSON, or written by another
+// tool in the same form. Fields a node type does not use are ignored and
+// missing ones are left empty.
+func UnmarshalJSON(data []byte) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return fromJSON(&n)
+}
+
+func toJSON(node Node) (*jsonNode, error) {
+	if isNil(node) {
+		return nil, nil
+	}
+
+	var n *jsonNode
+	var err error
+	children := func(nodes ...Node) []*jsonNode {
+		list := make([]*jsonNode, len(nodes))
+		for i, node := range nodes {
+			if err == nil {
+				list[i], err = toJSON(node)
+			}
+		}
+		return list
+	}
+	child := func(node Node) *jsonNode {
+		return children(node)[0]
+	}
+	scalar := func(value interface{}) json.RawMessage {
+		data, _ := json.Marshal(value)
+		return data
+	}
+	value := func(node Node) json.RawMessage {
+		if c := child(node); c != nil {
+			data, _ := json.Marshal(c)
+			return data
+		}
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		n = &jsonNode{File: node.File, Statements: children(statementNodes(node.Statements)...)}
+	case *LetStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Name: child(node.Name), Value: value(node.Value)}
+	case *ConstStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Name: child(node.Name), Value: value(node.Value)}
+	case *ReturnStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Value: value(node.ReturnValue)}
+	case *ExpressionStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Expression: child(node.Expression)}
+	case *BlockStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Statements: children(statementNodes(node.Statements)...), EndLine: node.EndLine}
+	case *WhileStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Condition: child(node.Condition), Body: child(node.Body)}
+	case *ForStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token), Init: child(node.Init), Condition: child(node.Condition), Post: child(node.Post), Body: child(node.Body)}
+	case *BreakStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token)}
+	case *ContinueStatement:
+		n = &jsonNode{Token: newJSONToken(node.Token)}
+	case *Identifier:
+		n = &jsonNode{Token: newJSONToken(node.Token), Value: scalar(node.Value)}
+	case *IntegerLiteral:
+		n = &jsonNode{Token: newJSONToken(node.Token)}
+		if node.Value != nil {
+			n.Value = scalar(node.Value.String())
+		}
+	case *FloatLiteral:
+		n = &jsonNode{Token: newJSONToken(node.Token)}
+		if node.Value != nil {
+			n.Value = scalar(node.Value.Text('g', -1))
+		}
+	case *StringLiteral:
+		n = &jsonNode{Token: newJSONToken(node.Token), Value: scalar(node.Value)}
+	case *Boolean:
+		n = &jsonNode{Token: newJSONToken(node.Token), Value: scalar(node.Value)}
+	case *PrefixExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Operator: node.Operator, Right: child(node.Right)}
+	case *InfixExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Left: child(node.Left), Operator: node.Operator, Right: child(node.Right)}
+	case *PostfixExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Left: child(node.Left), Operator: node.Operator}
+	case *IfExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Condition: child(node.Condition), Consequence: child(node.Consequence)}
+		for _, elif := range node.Elifs {
+			n.Elifs = append(n.Elifs, jsonBranch{Condition: child(elif.Condition), Consequence: child(elif.Consequence)})
+		}
+		n.Alternative = child(node.Alternative)
+	case *FunctionLiteral:
+		params := make([]Node, len(node.Parameters))
+		for i, param := range node.Parameters {
+			params[i] = param
+		}
+		n = &jsonNode{Token: newJSONToken(node.Token), Parameters: children(params...), Body: child(node.Body)}
+	case *CallExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Function: child(node.Function), Arguments: children(expressionNodes(node.Arguments)...)}
+	case *ArrayLiteral:
+		n = &jsonNode{Token: newJSONToken(node.Token), Elements: children(expressionNodes(node.Elements)...)}
+	case *IndexExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Left: child(node.Left), Index: child(node.Index)}
+	case *MultiDimensionalIndex:
+		n = &jsonNode{Indices: children(expressionNodes(node.Indices)...)}
+	case *Assignment:
+		n = &jsonNode{Token: newJSONToken(node.Token), Name: child(node.Name), Value: value(node.Value)}
+	case *HashLiteral:
+		n = &jsonNode{Token: newJSONToken(node.Token)}
+		for _, key := range node.keys() {
+			n.Pairs = append(n.Pairs, jsonPair{Key: child(key), Value: child(node.Pairs[key])})
+		}
+	case *DotExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Left: child(node.Left), Right: child(node.Right)}
+	case *ImportExpression:
+		n = &jsonNode{Token: newJSONToken(node.Token), Path: child(node.Path)}
+	default:
+		return nil, fmt.Errorf("cannot encode node of type %T", node)
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Type = reflect.TypeOf(node).Elem().Name()
+	return n, nil
+}
+
+func newJSONToken(tok token.Token) *jsonToken {
+	return &jsonToken{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}
+}
+
+func statementNodes(stmts []Statement) []Node {
+	nodes := make([]Node, len(stmts))
+	for i, stmt := range stmts {
+		nodes[i] = stmt
+	}
+	return nodes
+}
+
+func expressionNodes(exps []Expression) []Node {
+	nodes := make([]Node, len(exps))
+	for i, exp := range exps {
+		nodes[i] = exp
+	}
+	return nodes
+}
+
+// jsonDecoder turns jsonNodes back into nodes, keeping the first error.
+type jsonDecoder struct {
+	err error
+}
+
+func fromJSON(n *jsonNode) (Node, error) {
+	d := &jsonDecoder{}
+	node := d.node(n)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func (d *jsonDecoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *jsonDecoder) token(n *jsonNode) token.Token {
+	if n.Token == nil {
+		return token.Token{}
+	}
+	return token.Token{Type: n.Token.Type, Literal: n.Token.Literal, Line: n.Token.Line, Column: n.Token.Column}
+}
+
+func (d *jsonDecoder) statement(n *jsonNode) Statement {
+	if n == nil {
+		return nil
+	}
+	stmt, ok := d.node(n).(Statement)
+	if !ok {
+		d.fail("%s is not a statement", n.Type)
+	}
+	return stmt
+}
+
+func (d *jsonDecoder) statements(list []*jsonNode) []Statement {
+	var stmts []Statement
+	for _, n := range list {
+		stmts = append(stmts, d.statement(n))
+	}
+	return stmts
+}
+
+func (d *jsonDecoder) expression(n *jsonNode) Expression {
+	if n == nil {
+		return nil
+	}
+	exp, ok := d.node(n).(Expression)
+	if !ok {
+		d.fail("%s is not an expression", n.Type)
+	}
+	return exp
+}
+
+func (d *jsonDecoder) expressions(list []*jsonNode) []Expression {
+	var exps []Expression
+	for _, n := range list {
+		exps = append(exps, d.expression(n))
+	}
+	return exps
+}
+
+func (d *jsonDecoder) block(n *jsonNode) *BlockStatement {
+	if n == nil {
+		return nil
+	}
+	block, ok := d.node(n).(*BlockStatement)
+	if !ok {
+		d.fail("%s is not a BlockStatement", n.Type)
+	}
+	return block
+}
+
+func (d *jsonDecoder) identifier(n *jsonNode) *Identifier {
+	if n == nil {
+		return nil
+	}
+	ident, ok := d.node(n).(*Identifier)
+	if !ok {
+		d.fail("%s is not an Identifier", n.Type)
+	}
+	return ident
+}
+
+// value decodes the value of n, which is a node.
+func (d *jsonDecoder) value(n *jsonNode) Expression {
+	if len(n.Value) == 0 || string(n.Value) == "null" {
+		return nil
+	}
+	var v jsonNode
+	if err := json.Unmarshal(n.Value, &v); err != nil {
+		d.fail("value of %s: %v", n.Type, err)
+		return nil
+	}
+	return d.expression(&v)
+}
+
+// scalar decodes the value of n, which is a literal, into v.
+func (d *jsonDecoder) scalar(n *jsonNode, v interface{}) {
+	if len(n.Value) == 0 {
+		d.fail("%s has no value", n.Type)
+		return
+	}
+	if err := json.Unmarshal(n.Value, v); err != nil {
+		d.fail("value of %s: %v", n.Type, err)
+	}
+}
+
+func (d *jsonDecoder) node(n *jsonNode) Node {
+	tok := d.token(n)
+
+	switch n.Type {
+	case "Program":
+		return &Program{File: n.File, Statements: d.statements(n.Statements)}
+	case "LetStatement":
+		return &LetStatement{Token: tok, Name: d.identifier(n.Name), Value: d.value(n)}
+	case "ConstStatement":
+		return &ConstStatement{Token: tok, Name: d.identifier(n.Name), Value: d.value(n)}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: tok, ReturnValue: d.value(n)}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: tok, Expression: d.expression(n.Expression)}
+	case "BlockStatement":
+		return &BlockStatement{Token: tok, Statements: d.statements(n.Statements), EndLine: n.EndLine}
+	case "WhileStatement":
+		return &WhileStatement{Token: tok, Condition: d.expression(n.Condition), Body: d.block(n.Body)}
+	case "ForStatement":
+		return &ForStatement{Token: tok, Init: d.statement(n.Init), Condition: d.expression(n.Condition), Post: d.statement(n.Post), Body: d.block(n.Body)}
+	case "BreakStatement":
+		return &BreakStatement{Token: tok}
+	case "ContinueStatement":
+		return &ContinueStatement{Token: tok}
+	case "Identifier":
+		ident := &Identifier{Token: tok}
+		d.scalar(n, &ident.Value)
+		return ident
+	case "IntegerLiteral":
+		var text string
+		d.scalar(n, &text)
+		value, ok := new(big.Int).SetString(text, 10)
+		if !ok && d.err == nil {
+			d.fail("could not parse %q as integer", text)
+		}
+		return &IntegerLiteral{Token: tok, Value: value}
+	case "FloatLiteral":
+		var text string
+		d.scalar(n, &text)
+		value, ok := new(big.Float).SetString(text)
+		if !ok && d.err == nil {
+			d.fail("could not parse %q as float", text)
+		}
+		return &FloatLiteral{Token: tok, Value: value}
+	case "StringLiteral":
+		lit := &StringLiteral{Token: tok}
+		d.scalar(n, &lit.Value)
+		return lit
+	case "Boolean":
+		b := &Boolean{Token: tok}
+		d.scalar(n, &b.Value)
+		return b
+	case "PrefixExpression":
+		return &PrefixExpression{Token: tok, Operator: n.Operator, Right: d.expression(n.Right)}
+	case "InfixExpression":
+		return &InfixExpression{Token: tok, Left: d.expression(n.Left), Operator: n.Operator, Right: d.expression(n.Right)}
+	case "PostfixExpression":
+		return &PostfixExpression{Token: tok, Operator: n.Operator, Left: d.expression(n.Left)}
+	case "IfExpression":
+		ie := &IfExpression{Token: tok, Condition: d.expression(n.Condition), Consequence: d.block(n.Consequence)}
+		for _, elif := range n.Elifs {
+			ie.Elifs = append(ie.Elifs, &ElifExpression{Condition: d.expression(elif.Condition), Consequence: d.block(elif.Consequence)})
+		}
+		ie.Alternative = d.block(n.Alternative)
+		return ie
+	case "FunctionLiteral":
+		fl := &FunctionLiteral{Token: tok, Parameters: []*Identifier{}, Body: d.block(n.Body)}
+		for _, param := range n.Parameters {
+			fl.Parameters = append(fl.Parameters, d.identifier(param))
+		}
+		return fl
+	case "CallExpression":
+		return &CallExpression{Token: tok, Function: d.expression(n.Function), Arguments: d.expressions(n.Arguments)}
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: tok, Elements: d.expressions(n.Elements)}
+	case "IndexExpression":
+		return &IndexExpression{Token: tok, Left: d.expression(n.Left), Index: d.expression(n.Index)}
+	case "MultiDimensionalIndex":
+		return &MultiDimensionalIndex{Indices: d.expressions(n.Indices)}
+	case "Assignment":
+		return &Assignment{Token: tok, Name: d.expression(n.Name), Value: d.value(n)}
+	case "HashLiteral":
+		hl := &HashLiteral{Token: tok, Pairs: make(map[Expression]Expression)}
+		for _, pair := range n.Pairs {
+			key := d.expression(pair.Key)
+			hl.Keys = append(hl.Keys, key)
+			hl.Pairs[key] = d.expression(pair.Value)
+		}
+		return hl
+	case "DotExpression":
+		return &DotExpression{Token: tok, Left: d.expression(n.Left), Right: d.expression(n.Right)}
+	case "ImportExpression":
+		return &ImportExpression{Token: tok, Path: d.expression(n.Path)}
+	}
+	d.fail("unknown node type %q", n.Type)
+	return nil
+}
@@ -0,0 +1,193 @@
+package ast
+
+// Scope lists the variables declared directly in a function or loop. Each
+// name is given a slot in the environment created when the function is
+// called or the loop starts.
+type Scope struct {
+	Names map[string]int
+
+	// Captured is set when a function literal appears inside the scope, so a
+	// closure may keep its environment alive after the scope ends.
+	Captured bool
+}
+
+func NewScope() *Scope {
+	return &Scope{Names: make(map[string]int)}
+}
+
+func (s *Scope) declare(name string) int {
+	if slot, ok := s.Names[name]; ok {
+		return slot
+	}
+	slot := len(s.Names)
+	s.Names[name] = slot
+	return slot
+}
+
+// Resolve walks the program and gives every function and loop a Scope, then
+// marks each identifier that refers to one of their variables with the
+// (depth, slot) pair locating it. Top-level variables are left unresolved and
+// are looked up by name, since the REPL, eval and imports can add to the
+// top-level environment after the program has been resolved.
+//
+// Resolving the same program again recomputes the same annotations.
+func Resolve(program *Program) {
+	r := &resolver{}
+	for _, stmt := range program.Statements {
+		r.statement(stmt)
+	}
+}
+
+type resolver struct {
+	scopes []*Scope
+}
+
+func (r *resolver) push(scope *Scope) {
+	r.scopes = append(r.scopes, scope)
+}
+
+func (r *resolver) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *resolver) declare(ident *Identifier) {
+	if len(r.scopes) == 0 {
+		ident.Resolved = false
+		return
+	}
+	ident.Resolved = true
+	ident.Depth = 0
+	ident.Slot = r.scopes[len(r.scopes)-1].declare(ident.Value)
+}
+
+func (r *resolver) lookup(ident *Identifier) {
+	ident.Resolved = false
+	for depth := 0; depth < len(r.scopes); depth++ {
+		scope := r.scopes[len(r.scopes)-1-depth]
+		if slot, ok := scope.Names[ident.Value]; ok {
+			ident.Resolved = true
+			ident.Depth = depth
+			ident.Slot = slot
+			return
+		}
+	}
+}
+
+func (r *resolver) block(block *BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) statement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		// The value is resolved first so `let x = x + 1` still reads an outer x
+		r.expression(stmt.Value)
+		r.declare(stmt.Name)
+	case *ConstStatement:
+		r.expression(stmt.Value)
+		r.declare(stmt.Name)
+	case *ReturnStatement:
+		r.expression(stmt.ReturnValue)
+	case *ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *BlockStatement:
+		r.block(stmt)
+	case *WhileStatement:
+		stmt.Scope = NewScope()
+		r.push(stmt.Scope)
+		r.expression(stmt.Condition)
+		stmt.BodyScope = r.loopBody(stmt.Body)
+		r.pop()
+	case *ForStatement:
+		stmt.Scope = NewScope()
+		r.push(stmt.Scope)
+		if stmt.Init != nil {
+			r.statement(stmt.Init)
+		}
+		r.expression(stmt.Condition)
+		if stmt.Post != nil {
+			r.statement(stmt.Post)
+		}
+		stmt.BodyScope = r.loopBody(stmt.Body)
+		r.pop()
+	}
+}
+
+// loopBody resolves the body of a loop in a scope of its own, which is
+// emptied before each iteration.
+func (r *resolver) loopBody(body *BlockStatement) *Scope {
+	scope := NewScope()
+	r.push(scope)
+	r.block(body)
+	r.pop()
+	return scope
+}
+
+func (r *resolver) expression(exp Expression) {
+	switch exp := exp.(type) {
+	case *Identifier:
+		if exp != nil {
+			r.lookup(exp)
+		}
+	case *PrefixExpression:
+		r.expression(exp.Right)
+	case *InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *PostfixExpression:
+		r.expression(exp.Left)
+	case *IfExpression:
+		r.expression(exp.Condition)
+		r.block(exp.Consequence)
+		for _, elif := range exp.Elifs {
+			r.expression(elif.Condition)
+			r.block(elif.Consequence)
+		}
+		r.block(exp.Alternative)
+	case *FunctionLiteral:
+		for _, scope := range r.scopes {
+			scope.Captured = true
+		}
+		exp.Scope = NewScope()
+		r.push(exp.Scope)
+		for _, param := range exp.Parameters {
+			r.declare(param)
+		}
+		r.block(exp.Body)
+		r.pop()
+	case *CallExpression:
+		r.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.expression(arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expression(el)
+		}
+	case *IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *MultiDimensionalIndex:
+		for _, idx := range exp.Indices {
+			r.expression(idx)
+		}
+	case *Assignment:
+		r.expression(exp.Value)
+		r.expression(exp.Name)
+	case *HashLiteral:
+		for key, value := range exp.Pairs {
+			r.expression(key)
+			r.expression(value)
+		}
+	case *DotExpression:
+		// The right-hand side is a property name, not a variable
+		r.expression(exp.Left)
+	case *ImportExpression:
+		r.expression(exp.Path)
+	}
+}
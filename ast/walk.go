@@ -0,0 +1,299 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called by Walk for each node. If it returns a
+// non-nil visitor w, Walk visits the children of the node with w, then calls
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in source order, calling
+// v.Visit(node) first. Missing children, such as the value of a return
+// without one, are skipped. The conditions and bodies of else if branches
+// are visited as children of their if expression.
+func Walk(node Node, v Visitor) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Walk(stmt, v)
+		}
+	case *LetStatement:
+		Walk(n.Name, v)
+		Walk(n.Value, v)
+	case *ConstStatement:
+		Walk(n.Name, v)
+		Walk(n.Value, v)
+	case *ReturnStatement:
+		Walk(n.ReturnValue, v)
+	case *ExpressionStatement:
+		Walk(n.Expression, v)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			Walk(stmt, v)
+		}
+	case *WhileStatement:
+		Walk(n.Condition, v)
+		Walk(n.Body, v)
+	case *ForStatement:
+		Walk(n.Init, v)
+		Walk(n.Condition, v)
+		Walk(n.Post, v)
+		Walk(n.Body, v)
+	case *PrefixExpression:
+		Walk(n.Right, v)
+	case *InfixExpression:
+		Walk(n.Left, v)
+		Walk(n.Right, v)
+	case *PostfixExpression:
+		Walk(n.Left, v)
+	case *IfExpression:
+		Walk(n.Condition, v)
+		Walk(n.Consequence, v)
+		for _, elif := range n.Elifs {
+			Walk(elif.Condition, v)
+			Walk(elif.Consequence, v)
+		}
+		Walk(n.Alternative, v)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Walk(param, v)
+		}
+		Walk(n.Body, v)
+	case *CallExpression:
+		Walk(n.Function, v)
+		for _, arg := range n.Arguments {
+			Walk(arg, v)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Walk(el, v)
+		}
+	case *IndexExpression:
+		Walk(n.Left, v)
+		Walk(n.Index, v)
+	case *MultiDimensionalIndex:
+		for _, idx := range n.Indices {
+			Walk(idx, v)
+		}
+	case *Assignment:
+		Walk(n.Name, v)
+		Walk(n.Value, v)
+	case *HashLiteral:
+		for _, key := range n.keys() {
+			Walk(key, v)
+			Walk(n.Pairs[key], v)
+		}
+	case *DotExpression:
+		Walk(n.Left, v)
+		Walk(n.Right, v)
+	case *ImportExpression:
+		Walk(n.Path, v)
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node like Walk, calling f(node) for
+// each node and then f(nil) once its children are done. The children of a
+// node are skipped if f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Rewrite traverses the tree rooted at node bottom-up and replaces each node
+// with the result of fn, which is called once the node's children have been
+// rewritten. Returning the node unchanged keeps it. Rewrite returns the
+// replacement for node itself.
+//
+// A replacement must fit where the node was: an Expression for an
+// expression, a Statement for a statement, and a node of the same type for
+// a block, a declared name or a parameter. Rewrite panics otherwise.
+func Rewrite(node Node, fn func(Node) Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = rewriteStatements(n.Statements, fn)
+	case *LetStatement:
+		n.Name = rewriteIdentifier(n.Name, fn)
+		n.Value = rewriteExpression(n.Value, fn)
+	case *ConstStatement:
+		n.Name = rewriteIdentifier(n.Name, fn)
+		n.Value = rewriteExpression(n.Value, fn)
+	case *ReturnStatement:
+		n.ReturnValue = rewriteExpression(n.ReturnValue, fn)
+	case *ExpressionStatement:
+		n.Expression = rewriteExpression(n.Expression, fn)
+	case *BlockStatement:
+		n.Statements = rewriteStatements(n.Statements, fn)
+	case *WhileStatement:
+		n.Condition = rewriteExpression(n.Condition, fn)
+		n.Body = rewriteBlock(n.Body, fn)
+	case *ForStatement:
+		n.Init = rewriteStatement(n.Init, fn)
+		n.Condition = rewriteExpression(n.Condition, fn)
+		n.Post = rewriteStatement(n.Post, fn)
+		n.Body = rewriteBlock(n.Body, fn)
+	case *PrefixExpression:
+		n.Right = rewriteExpression(n.Right, fn)
+	case *InfixExpression:
+		n.Left = rewriteExpression(n.Left, fn)
+		n.Right = rewriteExpression(n.Right, fn)
+	case *PostfixExpression:
+		n.Left = rewriteExpression(n.Left, fn)
+	case *IfExpression:
+		n.Condition = rewriteExpression(n.Condition, fn)
+		n.Consequence = rewriteBlock(n.Consequence, fn)
+		for _, elif := range n.Elifs {
+			elif.Condition = rewriteExpression(elif.Condition, fn)
+			elif.Consequence = rewriteBlock(elif.Consequence, fn)
+		}
+		n.Alternative = rewriteBlock(n.Alternative, fn)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = rewriteIdentifier(param, fn)
+		}
+		n.Body = rewriteBlock(n.Body, fn)
+	case *CallExpression:
+		n.Function = rewriteExpression(n.Function, fn)
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = rewriteExpression(arg, fn)
+		}
+	case *ArrayLiteral:
+		for i, el := range n.Elements {
+			n.Elements[i] = rewriteExpression(el, fn)
+		}
+	case *IndexExpression:
+		n.Left = rewriteExpression(n.Left, fn)
+		n.Index = rewriteExpression(n.Index, fn)
+	case *MultiDimensionalIndex:
+		for i, idx := range n.Indices {
+			n.Indices[i] = rewriteExpression(idx, fn)
+		}
+	case *Assignment:
+		n.Name = rewriteExpression(n.Name, fn)
+		n.Value = rewriteExpression(n.Value, fn)
+	case *HashLiteral:
+		// Pairs is keyed by the key nodes, so it is rebuilt with the new keys
+		keys := n.keys()
+		pairs := make(map[Expression]Expression, len(keys))
+		for i, key := range keys {
+			value := n.Pairs[key]
+			keys[i] = rewriteExpression(key, fn)
+			pairs[keys[i]] = rewriteExpression(value, fn)
+		}
+		n.Keys = keys
+		n.Pairs = pairs
+	case *DotExpression:
+		n.Left = rewriteExpression(n.Left, fn)
+		n.Right = rewriteExpression(n.Right, fn)
+	case *ImportExpression:
+		n.Path = rewriteExpression(n.Path, fn)
+	}
+
+	return fn(node)
+}
+
+func rewriteStatements(stmts []Statement, fn func(Node) Node) []Statement {
+	for i, stmt := range stmts {
+		stmts[i] = rewriteStatement(stmt, fn)
+	}
+	return stmts
+}
+
+func rewriteStatement(stmt Statement, fn func(Node) Node) Statement {
+	if isNil(stmt) {
+		return stmt
+	}
+	result := Rewrite(stmt, fn)
+	replacement, ok := result.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace statement %T with %T", stmt, result))
+	}
+	return replacement
+}
+
+func rewriteExpression(exp Expression, fn func(Node) Node) Expression {
+	if isNil(exp) {
+		return exp
+	}
+	result := Rewrite(exp, fn)
+	replacement, ok := result.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace expression %T with %T", exp, result))
+	}
+	return replacement
+}
+
+func rewriteBlock(block *BlockStatement, fn func(Node) Node) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	result := Rewrite(block, fn)
+	replacement, ok := result.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace block with %T", result))
+	}
+	return replacement
+}
+
+func rewriteIdentifier(ident *Identifier, fn func(Node) Node) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	result := Rewrite(ident, fn)
+	replacement, ok := result.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace name %s with %T", ident.Value, result))
+	}
+	return replacement
+}
+
+// keys returns the keys of the hash in source order if known, and in
+// unspecified order if the literal was built without Keys.
+func (hl *HashLiteral) keys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// isNil reports whether node is nil or a nil pointer, as optional children
+// such as an if without an else are.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *FunctionLiteral:
+		return n == nil
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"1ylang/compiled"
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/parser"
+	"1ylang/repl"
+	"1ylang/token"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// bundleMagic ends an executable made by `1y build`. It follows the bundle,
+// which is appended to a copy of the interpreter, and the bundle's length.
+const bundleMagic = "1ybundle"
+
+// bundle is a script built into an executable together with the modules it
+// imports, held by the path they are imported with.
+type bundle struct {
+	Name    string            `json:"name"` // file name of the script, for error messages
+	Script  []byte            `json:"script"`
+	Modules map[string][]byte `json:"modules"`
+}
+
+// buildExecutable runs `1y build [-o output] script`, writing a copy of the
+// interpreter with the script and the modules it imports built in. Only
+// imports of string literals, as in import("lib"), can be found.
+func buildExecutable(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	output := fs.String("o", "", "Write the executable to this file instead of one named after the script")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: 1y build [-o output] script")
+	}
+	path := fs.Arg(0)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading file %s: %v", path, err)
+	}
+	b := &bundle{Name: filepath.Base(path), Script: content, Modules: map[string][]byte{}}
+	if err := bundleImports(b, path, content); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if runtime.GOOS == "windows" {
+			*output += ".exe"
+		}
+	}
+
+	interpreter, err := interpreterBinary()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	out.Write(interpreter)
+	out.Write(payload)
+	binary.Write(&out, binary.BigEndian, uint64(len(payload)))
+	out.WriteString(bundleMagic)
+
+	if err := os.WriteFile(*output, out.Bytes(), 0755); err != nil {
+		return fmt.Errorf("Error writing file %s: %v", *output, err)
+	}
+	return nil
+}
+
+// bundleImports adds the modules that content, the contents of file,
+// imports to b, along with those they import in turn.
+func bundleImports(b *bundle, file string, content []byte) error {
+	if compiled.IsCompiled(content) {
+		// The imports of a compiled module cannot be found from its tokens
+		return nil
+	}
+	p := parser.New(lexer.New(string(content)))
+	p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("Error parsing file %s:\n\t%s", file, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	l := lexer.New(string(content))
+	prev := [2]token.Token{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		// Go plugins are always loaded from their files, so they are left out
+		if tok.Type == token.STRING && prev[0].Type == token.IMPORT && prev[1].Type == token.LPAREN &&
+			!strings.HasSuffix(tok.Literal, evaluator.PluginExtension) {
+			if _, ok := b.Modules[tok.Literal]; !ok {
+				if err := bundleModule(b, file, tok.Literal); err != nil {
+					return err
+				}
+			}
+		}
+		prev[0], prev[1] = prev[1], tok
+	}
+	return nil
+}
+
+func bundleModule(b *bundle, importer, name string) error {
+	path := evaluator.FindModule(name)
+	if path == "" {
+		return fmt.Errorf("%s imports %q, which cannot be found", importer, name)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading file %s: %v", path, err)
+	}
+	b.Modules[name] = content
+	return bundleImports(b, path, content)
+}
+
+// interpreterBinary returns the contents of the running interpreter, less
+// any bundle built into it.
+func interpreterBinary() ([]byte, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("Error finding the interpreter: %v", err)
+	}
+	content, err := os.ReadFile(self)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the interpreter: %v", err)
+	}
+	if size, ok := bundleSize(content[max(len(content)-16, 0):]); ok {
+		content = content[:len(content)-16-int(size)]
+	}
+	return content, nil
+}
+
+// bundleSize reads the length of a bundle from trailer, the last 16 bytes of
+// an executable, and reports whether there is one.
+func bundleSize(trailer []byte) (uint64, bool) {
+	if len(trailer) != 16 || string(trailer[8:]) != bundleMagic {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(trailer[:8]), true
+}
+
+// builtBundle returns the bundle built into the running executable, or nil
+// if it is the plain interpreter.
+func builtBundle() *bundle {
+	self, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(self)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.Size() < 16 {
+		return nil
+	}
+	trailer := make([]byte, 16)
+	if _, err := f.ReadAt(trailer, info.Size()-16); err != nil {
+		return nil
+	}
+	size, ok := bundleSize(trailer)
+	if !ok || size > uint64(info.Size()-16) {
+		return nil
+	}
+
+	payload := make([]byte, size)
+	if _, err := f.ReadAt(payload, info.Size()-16-int64(size)); err != nil && err != io.EOF {
+		return nil
+	}
+	b := &bundle{}
+	if err := json.Unmarshal(payload, b); err != nil {
+		return nil
+	}
+	return b
+}
+
+// runBundle runs the script built into the executable. All the command
+// line arguments are the script's own.
+func runBundle(b *bundle) {
+	evaluator.SetArgs(os.Args[1:])
+	evaluator.SetBundle(b.Modules)
+	repl.Color = os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+	repl.ErrorOutput = os.Stderr
+
+	var ok bool
+	if compiled.IsCompiled(b.Script) {
+		program, err := compiled.Read(bytes.NewReader(b.Script))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", b.Name, err)
+			os.Exit(1)
+		}
+		program.File = b.Name
+		ok = repl.StartWithProgram(os.Stdout, program, false)
+	} else {
+		ok = repl.StartWithFile(os.Stdout, b.Name, string(b.Script), false)
+	}
+	evaluator.RunExitHooks()
+	if !ok {
+		os.Exit(1)
+	}
+}
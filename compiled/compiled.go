@@ -0,0 +1,83 @@
+// Package compiled stores parsed programs in .1yc files so that running or
+// importing them skips lexing and parsing. There is no bytecode backend; a
+// .1yc file holds the program's AST, gob-encoded behind a versioned header.
+package compiled
+
+import (
+	"1ylang/ast"
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Extension is the file extension of compiled programs.
+const Extension = ".1yc"
+
+// FormatVersion changes whenever the encoding of the AST changes. Files
+// written with a different version must be compiled again.
+const FormatVersion = 2
+
+var magic = []byte("1YC\x00")
+
+func init() {
+	for _, node := range []interface{}{
+		&ast.LetStatement{}, &ast.ConstStatement{}, &ast.ReturnStatement{},
+		&ast.ExpressionStatement{}, &ast.BlockStatement{}, &ast.BreakStatement{},
+		&ast.ContinueStatement{}, &ast.WhileStatement{}, &ast.ForStatement{},
+		&ast.Identifier{}, &ast.IntegerLiteral{}, &ast.FloatLiteral{},
+		&ast.StringLiteral{}, &ast.Boolean{}, &ast.PrefixExpression{},
+		&ast.InfixExpression{}, &ast.PostfixExpression{}, &ast.IfExpression{},
+		&ast.FunctionLiteral{}, &ast.CallExpression{}, &ast.ArrayLiteral{},
+		&ast.IndexExpression{}, &ast.MultiDimensionalIndex{}, &ast.Assignment{},
+		&ast.HashLiteral{}, &ast.DotExpression{}, &ast.ImportExpression{},
+	} {
+		gob.Register(node)
+	}
+}
+
+// IsCompiled reports whether data starts with the .1yc header.
+func IsCompiled(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Write encodes program to w as a .1yc file.
+func Write(w io.Writer, program *ast.Program) error {
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{FormatVersion}); err != nil {
+		return err
+	}
+
+	// Statements that failed to parse are nil and cannot be encoded
+	statements := make([]ast.Statement, 0, len(program.Statements))
+	for _, stmt := range program.Statements {
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+
+	return gob.NewEncoder(w).Encode(&ast.Program{Statements: statements})
+}
+
+// Read decodes a program written by Write.
+func Read(r io.Reader) (*ast.Program, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || !IsCompiled(header) {
+		return nil, errors.New("not a compiled 1y program")
+	}
+	if version := header[len(magic)]; version != FormatVersion {
+		return nil, fmt.Errorf("compiled program has format version %d, want %d; compile it again", version, FormatVersion)
+	}
+
+	program := &ast.Program{}
+	if err := gob.NewDecoder(br).Decode(program); err != nil {
+		return nil, fmt.Errorf("reading compiled program: %w", err)
+	}
+	return program, nil
+}
@@ -0,0 +1,58 @@
+package compiled_test
+
+import (
+	"1ylang/compiled"
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/parser"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+const h = {"one": 1, 2: [1.5, true]};
+let total = 0;
+for (let i = 0; i < 10; i++) {
+	if (i == 1) { continue; } elif (i > 5) { break; } else { total += h.one; }
+}
+let n = 0;
+while (n < 3) { n++; }
+let grid = [[1, 2], [3, 4]];
+return [add(total, n), grid[1, 0], h[2], -2 ** 3, "a" + "b"];`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	expected := evaluator.Eval(program, evaluator.NewEnvironment()).Inspect()
+
+	var buf bytes.Buffer
+	if err := compiled.Write(&buf, program); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !compiled.IsCompiled(buf.Bytes()) {
+		t.Fatalf("written data has no header")
+	}
+
+	decoded, err := compiled.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if got := evaluator.Eval(decoded, evaluator.NewEnvironment()).Inspect(); got != expected {
+		t.Errorf("compiled program behaves differently. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestReadRejectsOtherData(t *testing.T) {
+	if _, err := compiled.Read(strings.NewReader("let x = 1;")); err == nil {
+		t.Errorf("expected error for source text")
+	}
+
+	old := append([]byte("1YC\x00"), compiled.FormatVersion+1)
+	if _, err := compiled.Read(bytes.NewReader(old)); err == nil || !strings.Contains(err.Error(), "format version") {
+		t.Errorf("expected version error, got %v", err)
+	}
+}
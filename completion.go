@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// subcommand describes a subcommand of 1y for completion scripts.
+type subcommand struct {
+	name, usage string
+	flags       []completionFlag
+}
+
+// completionFlag is a flag of a subcommand. Those of running a script are
+// taken from the flag package instead.
+type completionFlag struct {
+	name, usage string
+	value, file bool // whether the flag takes a value, and whether it is a file
+}
+
+// subcommands must be kept up to date with the subcommands main handles.
+var subcommands = []subcommand{
+	{"build", "Make an executable with a script and its imports built in", []completionFlag{
+		{"o", "Write the executable to this file instead of one named after the script", true, true},
+	}},
+	{"completion", "Print a completion script for bash, zsh or fish", nil},
+	{"doc", "Show the documentation of builtins, namespaces and modules", []completionFlag{
+		{"json", "Print the entries as JSON", false, false},
+	}},
+	{"fmt", "Format scripts", []completionFlag{
+		{"w", "Write the result to the file instead of standard output", false, false},
+	}},
+	{"get", "Install modules and record them in 1y.json", []completionFlag{
+		{"name", "Install the module under this name instead of the last element of its source", true, false},
+	}},
+	{"lint", "Report likely mistakes in scripts", nil},
+	{"serve", "Evaluate code sent over JSON-RPC, in sessions of their own", []completionFlag{
+		{"addr", "Listen on this address", true, false},
+		{"idle", "Close sessions unused for this long, or never if 0", true, false},
+		{"max-memory", "Stop an evaluation once the interpreter uses more memory than this, e.g. 256MB", true, false},
+		{"max-sessions", "Refuse to open more sessions than this at once, or never if 0", true, false},
+		{"max-steps", "Stop each evaluation after this many steps, or never if 0", true, false},
+		{"sandbox", "Run code in sandbox mode, without import, file access and the exit, getenv and setenv builtins", false, false},
+		{"timeout", "Stop each evaluation that runs longer than this, or never if 0", true, false},
+	}},
+}
+
+// fileFlags are the flags of running a script that take a file.
+var fileFlags = map[string]bool{"f": true, "compile": true, "init": true, "record": true, "replay": true}
+
+// scriptFlags returns the flags of running a script, as defined in main.
+func scriptFlags() []completionFlag {
+	var flags []completionFlag
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{f.Name, f.Usage, !ok || !b.IsBoolFlag(), fileFlags[f.Name]})
+	})
+	return flags
+}
+
+// writeCompletion runs `1y completion bash|zsh|fish`, printing a script that
+// completes the subcommands and flags of 1y in that shell.
+func writeCompletion(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: 1y completion bash|zsh|fish")
+	}
+	switch args[0] {
+	case "bash":
+		bashCompletion(out)
+	case "zsh":
+		zshCompletion(out)
+	case "fish":
+		fishCompletion(out)
+	default:
+		return fmt.Errorf("no completion for %s, expected bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// flagNames returns the names of flags, for which keep returns true.
+func flagNames(flags []completionFlag, keep func(completionFlag) bool) string {
+	var names []string
+	for _, f := range flags {
+		if keep(f) {
+			names = append(names, "-"+f.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func bashCompletion(out io.Writer) {
+	var names []string
+	for _, c := range subcommands {
+		names = append(names, c.name)
+	}
+	flags := scriptFlags()
+	all := func(completionFlag) bool { return true }
+	value := func(f completionFlag) bool { return f.value && !f.file }
+	file := func(f completionFlag) bool { return f.file }
+
+	fmt.Fprintln(out, "# bash completion for 1y. Load it with: source <(1y completion bash)")
+	fmt.Fprintln(out, "_1y() {")
+	fmt.Fprintln(out, `    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} flags values files`)
+	fmt.Fprintln(out, `    case ${COMP_WORDS[1]} in`)
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "    %s) flags=%q values=%q files=%q ;;\n", c.name, flagNames(c.flags, all), flagNames(c.flags, value), flagNames(c.flags, file))
+	}
+	fmt.Fprintf(out, "    *) flags=%q values=%q files=%q ;;\n", flagNames(flags, all), flagNames(flags, value), flagNames(flags, file))
+	fmt.Fprintln(out, "    esac")
+	fmt.Fprintln(out, `    if [[ " $values " == *" $prev "* ]]; then`)
+	fmt.Fprintln(out, `        COMPREPLY=()`)
+	fmt.Fprintln(out, `    elif [[ " $files " == *" $prev "* ]]; then`)
+	fmt.Fprintln(out, `        COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Fprintln(out, `    elif [[ $cur == -* ]]; then`)
+	fmt.Fprintln(out, `        COMPREPLY=($(compgen -W "$flags" -- "$cur"))`)
+	fmt.Fprintln(out, `    elif [ "$COMP_CWORD" -eq 1 ]; then`)
+	fmt.Fprintf(out, "        COMPREPLY=($(compgen -W %q -- \"$cur\") $(compgen -f -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(out, "    else")
+	fmt.Fprintln(out, `        COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Fprintln(out, "    fi")
+	fmt.Fprintln(out, "}")
+	fmt.Fprintln(out, "complete -o filenames -F _1y 1y")
+}
+
+// shellQuote quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes the characters _arguments gives a meaning to in s.
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func zshArguments(out io.Writer, flags []completionFlag, indent string) {
+	for _, f := range flags {
+		spec := "-" + f.name + "[" + zshEscape(f.usage) + "]"
+		if f.file {
+			spec += ":file:_files"
+		} else if f.value {
+			spec += ":value: "
+		}
+		fmt.Fprintf(out, "%s%s \\\n", indent, shellQuote(spec))
+	}
+}
+
+func zshCompletion(out io.Writer) {
+	fmt.Fprintln(out, "#compdef 1y")
+	fmt.Fprintln(out, "# zsh completion for 1y. Load it with: source <(1y completion zsh)")
+	fmt.Fprintln(out, "_1y() {")
+	fmt.Fprintln(out, "    local -a commands")
+	fmt.Fprintln(out, "    commands=(")
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "        %s\n", shellQuote(c.name+":"+c.usage))
+	}
+	fmt.Fprintln(out, "    )")
+	fmt.Fprintln(out, "    case $words[2] in")
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "    %s)\n", c.name)
+		fmt.Fprintln(out, "        _arguments \\")
+		zshArguments(out, c.flags, "            ")
+		fmt.Fprintln(out, "            '*:file:_files' ;;")
+	}
+	fmt.Fprintln(out, "    *)")
+	fmt.Fprintln(out, "        _arguments \\")
+	zshArguments(out, scriptFlags(), "            ")
+	fmt.Fprintln(out, "            '1: :{_describe command commands; _files}' \\")
+	fmt.Fprintln(out, "            '*:file:_files' ;;")
+	fmt.Fprintln(out, "    esac")
+	fmt.Fprintln(out, "}")
+	fmt.Fprintln(out, "compdef _1y 1y")
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func fishFlags(out io.Writer, condition string, flags []completionFlag) {
+	for _, f := range flags {
+		fmt.Fprintf(out, "complete -c 1y -n %s -o %s", fishQuote(condition), f.name)
+		if f.file {
+			fmt.Fprint(out, " -r -F")
+		} else if f.value {
+			fmt.Fprint(out, " -x")
+		}
+		fmt.Fprintf(out, " -d %s\n", fishQuote(f.usage))
+	}
+}
+
+func fishCompletion(out io.Writer) {
+	fmt.Fprintln(out, "# fish completion for 1y. Load it with: 1y completion fish | source")
+	var names []string
+	for _, c := range subcommands {
+		names = append(names, c.name)
+		fmt.Fprintf(out, "complete -c 1y -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.usage))
+	}
+	for _, c := range subcommands {
+		fishFlags(out, "__fish_seen_subcommand_from "+c.name, c.flags)
+	}
+	fishFlags(out, "not __fish_seen_subcommand_from "+strings.Join(names, " "), scriptFlags())
+}
@@ -0,0 +1,170 @@
+package doc
+
+// builtinEntries documents the builtin functions, which are defined in
+// every environment.
+var builtinEntries = []Entry{
+	{"args", "args()", "Returns the command-line arguments that follow the script, as an array of strings. They are also held by the ARGS constant."},
+	{"concat", "concat(a, b, ...)", "Returns a new array holding the elements of all the arrays given, in order."},
+	{"eprint", "eprint(values...)", "Writes the values to standard error, separated by spaces, without a trailing newline."},
+	{"error", "error(kind, message)", "Stops the program with an error of the given kind, such as \"io\", and message, as a failed operation does. Embedders see the kind in the Kind of the RuntimeError. The kinds \"syntax\", \"limit\" and \"interrupt\" are reserved for the interpreter."},
+	{"eval", "eval(source, bindings?)", "Runs the 1y code in the string source and returns its value. Without bindings the code runs in the calling environment; with a hash of bindings it runs in a fresh environment holding only those names."},
+	{"exit", "exit(code?)", "Runs the exit hooks and ends the program with the integer exit code, 0 by default."},
+	{"first", "first(array)", "Returns the first element of array, or null if it is empty."},
+	{"float", "float(value)", "Converts an integer or a string to a float. Returns null if the string is not a number."},
+	{"format", "format(number, precision?, separator?)", "Formats number with precision digits after the decimal point, and with the digits of the integer part grouped in threes by separator, as in format(1234.5, 2, \",\") == \"1,234.50\"."},
+	{"getenv", "getenv(name, default?)", "Returns the value of the environment variable name, or default, or null, if it is not set."},
+	{"input", "input(prompt?)", "Prints prompt and reads a word from standard input."},
+	{"insert", "insert(array, index, value)", "Inserts value into array before the element at index, or at the end when index is the length of array, changing it, and returns the array."},
+	{"int", "int(value, radix?)", "Converts a float or a string to an integer, truncating floats. Strings are read in base radix, 10 by default, or as 0x, 0o and 0b literals when radix is 0. Returns null if the string is not a number."},
+	{"last", "last(array)", "Returns the last element of array, or null if it is empty."},
+	{"len", "len(value)", "Returns the number of characters in a string or elements in an array."},
+	{"load", "load(module)", "Runs the top-level code of an imported module now rather than on first use, and returns its members."},
+	{"parse", "parse(source)", "Parses the 1y code in the string source and returns its syntax tree as nested hashes."},
+	{"pop", "pop(array)", "Removes the last element of array and returns it, or null if array is empty."},
+	{"pprint", "pprint(value, indent?)", "Prints value with arrays and hashes spread over several lines, indented by indent spaces, 2 by default and at most 16. Hash keys are sorted, numbers by value."},
+	{"print", "print(values...)", "Writes the values to standard output, separated by spaces, without a trailing newline."},
+	{"prompt", "prompt(message, default?, hidden?)", "Shows message and reads a line, which can be edited on a terminal. Returns default, or \"\", if the line is empty and null at the end of input. Hidden input, such as a password, is not echoed."},
+	{"push", "push(array, value)", "Appends value to array, changing it, and returns the array."},
+	{"puts", "puts(values...)", "Prints the values separated by spaces, followed by a newline."},
+	{"raise", "raise(message)", "Stops the program with a runtime error holding message, as a failed operation does."},
+	{"removeAt", "removeAt(array, index)", "Removes the element at index from array, changing it, and returns the element."},
+	{"rest", "rest(array)", "Returns a new array holding all but the first element of array, or null if it is empty."},
+	{"setenv", "setenv(name, value)", "Sets the environment variable name to value."},
+	{"splice", "splice(array, index, count, values...)", "Removes count elements from array, starting at index, and puts the values in their place, changing array. Returns the removed elements as a new array."},
+	{"str", "str(number)", "Converts an integer or a float to a string."},
+	{"type", "type(value)", "Returns the type of value as a string, such as \"INTEGER\" or \"ARRAY\"."},
+}
+
+// namespace documents a library namespace and its functions, whose names
+// and signatures are given without the namespace.
+type namespace struct {
+	Name        string
+	Description string
+	Members     []Entry
+}
+
+var namespaces = []namespace{
+	{"Array", "Functions on arrays. Unlike the push and pop builtins they return new arrays rather than changing the ones given.", []Entry{
+		{"contains", "contains(array, value)", "Reports whether array holds an element equal to value."},
+		{"indexOf", "indexOf(array, value)", "Returns the index of the first element of array equal to value, or -1."},
+		{"join", "join(array, separator)", "Joins the elements of array, as they are printed, with separator between them."},
+		{"len", "len(array)", "Returns the number of elements in array."},
+		{"pop", "pop(array)", "Returns a new array without the last element of array."},
+		{"push", "push(array, value)", "Returns a new array with value appended to array."},
+		{"shift", "shift(array)", "Returns a new array without the first element of array."},
+		{"slice", "slice(array, start, end)", "Returns the elements of array from index start up to, but not including, end. Indexes out of range are clamped."},
+		{"unshift", "unshift(array, value)", "Returns a new array with value put in front of the elements of array."},
+		{"withCapacity", "withCapacity(n)", "Returns an empty array with room for n elements, so pushing them does not reallocate it."},
+	}},
+	{"FFI", "Functions calling C functions in shared libraries. They need an interpreter built with -tags ffi and cgo, and are not available in sandbox mode. The types of parameters and results are void, int8, int16, int32, int64, uint8, uint16, uint32, uint64, int, long, size_t, float, double, string and pointer; string is a char * and pointer any other pointer, passed as an integer.", []Entry{
+		{"open", "open(path)", "Loads the shared library at path, returning a hash with its path and two functions: declare(name, result, params) returns a function calling the C function name, given the type of its result and an array of the types of its parameters, and close() unloads the library."},
+	}},
+	{"File", "Functions creating temporary files and directories. They are not available in sandbox mode.", []Entry{
+		{"tempDir", "tempDir(pattern?)", "Creates a uniquely named directory that is removed with its contents when the interpreter exits or its session is closed, and returns its path. A '*' in pattern is replaced by the random part of the name."},
+		{"tempFile", "tempFile(pattern?)", "Creates an empty, uniquely named file that is removed when the interpreter exits or its session is closed, and returns its path. A '*' in pattern is replaced by the random part of the name."},
+	}},
+	{"Fn", "Functions that combine and transform functions.", []Entry{
+		{"compose", "compose(fns...)", "Returns the composition of the functions, so compose(f, g, h)(x) is f(g(h(x)))."},
+		{"curry", "curry(fn, arity?)", "Returns a function that collects arguments over several calls until fn's number of parameters is reached, then calls fn. Builtins need their arity given."},
+		{"memoize", "memoize(fn)", "Returns a function that caches the results of fn by its arguments. Calls with arguments that cannot be hash keys are not cached."},
+		{"partial", "partial(fn, args...)", "Returns fn with its first arguments bound, so partial(f, a, b)(c) is f(a, b, c)."},
+	}},
+	{"Glob", "Functions matching paths against glob patterns. Patterns use '/' separated segments matched with '*', '?' and '[a-z]', plus '**', which matches any number of directories. Files are not available in sandbox mode.", []Entry{
+		{"files", "files(pattern)", "Returns the paths of the files matching pattern, sorted."},
+		{"match", "match(pattern, path)", "Reports whether path matches pattern."},
+	}},
+	{"Math", "Mathematical functions on floats.", []Entry{
+		{"abs", "abs(x)", "Returns the absolute value of x."},
+		{"acos", "acos(x)", "Returns the arccosine of x, in radians."},
+		{"asin", "asin(x)", "Returns the arcsine of x, in radians."},
+		{"atan", "atan(x)", "Returns the arctangent of x, in radians."},
+		{"ceil", "ceil(x)", "Returns the least integer value greater than or equal to x."},
+		{"copysign", "copysign(x, y)", "Returns a value with the magnitude of x and the sign of y."},
+		{"cos", "cos(x)", "Returns the cosine of x radians."},
+		{"dim", "dim(x, y)", "Returns x - y if it is positive, and 0 otherwise."},
+		{"exp", "exp(x)", "Returns e to the power of x."},
+		{"floor", "floor(x)", "Returns the greatest integer value less than or equal to x."},
+		{"gamma", "gamma(x)", "Returns the Gamma function of x."},
+		{"hypot", "hypot(x, y)", "Returns sqrt(x*x + y*y), avoiding overflow."},
+		{"log", "log(x)", "Returns the natural logarithm of x."},
+		{"max", "max(x, y)", "Returns the larger of x and y."},
+		{"min", "min(x, y)", "Returns the smaller of x and y."},
+		{"mod", "mod(x, y)", "Returns the remainder of x / y, with the sign of x."},
+		{"pow", "pow(x, y)", "Returns x to the power of y."},
+		{"remainder", "remainder(x, y)", "Returns the IEEE 754 remainder of x / y, which rounds the quotient to the nearest integer."},
+		{"round", "round(x)", "Returns x rounded to the nearest integer, rounding half away from zero."},
+		{"sin", "sin(x)", "Returns the sine of x radians."},
+		{"sqrt", "sqrt(x)", "Returns the square root of x."},
+		{"tan", "tan(x)", "Returns the tangent of x radians."},
+		{"trunc", "trunc(x)", "Returns the integer part of x."},
+	}},
+	{"Pack", "Functions converting values to and from bytes, held as arrays of integers from 0 to 255. Formats follow Python's struct module: an optional byte order, '<' little endian or '>' big endian, followed by codes such as b, h, i and q for signed integers of 1, 2, 4 and 8 bytes, their upper case forms for unsigned ones, f and d for floats, ? for booleans, x for a pad byte and s for a string, each optionally preceded by a count.", []Entry{
+		{"pack", "pack(format, values)", "Returns the bytes of the array values laid out as format describes."},
+		{"size", "size(format)", "Returns the number of bytes format describes."},
+		{"unpack", "unpack(format, bytes)", "Returns the array of values that format describes, read from bytes."},
+	}},
+	{"String", "Functions on strings. Positions count characters, not bytes.", []Entry{
+		{"chars", "chars(s)", "Returns the characters of s as an array of strings."},
+		{"compare", "compare(a, b)", "Returns -1, 0 or 1 as a sorts before, equal to or after b."},
+		{"concat", "concat(a, b)", "Returns b appended to a."},
+		{"contains", "contains(s, sub)", "Reports whether sub occurs in s."},
+		{"count", "count(s, sub)", "Returns the number of times sub occurs in s without overlapping."},
+		{"fields", "fields(s)", "Returns the words of s, split around runs of white space."},
+		{"fieldsFunc", "fieldsFunc(s, f)", "Returns the parts of s split around runs of characters for which the Go function f is true."},
+		{"hasPrefix", "hasPrefix(s, prefix)", "Reports whether s begins with prefix."},
+		{"hasSuffix", "hasSuffix(s, suffix)", "Reports whether s ends with suffix."},
+		{"index", "index(s, sub)", "Returns the position of the first sub in s, or -1."},
+		{"join", "join(strings, separator)", "Joins an array of strings with separator between them."},
+		{"lastIndex", "lastIndex(s, sub)", "Returns the position of the last sub in s, or -1."},
+		{"len", "len(s)", "Returns the number of characters in s."},
+		{"lower", "lower(s)", "Returns s in lower case."},
+		{"map", "map(f, s)", "Returns s with each character replaced by the Go function f."},
+		{"repeat", "repeat(s, count)", "Returns count copies of s joined together."},
+		{"replace", "replace(s, old, new, n)", "Returns s with the first n occurrences of old replaced by new, or all of them if n is negative."},
+		{"split", "split(s, separator)", "Returns the parts of s between occurrences of separator."},
+		{"substr", "substr(s, start, length)", "Returns length characters of s from position start. Positions out of range are clamped."},
+		{"template", "template(text, values)", "Replaces each {name} in text with the value of name in the hash values. Unknown names are kept as they are, and {{ and }} stand for braces."},
+		{"toTitle", "toTitle(s)", "Returns s in title case."},
+		{"toTitleSpecial", "toTitleSpecial(s, case)", "Returns s in title case using the Go special casing rules case."},
+		{"trim", "trim(s)", "Returns s without leading and trailing white space."},
+		{"trimFunc", "trimFunc(s, f)", "Returns s without the leading and trailing characters for which the Go function f is true."},
+		{"trimLeft", "trimLeft(s, cutset)", "Returns s without the leading characters that are in cutset."},
+		{"trimPrefix", "trimPrefix(s, prefix)", "Returns s without prefix, if it begins with it."},
+		{"trimRight", "trimRight(s, cutset)", "Returns s without the trailing characters that are in cutset."},
+		{"trimSpace", "trimSpace(s)", "Returns s without leading and trailing white space."},
+		{"trimSuffix", "trimSuffix(s, suffix)", "Returns s without suffix, if it ends with it."},
+		{"upper", "upper(s)", "Returns s in upper case."},
+	}},
+	{"Term", "Functions controlling the terminal with ANSI escape codes.", []Entry{
+		{"clear", "clear()", "Clears the screen and moves the cursor to the top left."},
+		{"clearLine", "clearLine()", "Clears the line the cursor is on and moves it to the start."},
+		{"color", "color(text, styles...)", "Returns text wrapped in the ANSI codes of styles such as \"red\", \"bgBlue\" or \"bold\"."},
+		{"down", "down(n)", "Moves the cursor n lines down."},
+		{"hideCursor", "hideCursor()", "Hides the cursor."},
+		{"isTerminal", "isTerminal()", "Reports whether standard output is a terminal."},
+		{"left", "left(n)", "Moves the cursor n columns left."},
+		{"moveTo", "moveTo(row, col)", "Moves the cursor to row and col, counting from 1."},
+		{"readKey", "readKey()", "Reads a single key press without waiting for enter. Arrow and control keys are returned by name, such as \"up\", \"enter\" or \"escape\"."},
+		{"right", "right(n)", "Moves the cursor n columns right."},
+		{"showCursor", "showCursor()", "Shows the cursor again."},
+		{"size", "size()", "Returns the [columns, rows] of the terminal standard output is attached to."},
+		{"up", "up(n)", "Moves the cursor n lines up."},
+	}},
+	{"Time", "Functions on times and durations. Times are RFC 3339 strings with a UTC offset, such as \"2024-06-07T15:04:05.123+08:00\", and durations are integer milliseconds.", []Entry{
+		{"add", "add(t, ms)", "Returns t plus ms milliseconds."},
+		{"addDate", "addDate(t, years, months, days)", "Returns t plus the given years, months and days, normalizing overflow, so Jan 31 plus one month is in March."},
+		{"diff", "diff(a, b)", "Returns a - b in milliseconds."},
+		{"duration", "duration(text)", "Converts a Go duration string such as \"1h30m\" to milliseconds."},
+		{"format", "format(t, layout)", "Formats t with a Go reference-time layout such as \"2006-01-02\"."},
+		{"formatDuration", "formatDuration(ms)", "Formats ms milliseconds as a Go duration string such as \"1h30m0s\"."},
+		{"fromUnix", "fromUnix(seconds, zone?)", "Returns the time seconds after the Unix epoch, in zone or the local zone."},
+		{"inZone", "inZone(t, zone)", "Converts t to an IANA zone such as \"Asia/Shanghai\" or \"UTC\"."},
+		{"now", "now(zone?)", "Returns the current time, in zone or the local zone."},
+		{"parse", "parse(text, layout?)", "Normalizes a time string. Without a layout RFC 3339, \"2006-01-02 15:04:05\" and \"2006-01-02\" are accepted."},
+		{"sub", "sub(t, ms)", "Returns t minus ms milliseconds."},
+		{"unix", "unix(t)", "Returns t as seconds since the Unix epoch."},
+	}},
+	{"Watch", "Functions watching the file system for changes. They are not available in sandbox mode.", []Entry{
+		{"path", "path(path, callback, intervalMs?)", "Polls a file or directory every intervalMs, 500 by default, and calls callback with a hash {\"type\": \"create\", \"modify\" or \"delete\", \"path\": ...} for each change. It returns when callback returns false, and fails when the program is interrupted or times out."},
+	}},
+}
@@ -0,0 +1,214 @@
+// Package doc holds documentation for the builtin functions and library
+// namespaces, and extracts it from the doc comments of 1y modules.
+package doc
+
+import (
+	"1ylang/ast"
+	"1ylang/lexer"
+	"1ylang/parser"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Entry documents a builtin function, a library namespace such as String, a
+// function in one such as String.upper, or a member of a module.
+type Entry struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature,omitempty"` // "" for namespaces and modules
+	Description string `json:"description"`
+}
+
+// Summary returns the first sentence of the description.
+func (e Entry) Summary() string {
+	text := strings.Join(strings.Fields(e.Description), " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		return text[:i+1]
+	}
+	return text
+}
+
+var registry = map[string]Entry{}
+
+func init() {
+	for _, e := range builtinEntries {
+		registry[e.Name] = e
+	}
+	for _, ns := range namespaces {
+		registry[ns.Name] = Entry{Name: ns.Name, Description: ns.Description}
+		for _, e := range ns.Members {
+			e.Name = ns.Name + "." + e.Name
+			e.Signature = ns.Name + "." + e.Signature
+			registry[e.Name] = e
+		}
+	}
+}
+
+// Lookup returns the entry for name, such as "len", "String" or
+// "String.upper".
+func Lookup(name string) (Entry, bool) {
+	e, ok := registry[name]
+	return e, ok
+}
+
+// Members returns the entries of the functions in namespace, sorted by name.
+func Members(namespace string) []Entry {
+	var members []Entry
+	for name, e := range registry {
+		if strings.HasPrefix(name, namespace+".") {
+			members = append(members, e)
+		}
+	}
+	sortEntries(members)
+	return members
+}
+
+// All returns every entry, sorted by name.
+func All() []Entry {
+	entries := make([]Entry, 0, len(registry))
+	for _, e := range registry {
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// Builtins returns the entries of the builtin functions, sorted by name.
+func Builtins() []Entry {
+	entries := make([]Entry, 0, len(builtinEntries))
+	for _, e := range builtinEntries {
+		entries = append(entries, registry[e.Name])
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// Namespaces returns the entries of the library namespaces, sorted by name.
+func Namespaces() []Entry {
+	entries := make([]Entry, 0, len(namespaces))
+	for _, ns := range namespaces {
+		entries = append(entries, registry[ns.Name])
+	}
+	sortEntries(entries)
+	return entries
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+}
+
+// Module returns the documentation of the module with source src: an entry
+// named module for the comment at the top of the file, if it is followed by
+// a blank line, and one for each top-level let or const that has a comment
+// on the lines right above it. Members are named module.name.
+func Module(module, src string) ([]Entry, error) {
+	l := lexer.New(src)
+	l.RecordComments()
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", module, p.Errors()[0])
+	}
+
+	// Comments on lines of their own are collected by the line they end
+	// on, so the ones above a statement are found from its line
+	comments := map[int]lexer.Comment{}
+	for _, c := range l.Comments() {
+		lineStart := strings.LastIndexByte(src[:c.Start], '\n') + 1
+		if strings.TrimSpace(src[lineStart:c.Start]) == "" {
+			comments[endLine(c)] = c
+		}
+	}
+
+	var entries []Entry
+	if text, end := fileComment(l.Comments()); text != "" && blankLine(src, end+1) {
+		entries = append(entries, Entry{Name: module, Description: text})
+	}
+
+	for _, stmt := range program.Statements {
+		name, value, line := declaration(stmt)
+		if name == "" {
+			continue
+		}
+		text := commentAbove(comments, line)
+		if text == "" {
+			continue
+		}
+		signature := name
+		if fn, ok := value.(*ast.FunctionLiteral); ok {
+			params := make([]string, len(fn.Parameters))
+			for i, param := range fn.Parameters {
+				params[i] = param.Value
+			}
+			signature = name + "(" + strings.Join(params, ", ") + ")"
+		}
+		entries = append(entries, Entry{Name: module + "." + name, Signature: module + "." + signature, Description: text})
+	}
+	return entries, nil
+}
+
+// fileComment returns the text of the comments on the lines from the first
+// line of the file, and the line they end on.
+func fileComment(comments []lexer.Comment) (string, int) {
+	var texts []string
+	line := 0
+	for _, c := range comments {
+		if c.Line != line+1 {
+			break
+		}
+		texts = append(texts, commentText(c))
+		line = endLine(c)
+	}
+	return strings.Join(texts, "\n"), line
+}
+
+// commentAbove returns the text of the comments on the lines right above
+// line, or "" if there are none.
+func commentAbove(comments map[int]lexer.Comment, line int) string {
+	var texts []string
+	for {
+		c, ok := comments[line-1]
+		if !ok {
+			break
+		}
+		texts = append([]string{commentText(c)}, texts...)
+		line = c.Line
+	}
+	return strings.Join(texts, "\n")
+}
+
+func endLine(c lexer.Comment) int {
+	return c.Line + strings.Count(c.Text, "\n")
+}
+
+// blankLine reports whether line of src is blank or past the end of src.
+func blankLine(src string, line int) bool {
+	lines := strings.Split(src, "\n")
+	return line > len(lines) || strings.TrimSpace(lines[line-1]) == ""
+}
+
+// commentText returns the text of c without its delimiters.
+func commentText(c lexer.Comment) string {
+	text := c.Text
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimSpace(strings.TrimPrefix(text, "//"))
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimSpace(line), "* ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// declaration returns the name declared by stmt, its value and the line it
+// starts on, or "" if stmt declares nothing.
+func declaration(stmt ast.Statement) (string, ast.Expression, int) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Name.Value, stmt.Value, stmt.Token.Line
+	case *ast.ConstStatement:
+		return stmt.Name.Value, stmt.Value, stmt.Token.Line
+	}
+	return "", nil, 0
+}
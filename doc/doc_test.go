@@ -0,0 +1,111 @@
+package doc
+
+import (
+	"1ylang/evaluator"
+	"1ylang/lib"
+	"1ylang/object"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestRegistryComplete checks that every builtin and library function is
+// documented, and that nothing documented is missing from them.
+func TestRegistryComplete(t *testing.T) {
+	var documented []string
+	for _, e := range Builtins() {
+		documented = append(documented, e.Name)
+	}
+	if names := evaluator.BuiltinNames(); !reflect.DeepEqual(documented, names) {
+		t.Errorf("documented builtins = %v, want %v", documented, names)
+	}
+
+	env := evaluator.NewEnvironment()
+	for _, register := range []func(*object.Environment){
+		lib.RegisterStringFuncs, lib.RegisterArrayFuncs, lib.RegisterMathFuncs,
+		lib.RegisterPackFuncs, lib.RegisterFunctionalFuncs, lib.RegisterTimeFuncs,
+		lib.RegisterTermFuncs, lib.RegisterWatchFuncs, lib.RegisterGlobFuncs,
+		lib.RegisterFileFuncs, lib.RegisterFFIFuncs,
+	} {
+		register(env)
+	}
+
+	for _, ns := range Namespaces() {
+		val, ok, _ := env.Get(ns.Name)
+		if !ok {
+			t.Errorf("namespace %s is documented but not registered", ns.Name)
+			continue
+		}
+		var names []string
+		for _, pair := range val.(*object.Hash).Pairs {
+			names = append(names, ns.Name+"."+pair.Key.(*object.String).Value)
+		}
+		sort.Strings(names)
+
+		var documented []string
+		for _, e := range Members(ns.Name) {
+			documented = append(documented, e.Name)
+		}
+		if !reflect.DeepEqual(documented, names) {
+			t.Errorf("documented %s functions = %v, want %v", ns.Name, documented, names)
+		}
+	}
+	if len(Namespaces()) != len(env.Store()) {
+		t.Errorf("%d namespaces documented, %d registered", len(Namespaces()), len(env.Store()))
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e, ok := Lookup("String.upper")
+	if !ok {
+		t.Fatal("String.upper not found")
+	}
+	if e.Signature != "String.upper(s)" || e.Summary() != "Returns s in upper case." {
+		t.Errorf("String.upper = %+v", e)
+	}
+	if _, ok := Lookup("String.nope"); ok {
+		t.Error("String.nope found")
+	}
+	if e, _ := Lookup("int"); e.Summary() != "Converts a float or a string to an integer, truncating floats." {
+		t.Errorf("int summary = %q", e.Summary())
+	}
+}
+
+func TestModule(t *testing.T) {
+	src := `// Helpers for geometry.
+
+// area returns the area of a
+// w by h rectangle.
+let area = fn(w, h) { w * h };
+
+let undocumented = 1;
+let x = 2; // not a doc comment
+let y = 3;
+
+/* The ratio of a circle's
+ * circumference to its diameter. */
+const PI = 3.14159;
+`
+	entries, err := Module("geo", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Name: "geo", Description: "Helpers for geometry."},
+		{Name: "geo.area", Signature: "geo.area(w, h)", Description: "area returns the area of a\nw by h rectangle."},
+		{Name: "geo.PI", Signature: "geo.PI", Description: "The ratio of a circle's\ncircumference to its diameter."},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Module() =\n%#v\nwant\n%#v", entries, expected)
+	}
+
+	// A comment right above the first declaration documents it, not the module
+	entries, _ = Module("m", "// f does nothing.\nlet f = fn() {};\n")
+	if len(entries) != 1 || entries[0].Name != "m.f" {
+		t.Errorf("Module() = %#v", entries)
+	}
+
+	if _, err := Module("bad", "let = ;"); err == nil {
+		t.Error("expected a parse error")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"1ylang/compiled"
+	"1ylang/doc"
+	"1ylang/evaluator"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// showDoc runs `1y doc [-json] [names]`, printing the documentation of
+// builtins such as len, library namespaces such as String or their functions
+// such as String.upper, and modules or their members, taken from the doc
+// comments in the module's source. Without names the builtins and namespaces
+// are listed.
+func showDoc(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the entries as JSON")
+	fs.Parse(args)
+
+	var entries []doc.Entry
+	if fs.NArg() == 0 {
+		if *asJSON {
+			return writeJSON(out, doc.All())
+		}
+		fmt.Fprintln(out, "Builtins:")
+		for _, e := range doc.Builtins() {
+			fmt.Fprintf(out, "  %-40s %s\n", e.Signature, e.Summary())
+		}
+		fmt.Fprintln(out, "\nNamespaces:")
+		for _, e := range doc.Namespaces() {
+			fmt.Fprintf(out, "  %-40s %s\n", e.Name, e.Summary())
+		}
+		return nil
+	}
+
+	// The functions listed under a namespace or module are kept brief
+	var brief []bool
+	for _, name := range fs.Args() {
+		found, err := lookupDoc(name)
+		if err != nil {
+			return err
+		}
+		for i, e := range found {
+			entries = append(entries, e)
+			brief = append(brief, i > 0 && found[0].Signature == "")
+		}
+	}
+	if *asJSON {
+		return writeJSON(out, entries)
+	}
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		writeEntry(out, e, brief[i])
+	}
+	return nil
+}
+
+// lookupDoc returns the entries documenting name: a builtin or library
+// function, a namespace and its functions, or a module or its member, named
+// by the module's path as it would be imported.
+func lookupDoc(name string) ([]doc.Entry, error) {
+	if e, ok := doc.Lookup(name); ok {
+		return append([]doc.Entry{e}, doc.Members(name)...), nil
+	}
+
+	if path := evaluator.FindModule(name); path != "" {
+		return moduleDoc(path, "")
+	}
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		if path := evaluator.FindModule(name[:i]); path != "" {
+			return moduleDoc(path, name[i+1:])
+		}
+	}
+	return nil, fmt.Errorf("no documentation for %s", name)
+}
+
+// moduleDoc returns the documentation of the module at path, or of its
+// member if that is not "".
+func moduleDoc(path, member string) ([]doc.Entry, error) {
+	if strings.HasSuffix(path, compiled.Extension) {
+		return nil, fmt.Errorf("%s is compiled, so its doc comments are gone", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file %s: %v", path, err)
+	}
+	module := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	entries, err := doc.Module(module, string(content))
+	if err != nil {
+		return nil, err
+	}
+	if member == "" {
+		return entries, nil
+	}
+	for _, e := range entries {
+		if e.Name == module+"."+member {
+			return []doc.Entry{e}, nil
+		}
+	}
+	return nil, fmt.Errorf("no documentation for %s in %s", member, path)
+}
+
+// writeEntry prints e's signature, or its name, followed by its description
+// indented, or only the first sentence of it if brief.
+func writeEntry(out io.Writer, e doc.Entry, brief bool) {
+	title := e.Signature
+	if title == "" {
+		title = e.Name
+	}
+	text := e.Description
+	if brief {
+		text = e.Summary()
+	}
+	fmt.Fprintf(out, "%s\n    %s\n", title, strings.ReplaceAll(text, "\n", "\n    "))
+}
+
+func writeJSON(out io.Writer, entries []doc.Entry) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
@@ -1,11 +1,19 @@
 package evaluator
 
 import (
+	"1ylang/lexer"
 	"1ylang/object"
+	"1ylang/parser"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/term"
 )
 
 // newBuiltin is a helper function to create a new builtin function object.
@@ -13,6 +21,53 @@ func newBuiltin(fn func(args ...object.Object) object.Object) *object.Builtin {
 	return &object.Builtin{Fn: fn}
 }
 
+// Stdout, Stderr and Stdin are the streams the builtins write output to and
+// read input from, unless the interpreter they run in has streams of its
+// own. They can be replaced where there is no terminal, such as in a
+// browser, or to capture what a program prints.
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+	Stdin  io.Reader = os.Stdin
+)
+
+// exitHooks run before the interpreter exits, e.g. to remove temporary files.
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// AtExit registers fn to run when the interpreter exits.
+func AtExit(fn func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, fn)
+}
+
+// RunExitHooks runs the registered exit hooks, most recent first.
+func RunExitHooks() {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+	runHooks(hooks)
+}
+
+func runHooks(hooks []func()) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// SetArgs sets the arguments returned by the `args` builtin and held by the
+// ARGS constant in environments made by NewEnvironment. Environments created
+// earlier keep the previous ARGS.
+func SetArgs(args []string) {
+	options := defaultInterpreter.options
+	options.Args = args
+	reconfigureDefault(options)
+}
+
 var builtins = map[string]*object.Builtin{
 	"exit": newBuiltin(func(args ...object.Object) object.Object {
 		// allow user to specify an exit code
@@ -26,6 +81,7 @@ var builtins = map[string]*object.Builtin{
 			}
 			code = int(args[0].(*object.Integer).Value.Int64())
 		}
+		RunExitHooks()
 		os.Exit(code)
 		return NULL
 	}),
@@ -35,23 +91,13 @@ var builtins = map[string]*object.Builtin{
 		}
 		switch arg := args[0].(type) {
 		case *object.String:
-			return &object.Integer{Value: big.NewInt(int64(len(arg.Value)))}
+			return int64Object(int64(utf8.RuneCountInString(arg.Value)))
 		case *object.Array:
-			return &object.Integer{Value: big.NewInt(int64(len(arg.Elements)))}
+			return int64Object(int64(len(arg.Elements)))
 		default:
 			return newError("argument to `len` not supported, got %s", args[0].Type())
 		}
 	}),
-	"puts": newBuiltin(func(args ...object.Object) object.Object {
-		for index, arg := range args {
-			if index > 0 {
-				fmt.Print(" ")
-			}
-			fmt.Print(arg.Inspect())
-		}
-		fmt.Println()
-		return NULL
-	}),
 	"first": newBuiltin(func(args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -94,9 +140,7 @@ var builtins = map[string]*object.Builtin{
 		arr := args[0].(*object.Array)
 		length := len(arr.Elements)
 		if length > 0 {
-			newElements := make([]object.Object, length-1)
-			copy(newElements, arr.Elements[1:length])
-			return &object.Array{Elements: newElements}
+			return arr.Slice(1, length)
 		}
 
 		return NULL
@@ -112,7 +156,7 @@ var builtins = map[string]*object.Builtin{
 		arr := args[0].(*object.Array)
 
 		// Modify the original array by appending the new element
-		arr.Elements = append(arr.Elements, args[1])
+		arr.Push(args[1])
 
 		// Return the modified array
 		return arr
@@ -135,6 +179,66 @@ var builtins = map[string]*object.Builtin{
 
 		return NULL
 	}),
+	"insert": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=3", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `insert` must be ARRAY, got %s", args[0].Type())
+		}
+		i, err := arrayPosition("insert", args[1], len(arr.Elements))
+		if err != nil {
+			return err
+		}
+
+		arr.Splice(i, 0, args[2])
+		return arr
+	}),
+	"removeAt": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `removeAt` must be ARRAY, got %s", args[0].Type())
+		}
+		i, err := arrayPosition("removeAt", args[1], len(arr.Elements)-1)
+		if err != nil {
+			return err
+		}
+
+		return arr.Splice(i, 1)[0]
+	}),
+	"splice": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) < 3 {
+			return newError("wrong number of arguments. got=%d, want=3+", len(args))
+		}
+		arr, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument to `splice` must be ARRAY, got %s", args[0].Type())
+		}
+		i, err := arrayPosition("splice", args[1], len(arr.Elements))
+		if err != nil {
+			return err
+		}
+		count, ok := args[2].(*object.Integer)
+		if !ok {
+			return newError("count to `splice` must be INTEGER, got %s", args[2].Type())
+		}
+		if count.Value.Sign() < 0 {
+			return newError("count to `splice` must not be negative, got %s", count.Value.String())
+		}
+
+		// Like a slice, the count stops at the end of the array
+		n := len(arr.Elements) - i
+		if count.Value.IsInt64() && count.Value.Int64() < int64(n) {
+			n = int(count.Value.Int64())
+		}
+
+		removed := arr.Splice(i, n, args[3:]...)
+		return &object.Array{Elements: removed}
+	}),
 	"concat": newBuiltin(func(args ...object.Object) object.Object {
 		if len(args) < 2 {
 			return newError("wrong number of arguments. got=%d, want=2+", len(args))
@@ -146,64 +250,57 @@ var builtins = map[string]*object.Builtin{
 			}
 		}
 
-		newElements := []object.Object{}
+		size := 0
+		for _, arg := range args {
+			size += len(arg.(*object.Array).Elements)
+		}
+
+		newElements := make([]object.Object, 0, size)
 		for _, arg := range args {
 			newElements = append(newElements, arg.(*object.Array).Elements...)
 		}
 
 		return &object.Array{Elements: newElements}
 	}),
-	"input": newBuiltin(func(args ...object.Object) object.Object {
-		if len(args) == 1 {
-			prompt := args[0].Inspect()
-			// Define a map of escape sequences to their actual characters
-			escapeSequences := map[string]string{
-				"\\n":  "\n",
-				"\\t":  "\t",
-				"\\\\": "\\",
-				"\\\"": "\"",
-				"\\'":  "'",
-				"\\r":  "\r",
-				"\\b":  "\b",
-				"\\f":  "\f",
-				"\\v":  "\v",
-			}
-
-			// Replace escape sequences with actual characters
-			for seq, char := range escapeSequences {
-				prompt = strings.ReplaceAll(prompt, seq, char)
-			}
-
-			// Print the prompt
-			fmt.Print(prompt)
-		} else if len(args) > 1 {
-			return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	"int": newBuiltin(func(args ...object.Object) object.Object {
+		// int(value, radix) returns null when a string cannot be parsed, so
+		// interactive input can be validated without aborting the program
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
 		}
 
-		var input string
-		fmt.Scanln(&input)
-		return &object.String{Value: input}
-	}),
-	"int": newBuiltin(func(args ...object.Object) object.Object {
-		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		radix := 10
+		if len(args) == 2 {
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("radix requires a STRING argument, got %s", args[0].Type())
+			}
+			radixObj, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("radix to `int` must be INTEGER, got %s", args[1].Type())
+			}
+			radix = int(radixObj.Value.Int64())
+			if radix != 0 && (radix < 2 || radix > 36) {
+				return newError("radix must be 0 or between 2 and 36, got %d", radix)
+			}
 		}
 
 		switch arg := args[0].(type) {
 		case *object.String:
-			value, ok := new(big.Int).SetString(arg.Value, 10)
+			value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), radix)
 			if !ok {
-				return newError("cannot convert %s to int", arg.Value)
+				return NULL
 			}
-			return &object.Integer{Value: value}
+			return integerObject(value)
+		case *object.Integer:
+			return arg
 		case *object.Float:
-			value, ok := new(big.Int).SetString(arg.Value.String(), 10)
-			if !ok {
-				return newError("cannot convert %s to int", arg.Value.String())
+			if arg.Value.IsInf() {
+				return newError("cannot convert %s to INTEGER", arg.Inspect())
 			}
-			return &object.Integer{Value: value}
+			value, _ := arg.Value.Int(nil)
+			return integerObject(value)
 		default:
-			return newError("argument to `int` must be STRING or FLOAT, got %s", args[0].Type())
+			return newError("argument to `int` must be STRING, INTEGER or FLOAT, got %s", args[0].Type())
 		}
 	}),
 	"float": newBuiltin(func(args ...object.Object) object.Object {
@@ -213,15 +310,17 @@ var builtins = map[string]*object.Builtin{
 
 		switch arg := args[0].(type) {
 		case *object.String:
-			value, ok := new(big.Float).SetString(arg.Value)
+			value, ok := new(big.Float).SetString(strings.TrimSpace(arg.Value))
 			if !ok {
-				return newError("cannot convert %s to float", arg.Value)
+				return NULL
 			}
 			return &object.Float{Value: value}
 		case *object.Integer:
 			return &object.Float{Value: new(big.Float).SetInt(arg.Value)}
+		case *object.Float:
+			return arg
 		default:
-			return newError("argument to `float` must be STRING or INTEGER, got %s", args[0].Type())
+			return newError("argument to `float` must be STRING, INTEGER or FLOAT, got %s", args[0].Type())
 		}
 	}),
 	"str": newBuiltin(func(args ...object.Object) object.Object {
@@ -238,6 +337,105 @@ var builtins = map[string]*object.Builtin{
 			return newError("argument to `str` must be INTEGER or FLOAT, got %s", args[0].Type())
 		}
 	}),
+	"format": newBuiltin(func(args ...object.Object) object.Object {
+		// format(number, precision, thousandsSep) behaves like toFixed when a
+		// precision is given and groups the integer part when a separator is given
+		if len(args) < 1 || len(args) > 3 {
+			return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+		}
+
+		precision := -1
+		if len(args) > 1 {
+			prec, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `format` must be INTEGER, got %s", args[1].Type())
+			}
+			if prec.Value.Sign() < 0 {
+				return newError("precision must not be negative, got %s", prec.Value.String())
+			}
+			if !prec.Value.IsInt64() || prec.Value.Int64() > maxFormatPrecision {
+				return newError("precision must be at most %d, got %s", maxFormatPrecision, prec.Value.String())
+			}
+			precision = int(prec.Value.Int64())
+		}
+
+		sep := ""
+		if len(args) > 2 {
+			sepObj, ok := args[2].(*object.String)
+			if !ok {
+				return newError("argument 3 to `format` must be STRING, got %s", args[2].Type())
+			}
+			sep = sepObj.Value
+		}
+
+		var text string
+		switch arg := args[0].(type) {
+		case *object.Integer:
+			if precision > 0 {
+				text = new(big.Float).SetInt(arg.Value).Text('f', precision)
+			} else {
+				text = arg.Value.String()
+			}
+		case *object.Float:
+			text = arg.Value.Text('f', precision)
+			// +Inf and -Inf have no digits to group
+			if arg.Value.IsInf() {
+				sep = ""
+			}
+		default:
+			return newError("argument to `format` must be INTEGER or FLOAT, got %s", args[0].Type())
+		}
+
+		return &object.String{Value: groupThousands(text, sep)}
+	}),
+	"getenv": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+		if args[0].Type() != object.STRING_OBJ {
+			return newError("argument to `getenv` must be STRING, got %s", args[0].Type())
+		}
+
+		// fall back to the default, or null, when the variable is not set
+		if value, ok := os.LookupEnv(args[0].(*object.String).Value); ok {
+			return &object.String{Value: value}
+		}
+		if len(args) == 2 {
+			return args[1]
+		}
+		return NULL
+	}),
+	"setenv": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		if args[0].Type() != object.STRING_OBJ {
+			return newError("argument to `setenv` must be STRING, got %s", args[0].Type())
+		}
+
+		name := args[0].(*object.String).Value
+		if err := os.Setenv(name, args[1].Inspect()); err != nil {
+			return newError("cannot set environment variable %s: %s", name, err)
+		}
+		return NULL
+	}),
+	"parse": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		source, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `parse` must be STRING, got %s", args[0].Type())
+		}
+
+		p := parser.New(lexer.New(source.Value))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return newError("parsing failed: %s", strings.Join(p.Errors(), "\n"))
+		}
+
+		return astToObject(program)
+	}),
 	"type": newBuiltin(func(args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -245,4 +443,357 @@ var builtins = map[string]*object.Builtin{
 
 		return &object.String{Value: string(args[0].Type())}
 	}),
+	// raise and error fail the program with a message of its own, which
+	// stops it as any runtime error does
+	"raise": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		message, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `raise` must be STRING, got %s", args[0].Type())
+		}
+
+		return &object.Error{Message: message.Value}
+	}),
+	"error": newBuiltin(func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		kind, ok := args[0].(*object.String)
+		if !ok {
+			return newError("kind given to `error` must be STRING, got %s", args[0].Type())
+		}
+		// Embedders rely on these kinds meaning the interpreter stopped the
+		// program, so programs cannot fake them
+		switch kind.Value {
+		case ErrorSyntax, ErrorLimit, ErrorInterrupt:
+			return newError("kind %q given to `error` is reserved for the interpreter", kind.Value)
+		}
+		message, ok := args[1].(*object.String)
+		if !ok {
+			return newError("message given to `error` must be STRING, got %s", args[1].Type())
+		}
+
+		return &object.Error{Kind: kind.Value, Message: message.Value}
+	}),
+}
+
+// streamBuiltins are builtins that read or write the streams of the
+// interpreter they are called in. Each interpreter binds them to itself.
+var streamBuiltins = map[string]func(in *Interpreter, args ...object.Object) object.Object{
+	"puts": func(in *Interpreter, args ...object.Object) object.Object {
+		for index, arg := range args {
+			if index > 0 {
+				fmt.Fprint(in.Stdout(), " ")
+			}
+			fmt.Fprint(in.Stdout(), arg.Inspect())
+		}
+		fmt.Fprintln(in.Stdout())
+		return NULL
+	},
+	"print": func(in *Interpreter, args ...object.Object) object.Object {
+		writeArgs(in.Stdout(), args)
+		return NULL
+	},
+	"eprint": func(in *Interpreter, args ...object.Object) object.Object {
+		writeArgs(in.Stderr(), args)
+		return NULL
+	},
+	"pprint": func(in *Interpreter, args ...object.Object) object.Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+
+		indent := 2
+		if len(args) == 2 {
+			indentObj, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument 2 to `pprint` must be INTEGER, got %s", args[1].Type())
+			}
+			if indentObj.Value.Sign() < 0 || !indentObj.Value.IsInt64() || indentObj.Value.Int64() > maxPrettyIndent {
+				return newError("indent must be between 0 and %d, got %s", maxPrettyIndent, indentObj.Value)
+			}
+			indent = int(indentObj.Value.Int64())
+		}
+
+		fmt.Fprintln(in.Stdout(), object.Pretty(args[0], indent))
+		return NULL
+	},
+	"input": func(in *Interpreter, args ...object.Object) object.Object {
+		if len(args) == 1 {
+			prompt := args[0].Inspect()
+			// Define a map of escape sequences to their actual characters
+			escapeSequences := map[string]string{
+				"\\n":  "\n",
+				"\\t":  "\t",
+				"\\\\": "\\",
+				"\\\"": "\"",
+				"\\'":  "'",
+				"\\r":  "\r",
+				"\\b":  "\b",
+				"\\f":  "\f",
+				"\\v":  "\v",
+			}
+
+			// Replace escape sequences with actual characters
+			for seq, char := range escapeSequences {
+				prompt = strings.ReplaceAll(prompt, seq, char)
+			}
+
+			// Print the prompt
+			fmt.Fprint(in.Stdout(), prompt)
+		} else if len(args) > 1 {
+			return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+		}
+
+		var input string
+		fmt.Fscanln(in.Stdin(), &input)
+		return &object.String{Value: input}
+	},
+	"prompt": func(in *Interpreter, args ...object.Object) object.Object {
+		// prompt(message, default, hidden) reads a line with editing support on
+		// a terminal; hidden input is not echoed, for passwords
+		if len(args) < 1 || len(args) > 3 {
+			return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+		}
+
+		message, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `prompt` must be STRING, got %s", args[0].Type())
+		}
+		var fallback object.Object = &object.String{Value: ""}
+		if len(args) > 1 {
+			fallback = args[1]
+		}
+		hidden := len(args) > 2 && isTruthy(args[2])
+
+		line, err := readPromptLine(in, message.Value, hidden)
+		if err == io.EOF {
+			return NULL
+		}
+		if err != nil {
+			return newError("cannot read input: %s", err)
+		}
+		if line == "" {
+			return fallback
+		}
+		return &object.String{Value: line}
+	},
+}
+
+// readPromptLine shows message and reads one line from the input of in. On
+// a terminal the line can be edited with the usual keys; otherwise it is
+// read as is.
+func readPromptLine(in *Interpreter, message string, hidden bool) (string, error) {
+	stdin, ok := in.Stdin().(*os.File)
+	if !ok || !term.IsTerminal(int(stdin.Fd())) {
+		fmt.Fprint(in.Stdout(), message)
+		return readLine(in.Stdin())
+	}
+	fd := int(stdin.Fd())
+
+	if hidden {
+		fmt.Fprint(in.Stdout(), message)
+		line, err := term.ReadPassword(fd)
+		fmt.Fprintln(in.Stdout())
+		return string(line), err
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in.Stdin(), in.Stdout()}, message)
+	return terminal.ReadLine()
+}
+
+// readLine reads up to the next newline one byte at a time, so no input
+// beyond the line is consumed from r.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return string(line), err
+		}
+	}
+}
+
+// writeArgs writes the inspected arguments separated by spaces, without a trailing newline.
+func writeArgs(out io.Writer, args []object.Object) {
+	for index, arg := range args {
+		if index > 0 {
+			io.WriteString(out, " ")
+		}
+		io.WriteString(out, arg.Inspect())
+	}
+}
+
+// envBuiltins are builtins that need the environment they are called from.
+// They are registered in init to avoid an initialization cycle through Eval.
+var envBuiltins map[string]func(env *object.Environment, args ...object.Object) object.Object
+
+// arrayPosition returns index, given to the builtin name, as a position in
+// an array, which must be from 0 up to max.
+func arrayPosition(name string, index object.Object, max int) (int, *object.Error) {
+	idx, ok := index.(*object.Integer)
+	if !ok {
+		return 0, newError("index to `%s` must be INTEGER, got %s", name, index.Type())
+	}
+	if idx.Value.Sign() < 0 || idx.Value.Cmp(big.NewInt(int64(max))) > 0 {
+		return 0, newError("index out of range: %s", idx.Value.String())
+	}
+	return int(idx.Value.Int64()), nil
+}
+
+func init() {
+	envBuiltins = map[string]func(env *object.Environment, args ...object.Object) object.Object{
+		"eval": evalBuiltin,
+		"args": argsBuiltin,
+	}
+
+	// load reaches Eval through loadModule, so it is added here for the same reason
+	builtins["load"] = newBuiltin(loadBuiltin)
+
+	defaultInterpreter = New(Options{})
+}
+
+// BuiltinNames returns the names of the builtin functions, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(streamBuiltins)+len(envBuiltins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range streamBuiltins {
+		names = append(names, name)
+	}
+	for name := range envBuiltins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewEnvironment creates an empty global environment of the default
+// interpreter, in which the builtins are defined.
+func NewEnvironment() *object.Environment {
+	return defaultInterpreter.NewEnvironment()
+}
+
+// argsBuiltin implements args(), returning the arguments of the interpreter
+// it is called in.
+func argsBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return InterpreterOf(env).argsArray()
+}
+
+// loadBuiltin implements load(module), running the module's top-level code
+// now rather than on first use and returning its members.
+func loadBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	module, ok := args[0].(*object.Module)
+	if !ok {
+		return newError("argument to `load` must be MODULE, got %s", args[0].Type())
+	}
+	return loadModule(module)
+}
+
+// evalBuiltin implements eval(source, bindings). Without bindings the source
+// runs in the calling environment; with a hash it runs in a fresh environment
+// holding only those bindings.
+func evalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `eval` must be STRING, got %s", args[0].Type())
+	}
+
+	if len(args) == 2 {
+		bindings, ok := args[1].(*object.Hash)
+		if !ok {
+			return newError("argument 2 to `eval` must be HASH, got %s", args[1].Type())
+		}
+
+		env = InterpreterOf(env).NewEnvironment()
+		for _, pair := range bindings.Pairs {
+			name, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("binding names must be STRING, got %s", pair.Key.Type())
+			}
+			if result := env.NewVar(name.Value, pair.Value); isError(result) {
+				return result
+			}
+		}
+	}
+
+	l := lexer.New(source.Value)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parsing eval input failed: %s", strings.Join(p.Errors(), "\n"))
+	}
+	// Positions in the evaluated code are not positions in the caller's file
+	program.File = "<eval>"
+
+	result := Eval(program, env)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// maxPrettyIndent bounds the spaces pprint indents each level by.
+const maxPrettyIndent = 16
+
+// maxFormatPrecision bounds the digits format writes after the point.
+const maxFormatPrecision = 1000
+
+// groupThousands inserts sep between every three digits of the integer part of
+// a plain decimal number string, leaving the sign and fraction untouched.
+func groupThousands(text, sep string) string {
+	if sep == "" {
+		return text
+	}
+
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+
+	intPart, fracPart := text, ""
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		intPart, fracPart = text[:dot], text[dot:]
+	}
+
+	var out strings.Builder
+	for i, digit := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			out.WriteString(sep)
+		}
+		out.WriteRune(digit)
+	}
+
+	return sign + out.String() + fracPart
 }
@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/lexer"
+	"1ylang/object"
+	"1ylang/parser"
+	"fmt"
+	"strings"
+)
+
+// Kinds of RuntimeError, also held by the Kind of the errors programs fail
+// with, where "" means ErrorRuntime.
+const (
+	ErrorRuntime   = "runtime"   // an operation failed, such as on the wrong type
+	ErrorSyntax    = "syntax"    // the source could not be parsed
+	ErrorLimit     = "limit"     // a resource limit was exceeded
+	ErrorInterrupt = "interrupt" // Interrupt was called or a timeout expired
+)
+
+// RuntimeError is the Go error for a program that failed, so that callers
+// need not inspect the *object.Error it evaluated to.
+type RuntimeError struct {
+	Kind    string // one of the Error kinds above
+	Message string
+	Code    string // ID of the message in the locale catalog, "" if not in it
+
+	// Position the error happened at. Line is 0 if it is unknown, and File
+	// is "" for code not read from a file.
+	File         string
+	Line, Column int
+
+	// Stack holds the calls the error was returned through, innermost first.
+	Stack []object.Frame
+}
+
+// Error returns the message, preceded by the position if it is known.
+func (e *RuntimeError) Error() string {
+	switch {
+	case e.Line > 0 && e.File != "":
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return e.Message
+}
+
+// ErrorOf returns the *RuntimeError for result if it is an error, and nil
+// otherwise.
+func ErrorOf(result object.Object) error {
+	err, ok := result.(*object.Error)
+	if !ok {
+		return nil
+	}
+	kind := err.Kind
+	if kind == "" {
+		kind = ErrorRuntime
+	}
+	return &RuntimeError{
+		Kind:    kind,
+		Message: err.Message,
+		Code:    err.Code,
+		File:    err.File,
+		Line:    err.Line,
+		Column:  err.Column,
+		Stack:   append([]object.Frame(nil), err.Stack...),
+	}
+}
+
+// SyntaxError returns the error for source read from file, if it is not "",
+// that failed to parse with diagnostics. Its message holds them all, one per
+// line, and its position is that of the first.
+func SyntaxError(file string, diagnostics []parser.Diagnostic) *RuntimeError {
+	messages := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		messages[i] = d.Message
+	}
+	err := &RuntimeError{Kind: ErrorSyntax, Message: strings.Join(messages, "\n"), File: file}
+	if len(diagnostics) > 0 {
+		err.Code, err.Line, err.Column = diagnostics[0].Code, diagnostics[0].Line, diagnostics[0].Column
+	}
+	return err
+}
+
+// Exec parses src, read from file if it is not "", and runs it in env, a
+// global environment of the interpreter, or a new one if env is nil. It
+// returns the value the program ends with, or a *RuntimeError if it cannot
+// be parsed, as from SyntaxError, or fails.
+func (in *Interpreter) Exec(env *object.Environment, file, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if diagnostics := p.Diagnostics(); len(diagnostics) != 0 {
+		return nil, SyntaxError(file, diagnostics)
+	}
+	program.File = file
+	return in.Run(env, program)
+}
+
+// Run evaluates program in env, a global environment of the interpreter or a
+// new one if env is nil, returning the value it ends with or a *RuntimeError
+// if it fails.
+func (in *Interpreter) Run(env *object.Environment, program *ast.Program) (object.Object, error) {
+	if env == nil {
+		env = in.NewEnvironment()
+	}
+	result := Eval(program, env)
+	if err := ErrorOf(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,181 +2,137 @@ package evaluator
 
 import (
 	"1ylang/ast"
+	"1ylang/compiled"
 	"1ylang/lexer"
+	"1ylang/locale"
 	"1ylang/object"
 	"1ylang/parser"
-	"fmt"
+	"1ylang/token"
+	"bytes"
 	"math"
 	"math/big"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 )
 
-// Eval evaluates an AST node
+// Eval evaluates an AST node in the interpreter of env.
 func Eval(node ast.Node, env *object.Environment) object.Object {
-	switch node := node.(type) {
-	case *ast.Program:
-		return evalProgram(node, env)
-
-	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
-
-	case *ast.ExpressionStatement:
-		val := Eval(node.Expression, env)
-		return val
-
-	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
-			return val
-		}
-		return &object.ReturnValue{Value: val}
-
-	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
-
-	case *ast.Boolean:
-		return nativeBoolToBooleanObject(node.Value)
-
-	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
-		}
-		return evalPrefixExpression(node.Operator, right)
-
-	case *ast.PostfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		return evalPostfixExpression(node.Operator, left)
-
-	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
-		}
-		return evalInfixExpression(node.Operator, left, right)
-
-	case *ast.IfExpression:
-		return evalIfExpression(node, env)
-
-	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
-			return val
-		}
-		return env.NewVar(node.Name.Value, val)
+	return InterpreterOf(env).Eval(node, env)
+}
 
-	case *ast.ConstStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
-			return val
-		}
-		return env.NewConst(node.Name.Value, val)
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	BREAK = &object.Break{}
+	CNT   = &object.Continue{}
+)
 
-	case *ast.Identifier:
-		return evalIdentifier(node, env)
+// Integers in this range are preallocated and shared, like TRUE and FALSE.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1024
+)
 
-	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+var smallIntegers = func() []*object.Integer {
+	integers := make([]*object.Integer, maxCachedInteger-minCachedInteger+1)
+	for i := range integers {
+		integers[i] = &object.Integer{Value: big.NewInt(int64(i + minCachedInteger))}
+	}
+	return integers
+}()
 
-	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+// integerObject wraps value in an Integer, reusing the shared object for small values.
+func integerObject(value *big.Int) *object.Integer {
+	if value.IsInt64() {
+		if n := value.Int64(); n >= minCachedInteger && n <= maxCachedInteger {
+			return smallIntegers[n-minCachedInteger]
 		}
+	}
+	return &object.Integer{Value: value}
+}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+// integerResult stores the result of op in a pooled Integer, or returns the
+// shared object when the result is small.
+func integerResult(op func(z *big.Int)) *object.Integer {
+	i := object.AcquireInteger()
+	op(i.Value)
+	if i.Value.IsInt64() {
+		if n := i.Value.Int64(); n >= minCachedInteger && n <= maxCachedInteger {
+			object.ReleaseInteger(i)
+			return smallIntegers[n-minCachedInteger]
 		}
+	}
+	return i
+}
 
-		return applyFunction(function, args)
-
-	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
-
-	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
-			return elements[0]
-		}
-		return &object.Array{Elements: elements}
+// floatResult stores the result of op in a pooled Float.
+func floatResult(op func(z *big.Float)) *object.Float {
+	f := object.AcquireFloat()
+	op(f.Value)
+	return f
+}
 
-	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		index := Eval(node.Index, env)
-		if isError(index) {
-			return index
-		}
-		return evalIndexExpression(left, index)
+// releaseOperands hands the operands of node back to the pool once node has
+// evaluated to result, unless result is one of them. && and || return one
+// of their operands, so theirs are kept.
+func releaseOperands(node *ast.InfixExpression, left, right, result object.Object) {
+	if node.Operator == "&&" || node.Operator == "||" {
+		return
+	}
+	if left != result {
+		releaseTemporary(node.Left, left)
+	}
+	if right != result {
+		releaseTemporary(node.Right, right)
+	}
+}
 
-	case *ast.MultiDimensionalIndex:
-		indices := evalExpressions(node.Indices, env)
-		if len(indices) == 1 && isError(indices[0]) {
-			return indices[0]
+// releaseTemporary hands the value of an arithmetic subexpression back to the
+// pool once the enclosing expression has produced its result. Only values
+// computed by an operator are released: nothing else can refer to them, while
+// literals and variables may be reachable from elsewhere.
+func releaseTemporary(node ast.Expression, value object.Object) {
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		// && and || return one of their operands, which may be a variable
+		if node.Operator == "&&" || node.Operator == "||" {
+			return
 		}
-		return &object.MultiDimensionalIndex{Indices: indices}
-
-	case *ast.Assignment:
-		return evalAssignmentExpression(node, env)
-
-	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
-
-	case *ast.FloatLiteral:
-		return &object.Float{Value: node.Value}
-
-	case *ast.WhileStatement:
-		return evalWhileStatement(node, env)
-
-	case *ast.ImportExpression:
-		return evalImportExpression(node, env)
-
-	case *ast.DotExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
+	case *ast.PrefixExpression:
+		// ++x and --x return the value just stored in x
+		if node.Operator == "++" || node.Operator == "--" {
+			return
 		}
+	default:
+		return
+	}
 
-		right, ok := node.Right.(*ast.Identifier)
-		if !ok {
-			return newError("expected property name to be identifier, got %T", node.Right)
+	switch value := value.(type) {
+	case *object.Integer:
+		if !isSmallInteger(value) {
+			object.ReleaseInteger(value)
 		}
-
-		return evalDotExpression(left, right)
-
-	case *ast.BreakStatement:
-		return BREAK
-	case *ast.ContinueStatement:
-		return CNT
-
-	case *ast.ForStatement:
-		return evalForStatement(node, env)
+	case *object.Float:
+		object.ReleaseFloat(value)
 	}
+}
 
-	return nil
+func isSmallInteger(i *object.Integer) bool {
+	if !i.Value.IsInt64() {
+		return false
+	}
+	n := i.Value.Int64()
+	return n >= minCachedInteger && n <= maxCachedInteger && smallIntegers[n-minCachedInteger] == i
 }
 
-var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	BREAK = &object.Break{}
-	CNT   = &object.Continue{}
-)
+// int64Object wraps n in an Integer, reusing the shared object for small values.
+func int64Object(n int64) *object.Integer {
+	if n >= minCachedInteger && n <= maxCachedInteger {
+		return smallIntegers[n-minCachedInteger]
+	}
+	return &object.Integer{Value: big.NewInt(n)}
+}
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
@@ -193,10 +149,6 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalMinusPrefixOperatorExpression(right)
 	case "~":
 		return evalTildePrefixOperatorExpression(right)
-	case "++":
-		return evalIncrementExpression(right, true)
-	case "--":
-		return evalDecrementExpression(right, true)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -230,7 +182,7 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	switch right := right.(type) {
 	case *object.Integer:
-		return &object.Integer{Value: new(big.Int).Neg(right.Value)}
+		return integerObject(new(big.Int).Neg(right.Value))
 	case *object.Float:
 		return &object.Float{Value: new(big.Float).Neg(right.Value)}
 	default:
@@ -238,7 +190,7 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(in *Interpreter, operator string, left, right object.Object) object.Object {
 	switch {
 	case operator == "&&":
 		return evalLogicalAndExpression(left, right)
@@ -256,16 +208,25 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ || left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
 		if operator == "*" {
-			return &object.String{Value: strings.Repeat(left.(*object.String).Value, int(right.(*object.Integer).Value.Int64()))}
+			str, count := repeatOperands(left, right)
+			if err := in.checkRepeat(len(str.(*object.String).Value), count); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(str.(*object.String).Value, count)}
 		}
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
 		return evalArrayInfixExpression(operator, left, right)
 	case left.Type() == object.ARRAY_OBJ && right.Type() == object.INTEGER_OBJ || left.Type() == object.INTEGER_OBJ && right.Type() == object.ARRAY_OBJ:
 		if operator == "*" {
-			elements := make([]object.Object, 0)
-			for i := 0; i < int(right.(*object.Integer).Value.Int64()); i++ {
-				elements = append(elements, left.(*object.Array).Elements...)
+			arr, count := repeatOperands(left, right)
+			source := arr.(*object.Array).Elements
+			if err := in.checkRepeat(len(source), count); err != nil {
+				return err
+			}
+			elements := make([]object.Object, 0, len(source)*count)
+			for i := 0; i < count; i++ {
+				elements = append(elements, source...)
 			}
 			return &object.Array{Elements: elements}
 		}
@@ -285,55 +246,27 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	// Results are always new (or pooled) objects: integers may be shared
+	// through the small integer cache, so they must never be modified in place
 	switch operator {
-	case "+":
-		return &object.Integer{Value: new(big.Int).Add(leftVal, rightVal)}
-	case "+=":
-		result := new(big.Int).Add(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "-":
-		return &object.Integer{Value: new(big.Int).Sub(leftVal, rightVal)}
-	case "-=":
-		result := new(big.Int).Sub(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "*":
-		return &object.Integer{Value: new(big.Int).Mul(leftVal, rightVal)}
-	case "*=":
-		result := new(big.Int).Mul(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "/":
-		if rightVal.Cmp(big.NewInt(0)) == 0 {
+	case "+", "+=":
+		return integerResult(func(z *big.Int) { z.Add(leftVal, rightVal) })
+	case "-", "-=":
+		return integerResult(func(z *big.Int) { z.Sub(leftVal, rightVal) })
+	case "*", "*=":
+		return integerResult(func(z *big.Int) { z.Mul(leftVal, rightVal) })
+	case "/", "/=":
+		if rightVal.Sign() == 0 {
 			return newError("division by zero")
 		}
-		return &object.Integer{Value: new(big.Int).Div(leftVal, rightVal)}
-	case "/=":
-		if rightVal.Cmp(big.NewInt(0)) == 0 {
-			return newError("division by zero")
-		}
-		result := new(big.Int).Div(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "%":
-		if rightVal.Cmp(big.NewInt(0)) == 0 {
+		return integerResult(func(z *big.Int) { z.Div(leftVal, rightVal) })
+	case "%", "%=":
+		if rightVal.Sign() == 0 {
 			return newError("modulus by zero")
 		}
-		return &object.Integer{Value: new(big.Int).Mod(leftVal, rightVal)}
-	case "%=":
-		if rightVal.Cmp(big.NewInt(0)) == 0 {
-			return newError("modulus by zero")
-		}
-		result := new(big.Int).Mod(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "**":
+		return integerResult(func(z *big.Int) { z.Mod(leftVal, rightVal) })
+	case "**", "**=":
 		return &object.Float{Value: bigFloatPow(new(big.Float).SetInt(leftVal), new(big.Float).SetInt(rightVal))}
-	case "**=":
-		result := bigFloatPow(new(big.Float).SetInt(leftVal), new(big.Float).SetInt(rightVal))
-		left = &object.Float{Value: result}
-		return &object.Float{Value: result}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
 	case ">":
@@ -346,36 +279,16 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
 	case "<=":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
-	case "&":
-		return &object.Integer{Value: new(big.Int).And(leftVal, rightVal)}
-	case "&=":
-		result := new(big.Int).And(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "|":
-		return &object.Integer{Value: new(big.Int).Or(leftVal, rightVal)}
-	case "|=":
-		result := new(big.Int).Or(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "^":
-		return &object.Integer{Value: new(big.Int).Xor(leftVal, rightVal)}
-	case "^=":
-		result := new(big.Int).Xor(leftVal, rightVal)
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case ">>":
-		return &object.Integer{Value: new(big.Int).Rsh(leftVal, uint(rightVal.Int64()))}
-	case ">>=":
-		result := new(big.Int).Rsh(leftVal, uint(rightVal.Int64()))
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
-	case "<<":
-		return &object.Integer{Value: new(big.Int).Lsh(leftVal, uint(rightVal.Int64()))}
-	case "<<=":
-		result := new(big.Int).Lsh(leftVal, uint(rightVal.Int64()))
-		leftVal.Set(result)
-		return &object.Integer{Value: result}
+	case "&", "&=":
+		return integerResult(func(z *big.Int) { z.And(leftVal, rightVal) })
+	case "|", "|=":
+		return integerResult(func(z *big.Int) { z.Or(leftVal, rightVal) })
+	case "^", "^=":
+		return integerResult(func(z *big.Int) { z.Xor(leftVal, rightVal) })
+	case ">>", ">>=":
+		return integerResult(func(z *big.Int) { z.Rsh(leftVal, uint(rightVal.Int64())) })
+	case "<<", "<<=":
+		return integerResult(func(z *big.Int) { z.Lsh(leftVal, uint(rightVal.Int64())) })
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -391,22 +304,20 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 	leftVal := toFloat(left)
 	rightVal := toFloat(right)
 
-	result := new(big.Float)
-
 	switch operator {
 	case "+":
-		result.Add(leftVal, rightVal)
+		return floatResult(func(z *big.Float) { z.Add(leftVal, rightVal) })
 	case "-":
-		result.Sub(leftVal, rightVal)
+		return floatResult(func(z *big.Float) { z.Sub(leftVal, rightVal) })
 	case "*":
-		result.Mul(leftVal, rightVal)
+		return floatResult(func(z *big.Float) { z.Mul(leftVal, rightVal) })
 	case "/":
 		if rightVal.Cmp(big.NewFloat(0)) == 0 {
 			return newError("division by zero")
 		}
-		result.Quo(leftVal, rightVal)
+		return floatResult(func(z *big.Float) { z.Quo(leftVal, rightVal) })
 	case "**":
-		result = bigFloatPow(leftVal, rightVal)
+		return &object.Float{Value: bigFloatPow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
 	case ">":
@@ -422,8 +333,6 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
-
-	return &object.Float{Value: result}
 }
 
 func toFloat(obj object.Object) *big.Float {
@@ -437,34 +346,6 @@ func toFloat(obj object.Object) *big.Float {
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
-		return condition
-	}
-
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
-	}
-
-	for _, elif := range ie.Elifs {
-		condition := Eval(elif.Condition, env)
-		if isError(condition) {
-			return condition
-		}
-
-		if isTruthy(condition) {
-			return Eval(elif.Consequence, env)
-		}
-	}
-
-	if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	}
-
-	return NULL
-}
-
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -478,42 +359,70 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
-
-	for _, statement := range program.Statements {
-		result = Eval(statement, env)
-
-		switch result := result.(type) {
-		case *object.ReturnValue:
-			return result.Value
-		case *object.Error:
-			return result
-		}
-	}
-
-	return result
+func newError(format string, a ...interface{}) *object.Error {
+	code, message := locale.Sprintf(format, a...)
+	return &object.Error{Message: message, Code: code}
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
-
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
-
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
-		}
+// position returns the token a node starts with, or, for operators and
+// calls, the token of the operator or opening parenthesis, which is where
+// errors they cause are reported.
+func position(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ConstStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.WhileStatement:
+		return node.Token
+	case *ast.ForStatement:
+		return node.Token
+	case *ast.BreakStatement:
+		return node.Token
+	case *ast.ContinueStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.PostfixExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.Assignment:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.DotExpression:
+		return node.Token
+	case *ast.ImportExpression:
+		return node.Token
 	}
-
-	return result
-}
-
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return token.Token{}
 }
 
 func isError(obj object.Object) bool {
@@ -521,38 +430,48 @@ func isError(obj object.Object) bool {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Resolved {
+		if val, ok, _ := env.GetAt(node.Depth, node.Slot); ok {
+			return val
+		}
+	}
+
 	if val, ok, _ := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
+	if builtin, ok := envBuiltins[node.Value]; ok {
+		return newBuiltin(func(args ...object.Object) object.Object {
+			return builtin(env, args...)
+		})
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-	var result []object.Object
-
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
-			return []object.Object{evaluated}
-		}
-		result = append(result, evaluated)
+// ApplyFunction calls a function or builtin object with the given arguments,
+// allowing library code to call back into 1y functions. 1y functions run in
+// the interpreter that defined them, and builtins in the default one.
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	in := defaultInterpreter
+	if fn, ok := fn.(*object.Function); ok {
+		in = InterpreterOf(fn.Env)
 	}
-
-	return result
+	if in.hooks != nil {
+		return in.hooks.call(in, "", fn, args)
+	}
+	return in.applyFunction(fn, args)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (in *Interpreter) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		call, err := in.enterFunction(fn, args)
+		if err != nil {
+			return err
+		}
+		return in.exitFunction(&call, Eval(fn.Body, call.env))
 
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -562,16 +481,86 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// functionCall is a call of a 1y function in progress: the environment its
+// body runs in and what to restore once it has run.
+type functionCall struct {
+	fn        *object.Function
+	env       *object.Environment
+	reuse     bool
+	outerFile string
+	entry     *ProfileEntry
+}
+
+// enterFunction starts a call of fn with args, whose body is then to be
+// evaluated in call.env and its value passed to exitFunction.
+func (in *Interpreter) enterFunction(fn *object.Function, args []object.Object) (functionCall, *object.Error) {
+	call := functionCall{fn: fn, outerFile: in.currentFile}
+	if in.profile != nil {
+		call.entry = in.profile.function(fn.Body, "fn")
+		call.entry.enter()
+	}
+
+	if len(args) < len(fn.Parameters) {
+		if call.entry != nil {
+			call.entry.exit()
+		}
+		return call, newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+	}
+
+	// The environment of a function that creates no closures is dead once
+	// the call returns, so it can be reused by the next call
+	call.reuse = fn.Scope != nil && !fn.Scope.Captured
+	call.env = in.extendFunctionEnv(fn, args, call.reuse)
+	in.currentFile = fn.File
+	return call, nil
+}
 
+// exitFunction ends a call started by enterFunction, whose body evaluated
+// to evaluated, and returns the value of the call.
+func (in *Interpreter) exitFunction(call *functionCall, evaluated object.Object) object.Object {
+	in.currentFile = call.outerFile
+	if call.reuse {
+		call.fn.ReleaseFrame(call.env)
+	}
+	if call.entry != nil {
+		call.entry.exit()
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+func (in *Interpreter) extendFunctionEnv(fn *object.Function, args []object.Object, reuse bool) *object.Environment {
+	var env *object.Environment
+	if reuse {
+		env = fn.AcquireFrame(scopeNames(fn.Scope))
+		if in.stats != nil {
+			in.stats.Environments++
+			in.stats.PooledFrames++
+		}
+	} else {
+		env = in.newScopedEnvironment(fn.Env, fn.Scope)
+	}
+
+	// Resolved parameters have a slot of their own in the call's scope
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if param.Resolved {
+			env.DeclareAt(param.Slot, param.Value, args[paramIdx], false)
+		} else {
+			env.Set(param.Value, args[paramIdx])
+		}
 	}
 
 	return env
 }
 
+// scopeNames returns the slot assignments of a resolved scope, or nil for
+// code that was never resolved.
+func scopeNames(scope *ast.Scope) map[string]int {
+	if scope == nil {
+		return nil
+	}
+	return scope.Names
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -584,7 +573,7 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	rightVal := right.(*object.String).Value
 	switch operator {
 	case "+":
-		return &object.String{Value: leftVal + rightVal}
+		return object.Concat(left.(*object.String), rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -603,11 +592,19 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
+	if module, ok := left.(*object.Module); ok {
+		members := loadModule(module)
+		if isError(members) {
+			return members
+		}
+		left = members
+	}
+
 	switch {
+	case index.Type() == object.MULTIDIMENSIONAL_INDEX_OBJ:
+		return evalMultiDimensionalIndexExpression(left, index.(*object.MultiDimensionalIndex))
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.MULTIDIMENSIONAL_INDEX_OBJ:
-		return evalMultiDimensionalIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -617,36 +614,20 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
-func evalMultiDimensionalIndexExpression(array, index object.Object) object.Object {
-	arrayObj, ok := array.(*object.Array)
-	if !ok {
-		return newError("left object is not an array: %s", array.Type())
-	}
-
-	indexObj, ok := index.(*object.MultiDimensionalIndex)
-	if !ok {
-		return newError("index object is not a multi-dimensional index: %s", index.Type())
-	}
-
-	current := arrayObj
-	for _, idx := range indexObj.Indices {
-		idxVal, ok := idx.(*object.Integer)
-		if !ok {
-			return newError("index is not an integer: %s", idx.Type())
-		}
-
-		if idxVal.Value.Int64() < 0 || idxVal.Value.Int64() >= int64(len(current.Elements)) {
+// evalMultiDimensionalIndexExpression indexes left by each index in turn, so
+// m[i, j] is m[i][j] on nested arrays and hashes. A missing element or key
+// makes the whole expression null.
+func evalMultiDimensionalIndexExpression(left object.Object, index *object.MultiDimensionalIndex) object.Object {
+	current := left
+	for _, idx := range index.Indices {
+		if current == NULL {
 			return NULL
 		}
-
-		element := current.Elements[idxVal.Value.Int64()]
-		arrayElement, ok := element.(*object.Array)
-		if !ok {
-			return element
+		current = evalIndexExpression(current, idx)
+		if isError(current) {
+			return current
 		}
-		current = arrayElement
 	}
-
 	return current
 }
 
@@ -662,118 +643,128 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObj.Elements[idx.Int64()]
 }
 
-func evalAssignmentExpression(node *ast.Assignment, env *object.Environment) object.Object {
-	val := Eval(node.Value, env)
-	if isError(val) {
-		return val
+// assignIdentifier stores val in the variable name.
+func assignIdentifier(name *ast.Identifier, val object.Object, env *object.Environment) object.Object {
+	if name.Resolved {
+		if _, ok, readOnly := env.GetAt(name.Depth, name.Slot); ok {
+			if readOnly {
+				return newError("cannot assign to constant '%s'", name.Value)
+			}
+			env.SetAt(name.Depth, name.Slot, val)
+			return val
+		}
 	}
 
-	switch name := node.Name.(type) {
-	case *ast.Identifier:
-		_, ok, readOnly := env.Get(name.Value)
-		if !ok {
-			return newError("identifier not found: " + name.Value)
-		}
+	_, ok, readOnly := env.Get(name.Value)
+	if !ok {
+		return newError("identifier not found: %s", name.Value)
+	}
 
-		if readOnly {
-			return newError("cannot assign to constant '%s'", name.Value)
-		}
+	if readOnly {
+		return newError("cannot assign to constant '%s'", name.Value)
+	}
 
-		env.Set(name.Value, val)
-		return val
+	env.Set(name.Value, val)
+	return val
+}
 
-	case *ast.DotExpression:
-		left := Eval(name.Left, env)
-		if isError(left) {
-			return left
+// assignIndex stores val in the element of left at index.
+func assignIndex(left, index, val object.Object) object.Object {
+	if multi, ok := index.(*object.MultiDimensionalIndex); ok {
+		// m[i, j] = v sets the element j of m[i]
+		last := len(multi.Indices) - 1
+		for _, idx := range multi.Indices[:last] {
+			left = evalIndexExpression(left, idx)
+			if isError(left) {
+				return left
+			}
 		}
+		index = multi.Indices[last]
+	}
+
+	return evalIndexAssignment(left, index, val)
+}
 
-		right, ok := name.Right.(*ast.Identifier)
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Module:
+		members := loadModule(left)
+		if isError(members) {
+			return members
+		}
+		return evalIndexAssignment(members, index, val)
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
 		if !ok {
-			return newError("expected property name to be identifier, got %T", name.Right)
+			return newError("index is not an integer: %s", index.Type())
 		}
-
-		return evalDotAssignment(left, right, val)
-
+		if idx.Value.Sign() < 0 || idx.Value.Cmp(big.NewInt(int64(len(left.Elements)))) >= 0 {
+			return newError("index out of range: %s", idx.Value.String())
+		}
+		left.Set(int(idx.Value.Int64()), val)
+		return val
+	case *object.Hash:
+		if _, ok := object.AsHashable(index); !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if err := putPair(left, index, val); err != nil {
+			return err
+		}
+		return val
 	default:
-		return newError("invalid assignment target: %T", node.Name)
+		return newError("index assignment not supported: %s", left.Type())
 	}
 }
 
 func evalDotAssignment(left object.Object, right *ast.Identifier, val object.Object) object.Object {
 	switch left := left.(type) {
+	case *object.Module:
+		members := loadModule(left)
+		if isError(members) {
+			return members
+		}
+		return evalDotAssignment(members, right, val)
 	case *object.Hash:
-		key := &object.String{Value: right.Value}
-		hashKey := key.HashKey()
-		left.Pairs[hashKey] = object.HashPair{Key: key, Value: val}
+		if err := putPair(left, object.Intern(right.Value), val); err != nil {
+			return err
+		}
 		return val
 	default:
 		return newError("not a hash: %s", left.Type())
 	}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
-			return key
-		}
-
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
-		}
-
-		value := Eval(valueNode, env)
-		if isError(value) {
-			return value
-		}
-
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+// putPair stores val under key in hash, reporting a key that collides with
+// another.
+func putPair(hash *object.Hash, key, val object.Object) *object.Error {
+	if !hash.Put(key, val) {
+		other := hash.Pairs[key.(object.Hashable).HashKey()].Key
+		return newError("hash key %s collides with key %s", key.Inspect(), other.Inspect())
 	}
-
-	return &object.Hash{Pairs: pairs}
+	return nil
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObj := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
+	// Different keys can share a hash key, if rarely, so a pair found must
+	// also have an equal key
 	pair, ok := hashObj.Pairs[key.HashKey()]
-	if !ok {
+	if !ok || !object.IsEqual(pair.Key, index) {
 		return NULL
 	}
 
 	return pair.Value
 }
 
-func evalLoopStatement(body *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
-
-	for _, statement := range body.Statements {
-		result = Eval(statement, env)
-
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
-				return result
-			}
-		}
-	}
-
-	return result
-}
-
 func evalTildePrefixOperatorExpression(right object.Object) object.Object {
 	switch right := right.(type) {
 	case *object.Integer:
-		return &object.Integer{Value: new(big.Int).Not(right.Value)}
+		return integerObject(new(big.Int).Not(right.Value))
 	default:
 		return newError("unknown operator: ~%s", right.Type())
 	}
@@ -807,14 +798,27 @@ func evalHashInfixExpression(operator string, left, right object.Object) object.
 	}
 }
 
+// repeatOperands splits the operands of `*` between a sequence and an integer,
+// which may appear in either order. Negative counts repeat zero times.
+func repeatOperands(left, right object.Object) (object.Object, int) {
+	seq, count := left, right
+	if left.Type() == object.INTEGER_OBJ {
+		seq, count = right, left
+	}
+	n := count.(*object.Integer).Value.Int64()
+	if n < 0 {
+		n = 0
+	}
+	return seq, int(n)
+}
+
 func evalArrayInfixExpression(operator string, left, right object.Object) object.Object {
 	leftArr := left.(*object.Array)
 	rightArr := right.(*object.Array)
 
 	switch operator {
 	case "+":
-		elements := append(leftArr.Elements, rightArr.Elements...)
-		return &object.Array{Elements: elements}
+		return leftArr.Extend(rightArr.Elements)
 	case "==":
 		return nativeBoolToBooleanObject(object.IsEqual(leftArr, rightArr))
 	case "!=":
@@ -824,49 +828,6 @@ func evalArrayInfixExpression(operator string, left, right object.Object) object
 	}
 }
 
-func evalPostfixExpression(operator string, left object.Object) object.Object {
-	switch operator {
-	case "++":
-		return evalIncrementExpression(left, false)
-	case "--":
-		return evalDecrementExpression(left, false)
-	default:
-		return newError("unknown operator: %s%s", operator, left.Type())
-	}
-}
-
-func evalIncrementExpression(operand object.Object, isPrefix bool) object.Object {
-	switch operand := operand.(type) {
-	case *object.Integer:
-		if isPrefix {
-			operand.Value.Add(operand.Value, big.NewInt(1))
-			return operand
-		} else {
-			result := &object.Integer{Value: new(big.Int).Set(operand.Value)}
-			operand.Value.Add(operand.Value, big.NewInt(1))
-			return result
-		}
-	default:
-		return newError("unknown operator: ++%s", operand.Type())
-	}
-}
-
-func evalDecrementExpression(operand object.Object, isPrefix bool) object.Object {
-	switch operand := operand.(type) {
-	case *object.Integer:
-		if isPrefix {
-			operand.Value.Sub(operand.Value, big.NewInt(1))
-			return operand
-		} else {
-			result := &object.Integer{Value: new(big.Int).Set(operand.Value)}
-			operand.Value.Sub(operand.Value, big.NewInt(1))
-			return result
-		}
-	default:
-		return newError("unknown operator: --%s", operand.Type())
-	}
-}
-
 func evalLogicalAndExpression(left, right object.Object) object.Object {
 	if isTruthy(left) {
 		return right
@@ -883,9 +844,14 @@ func evalLogicalOrExpression(left, right object.Object) object.Object {
 
 func evalDotExpression(left object.Object, right *ast.Identifier) object.Object {
 	switch left := left.(type) {
+	case *object.Module:
+		members := loadModule(left)
+		if isError(members) {
+			return members
+		}
+		return evalDotExpression(members, right)
 	case *object.Hash:
-		key := &object.String{Value: right.Value}
-		hashKey := key.HashKey()
+		hashKey := object.Intern(right.Value).HashKey()
 		if pair, ok := left.Pairs[hashKey]; ok {
 			return pair.Value
 		} else {
@@ -896,134 +862,119 @@ func evalDotExpression(left object.Object, right *ast.Identifier) object.Object
 	}
 }
 
+// evalStringIndexExpression returns the character of str at index, counting
+// code points rather than bytes, as len does.
 func evalStringIndexExpression(str, index object.Object) object.Object {
 	strObj := str.(*object.String)
 	idx := index.(*object.Integer).Value
-	max := big.NewInt(int64(len(strObj.Value) - 1))
 
-	if idx.Cmp(big.NewInt(0)) < 0 || idx.Cmp(max) > 0 {
+	if idx.Sign() < 0 || !idx.IsInt64() {
 		return NULL
 	}
 
-	return &object.String{Value: string(strObj.Value[idx.Int64()])}
+	// A string has at most as many code points as bytes
+	if idx.Int64() >= int64(len(strObj.Value)) {
+		return NULL
+	}
+	if char, ok := strObj.CharAt(int(idx.Int64())); ok {
+		return &object.String{Value: char}
+	}
+	return NULL
 }
 
-func evalImportExpression(ie *ast.ImportExpression, env *object.Environment) object.Object {
-	// Evaluate the import path
-	pathObj := Eval(ie.Path, env)
+// importModule imports the module at the path pathObj, the value of the
+// path of an import expression.
+func (in *Interpreter) importModule(pathObj object.Object) object.Object {
 	if pathObj.Type() != object.STRING_OBJ {
 		return newError("import path must be a string, got %s", pathObj.Type())
 	}
 
-	path := pathObj.(*object.String).Value
-	if !strings.HasSuffix(path, ".1y") {
-		path += ".1y"
+	var start time.Time
+	if in.importTimes != nil {
+		start = time.Now()
 	}
 
-	// Try to read the file from the current working directory or interpreter directory
-	content, err := readFileFromCurrentOrInterpreterDir(path)
+	name := pathObj.(*object.String).Value
+	if in.Sandboxed() && (isPlugin(name) || in.options.Loader == nil) {
+		// Plugins and the default loader read the file system, while a
+		// loader the interpreter was given reaches what it was meant to
+		return newError("`import` is not allowed in sandbox mode")
+	}
+	if isPlugin(name) {
+		m, err := loadPlugin(name, in)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return m
+	}
+	loader := in.Loader()
+	path, err := loader.Resolve(name)
 	if err != nil {
-		return newError("could not read file: %s", path)
+		return newError("%s", err)
 	}
-
-	// Lexical and syntactical analysis
-	l := lexer.New(string(content))
-	p := parser.New(l)
-	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		return newError("parsing file %s failed: %s", path, strings.Join(p.Errors(), "\n"))
+	content, err := loader.Load(path)
+	if err != nil {
+		return newError("%s", err)
 	}
 
-	// Create a new environment and execute the program
-	newEnv := object.NewEnvironment()
-	Eval(program, newEnv)
-
-	// Wrap the variables in the new environment into a Hash object
-	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
-	for k, v := range newEnv.Store() {
-		hashKey := &object.String{Value: k}
-		hash.Pairs[hashKey.HashKey()] = object.HashPair{Key: hashKey, Value: v.Value}
+	var program *ast.Program
+	if compiled.IsCompiled(content) {
+		program, err = compiled.Read(bytes.NewReader(content))
+		if err != nil {
+			return newError("loading file %s failed: %s", path, err)
+		}
+	} else {
+		// Lexical and syntactical analysis
+		l := lexer.New(string(content))
+		p := parser.New(l)
+		program = p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return newError("parsing file %s failed: %s", path, strings.Join(p.Errors(), "\n"))
+		}
 	}
 
-	return hash
-}
-
-func readFileFromCurrentOrInterpreterDir(path string) ([]byte, error) {
-	// Try to read the file from the current working directory
-	content, err := os.ReadFile(path)
-	if err == nil {
-		return content, nil
-	}
+	program.File = path
 
-	// Get the interpreter directory
-	execPath, err := os.Executable()
-	if err != nil {
-		return nil, err
+	// The module's code runs in its own environment when it is first used
+	m := &object.Module{Path: path, Program: program, Env: in.NewEnvironment()}
+	if in.importTimes != nil {
+		in.importTimes.parsed(m, time.Since(start))
 	}
-	execDir := filepath.Dir(execPath)
-
-	// Try to read the file from the interpreter directory
-	fullPath := filepath.Join(execDir, path)
-	return os.ReadFile(fullPath)
+	return m
 }
 
-func evalLoop(init ast.Statement, condition ast.Expression, post ast.Statement, body *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
-
-	loopEnv := object.NewEnclosedEnvironment(env)
-
-	if init != nil {
-		result = Eval(init, loopEnv)
-		if isError(result) {
-			return result
-		}
+// moduleMembers wraps the variables in a module's environment into a hash.
+func moduleMembers(env *object.Environment) *object.Hash {
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	for k, v := range env.Store() {
+		hashKey := object.Intern(k)
+		hash.Pairs[hashKey.HashKey()] = object.HashPair{Key: hashKey, Value: v.Value}
 	}
+	return hash
+}
 
-	for {
-		if condition != nil {
-			cond := Eval(condition, loopEnv)
-			if isError(cond) {
-				return cond
-			}
-			if !isTruthy(cond) {
-				break
-			}
-		}
+// loadModule runs a module's top-level code if it has not run yet and returns
+// its members as a hash, or the error the code produced.
+func loadModule(m *object.Module) object.Object {
+	if m.Program != nil {
+		program := m.Program
+		m.Program = nil
 
-		result = evalLoopStatement(body, loopEnv)
-		if result != nil {
-			if result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ {
-				return result
-			}
-			if result.Type() == object.BREAK_OBJ {
-				return NULL
-			}
-			if result.Type() == object.CONTINUE_OBJ {
-				if post != nil {
-					result = Eval(post, loopEnv)
-					if isError(result) {
-						return result
-					}
-				}
-				continue
-			}
+		if t := InterpreterOf(m.Env).importTimes; t != nil {
+			defer func(start time.Time) { t.ran(m, time.Since(start)) }(time.Now())
 		}
-
-		if post != nil {
-			result = Eval(post, loopEnv)
-			if isError(result) {
-				return result
-			}
+		if result := Eval(program, m.Env); isError(result) {
+			m.Err = result.(*object.Error)
+		} else {
+			m.Members = moduleMembers(m.Env)
 		}
 	}
 
-	return NULL
-}
-
-func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
-	return evalLoop(fs.Init, fs.Condition, fs.Post, fs.Body, env)
-}
-
-func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
-	return evalLoop(nil, ws.Condition, nil, ws.Body, env)
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.Members == nil {
+		return newError("module %s used while it is loading", m.Path)
+	}
+	return m.Members
 }
@@ -1,11 +1,24 @@
 package evaluator
 
 import (
+	"1ylang/ast"
 	"1ylang/lexer"
 	"1ylang/object"
 	"1ylang/parser"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -38,11 +51,16 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+// testEval evaluates input in a new environment. It panics if input does not
+// parse, so that a test cannot pass on a program that never ran.
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	env := object.NewEnvironment()
+	if errs := p.Errors(); len(errs) != 0 {
+		panic(fmt.Sprintf("parsing %q failed: %s", input, strings.Join(errs, "; ")))
+	}
+	env := NewEnvironment()
 
 	return Eval(program, env)
 }
@@ -251,6 +269,10 @@ if (10 > 1) {
 			`999[1]`,
 			"index operator not supported: INTEGER",
 		},
+		{
+			"let f = fn(a, b) { a }; f(1);",
+			"wrong number of arguments. got=1, want=2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -279,11 +301,17 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 1, b = a + 1; a + b", 3},
+		{"let a; a = 4; a", 4},
+		{"let f = fn() { let a, b = 2; a = 1; a + b }; f()", 3},
 	}
 
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
+
+	testNullObject(t, testEval("let a; a"))
+	testNullObject(t, testEval("fn() { let a = 1, b; b }()"))
 }
 
 func TestFunctionObject(t *testing.T) {
@@ -355,6 +383,27 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestRepeatedStringConcatenation(t *testing.T) {
+	input := `
+let s = "";
+let parts = [];
+for (let i = 0; i < 100; i++) {
+	s += "ab";
+	if (i == 49) { parts = [s + "x", s + "y"]; }
+}
+[len(s), len(parts[0]), parts[0] == parts[1]]`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	testIntegerObject(t, result.Elements[0], 200)
+	testIntegerObject(t, result.Elements[1], 101)
+	testBooleanObject(t, result.Elements[2], false)
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -369,6 +418,38 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`"你好世界"[1]`, "好"},
+		{`"héllo"[2]`, "l"},
+		{`"你好"[2]`, nil},
+		{`"abc"[-1]`, nil},
+		{`"abc"[99999999999999999999]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value for %s. got=%q, want=%q", tt.input, str.Value, expected)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -377,11 +458,15 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("你好")`, 2},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
 		{`puts("hello", "world!")`, nil},
+		{`print("hello", "world!")`, nil},
+		{`eprint("hello", "world!")`, nil},
 		{`first([1, 2, 3])`, 1},
 		{`first([])`, nil},
 		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
@@ -392,6 +477,26 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([])`, nil},
 		{`push([], 1)`, []int{1}},
 		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`insert([1, 3], 1, 2)`, []int{1, 2, 3}},
+		{`insert([1, 2], 2, 3)`, []int{1, 2, 3}},
+		{`let a = [2]; insert(a, 0, 1); a`, []int{1, 2}},
+		{`insert([1], 2, 3)`, "index out of range: 2"},
+		{`insert([1], "0", 3)`, "index to `insert` must be INTEGER, got STRING"},
+		{`removeAt([1, 2, 3], 1)`, 2},
+		{`let a = [1, 2, 3]; removeAt(a, 0); a`, []int{2, 3}},
+		{`removeAt([], 0)`, "index out of range: 0"},
+		{`removeAt(1, 0)`, "argument to `removeAt` must be ARRAY, got INTEGER"},
+		{`splice([1, 2, 3, 4], 1, 2)`, []int{2, 3}},
+		{`let a = [1, 2, 3, 4]; splice(a, 1, 2, 5, 6, 7); a`, []int{1, 5, 6, 7, 4}},
+		{`let a = [1, 2]; splice(a, 1, 10); a`, []int{1}},
+		{`let a = [1, 2]; splice(a, 2, 0, 3); a`, []int{1, 2, 3}},
+		{`let a = [1, 2, 3]; let b = rest(a); splice(b, 0, 1); a`, []int{1, 2, 3}},
+		{`splice([1], 0, -1)`, "count to `splice` must not be negative, got -1"},
+		{`splice([1], 0)`, "wrong number of arguments. got=2, want=3+"},
+		{`let size = len; size("abc")`, 3},
+		{`let len = fn(x) { 42 }; len("abc")`, 42},
+		{`len("ab")`, 2},
+		{`len = 1`, "cannot assign to constant 'len'"},
 	}
 
 	for _, tt := range tests {
@@ -433,6 +538,71 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestPrintBuiltins(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{`print("hello", "world!")`, "hello world!", ""},
+		{`print("a"); print("b")`, "ab", ""},
+		{`print()`, "", ""},
+		{`print(1, 2.5, true, [1, "x"])`, `1 2.5 true [1, "x"]`, ""},
+		{`eprint("hello", "world!")`, "", "hello world!"},
+		{`eprint("a"); eprint("b")`, "", "ab"},
+		{`eprint()`, "", ""},
+		{`puts("hello", "world!")`, "hello world!\n", ""},
+		{`print("out"); eprint("err")`, "out", "err"},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		in := New(Options{Stdout: &out, Stderr: &errOut})
+		result := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), in.NewEnvironment())
+		testNullObject(t, result)
+		if out.String() != tt.stdout {
+			t.Errorf("%s wrote %q, want %q", tt.input, out.String(), tt.stdout)
+		}
+		if errOut.String() != tt.stderr {
+			t.Errorf("%s wrote %q to stderr, want %q", tt.input, errOut.String(), tt.stderr)
+		}
+	}
+}
+
+func TestPrettyPrintBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`pprint("text")`, "text\n"},
+		{`pprint([])`, "[]\n"},
+		{`pprint({10: "b", 9: "a", 1.5: "c", -2: "d"})`, "{\n  -2: \"d\",\n  1.5: \"c\",\n  9: \"a\",\n  10: \"b\"\n}\n"},
+		{`pprint({"b": 1, true: 2, 3: 3, false: 4, "a": 5})`, "{\n  false: 4,\n  true: 2,\n  3: 3,\n  \"a\": 5,\n  \"b\": 1\n}\n"},
+		{`pprint({"x": [1, {"y": []}]}, 4)`, "{\n    \"x\": [\n        1,\n        {\n            \"y\": []\n        }\n    ]\n}\n"},
+		{`pprint([1], 0)`, "[\n1\n]\n"},
+		{`pprint([1], -1)`, "indent must be between 0 and 16, got -1"},
+		{`pprint([1], 17)`, "indent must be between 0 and 16, got 17"},
+		{`pprint([1], 100000000000000000000)`, "indent must be between 0 and 16, got 100000000000000000000"},
+		{`pprint([1], "2")`, "argument 2 to `pprint` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		in := New(Options{Stdout: &out})
+		result := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), in.NewEnvironment())
+		if err, ok := result.(*object.Error); ok {
+			if err.Message != tt.expected {
+				t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Message)
+			}
+			continue
+		}
+		testNullObject(t, result)
+		if out.String() != tt.expected {
+			t.Errorf("%s wrote %q, want %q", tt.input, out.String(), tt.expected)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -613,6 +783,34 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{[1, 2]: 5}[[1, 2]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`let grid = {}; let x = 3; grid[[x, 4]] = 5; grid[[3, 4]]`,
+			5,
+		},
+		{
+			`let grid = {}; grid[["a", [1]]] = 5; grid[["a", [1]]]`,
+			5,
+		},
+		{
+			`let k = [1, 2]; let h = {}; h[k] = 5; k[0] = 9; h[[1, 2]]`,
+			5,
+		},
+		{
+			`let k = [1, 2]; let h = {}; h[k] = 5; k[0] = 9; h[[9, 2]]`,
+			nil,
+		},
+		{
+			`let k = [1, [2]]; let h = {k: 5}; k[1][0] = 9; h[[1, [2]]]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -626,6 +824,50 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestMultiDimensionalIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let m = [[1, 2], [3, 4]]; m[0, 1]", 2},
+		{"let m = [[[1, 2], [3, 4]], [[5, 6], [7, 8]]]; m[1, 0, 1]", 6},
+		{"let m = [[1, 2], [3, 4]]; m[2, 0]", nil},
+		{"let m = [[1, 2], [3, 4]]; m[0, 5]", nil},
+		{`let h = {"a": {"b": 1}}; h["a", "b"]`, 1},
+		{`let h = {"a": [1, 2]}; h["a", 1]`, 2},
+		{`let h = {"a": {"b": 1}}; h["x", "b"]`, nil},
+		{`let a = [{"x": 3}]; a[0, "x"]`, 3},
+		{"let m = [[1, 2], [3, 4]]; m[1, 0] = 9; m[1, 0]", 9},
+		{`let h = {"a": {}}; h["a", "b"] = 7; h["a"]["b"]`, 7},
+		{"let m = [[1, 2]]; let row = m[0]; m[0, 0] = 5; row[0]", 5},
+		{"let m = [[1, 2], [3, 4]]; m[0, 0] += 10; m[0, 0]", 11},
+		{"let m = [1, 2]; m[0, 0]", "index operator not supported: INTEGER"},
+		{`let m = [[1, 2]]; m[0, "x"]`, "index operator not supported: ARRAY"},
+		{"let m = [[1, 2]]; m[0, 2] = 1", "index out of range: 2"},
+		{"let m = [[1, 2]]; m[1, 0] = 1", "index assignment not supported: NULL"},
+		{"let m = [[1, 2]]; m[0, y]", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestConstStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -806,4 +1048,1170 @@ func TestLoopStatement(t *testing.T) {
 	for _, tt := range tests {
 		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
-}
\ No newline at end of file
+}
+
+func TestFormatBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format(3.14159, 2)`, "3.14"},
+		{`format(2.5)`, "2.5"},
+		{`format(42, 2)`, "42.00"},
+		{`format(1234567)`, "1234567"},
+		{`format(1234567, 0, ",")`, "1,234,567"},
+		{`format(-1234567.891, 2, ",")`, "-1,234,567.89"},
+		{`format(999.5, 0, ",")`, "1,000"},
+		{`format(123, 0, " ")`, "123"},
+		{`format(float("inf"), 2, ",")`, "+Inf"},
+		{`format(-float("inf"), 0, ",")`, "-Inf"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestFormatBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format(1, -1)`, "precision must not be negative, got -1"},
+		{`format(1, 99999999999)`, "precision must be at most 1000, got 99999999999"},
+		{`format(1, 100000000000000000000)`, "precision must be at most 1000, got 100000000000000000000"},
+		{`format("1")`, "argument to `format` must be INTEGER or FLOAT, got STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("wrong result for %s. expected error %q, got=%v", tt.input, tt.expected, testEval(tt.input))
+		}
+	}
+}
+
+func TestNumberConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int("42")`, 42},
+		{`int("  42  ")`, 42},
+		{`int("ff", 16)`, 255},
+		{`int("-101", 2)`, -5},
+		{`int("0x1f", 0)`, 31},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("abc")`, nil},
+		{`int("12", 16)`, 18},
+		{`int("z", 37)`, "radix must be 0 or between 2 and 36, got 37"},
+		{`int(12, 16)`, "radix requires a STRING argument, got INTEGER"},
+		{`float("oops")`, nil},
+		{`int(float(" 2.5 "))`, 2},
+		{`int(42)`, 42},
+		{`int(true)`, "argument to `int` must be STRING, INTEGER or FLOAT, got BOOLEAN"},
+		{`int(float("inf"))`, "cannot convert +Inf to INTEGER"},
+		{`int(float("-Inf"))`, "cannot convert -Inf to INTEGER"},
+		{`float([])`, "argument to `float` must be STRING, INTEGER or FLOAT, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEnvironmentVariableBuiltins(t *testing.T) {
+	t.Setenv("ONE_Y_TEST_VAR", "")
+
+	evaluated := testEval(`setenv("ONE_Y_TEST_VAR", 42); getenv("ONE_Y_TEST_VAR")`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "42" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+
+	testNullObject(t, testEval(`getenv("ONE_Y_TEST_UNSET_VAR")`))
+	testIntegerObject(t, testEval(`getenv("ONE_Y_TEST_UNSET_VAR", 7)`), 7)
+}
+
+func TestArgsBuiltin(t *testing.T) {
+	SetArgs([]string{"input.txt", "--flag"})
+	defer SetArgs(nil)
+
+	evaluated := testEval(`args()`)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(array.Elements) != 2 {
+		t.Fatalf("wrong num of elements. want=2, got=%d", len(array.Elements))
+	}
+	if array.Elements[1].Inspect() != "--flag" {
+		t.Errorf("wrong element. want=%q, got=%q", "--flag", array.Elements[1].Inspect())
+	}
+	if got := testEval(`ARGS[0] + str(len(ARGS))`); got.Inspect() != "input.txt2" {
+		t.Errorf("wrong ARGS. got=%q", got.Inspect())
+	}
+}
+
+func TestEvalBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`eval("1 + 2")`, 3},
+		{`let x = 5; eval("x * 2")`, 10},
+		{`eval("let y = 4;"); y`, 4},
+		{`let f = fn() { let z = 3; eval("z + 1") }; f()`, 4},
+		{`eval("x * 2", {"x": 21})`, 42},
+		{`let x = 1; eval("x", {})`, "identifier not found: x"},
+		{`eval(1)`, "argument to `eval` must be STRING, got INTEGER"},
+		{`eval("let")`, "parsing eval input failed: expected next token to be IDENT, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestParseBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`parse("1;").type`, "Program"},
+		{`parse("let x = 1;").statements[0].type`, "LetStatement"},
+		{`parse("let x = 1;").statements[0].name.value`, "x"},
+		{`parse("a + b").statements[0].expression.operator`, "+"},
+		{`parse("f(1, 2)").statements[0].expression.arguments[1].type`, "IntegerLiteral"},
+		{`parse("if (x) { 1 } else { 2 }").statements[0].expression.alternative.type`, "BlockStatement"},
+		{`type(parse("if (x) { 1 }").statements[0].expression.alternative)`, "NULL"},
+		{`parse("fn(a, b) { a }").statements[0].expression.parameters[1].value`, "b"},
+		{`let ps = parse("{1: 0, 2: 0, 3: 0, 4: 0, 5: 0, 6: 0}").statements[0].expression.pairs; let s = ""; for (let i = 0; i < len(ps); i++) { s = s + str(ps[i].key.value) } s`, "123456"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+}
+
+func TestRecursiveFunctionParameters(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(15);`
+
+	testIntegerObject(t, testEval(input), 610)
+}
+
+func TestFunctionParametersStayInCallScope(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// a parameter shadows a variable of the defining scope without
+		// assigning to it
+		{"let n = 100; let double = fn(n) { n * 2 }; double(3); n;", 100},
+		{"let n = 100; let double = fn(n) { n * 2 }; double(3);", 6},
+		// a parameter is not left behind as a global after the call
+		{"let f = fn(p) { p }; f(1); let p = 7; p;", 7},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestExitHooks(t *testing.T) {
+	var order []int
+	AtExit(func() { order = append(order, 1) })
+	AtExit(func() { order = append(order, 2) })
+
+	RunExitHooks()
+	RunExitHooks()
+
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("exit hooks ran in wrong order or more than once. got=%v", order)
+	}
+}
+
+func TestIncrementDoesNotMutateSharedIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { let a = 5; a++; a }; f(); f()", 6},
+		{"let a = 1; let b = a; a++; b", 1},
+		{"let a = 1; a++; 1", 1},
+		{"let a = 1; ++a", 2},
+		{"let a = 1; a++", 1},
+		{"let a = 1; --a; a--; a", -1},
+		{`let h = {"n": 1}; h.n++; h.n`, 2},
+		{"let arr = [1, 2]; arr[1]++; arr[1]", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	evaluated := testEval("const c = 1; c++")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "cannot assign to constant 'c'" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestSmallIntegersAreShared(t *testing.T) {
+	if testEval("1 + 1") != testEval("4 - 2") {
+		t.Errorf("small integers are not shared")
+	}
+	testIntegerObject(t, testEval("1000 + 1000"), 2000)
+}
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; let f = fn() { let x = x + 1; x }; f() + x", 3},
+		{"let make = fn(a) { fn(b) { fn(c) { a + b + c } } }; make(1)(2)(3)", 6},
+		{"let f = fn() { let g = fn() { y }; let y = 7; g() }; f()", 7},
+		{"let f = fn(n) { let total = 0; for (let i = 0; i < n; i++) { total += i; } total }; f(5)", 10},
+		{"let f = fn() { let c = 1; c = c + 1; c }; f()", 2},
+		{"let f = fn() { eval(\"let z = 4;\"); z }; f()", 4},
+		{"let f = fn(a) { eval(\"a = 9;\"); a }; f(1)", 9},
+		{"let y = 5; let f = fn() { const y = 1; y = 2 }; f()", "cannot assign to constant 'y'"},
+		{"let f = fn() { let a = 1; let a = 2 }; f()", "cannot redeclare variable 'a'"},
+		{"let total = 0; for (let i = 0; i < 4; i++) { let sq = i * i; total += sq; } total", 14},
+		{"let f = fn() { let out = 0; let n = 0; while (n < 3) { let k = n; out += k; n++; } out }; f()", 3},
+		{"let n = 0; let fs = []; while (n < 3) { let v = n; push(fs, fn() { v }); n++; } fs[0]() + fs[2]()", 2},
+		{"let fs = []; for (let i = 0; i < 3; i++) { let v = i; push(fs, eval(\"fn() { v }\")); } fs[0]() + fs[2]()", 2},
+		{"let f = fn() { eval(\"let z = 4;\"); z }; f() + f()", 8},
+		{"let mk = fn(x) { eval(\"fn() { x }\") }; let a = mk(1); let b = mk(2); a() + b()", 3},
+		{"let sq = fn(x) { let y = x * x; y }; sq(3) + sq(4)", 25},
+		{"let fact = fn(n) { if (n == 0) { return 1; } n * fact(n - 1) }; fact(5) + fact(3)", 126},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestArrayInfixDoesNotAlias(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3, 4]; pop(a); let b = a + [9]; let c = a + [8]; b[3]", 9},
+		{"let a = [1, 2]; let b = a * 2; push(b, 3); len(a)", 2},
+		{"len(3 * [1, 2])", 6},
+		{"len(-1 * [1, 2])", 0},
+		{"let a = [1, 2]; let b = a + [3]; let c = a + [4]; push(a, 5); push(b, 6); a[2] + b[2] + c[1] + b[3] + len(c)", 19},
+		{"let a = [1, 2, 3]; let r = rest(a); push(r, 7); push(a, 8); r[2] + a[3] + len(a)", 19},
+		{"let a = [1, 2]; let b = a + [3]; let c = a + [4]; b[0] = 9; push(a, 5); a[0] + b[2] + c[0] + c[2]", 9},
+		{"let a = [1, 2, 3]; let r = rest(a); r[0] = 9; push(r, 7); a[1] + len(a)", 5},
+		{"let a = []; for (let i = 0; i < 100; i++) { a = a + [i]; } a[99] + len(a)", 199},
+		{`2 * "ab"`, "abab"},
+		{`"ab" * 0`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		}
+	}
+}
+
+func TestPooledTemporariesAreNotShared(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5000; let b = (a * 2) + (a * 3); let c = (a * 4) + (a * 5); b", 25000},
+		{"let a = 5000; let x = a * 2; let y = (x || 0) + 1; let z = (a * 3) + 7; x", 10000},
+		{"let a = 5000; let x = a * 2; let y = (x && x) * 3; let z = (a * 7) - 1; x", 10000},
+		{"let a = 5000; let x = a * 2; let y = -(++x) + 0; let z = (a * 9) * 1; x", 10001},
+		{"let t = 0; for (let i = 0; i < 50; i++) { t = t + (i * 1000) * 2; } t", 2450000},
+		// && and || evaluate to an operand computed by an operator
+		{"let a = 5000; let x = (a * 3) || 0; let z = a * 13; let w = a * 17; x", 15000},
+		{"let a = 5000; let y = (a * 7) && (a * 11); let z = a * 13; let w = a * 17; y", 55000},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	float := testEval("let x = (1.5 * 2.0) || 0; let y = 8.5 * 2.0; let z = 4.25 * 2.0; x")
+	if f, ok := float.(*object.Float); !ok || f.Inspect() != "3.0" {
+		t.Errorf("pooled float was reused. got=%s", float.Inspect())
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)"
+	testIntegerObject(t, testEval(input), 100000)
+
+	// Nesting is bounded by MaxDepth rather than by the goroutine stack
+	nested := strings.Repeat("-(", 200000) + "1" + strings.Repeat(")", 200000)
+	testIntegerObject(t, testEval(nested), 1)
+
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 1000
+
+	evaluated := testEval("let f = fn() { f() }; f()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "maximum evaluation depth exceeded (1000)" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if depth := Default().depth; depth != 0 {
+		t.Errorf("depth not restored after error. got=%d", depth)
+	}
+}
+
+func TestDeepPanic(t *testing.T) {
+	crash := errors.New("crash")
+	in := New(Options{Builtins: map[string]*object.Builtin{
+		"crash": {Fn: func(args ...object.Object) object.Object { panic(crash) }},
+	}})
+	program := parser.New(lexer.New("let f = fn(n) { if (n == 0) { crash() } else { f(n - 1) } }; f(20000)")).ParseProgram()
+
+	defer func() {
+		// Evaluation runs on the caller's goroutine, so the panic reaches
+		// it as it was raised
+		if r := recover(); r != crash {
+			t.Fatalf("expected the value raised, got %T (%v)", r, r)
+		}
+		if in.depth != 0 || in.currentFile != "" {
+			t.Errorf("evaluation state not restored after panic. depth=%d, file=%q", in.depth, in.currentFile)
+		}
+	}()
+	Eval(program, in.NewEnvironment())
+	t.Fatal("crash did not panic")
+}
+
+func TestLazyImport(t *testing.T) {
+	dir := t.TempDir()
+	module := `setenv("ONEY_TEST_MODULE", "loaded"); let double = fn(x) { x * 2 };`
+	if err := os.WriteFile(filepath.Join(dir, "util.1y"), []byte(module), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := `let x = 1; missing();`
+	if err := os.WriteFile(filepath.Join(dir, "broken.1y"), []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ONEY_TEST_MODULE", "")
+	path := filepath.Join(dir, "util")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import("` + path + `"); getenv("ONEY_TEST_MODULE")`, ""},
+		{`let m = import("` + path + `"); m.double(4)`, 8},
+		{`let m = import("` + path + `"); m.double; getenv("ONEY_TEST_MODULE")`, "loaded"},
+		{`let m = import("` + path + `"); m["double"](5)`, 10},
+		{`let m = import("` + path + `"); load(m).double(1)`, 2},
+		{`import("` + filepath.Join(dir, "broken") + `").x`, "identifier not found: missing"},
+		{`load(1)`, "argument to `load` must be MODULE, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		os.Setenv("ONEY_TEST_MODULE", "")
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: wrong value. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected result %T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	input := `let fib = fn(n) {
+	if (n < 2) { return n; }
+	fib(n - 1) + fib(n - 2)
+};
+let apply = fn(f, x) { f(x) };
+apply(fn(x) { x }, fib(10));`
+
+	p := StartProfile()
+	testEval(input)
+	StopProfile()
+
+	calls := map[string]int{}
+	for _, entry := range p.Functions {
+		calls[entry.Name] = entry.Calls
+	}
+	expected := map[string]int{"fib (line 1)": 177, "apply (line 5)": 1, "f (line 6)": 1}
+	for name, count := range expected {
+		if calls[name] != count {
+			t.Errorf("wrong call count for %q. expected=%d, got=%d (%v)", name, count, calls[name], calls)
+		}
+	}
+
+	// Line 2 also counts its return statements, line 6 the body of fn(x) { x }
+	lines := map[int]int{1: 1, 2: 266, 3: 88, 5: 2, 6: 2}
+	for line, count := range lines {
+		if entry := p.Lines[line]; entry == nil || entry.Calls != count {
+			t.Errorf("wrong count for line %d. expected=%d, got=%+v", line, count, entry)
+		}
+	}
+	if entry := p.Lines[6]; entry.Time < p.Lines[2].Time {
+		t.Errorf("line time should include the calls it makes. line 6=%v, line 2=%v", entry.Time, p.Lines[2].Time)
+	}
+
+	testEval("let f = fn() { 1 }; f()")
+	if len(p.Functions) != 3 {
+		t.Errorf("calls after StopProfile were recorded")
+	}
+}
+
+func TestStats(t *testing.T) {
+	input := `let f = fn(x) { x };
+f(1);
+f(2);
+for (let i = 0; i < 3; i++) { let s = "a" + "b"; }
+[1, 2];`
+
+	s := StartStats()
+	testEval(input)
+	StopStats()
+
+	if s.Nodes["ForStatement"] != 1 || s.Nodes["CallExpression"] != 2 {
+		t.Errorf("wrong node counts. got=%v", s.Nodes)
+	}
+	// Three iterations each make two string literals and their concatenation
+	if s.Objects[object.STRING_OBJ] != 9 || s.Objects[object.ARRAY_OBJ] != 1 || s.Objects[object.BOOLEAN_OBJ] != 0 {
+		t.Errorf("wrong object counts. got=%v", s.Objects)
+	}
+	// The loop and its body have one environment each, as the body's is
+	// reused for every iteration
+	if s.Environments != 4 || s.PooledFrames != 2 {
+		t.Errorf("wrong environment counts. got=%d (%d pooled)", s.Environments, s.PooledFrames)
+	}
+
+	var out strings.Builder
+	s.Report(&out)
+	if !strings.Contains(out.String(), "garbage collections") {
+		t.Errorf("report without memory statistics:\n%s", out.String())
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input        string
+		line, column int
+	}{
+		{"1 + true", 1, 3},
+		{"let x = 1;\nlet y = x + foo;\ny", 2, 13},
+		{"let f = fn(a) {\n  a / 0\n};\nf(1)", 2, 5},
+		{"if (true) {\n  -true\n}", 2, 3},
+		{"len(1, 2)", 1, 4},
+	}
+
+	for _, tt := range tests {
+		program, errors := parser.Parse([]byte(tt.input))
+		if len(errors) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, errors)
+		}
+		program.File = "test.1y"
+
+		errObj, ok := Eval(program, NewEnvironment()).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+		if errObj.File != "test.1y" || errObj.Line != tt.line || errObj.Column != tt.column {
+			t.Errorf("wrong position for %q. expected=test.1y:%d:%d, got=%s:%d:%d",
+				tt.input, tt.line, tt.column, errObj.File, errObj.Line, errObj.Column)
+		}
+	}
+
+	// Code run by eval has positions of its own
+	errObj, ok := testEval(`let x = 1; eval("x + foo")`).(*object.Error)
+	if !ok || errObj.File != "<eval>" || errObj.Line != 1 || errObj.Column != 5 {
+		t.Errorf("wrong position for an error in eval. got=%+v", errObj)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	input := `let double = fn(x) { x * 2 };
+let f = fn(x) {
+  let y = double(x);
+  y + 1
+};
+double(5);
+f(3);`
+
+	tests := []struct {
+		function string
+		expected string
+	}{
+		{"", `line 1 [depth 0] let double = fn(x); => fn(x) { (x * 2) }
+line 2 [depth 0] let f = fn(x); => fn(x) { let y = double(x);(y + 1) }
+call double(5)
+  line 1 [depth 1] (x * 2) => 10
+double returned 10
+line 6 [depth 0] double(5) => 10
+call f(3)
+  call double(3)
+    line 1 [depth 1] (x * 2) => 6
+  double returned 6
+  line 3 [depth 1] let y = double(x); => 6
+  line 4 [depth 1] (y + 1) => 7
+f returned 7
+line 7 [depth 0] f(3) => 7
+`},
+		{"f", `call f(3)
+  call double(3)
+    line 1 [depth 1] (x * 2) => 6
+  double returned 6
+  line 3 [depth 1] let y = double(x); => 6
+  line 4 [depth 1] (y + 1) => 7
+f returned 7
+`},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		StartTrace(&out, tt.function)
+		testEval(input)
+		StopTrace()
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong trace for function %q.\nexpected:\n%s\ngot:\n%s", tt.function, tt.expected, out.String())
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	defer ClearInterrupt()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		Interrupt()
+	}()
+	result := testEval("let i = 0; while (true) { i += 1 }")
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "interrupted" {
+		t.Fatalf("expected interrupted error, got %v", result)
+	}
+
+	ClearInterrupt()
+	testIntegerObject(t, testEval("1 + 1"), 2)
+}
+
+func TestTimeout(t *testing.T) {
+	defer ClearInterrupt()
+
+	stop := Timeout(10 * time.Millisecond)
+	defer stop()
+	result := testEval("while (true) { 1 }")
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "timeout: execution took longer than 10ms" {
+		t.Fatalf("expected timeout error, got %v", result)
+	}
+
+	// A stopped timeout does not fire
+	ClearInterrupt()
+	Timeout(10 * time.Millisecond)()
+	time.Sleep(20 * time.Millisecond)
+	testIntegerObject(t, testEval("1 + 1"), 2)
+}
+
+func TestSandbox(t *testing.T) {
+	SetSandbox(true)
+	defer SetSandbox(false)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import("demo")`, "`import` is not allowed in sandbox mode"},
+		{`getenv("HOME")`, "`getenv` is not allowed in sandbox mode"},
+		{`setenv("A", "b")`, "`setenv` is not allowed in sandbox mode"},
+		{`exit(1)`, "`exit` is not allowed in sandbox mode"},
+		{`eval("exit()")`, "`exit` is not allowed in sandbox mode"},
+		{`len("abc")`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong result for %q. expected error %q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestInterpreters(t *testing.T) {
+	untrusted := New(Options{Sandbox: true, Args: []string{"a"}})
+	trusted := New(Options{Args: []string{"b", "c"}, Builtins: map[string]*object.Builtin{
+		"answer": newBuiltin(func(args ...object.Object) object.Object { return int64Object(42) }),
+	}})
+	run := func(in *Interpreter, input string) object.Object {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return Eval(program, in.NewEnvironment())
+	}
+
+	tests := []struct {
+		in       *Interpreter
+		input    string
+		expected string
+	}{
+		{untrusted, `import("demo")`, "`import` is not allowed in sandbox mode"},
+		{untrusted, `eval("exit(1)", {"x": 1})`, "`exit` is not allowed in sandbox mode"},
+		{untrusted, `args()[0] + ARGS[0]`, "aa"},
+		{untrusted, `answer()`, "identifier not found: answer"},
+		{trusted, `eval("args()[1]", {"x": 1})`, "c"},
+		{trusted, `answer() + len(ARGS)`, "44"},
+		{trusted, `getenv("ONE_Y_TEST_UNSET_VAR", 1)`, "1"},
+	}
+	for _, tt := range tests {
+		evaluated := run(tt.in, tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if env := untrusted.NewEnvironment(); InterpreterOf(env) != untrusted || !InterpreterOf(env).Sandboxed() {
+		t.Errorf("environment not owned by its interpreter")
+	}
+	if Sandboxed() || InterpreterOf(object.NewEnvironment()).Sandboxed() {
+		t.Errorf("sandboxed interpreter changed the default")
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	limited := New(Options{Limits: Limits{Steps: 5000}})
+	var calls int
+	hooked := New(Options{Hooks: &Hooks{OnCall: func(string, object.Object, []object.Object) { calls++ }}})
+	interrupted := New(Options{})
+
+	var wg sync.WaitGroup
+	var limitErr, hookedErr, interruptErr error
+	var hookedResult object.Object
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		_, limitErr = limited.Exec(nil, "limited.1y", "let n = 0;\nwhile (true) { n = n + 1 }")
+	}()
+	go func() {
+		defer wg.Done()
+		src := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };\nfib(15) + missing"
+		hookedResult, hookedErr = hooked.Exec(nil, "hooked.1y", src)
+	}()
+	go func() {
+		defer wg.Done()
+		stop := interrupted.Timeout(20 * time.Millisecond)
+		defer stop()
+		_, interruptErr = interrupted.Exec(nil, "", "while (true) {}")
+	}()
+	wg.Wait()
+
+	if rerr, ok := limitErr.(*RuntimeError); !ok || rerr.Kind != ErrorLimit || rerr.File != "limited.1y" {
+		t.Errorf("wrong error from the limited interpreter. got=%+v", limitErr)
+	}
+	if rerr, ok := hookedErr.(*RuntimeError); !ok || rerr.Error() != "hooked.1y:2:11: identifier not found: missing" {
+		t.Errorf("wrong error from the hooked interpreter. got=%v, %+v", hookedResult, hookedErr)
+	}
+	if calls != 1973 {
+		t.Errorf("wrong number of calls hooked. got=%d", calls)
+	}
+	if rerr, ok := interruptErr.(*RuntimeError); !ok || rerr.Kind != ErrorInterrupt {
+		t.Errorf("wrong error from the interrupted interpreter. got=%+v", interruptErr)
+	}
+
+	if usage := limited.Usage(); usage.Steps != 5001 {
+		t.Errorf("wrong usage of the limited interpreter. got=%+v", usage)
+	}
+	for _, in := range []*Interpreter{limited, hooked, interrupted, Default()} {
+		if in.depth != 0 || in.currentFile != "" {
+			t.Errorf("evaluation state not restored. depth=%d, file=%q", in.depth, in.currentFile)
+		}
+	}
+	if result, err := hooked.Exec(nil, "", "1 + 1"); err != nil || result.Inspect() != "2" {
+		t.Errorf("interruption of another interpreter stopped this one. got=%v, %v", result, err)
+	}
+}
+
+func TestConcurrentStringIndexing(t *testing.T) {
+	// Interned literals are shared by every interpreter, so indexing one in
+	// several at once must not race on the offsets it keeps
+	var wg sync.WaitGroup
+	results := make([]object.Object, 4)
+	errs := make([]error, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = New(Options{}).Exec(nil, "", `let s = "你好世界"; s[1] + s[3]`)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if errs[i] != nil || result.Inspect() != "好界" {
+			t.Errorf("wrong result from interpreter %d. got=%v, %v", i, result, errs[i])
+		}
+	}
+}
+
+func TestInterpreterStreams(t *testing.T) {
+	var out, errOut bytes.Buffer
+	in := New(Options{Stdout: &out, Stderr: &errOut, Stdin: strings.NewReader("first\nsecond\n")})
+
+	program := parser.New(lexer.New(`puts(1, "a"); print("b"); eprint("oops"); let x = input("? "); pprint([x, prompt("name: ")])`)).ParseProgram()
+	if result := Eval(program, in.NewEnvironment()); isError(result) {
+		t.Fatalf("program failed: %s", result.Inspect())
+	}
+
+	if expected := "1 a\nb? name: [\n  \"first\",\n  \"second\"\n]\n"; out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+	if errOut.String() != "oops" {
+		t.Errorf("wrong error output. got=%q", errOut.String())
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+		output   string
+	}{
+		{`prompt("name: ")`, "Ada\n", "Ada", "name: "},
+		{`prompt("name: ")`, "Ada\r\nrest\n", "Ada", "name: "},
+		{`prompt("name: ")`, "Ada", "Ada", "name: "},
+		{`prompt("name: ", "nobody")`, "\n", "nobody", "name: "},
+		{`prompt("name: ", "nobody")`, "\r\n", "nobody", "name: "},
+		{`prompt("port: ", 8080)`, "\n", "8080", "port: "},
+		{`prompt("password: ", "", true)`, "secret\n", "secret", "password: "},
+		{`prompt("name: ")`, "", "null", "name: "},
+		{`prompt("name: ", "nobody")`, "", "null", "name: "},
+		{`[prompt("a: "), prompt("b: "), prompt("c: ")]`, "1\r\n2\n", `["1", "2", null]`, "a: b: c: "},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		in := New(Options{Stdout: &out, Stdin: strings.NewReader(tt.stdin)})
+		result := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), in.NewEnvironment())
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s with input %q = %s, want %s", tt.input, tt.stdin, result.Inspect(), tt.expected)
+		}
+		if out.String() != tt.output {
+			t.Errorf("%s wrote %q, want %q", tt.input, out.String(), tt.output)
+		}
+	}
+}
+
+func TestPromptErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`prompt()`, "wrong number of arguments. got=0, want=1 to 3"},
+		{`prompt("a", "b", true, 1)`, "wrong number of arguments. got=4, want=1 to 3"},
+		{`prompt(1)`, "argument to `prompt` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		in := New(Options{Stdout: &bytes.Buffer{}, Stdin: strings.NewReader("x\n")})
+		errObj, ok := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), in.NewEnvironment()).(*object.Error)
+		if !ok {
+			t.Errorf("%s did not fail", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s failed with %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := strings.NewReader("one\r\ntwo\n\nlast")
+	for _, expected := range []string{"one", "two", "", "last"} {
+		line, err := readLine(r)
+		if err != nil || line != expected {
+			t.Errorf("readLine() = %q, %v, want %q", line, err, expected)
+		}
+	}
+	if line, err := readLine(r); err != io.EOF || line != "" {
+		t.Errorf("readLine() at the end = %q, %v, want io.EOF", line, err)
+	}
+
+	// Nothing past the line is consumed, so other readers of the input
+	// continue where prompt stopped
+	r = strings.NewReader("first\nsecond\n")
+	readLine(r)
+	if r.Len() != len("second\n") {
+		t.Errorf("readLine consumed %d bytes past the line", len("second\n")-r.Len())
+	}
+}
+
+func TestModuleLoaders(t *testing.T) {
+	files := fstest.MapFS{
+		"util.1y":        {Data: []byte("let double = fn(x) { x * 2 };")},
+		"geo/index.1y":   {Data: []byte("let pi = 3;")},
+		"lib/strings.1y": {Data: []byte("let twice = fn(s) { s + s };")},
+	}
+	server := httptest.NewServer(http.FileServer(http.FS(files)))
+	defer server.Close()
+
+	tests := []struct {
+		loader   ModuleLoader
+		input    string
+		expected interface{}
+	}{
+		{MapLoader{"util.1y": []byte("let x = 7;")}, `import("util").x`, 7},
+		{MapLoader{"util": []byte("let x = 8;"), "util.1y": []byte("let x = 7;")}, `import("util").x`, 8},
+		{MapLoader{}, `import("util")`, "no module util"},
+		{FSLoader{files}, `import("util").double(21)`, 42},
+		{FSLoader{files}, `import("geo").pi`, 3},
+		{FSLoader{files}, `import("lib/strings.1y").twice("ab")`, "abab"},
+		{FSLoader{files}, `import("../util")`, "no module ../util"},
+		{HTTPLoader{}, `import("` + server.URL + `/util").double(4)`, 8},
+		{HTTPLoader{}, `import("` + server.URL + `/missing")`, "could not fetch " + server.URL + "/missing.1y: 404 Not Found"},
+		{HTTPLoader{}, `import("util")`, "module util is not an http or https URL"},
+	}
+
+	for _, tt := range tests {
+		env := New(Options{Loader: tt.loader}).NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+			} else if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestSandboxedImports(t *testing.T) {
+	loader := MapLoader{"util.1y": []byte("let x = 7;")}
+	tests := []struct {
+		options  Options
+		input    string
+		expected interface{}
+	}{
+		{Options{Sandbox: true, Loader: loader}, `import("util").x`, 7},
+		{Options{Sandbox: true, Loader: loader}, `import("util.so")`, "`import` is not allowed in sandbox mode"},
+		{Options{Sandbox: true}, `import("util")`, "`import` is not allowed in sandbox mode"},
+		{Options{Sandbox: true}, `import(1)`, "import path must be a string, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		env := New(tt.options).NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); !ok || err.Message != expected {
+				t.Errorf("%s: expected error %q, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	in := New(Options{})
+	src := "let inner = fn(x) { x + true };\nlet outer = fn() { inner(1) };\nouter()"
+	_, err := in.Exec(nil, "test.1y", src)
+	rerr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not a *RuntimeError. got=%T (%v)", err, err)
+	}
+	if rerr.Kind != ErrorRuntime || rerr.Message != "type mismatch: INTEGER + BOOLEAN" || rerr.Code != "type-mismatch" {
+		t.Errorf("wrong error. got=%+v", rerr)
+	}
+	if rerr.Error() != "test.1y:1:23: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong message. got=%q", rerr.Error())
+	}
+	stack := fmt.Sprint(rerr.Stack)
+	if stack != "[{inner test.1y 2 20} {outer test.1y 3 1}]" {
+		t.Errorf("wrong stack. got=%s", stack)
+	}
+
+	_, err = in.Exec(nil, "", "let = 1")
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorSyntax || rerr.Line != 1 || rerr.Column != 5 {
+		t.Errorf("wrong syntax error. got=%+v", err)
+	}
+
+	src = "let check = fn(x) {\n  if (x < 0) { raise(\"negative\") }\n  x\n};\ncheck(-1)"
+	_, err = in.Exec(nil, "test.1y", src)
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorRuntime || rerr.Error() != "test.1y:2:21: negative" {
+		t.Errorf("wrong raised error. got=%+v", err)
+	}
+	_, err = in.Exec(nil, "", `error("io", "disk full"); 1`)
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != "io" || rerr.Message != "disk full" {
+		t.Errorf("wrong error from error(). got=%+v", err)
+	}
+	for _, kind := range []string{ErrorSyntax, ErrorLimit, ErrorInterrupt} {
+		_, err = in.Exec(nil, "", fmt.Sprintf(`error(%q, "fake")`, kind))
+		expected := fmt.Sprintf("kind %q given to `error` is reserved for the interpreter", kind)
+		if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorRuntime || rerr.Message != expected {
+			t.Errorf("error(%q) was not refused. got=%+v", kind, err)
+		}
+	}
+	_, err = in.Exec(nil, "", `error("runtime", "plain")`)
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorRuntime || rerr.Message != "plain" {
+		t.Errorf("wrong error from error(\"runtime\"). got=%+v", err)
+	}
+	_, err = in.Exec(nil, "", "raise(1)")
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Message != "argument to `raise` must be STRING, got INTEGER" {
+		t.Errorf("wrong error for raise(1). got=%+v", err)
+	}
+
+	if result, err := in.Exec(nil, "", "1 + 2"); err != nil || result.Inspect() != "3" {
+		t.Errorf("wrong result. got=%v, %v", result, err)
+	}
+	if ErrorOf(NULL) != nil {
+		t.Errorf("ErrorOf(NULL) is not nil")
+	}
+
+	in.SetLimits(Limits{Steps: 100})
+	_, err = in.Exec(nil, "", "while (true) {}")
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != ErrorLimit {
+		t.Errorf("wrong limit error. got=%+v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defer SetLimits(Limits{})
+
+	tests := []struct {
+		limits   Limits
+		input    string
+		expected string
+	}{
+		{Limits{Steps: 1000}, "while (true) { 1 }", "resource limit exceeded: more than 1000 evaluation steps"},
+		{Limits{Objects: 100}, "let a = []; for (let i = 0; i < 1000; i++) { a = a + [i]; } len(a)", "resource limit exceeded: more than 100 objects"},
+		{Limits{Collection: 50}, `let s = ""; while (true) { s = s + "x"; }`, "resource limit exceeded: collection of 51 elements is larger than 50"},
+		{Limits{Collection: 50}, "[1, 2] * 1000000000000", "resource limit exceeded: repeating 2 elements 1000000000000 times is larger than 50"},
+		{Limits{Collection: 3}, `let h = {}; let f = fn(h) { {1: 1, 2: 2, 3: 3, 4: 4} }; f(h)`, "resource limit exceeded: collection of 4 elements is larger than 3"},
+	}
+
+	for _, tt := range tests {
+		SetLimits(tt.limits)
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+
+	SetLimits(Limits{Memory: 64 << 20})
+	evaluated := testEval("let a = []; while (true) { a = a + [[0] * 100000]; }")
+	if errObj, ok := evaluated.(*object.Error); !ok || !strings.HasPrefix(errObj.Message, "resource limit exceeded: ") {
+		t.Errorf("memory limit not enforced. got=%v", evaluated)
+	}
+	testIntegerObject(t, testEval("len([0] * 100000)"), 100000)
+
+	SetLimits(Limits{Steps: 1000, Objects: 100, Collection: 10})
+	testIntegerObject(t, testEval("let a = [1, 2, 3]; len(a * 3)"), 9)
+	if usage := CurrentUsage(); usage.Steps == 0 || usage.Objects == 0 {
+		t.Errorf("usage not counted. got=%+v", usage)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	defer SetHooks(nil)
+
+	var entered, exited int
+	var calls []string
+	SetHooks(&Hooks{
+		OnNodeEnter: func(node ast.Node, env *object.Environment) { entered++ },
+		OnNodeExit:  func(node ast.Node, env *object.Environment, result object.Object) { exited++ },
+		OnCall: func(name string, fn object.Object, args []object.Object) {
+			calls = append(calls, fmt.Sprintf("call %s(%d)", name, len(args)))
+		},
+		OnReturn: func(name string, fn object.Object, result object.Object) {
+			calls = append(calls, fmt.Sprintf("%s returned %s", name, result.Inspect()))
+		},
+	})
+	testIntegerObject(t, testEval("let double = fn(x) { x * 2 }; double(len([1, 2]))"), 4)
+
+	if entered == 0 || entered != exited {
+		t.Errorf("nodes entered and exited do not match. entered=%d, exited=%d", entered, exited)
+	}
+	expected := []string{"call len(1)", "len returned 2", "call double(1)", "double returned 4"}
+	if strings.Join(calls, "; ") != strings.Join(expected, "; ") {
+		t.Errorf("wrong calls. expected=%q, got=%q", expected, calls)
+	}
+}
+
+func TestHooksKeepResults(t *testing.T) {
+	defer SetHooks(nil)
+
+	var kept []object.Object
+	SetHooks(&Hooks{
+		OnNodeExit: func(node ast.Node, env *object.Environment, result object.Object) {
+			if _, ok := node.(*ast.InfixExpression); ok {
+				kept = append(kept, result)
+			}
+		},
+	})
+	testEval("let x = (5000 + 5000) * 2; for (let i = 0; i < 100; i++) { x = (i + 100000) * 3 + 1; }")
+
+	if len(kept) < 2 || kept[0].Inspect() != "10000" || kept[1].Inspect() != "20000" {
+		t.Errorf("results kept by a hook were reused. got=%v", kept[:2])
+	}
+}
+
+func TestImportPlugin(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.so")
+	if err := os.WriteFile(bad, []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import("missing.so")`, "could not read file: missing.so"},
+		{`import("` + bad + `")`, "loading plugin " + bad + " failed: "},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || !strings.HasPrefix(errObj.Message, tt.expected) {
+			t.Errorf("wrong result for %s. expected error %q, got=%v", tt.input, tt.expected, testEval(tt.input))
+		}
+	}
+}
+
+func TestImportTimes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.1y"), []byte(`let x = 1;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "a")
+
+	times := StartImportTimes()
+	testEval(`let a = import("` + path + `"); let b = import("` + path + `"); a.x`)
+	StopImportTimes()
+	testEval(`import("` + path + `").x`)
+
+	if len(times.Modules) != 2 {
+		t.Fatalf("wrong number of modules timed. got=%d, want=2", len(times.Modules))
+	}
+	for i, m := range times.Modules {
+		if m.Path != path+".1y" || m.Parse <= 0 {
+			t.Errorf("module %d: wrong time %+v", i, m)
+		}
+	}
+	if times.Modules[0].Run <= 0 || times.Modules[1].Run != 0 {
+		t.Errorf("wrong run times: %v and %v, only the first module ran", times.Modules[0].Run, times.Modules[1].Run)
+	}
+}
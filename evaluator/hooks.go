@@ -0,0 +1,72 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+)
+
+// Hooks are functions called as a program is evaluated, so that tools such
+// as debuggers, tracers and coverage reports can be built outside this
+// package. Any of them may be nil.
+//
+// The objects hooks are passed stay valid for as long as hooks keep them:
+// while hooks are set, the interpreter does not reuse the values of
+// arithmetic subexpressions as it otherwise does.
+type Hooks struct {
+	// OnNodeEnter is called before node is evaluated in env, and OnNodeExit
+	// once it has been, with its result. Nodes that fail before they start,
+	// by exceeding MaxDepth or a limit, are left out.
+	OnNodeEnter func(node ast.Node, env *object.Environment)
+	OnNodeExit  func(node ast.Node, env *object.Environment, result object.Object)
+
+	// OnCall is called before fn is called with args, and OnReturn once it
+	// has returned result. name is the function as the call writes it, such
+	// as "f" or "Math.sqrt", or "" for calls made by builtins through
+	// ApplyFunction.
+	OnCall   func(name string, fn object.Object, args []object.Object)
+	OnReturn func(name string, fn object.Object, result object.Object)
+}
+
+// SetHooks makes the interpreter call the functions of h as it evaluates,
+// until it is called again with nil. Hooks slow evaluation down even when
+// they do little.
+func (in *Interpreter) SetHooks(h *Hooks) {
+	in.hooks = h
+}
+
+// SetHooks sets the hooks of the default interpreter, and of those later
+// created with its options.
+func SetHooks(h *Hooks) {
+	defaultInterpreter.options.Hooks = h
+	defaultInterpreter.SetHooks(h)
+}
+
+// call calls fn with args in interpreter in, as the call named name,
+// between OnCall and OnReturn.
+func (h *Hooks) call(in *Interpreter, name string, fn object.Object, args []object.Object) object.Object {
+	if h.OnCall != nil {
+		h.OnCall(name, fn, args)
+	}
+	var result object.Object
+	if in.trace != nil && name != "" {
+		result = in.trace.call(in, name, fn, args)
+	} else {
+		result = in.applyFunction(fn, args)
+	}
+	if h.OnReturn != nil {
+		h.OnReturn(name, fn, result)
+	}
+	return result
+}
+
+// callNamed calls fn with args as the call named name, between the hooks
+// and in the trace of the interpreter if it has them.
+func (in *Interpreter) callNamed(name string, fn object.Object, args []object.Object) object.Object {
+	if in.hooks != nil {
+		return in.hooks.call(in, name, fn, args)
+	}
+	if in.trace != nil {
+		return in.trace.call(in, name, fn, args)
+	}
+	return in.applyFunction(fn, args)
+}
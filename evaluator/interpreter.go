@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"1ylang/object"
+	"1ylang/vfs"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Options configure an interpreter created by New.
+type Options struct {
+	// Sandbox forbids programs to import files or call the builtins that
+	// reach outside the interpreter, as SetSandbox does. Modules may still
+	// be imported through a Loader given below.
+	Sandbox bool
+
+	// Args are returned by the args builtin and held by ARGS.
+	Args []string
+
+	// Builtins are predeclared next to the standard builtins, replacing
+	// those of the same names.
+	Builtins map[string]*object.Builtin
+
+	// Loader finds and reads the modules programs import. If it is nil,
+	// they are loaded from the bundle set by SetBundle or from files.
+	Loader ModuleLoader
+
+	// FS is the file system the File and Glob libraries work on, the one
+	// of the operating system if nil. Giving a sandboxed interpreter one,
+	// such as a vfs.Dir or vfs.Mem, lets its programs use files without
+	// reaching any others.
+	FS vfs.FS
+
+	// Stdout, Stderr and Stdin are the streams builtins such as puts, eprint
+	// and input use. Those left nil are the package's Stdout, Stderr and
+	// Stdin at the time of use.
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Limits bound the resources the interpreter's programs may use, as
+	// set later by Interpreter.SetLimits.
+	Limits Limits
+
+	// Hooks, if not nil, are called as the interpreter's programs are
+	// evaluated, as set later by Interpreter.SetHooks.
+	Hooks *Hooks
+
+	// The trace, profile and statistics started on the default interpreter
+	// by the package's StartTrace, StartProfile and StartStats, so that
+	// interpreters created with its options record into them too
+	trace   *Trace
+	profile *Profile
+	stats   *Stats
+}
+
+// Interpreter holds the builtins and settings shared by the global
+// environments it creates. Several interpreters configured differently,
+// such as one sandboxed for untrusted code and one not, can run programs in
+// the same process: a program, the modules it imports and the code it passes
+// to eval all use the interpreter of the environment they run in.
+//
+// An interpreter also holds the state of the program it runs: its limits
+// and usage, hooks, trace, profile and statistics. Interpreters run
+// concurrently, each on its own goroutine, but one interpreter runs one
+// program at a time.
+type Interpreter struct {
+	options  Options
+	universe *object.Environment // root frame of the global environments
+
+	depth       int    // nodes being evaluated, checked against MaxDepth
+	currentFile string // see Interpreter.Eval
+
+	limits      Limits
+	usage       Usage
+	stopMonitor func()
+	overMemory  atomic.Bool // set by the monitor when the heap seems too big
+
+	// interruption is set to the message of the error that stops the
+	// evaluation in progress, by Interrupt or when a timeout expires
+	interruption atomic.Pointer[string]
+
+	hooks       *Hooks
+	trace       *Trace
+	profile     *Profile
+	stats       *Stats
+	importTimes *ImportTimes
+
+	// exitHooks run when the interpreter is closed. The default interpreter
+	// registers them with the package's AtExit instead, as it lasts as long
+	// as the process.
+	exitMu    sync.Mutex
+	exitHooks []func()
+	isDefault bool
+}
+
+// New creates an interpreter configured by options.
+func New(options Options) *Interpreter {
+	in := &Interpreter{options: options}
+	in.options.Args = append([]string(nil), options.Args...)
+	in.options.Builtins = make(map[string]*object.Builtin, len(options.Builtins))
+	for name, builtin := range options.Builtins {
+		in.options.Builtins[name] = builtin
+	}
+	in.universe = object.NewRootEnvironment(in, in.rootNames())
+	in.SetLimits(options.Limits)
+	in.hooks = options.Hooks
+	in.trace = options.trace
+	in.profile = options.profile
+	in.stats = options.stats
+	return in
+}
+
+// defaultInterpreter is the interpreter of environments made by
+// NewEnvironment, configured by SetSandbox and SetArgs.
+var defaultInterpreter *Interpreter
+
+// reconfigureDefault replaces the default interpreter with one configured by
+// options, which carry its limits, hooks and recordings over.
+func reconfigureDefault(options Options) {
+	if defaultInterpreter != nil {
+		defaultInterpreter.SetLimits(Limits{})
+	}
+	defaultInterpreter = New(options)
+	defaultInterpreter.isDefault = true
+}
+
+// Default returns the interpreter of environments made by NewEnvironment.
+func Default() *Interpreter {
+	return defaultInterpreter
+}
+
+// InterpreterOf returns the interpreter env was created by, or the default
+// interpreter for environments made without one, such as with
+// object.NewEnvironment.
+func InterpreterOf(env *object.Environment) *Interpreter {
+	if in, ok := env.Owner().(*Interpreter); ok {
+		return in
+	}
+	return defaultInterpreter
+}
+
+// NewEnvironment creates an empty global environment in which the builtins
+// of the interpreter are defined.
+func (in *Interpreter) NewEnvironment() *object.Environment {
+	return object.NewEnclosedEnvironment(in.universe)
+}
+
+// Options returns the options the interpreter was created with, such as to
+// create another one like it.
+func (in *Interpreter) Options() Options {
+	return in.options
+}
+
+// AtExit registers fn to run when the interpreter is closed, such as to
+// remove the temporary files its programs created. For the default
+// interpreter, fn runs when the process exits, as with the package's AtExit.
+func (in *Interpreter) AtExit(fn func()) {
+	if in.isDefault {
+		AtExit(fn)
+		return
+	}
+	in.exitMu.Lock()
+	defer in.exitMu.Unlock()
+	in.exitHooks = append(in.exitHooks, fn)
+}
+
+// Close runs the functions registered with AtExit, most recent first. The
+// interpreter can still run programs afterwards.
+func (in *Interpreter) Close() {
+	in.exitMu.Lock()
+	hooks := in.exitHooks
+	in.exitHooks = nil
+	in.exitMu.Unlock()
+	runHooks(hooks)
+}
+
+// Sandboxed reports whether the interpreter runs programs in sandbox mode.
+func (in *Interpreter) Sandboxed() bool {
+	return in.options.Sandbox
+}
+
+// Loader returns the loader of the modules the interpreter's programs
+// import.
+func (in *Interpreter) Loader() ModuleLoader {
+	if in.options.Loader != nil {
+		return in.options.Loader
+	}
+	return defaultLoader{}
+}
+
+// FS returns the file system the interpreter's programs use files on.
+func (in *Interpreter) FS() vfs.FS {
+	if in.options.FS != nil {
+		return in.options.FS
+	}
+	return vfs.OS{}
+}
+
+// Stdout returns the stream the interpreter's programs print to.
+func (in *Interpreter) Stdout() io.Writer {
+	if in.options.Stdout != nil {
+		return in.options.Stdout
+	}
+	return Stdout
+}
+
+// Stderr returns the stream the interpreter's programs print errors to.
+func (in *Interpreter) Stderr() io.Writer {
+	if in.options.Stderr != nil {
+		return in.options.Stderr
+	}
+	return Stderr
+}
+
+// Stdin returns the stream the interpreter's programs read input from.
+func (in *Interpreter) Stdin() io.Reader {
+	if in.options.Stdin != nil {
+		return in.options.Stdin
+	}
+	return Stdin
+}
+
+// rootNames returns the names predeclared in every global environment: the
+// builtins and ARGS. In sandbox mode, unsafe builtins fail when called.
+func (in *Interpreter) rootNames() map[string]object.Object {
+	names := make(map[string]object.Object, len(builtins)+len(streamBuiltins)+len(in.options.Builtins)+1)
+	for name, builtin := range builtins {
+		names[name] = builtin
+	}
+	for name, fn := range streamBuiltins {
+		names[name] = newBuiltin(func(args ...object.Object) object.Object {
+			return fn(in, args...)
+		})
+	}
+	for name, builtin := range in.options.Builtins {
+		names[name] = builtin
+	}
+	names["ARGS"] = in.argsArray()
+	if in.options.Sandbox {
+		for _, name := range unsafeBuiltins {
+			names[name] = forbidden(name)
+		}
+	}
+	return names
+}
+
+// argsArray returns the script arguments as an array of strings.
+func (in *Interpreter) argsArray() *object.Array {
+	elements := make([]object.Object, len(in.options.Args))
+	for i, arg := range in.options.Args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,236 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bounds the resources a program may use, so untrusted code cannot
+// run forever or exhaust memory. A zero field means no limit. Exceeding a
+// limit makes evaluation return a "resource limit exceeded" error.
+type Limits struct {
+	Steps      int // AST nodes evaluated
+	Objects    int // objects created by literals, operators and calls
+	Collection int // elements in one array or hash, or bytes in one string
+	Memory     int // bytes of heap in use by the whole process, checked every few milliseconds
+}
+
+// Usage reports the resources counted against the current limits.
+type Usage struct {
+	Steps   int
+	Objects int
+}
+
+// SetLimits replaces the limits of the interpreter's programs and resets
+// the usage counted against them.
+func (in *Interpreter) SetLimits(l Limits) {
+	if in.stopMonitor != nil {
+		in.stopMonitor()
+		in.stopMonitor = nil
+	}
+	in.limits = l
+	in.usage = Usage{}
+	in.overMemory.Store(false)
+	if l.Memory > 0 {
+		in.stopMonitor = monitorMemory(l.Memory, &in.overMemory)
+	}
+}
+
+// Usage returns the resources used since the limits were last set.
+func (in *Interpreter) Usage() Usage {
+	return in.usage
+}
+
+// SetLimits sets the limits of the default interpreter, and of those later
+// created with its options.
+func SetLimits(l Limits) {
+	defaultInterpreter.options.Limits = l
+	defaultInterpreter.SetLimits(l)
+}
+
+// CurrentUsage returns the resources used by the default interpreter since
+// the limits were last set.
+func CurrentUsage() Usage {
+	return defaultInterpreter.Usage()
+}
+
+func limitError(format string, a ...interface{}) *object.Error {
+	err := newError("resource limit exceeded: "+format, a...)
+	err.Kind = ErrorLimit
+	return err
+}
+
+// Interrupt makes the evaluation in progress fail with an "interrupted"
+// error at the next node it evaluates, as when Ctrl+C is pressed. It is
+// safe to call from another goroutine, such as a signal handler. Evaluation
+// keeps failing until ClearInterrupt is called.
+func (in *Interpreter) Interrupt() {
+	in.interruptWith("interrupted")
+}
+
+func (in *Interpreter) interruptWith(message string) {
+	in.interruption.Store(&message)
+}
+
+// ClearInterrupt allows evaluation to run again after Interrupt or a
+// timeout.
+func (in *Interpreter) ClearInterrupt() {
+	in.interruption.Store(nil)
+}
+
+// Interrupted returns the error evaluation fails with after Interrupt or a
+// timeout, or nil if it may go on. Builtins that wait, such as for a file
+// to change, check it so that they can be stopped too.
+func (in *Interpreter) Interrupted() *object.Error {
+	if message := in.interruption.Load(); message != nil {
+		err := newError("%s", *message)
+		err.Kind = ErrorInterrupt
+		return err
+	}
+	return nil
+}
+
+// Timeout interrupts evaluation with a timeout error once d has passed, as
+// Interrupt does. Calling stop before then cancels the timeout.
+func (in *Interpreter) Timeout(d time.Duration) (stop func()) {
+	timer := time.AfterFunc(d, func() {
+		in.interruptWith(fmt.Sprintf("timeout: execution took longer than %v", d))
+	})
+	return func() { timer.Stop() }
+}
+
+// Interrupt interrupts the default interpreter, as Interpreter.Interrupt
+// does.
+func Interrupt() {
+	defaultInterpreter.Interrupt()
+}
+
+// ClearInterrupt lets the default interpreter run again after Interrupt or
+// a timeout.
+func ClearInterrupt() {
+	defaultInterpreter.ClearInterrupt()
+}
+
+// Timeout interrupts the default interpreter once d has passed, as
+// Interpreter.Timeout does.
+func Timeout(d time.Duration) (stop func()) {
+	return defaultInterpreter.Timeout(d)
+}
+
+// memoryInterval is how often the heap is measured against Limits.Memory.
+// Measuring it at each node would slow evaluation down too much.
+const memoryInterval = 5 * time.Millisecond
+
+// monitorMemory measures the heap in the background until stop is called,
+// setting over when it is over limit bytes for the next step to check.
+func monitorMemory(limit int, over *atomic.Bool) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(memoryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if heapInUse() > uint64(limit) {
+					over.Store(true)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// checkMemory reports an error if the heap is over the limit. Garbage counts
+// until it is collected, so it only fails if collecting is not enough.
+func (in *Interpreter) checkMemory() *object.Error {
+	in.overMemory.Store(false)
+	runtime.GC()
+	if heap := heapInUse(); in.limits.Memory > 0 && heap > uint64(in.limits.Memory) {
+		return limitError("%d bytes of memory in use is more than %d", heap, in.limits.Memory)
+	}
+	return nil
+}
+
+// checkStep counts the evaluation of one node.
+func (in *Interpreter) checkStep() *object.Error {
+	if err := in.Interrupted(); err != nil {
+		return err
+	}
+	if in.overMemory.Load() {
+		if err := in.checkMemory(); err != nil {
+			return err
+		}
+	}
+	in.usage.Steps++
+	if in.limits.Steps > 0 && in.usage.Steps > in.limits.Steps {
+		return limitError("more than %d evaluation steps", in.limits.Steps)
+	}
+	return nil
+}
+
+// checkResult counts the object node produced and checks its size.
+func (in *Interpreter) checkResult(node ast.Node, result object.Object) object.Object {
+	if in.limits.Objects > 0 && createsObject(node) {
+		in.usage.Objects++
+		if in.usage.Objects > in.limits.Objects {
+			return limitError("more than %d objects", in.limits.Objects)
+		}
+	}
+	if err := in.checkSize(collectionSize(result)); err != nil {
+		return err
+	}
+	return result
+}
+
+// checkSize reports an error if a collection of the given size is over the
+// limit.
+func (in *Interpreter) checkSize(size int) *object.Error {
+	if in.limits.Collection > 0 && size > in.limits.Collection {
+		return limitError("collection of %d elements is larger than %d", size, in.limits.Collection)
+	}
+	return nil
+}
+
+// checkRepeat checks the size of count copies of a collection before they
+// are made, without overflowing.
+func (in *Interpreter) checkRepeat(size, count int) *object.Error {
+	if in.limits.Collection > 0 && count > 0 && size > in.limits.Collection/count {
+		return limitError("repeating %d elements %d times is larger than %d", size, count, in.limits.Collection)
+	}
+	return nil
+}
+
+func createsObject(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral,
+		*ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral,
+		*ast.PrefixExpression, *ast.InfixExpression, *ast.CallExpression:
+		return true
+	}
+	return false
+}
+
+func collectionSize(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return len(obj.Elements)
+	case *object.String:
+		return len(obj.Value)
+	case *object.Hash:
+		return len(obj.Pairs)
+	}
+	return 0
+}
@@ -0,0 +1,795 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+	"math/big"
+)
+
+// MaxDepth limits how deeply evaluation may nest, counting every node being
+// evaluated, so runaway recursion reports an error instead of exhausting memory.
+var MaxDepth = 1_000_000
+
+// The evaluator keeps the nodes being evaluated on a stack of its own rather
+// than on the goroutine's, whose size is capped by the runtime and fatal to
+// overflow, so how deeply programs nest and recurse is bounded by memory and
+// MaxDepth alone.
+//
+// Each node being evaluated has a frame on the stack recording how far its
+// evaluation has got. Resuming a frame either asks for a child node to be
+// evaluated, pushing a frame for it, or finishes with the value of the node,
+// which the frame below resumes with once the finished frame is popped.
+// Calls of 1y functions push the function's body like any other child.
+// Builtins calling back into 1y functions, such as through ApplyFunction,
+// run an evaluation of their own, as do imported modules when they load.
+type machine struct {
+	in    *Interpreter
+	stack []frame
+
+	// next is the node the frame resumed last asked to be evaluated in
+	// nextEnv, before it resumes again with its value
+	next    ast.Node
+	nextEnv *object.Environment
+}
+
+// frame is the evaluation of one node in progress.
+type frame struct {
+	node ast.Node
+	env  *object.Environment
+	step int // how far the evaluation of node has got, 0 before it starts
+
+	index  int              // statement, branch or element reached
+	values []object.Object  // elements, arguments or indices evaluated so far
+	keys   []ast.Expression // of a hash literal, in the order evaluated
+
+	// first and second are values held while others are evaluated, such
+	// as the operands of an infix expression or the value being assigned,
+	// and container the array, hash or module an assignment stores into
+	first, second, container object.Object
+
+	entry *ProfileEntry // line profiled while the node runs
+
+	file        string // currentFile to restore once a program has run
+	restoreFile bool
+
+	loop *loopFrame // of a for or while loop
+	call *callFrame // of a call of a 1y function
+}
+
+// loopFrame is a for or while loop in progress.
+type loopFrame struct {
+	env, bodyEnv *object.Environment
+	iteration    int
+}
+
+// callFrame is a call of a 1y function by a call expression in progress.
+type callFrame struct {
+	name   string
+	hooks  *Hooks
+	trace  *Trace
+	traced tracedCall
+	fn     functionCall
+}
+
+// Eval evaluates an AST node in env, which must belong to the interpreter.
+//
+// The interpreter's currentFile is the file the code being evaluated was
+// read from, for the positions of errors. It changes as programs, imported
+// modules and functions defined in other files run.
+func (in *Interpreter) Eval(node ast.Node, env *object.Environment) object.Object {
+	// A panic leaves frames unfinished, which must not leave the
+	// interpreter thinking they still run
+	defer func(depth int, file string) {
+		in.depth, in.currentFile = depth, file
+	}(in.depth, in.currentFile)
+
+	m := machine{in: in}
+	if err := m.push(node, env); err != nil {
+		return err
+	}
+	return m.run()
+}
+
+// push starts the evaluation of node in env, returning the error that stops
+// it before it starts.
+func (m *machine) push(node ast.Node, env *object.Environment) *object.Error {
+	in := m.in
+	in.depth++
+	if in.depth > MaxDepth {
+		in.depth--
+		return newError("maximum evaluation depth exceeded (%d)", MaxDepth)
+	}
+	if err := in.checkStep(); err != nil {
+		in.depth--
+		return err
+	}
+	if in.hooks != nil && in.hooks.OnNodeEnter != nil {
+		in.hooks.OnNodeEnter(node, env)
+	}
+
+	// Frames are cleared as they are popped, so a free one only needs its
+	// node and environment set
+	if n := len(m.stack); n < cap(m.stack) {
+		m.stack = m.stack[:n+1]
+	} else {
+		m.stack = append(m.stack, frame{})
+	}
+	f := &m.stack[len(m.stack)-1]
+	f.node, f.env = node, env
+	if in.profile != nil {
+		if line := statementLine(node); line > 0 {
+			f.entry = in.profile.line(line)
+			f.entry.enter()
+		}
+	}
+	return nil
+}
+
+// run resumes the frames on the stack until the first one finishes, and
+// returns its value.
+func (m *machine) run() object.Object {
+	var value object.Object
+	for {
+		f := &m.stack[len(m.stack)-1]
+		result, done := m.resume(f, value)
+		if !done {
+			// The frame resumes with the value of the child, or with the
+			// error that kept it from starting
+			value = nil
+			if err := m.push(m.next, m.nextEnv); err != nil {
+				value = err
+			}
+			continue
+		}
+
+		result = m.finish(f, result)
+		m.stack[len(m.stack)-1] = frame{}
+		m.stack = m.stack[:len(m.stack)-1]
+		if len(m.stack) == 0 {
+			return result
+		}
+		value = result
+	}
+}
+
+// finish ends the evaluation of the node of f, which evaluated to result,
+// and returns its value.
+func (m *machine) finish(f *frame, result object.Object) object.Object {
+	in := m.in
+	node := f.node
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		if pos := position(node); pos.Line > 0 {
+			err.File, err.Line, err.Column = in.currentFile, pos.Line, pos.Column
+		}
+	}
+	if in.stats != nil {
+		in.stats.count(node, result)
+	}
+	if in.trace != nil {
+		if line := statementLine(node); line > 0 {
+			in.trace.statement(node, line, f.env, result)
+		}
+	}
+	if in.limits != (Limits{}) {
+		result = in.checkResult(node, result)
+	}
+	if in.hooks != nil && in.hooks.OnNodeExit != nil {
+		in.hooks.OnNodeExit(node, f.env, result)
+	}
+	if f.entry != nil {
+		f.entry.exit()
+	}
+	in.depth--
+	return result
+}
+
+// eval asks for node to be evaluated in env before the frame being resumed
+// resumes again with its value.
+func (m *machine) eval(node ast.Node, env *object.Environment) (object.Object, bool) {
+	m.next, m.nextEnv = node, env
+	return nil, false
+}
+
+// resume carries on with the evaluation of the node of f, value being the
+// value of the child it last asked for. It returns the value of the node
+// and true once it is done, or asks for another child with eval.
+func (m *machine) resume(f *frame, value object.Object) (object.Object, bool) {
+	in := m.in
+	env := f.env
+
+	switch node := f.node.(type) {
+	case *ast.Program:
+		return m.resumeProgram(f, node, value)
+
+	case *ast.BlockStatement:
+		return m.resumeBlock(f, node, value)
+
+	case *ast.ExpressionStatement:
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.Expression, env)
+		}
+		return value, true
+
+	case *ast.ReturnStatement:
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.ReturnValue, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		return &object.ReturnValue{Value: value}, true
+
+	case *ast.IntegerLiteral:
+		return integerObject(node.Value), true
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value), true
+
+	case *ast.PrefixExpression:
+		if node.Operator == "++" || node.Operator == "--" {
+			return m.resumeIncrement(f, node.Operator, node.Right, true, value)
+		}
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.Right, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		return evalPrefixExpression(node.Operator, value), true
+
+	case *ast.PostfixExpression:
+		return m.resumeIncrement(f, node.Operator, node.Left, false, value)
+
+	case *ast.InfixExpression:
+		switch f.step {
+		case 0:
+			f.step = 1
+			return m.eval(node.Left, env)
+		case 1:
+			if isError(value) {
+				return value, true
+			}
+			f.first = value
+			f.step = 2
+			return m.eval(node.Right, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		left, right := f.first, value
+		result := evalInfixExpression(in, node.Operator, left, right)
+		// Hooks were handed left and right and may have kept them
+		if in.hooks == nil {
+			releaseOperands(node, left, right, result)
+		}
+		return result, true
+
+	case *ast.IfExpression:
+		return m.resumeIf(f, node, value)
+
+	case *ast.LetStatement:
+		// `let x;` declares x as null
+		if f.step == 0 && node.Value != nil {
+			f.step = 1
+			return m.eval(node.Value, env)
+		}
+		if f.step == 0 {
+			value = NULL
+		}
+		if isError(value) {
+			return value, true
+		}
+		if node.Name.Resolved {
+			return env.DeclareAt(node.Name.Slot, node.Name.Value, value, false), true
+		}
+		return env.NewVar(node.Name.Value, value), true
+
+	case *ast.ConstStatement:
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.Value, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		if node.Name.Resolved {
+			return env.DeclareAt(node.Name.Slot, node.Name.Value, value, true), true
+		}
+		return env.NewConst(node.Name.Value, value), true
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env), true
+
+	case *ast.FunctionLiteral:
+		env.Capture()
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env, Scope: node.Scope, File: in.currentFile}, true
+
+	case *ast.CallExpression:
+		return m.resumeCall(f, node, value)
+
+	case *ast.StringLiteral:
+		return object.Intern(node.Value), true
+
+	case *ast.ArrayLiteral:
+		if result, done := m.collect(f, node.Elements, value); !done || len(f.values) < len(node.Elements) {
+			return result, done
+		}
+		return &object.Array{Elements: f.values}, true
+
+	case *ast.IndexExpression:
+		switch f.step {
+		case 0:
+			f.step = 1
+			return m.eval(node.Left, env)
+		case 1:
+			if isError(value) {
+				return value, true
+			}
+			f.first = value
+			f.step = 2
+			return m.eval(node.Index, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		return evalIndexExpression(f.first, value), true
+
+	case *ast.MultiDimensionalIndex:
+		if result, done := m.collect(f, node.Indices, value); !done || len(f.values) < len(node.Indices) {
+			return result, done
+		}
+		return &object.MultiDimensionalIndex{Indices: f.values}, true
+
+	case *ast.Assignment:
+		switch f.step {
+		case 0:
+			f.step = 1
+			return m.eval(node.Value, env)
+		case 1:
+			if isError(value) {
+				return value, true
+			}
+			f.first = value
+			f.step = 2
+			value = nil
+		}
+		return m.assign(f, node.Name, value)
+
+	case *ast.HashLiteral:
+		return m.resumeHash(f, node, value)
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}, true
+
+	case *ast.WhileStatement:
+		return m.resumeLoop(f, node.Scope, node.BodyScope, nil, node.Condition, nil, node.Body, value)
+
+	case *ast.ForStatement:
+		return m.resumeLoop(f, node.Scope, node.BodyScope, node.Init, node.Condition, node.Post, node.Body, value)
+
+	case *ast.ImportExpression:
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.Path, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		return in.importModule(value), true
+
+	case *ast.DotExpression:
+		if f.step == 0 {
+			f.step = 1
+			return m.eval(node.Left, env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		right, ok := node.Right.(*ast.Identifier)
+		if !ok {
+			return newError("expected property name to be identifier, got %T", node.Right), true
+		}
+		return evalDotExpression(value, right), true
+
+	case *ast.BreakStatement:
+		return BREAK, true
+
+	case *ast.ContinueStatement:
+		return CNT, true
+	}
+
+	return nil, true
+}
+
+// collect evaluates exps in turn, gathering their values in f.values. It is
+// done with the error of the first that fails, or with all their values.
+func (m *machine) collect(f *frame, exps []ast.Expression, value object.Object) (object.Object, bool) {
+	if f.values == nil {
+		f.values = make([]object.Object, 0, len(exps))
+	} else {
+		if isError(value) {
+			return value, true
+		}
+		f.values = append(f.values, value)
+	}
+	if len(f.values) < len(exps) {
+		return m.eval(exps[len(f.values)], f.env)
+	}
+	return nil, true
+}
+
+func (m *machine) resumeProgram(f *frame, program *ast.Program, value object.Object) (object.Object, bool) {
+	if f.step == 0 {
+		f.step = 1
+		if program.File != "" {
+			f.file, f.restoreFile = m.in.currentFile, true
+			m.in.currentFile = program.File
+		}
+		ast.Resolve(program)
+	} else {
+		switch result := value.(type) {
+		case *object.ReturnValue:
+			return m.endProgram(f, result.Value)
+		case *object.Error:
+			return m.endProgram(f, result)
+		}
+		f.first = value
+	}
+
+	if f.index < len(program.Statements) {
+		f.index++
+		return m.eval(program.Statements[f.index-1], f.env)
+	}
+	return m.endProgram(f, f.first)
+}
+
+func (m *machine) endProgram(f *frame, result object.Object) (object.Object, bool) {
+	if f.restoreFile {
+		m.in.currentFile = f.file
+	}
+	return result, true
+}
+
+func (m *machine) resumeBlock(f *frame, block *ast.BlockStatement, value object.Object) (object.Object, bool) {
+	if f.step == 0 {
+		f.step = 1
+	} else if value != nil {
+		rt := value.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			return value, true
+		}
+	}
+
+	if f.index < len(block.Statements) {
+		f.index++
+		return m.eval(block.Statements[f.index-1], f.env)
+	}
+	return value, true
+}
+
+func (m *machine) resumeIf(f *frame, ie *ast.IfExpression, value object.Object) (object.Object, bool) {
+	// Step 1 tests the condition of the if, step 2 that of the elif before
+	// f.index and step 3 runs the branch taken
+	switch f.step {
+	case 0:
+		f.step = 1
+		return m.eval(ie.Condition, f.env)
+	case 3:
+		return value, true
+	}
+
+	if isError(value) {
+		return value, true
+	}
+	if isTruthy(value) {
+		consequence := ie.Consequence
+		if f.step == 2 {
+			consequence = ie.Elifs[f.index-1].Consequence
+		}
+		f.step = 3
+		return m.eval(consequence, f.env)
+	}
+
+	if f.index < len(ie.Elifs) {
+		f.step = 2
+		f.index++
+		return m.eval(ie.Elifs[f.index-1].Condition, f.env)
+	}
+	if ie.Alternative != nil {
+		f.step = 3
+		return m.eval(ie.Alternative, f.env)
+	}
+	return NULL, true
+}
+
+func (m *machine) resumeHash(f *frame, node *ast.HashLiteral, value object.Object) (object.Object, bool) {
+	// Step 1 evaluates the key before f.index and step 2 its value
+	switch f.step {
+	case 0:
+		f.first = &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		f.keys = hashLiteralKeys(node)
+	case 1:
+		if isError(value) {
+			return value, true
+		}
+		if _, ok := object.AsHashable(value); !ok {
+			return newError("unusable as hash key: %s", value.Type()), true
+		}
+		f.second = value
+		f.step = 2
+		return m.eval(node.Pairs[f.keys[f.index-1]], f.env)
+	case 2:
+		if isError(value) {
+			return value, true
+		}
+		if err := putPair(f.first.(*object.Hash), f.second, value); err != nil {
+			return err, true
+		}
+	}
+
+	if f.index < len(f.keys) {
+		f.step = 1
+		f.index++
+		return m.eval(f.keys[f.index-1], f.env)
+	}
+	return f.first, true
+}
+
+// resumeIncrement evaluates ++ and -- by storing a new integer in the
+// operand rather than mutating the integer object, which may be shared.
+func (m *machine) resumeIncrement(f *frame, operator string, target ast.Expression, isPrefix bool, value object.Object) (object.Object, bool) {
+	switch f.step {
+	case 0:
+		f.step = 1
+		return m.eval(target, f.env)
+	case 1:
+		if isError(value) {
+			return value, true
+		}
+		integer, ok := value.(*object.Integer)
+		if !ok {
+			return newError("unknown operator: %s%s", operator, value.Type()), true
+		}
+
+		delta := int64(1)
+		if operator == "--" {
+			delta = -1
+		}
+		f.first = integerObject(new(big.Int).Add(integer.Value, big.NewInt(delta)))
+		f.second = integer
+		f.step = 2
+		value = nil
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.DotExpression, *ast.IndexExpression:
+		if result, done := m.assign(f, target, value); !done || isError(result) {
+			return result, done
+		}
+	}
+
+	if isPrefix {
+		return f.first, true
+	}
+	return f.second, true
+}
+
+// assign stores f.first in the variable, hash property or element named by
+// target, evaluating the hash or array and the index it names in turn.
+// f.index counts how many have been.
+func (m *machine) assign(f *frame, target ast.Expression, value object.Object) (object.Object, bool) {
+	val := f.first
+	switch target := target.(type) {
+	case *ast.Identifier:
+		return assignIdentifier(target, val, f.env), true
+
+	case *ast.DotExpression:
+		if f.index == 0 {
+			f.index = 1
+			return m.eval(target.Left, f.env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		right, ok := target.Right.(*ast.Identifier)
+		if !ok {
+			return newError("expected property name to be identifier, got %T", target.Right), true
+		}
+		return evalDotAssignment(value, right, val), true
+
+	case *ast.IndexExpression:
+		switch f.index {
+		case 0:
+			f.index = 1
+			return m.eval(target.Left, f.env)
+		case 1:
+			if isError(value) {
+				return value, true
+			}
+			f.container = value
+			f.index = 2
+			return m.eval(target.Index, f.env)
+		}
+		if isError(value) {
+			return value, true
+		}
+		return assignIndex(f.container, value, val), true
+
+	default:
+		return newError("invalid assignment target: %T", target), true
+	}
+}
+
+func (m *machine) resumeCall(f *frame, node *ast.CallExpression, value object.Object) (object.Object, bool) {
+	// Step 1 evaluates the function, step 2 the arguments and step 3 the
+	// body of a 1y function called
+	in := m.in
+	switch f.step {
+	case 0:
+		f.step = 1
+		return m.eval(node.Function, f.env)
+	case 1:
+		if isError(value) {
+			return value, true
+		}
+		f.first = value
+		f.step = 2
+		value = nil
+		fallthrough
+	case 2:
+		if result, done := m.collect(f, node.Arguments, value); !done || len(f.values) < len(node.Arguments) {
+			return result, done
+		}
+	case 3:
+		return m.endCall(node, m.returnFrom(f, value))
+	}
+
+	function, args := f.first, f.values
+	name := node.Function.String()
+	fn, ok := function.(*object.Function)
+	if ok && in.profile != nil {
+		// Function literals are anonymous, so name them after how they are called
+		in.profile.function(fn.Body, name)
+	}
+	if !ok || InterpreterOf(fn.Env) != in {
+		// Builtins run in Go, and functions of other interpreters in those
+		return m.endCall(node, in.callNamed(name, function, args))
+	}
+
+	call := &callFrame{name: name, hooks: in.hooks, trace: in.trace}
+	f.call = call
+	if call.hooks != nil && call.hooks.OnCall != nil {
+		call.hooks.OnCall(name, fn, args)
+	}
+	if call.trace != nil {
+		call.traced = call.trace.enter(name, args)
+	}
+	fnCall, err := in.enterFunction(fn, args)
+	if err != nil {
+		return m.endCall(node, m.returned(f, err))
+	}
+	call.fn = fnCall
+	f.step = 3
+	return m.eval(fn.Body, fnCall.env)
+}
+
+// returnFrom ends the call of f, whose function's body evaluated to value,
+// and returns the value of the call.
+func (m *machine) returnFrom(f *frame, value object.Object) object.Object {
+	return m.returned(f, m.in.exitFunction(&f.call.fn, value))
+}
+
+// returned reports the call of f returning result to its trace and hooks.
+func (m *machine) returned(f *frame, result object.Object) object.Object {
+	call := f.call
+	if call.trace != nil {
+		call.trace.exit(call.traced, result)
+	}
+	if call.hooks != nil && call.hooks.OnReturn != nil {
+		call.hooks.OnReturn(call.name, f.first, result)
+	}
+	return result
+}
+
+// endCall adds the call to the stack of the error it returned, if any.
+func (m *machine) endCall(node *ast.CallExpression, result object.Object) (object.Object, bool) {
+	if err, ok := result.(*object.Error); ok {
+		pos := position(node.Function)
+		err.Stack = append(err.Stack, object.Frame{Function: node.Function.String(), File: m.in.currentFile, Line: pos.Line, Column: pos.Column})
+	}
+	return result, true
+}
+
+// resumeLoop runs a for or while loop. Variables declared in the body last
+// for one iteration: the body's environment is emptied and reused for the
+// next one, unless a closure created in the body is still using it.
+func (m *machine) resumeLoop(f *frame, scope, bodyScope *ast.Scope, init ast.Statement, condition ast.Expression, post ast.Statement, body *ast.BlockStatement, value object.Object) (object.Object, bool) {
+	// Step 1 runs init, step 2 tests the condition, step 3 runs the
+	// statement of the body before f.index and step 4 runs post
+	const (
+		runInit = iota + 1
+		testCondition
+		runBody
+		runPost
+	)
+	in := m.in
+
+	step := f.step
+	switch step {
+	case 0:
+		f.loop = &loopFrame{env: in.newScopedEnvironment(f.env, scope)}
+		if init != nil {
+			f.step = runInit
+			return m.eval(init, f.loop.env)
+		}
+		step = runInit
+	case runInit, runPost:
+		if isError(value) {
+			return value, true
+		}
+	case testCondition:
+		if isError(value) {
+			return value, true
+		}
+		if !isTruthy(value) {
+			return NULL, true
+		}
+	case runBody:
+		if value != nil {
+			switch value.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+				return value, true
+			case object.BREAK_OBJ:
+				return NULL, true
+			case object.CONTINUE_OBJ:
+				f.index = len(body.Statements)
+			}
+		}
+	}
+
+	loop := f.loop
+	for {
+		switch step {
+		case runInit, runPost:
+			if step == runInit {
+				loop.bodyEnv = in.newScopedEnvironment(loop.env, bodyScope)
+			} else {
+				loop.iteration++
+			}
+			if condition != nil {
+				f.step = testCondition
+				return m.eval(condition, loop.env)
+			}
+			step = testCondition
+			continue
+
+		case testCondition:
+			if loop.iteration > 0 {
+				if loop.bodyEnv.Captured() {
+					loop.bodyEnv = in.newScopedEnvironment(loop.env, bodyScope)
+				} else {
+					loop.bodyEnv.Reset()
+				}
+			}
+			f.index = 0
+			step = runBody
+			continue
+
+		case runBody:
+			if f.index < len(body.Statements) {
+				f.step = runBody
+				f.index++
+				return m.eval(body.Statements[f.index-1], loop.bodyEnv)
+			}
+			if post != nil {
+				f.step = runPost
+				return m.eval(post, loop.env)
+			}
+			step = runPost
+			continue
+		}
+	}
+}
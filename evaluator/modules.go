@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"1ylang/compiled"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ModulesDir is the directory, relative to the current one, that `1y get`
+// installs modules into. A module installed as name is imported with
+// import("name"), which loads name/index.1y, and its other files with
+// import("name/file").
+const ModulesDir = "1y_modules"
+
+// FindModule returns the file that import(path) loads, or "" if there is
+// none. A path without an extension may name a .1y or .1yc file, while a
+// Go plugin is named with its extension, as in import("ext.so"). Files are
+// looked for in the current directory, then in ModulesDir, then next to the
+// interpreter, and a .1y file is preferred to a .1yc one wherever it is.
+func FindModule(path string) string {
+	candidates := []string{path}
+	if strings.HasSuffix(path, ".1y") {
+		candidates = append(candidates, path+"c")
+	} else if !strings.HasSuffix(path, compiled.Extension) && !isPlugin(path) {
+		candidates = []string{path + ".1y", path + compiled.Extension}
+	}
+
+	dirs := []string{""}
+	if !filepath.IsAbs(path) {
+		dirs = append(dirs, ModulesDir)
+	}
+	if self, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(self))
+	}
+	for _, candidate := range candidates {
+		for _, dir := range dirs {
+			file := filepath.Join(dir, candidate)
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file
+			}
+		}
+	}
+
+	// An installed module is imported by its name
+	if !filepath.IsAbs(path) && !strings.ContainsAny(path, `/\.`) {
+		file := filepath.Join(ModulesDir, path, "index.1y")
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// ModuleLoader finds and reads the modules programs import, so embedders
+// can choose where imports come from. Go plugins are always loaded from
+// files, whatever the loader.
+type ModuleLoader interface {
+	// Resolve returns the path of the module import(name) loads, which
+	// is also the File of its program, or an error if there is none.
+	Resolve(name string) (string, error)
+
+	// Load returns the source, or compiled form, of the module at path,
+	// as returned by Resolve.
+	Load(path string) ([]byte, error)
+}
+
+// FileLoader loads modules from files, found as by FindModule.
+type FileLoader struct{}
+
+func (FileLoader) Resolve(name string) (string, error) {
+	path := FindModule(name)
+	if path == "" {
+		if !strings.HasSuffix(name, ".1y") && !strings.HasSuffix(name, compiled.Extension) {
+			name += ".1y"
+		}
+		return "", fmt.Errorf("could not read file: %s", name)
+	}
+	return path, nil
+}
+
+func (FileLoader) Load(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %s", path)
+	}
+	return content, nil
+}
+
+// MapLoader loads modules from memory, holding their contents by path. A
+// name is resolved as by FSLoader.
+type MapLoader map[string][]byte
+
+func (m MapLoader) Resolve(name string) (string, error) {
+	for _, path := range moduleCandidates(name) {
+		if _, ok := m[path]; ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no module %s", name)
+}
+
+func (m MapLoader) Load(path string) ([]byte, error) {
+	content, ok := m[path]
+	if !ok {
+		return nil, fmt.Errorf("no module %s", path)
+	}
+	return content, nil
+}
+
+// FSLoader loads modules from a file system, such as an embed.FS. A name
+// is looked for as it is, then with the .1y and .1yc extensions if it has
+// none, and then, for the name of an installed module, as name/index.1y.
+type FSLoader struct {
+	FS fs.FS
+}
+
+func (l FSLoader) Resolve(name string) (string, error) {
+	for _, path := range moduleCandidates(name) {
+		if !fs.ValidPath(path) {
+			continue
+		}
+		if info, err := fs.Stat(l.FS, path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no module %s", name)
+}
+
+func (l FSLoader) Load(path string) ([]byte, error) {
+	content, err := fs.ReadFile(l.FS, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %s", path)
+	}
+	return content, nil
+}
+
+// moduleCandidates returns the paths import(name) may load from a MapLoader
+// or FSLoader, in order of preference.
+func moduleCandidates(name string) []string {
+	candidates := []string{name}
+	if !strings.HasSuffix(name, ".1y") && !strings.HasSuffix(name, compiled.Extension) {
+		candidates = append(candidates, name+".1y", name+compiled.Extension)
+	}
+	if !strings.ContainsAny(name, `/\.`) {
+		candidates = append(candidates, path.Join(name, "index.1y"))
+	}
+	return candidates
+}
+
+// HTTPLoader loads modules imported by their http or https URL, as in
+// import("https://example.com/lib/util"), where a URL whose path has no
+// extension names a .1y file.
+type HTTPLoader struct {
+	Client *http.Client // http.DefaultClient if nil
+}
+
+func (l HTTPLoader) Resolve(name string) (string, error) {
+	u, err := url.Parse(name)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("module %s is not an http or https URL", name)
+	}
+	if path.Ext(u.Path) == "" {
+		u.Path += ".1y"
+	}
+	return u.String(), nil
+}
+
+func (l HTTPLoader) Load(path string) ([]byte, error) {
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch %s: %s", path, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch %s: %v", path, err)
+	}
+	return content, nil
+}
+
+// bundle holds the modules built into the executable by `1y build`, by the
+// path they are imported with.
+var bundle map[string][]byte
+
+// SetBundle makes import(path) load files[path], if it is there, instead of
+// looking for a file, for interpreters without a loader of their own.
+func SetBundle(files map[string][]byte) {
+	bundle = files
+}
+
+// defaultLoader is the loader of interpreters without one of their own: it
+// loads the bundle, if the name is in it, and files otherwise.
+type defaultLoader struct{}
+
+func (defaultLoader) Resolve(name string) (string, error) {
+	if _, ok := bundle[name]; ok {
+		return name, nil
+	}
+	return FileLoader{}.Resolve(name)
+}
+
+func (defaultLoader) Load(path string) ([]byte, error) {
+	if content, ok := bundle[path]; ok {
+		return content, nil
+	}
+	return FileLoader{}.Load(path)
+}
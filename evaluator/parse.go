@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+)
+
+// astToObject converts an AST node into nested hashes and arrays so programs
+// can be inspected from 1y code. Every node becomes a hash with a "type" entry
+// naming the node plus one entry per child.
+func astToObject(node ast.Node) object.Object {
+	switch node := node.(type) {
+	case *ast.Program:
+		return nodeHash("Program", "statements", statementsToObject(node.Statements))
+	case *ast.BlockStatement:
+		if node == nil {
+			return NULL
+		}
+		return nodeHash("BlockStatement", "statements", statementsToObject(node.Statements))
+	case *ast.LetStatement:
+		return nodeHash("LetStatement", "name", astToObject(node.Name), "value", astToObject(node.Value))
+	case *ast.ConstStatement:
+		return nodeHash("ConstStatement", "name", astToObject(node.Name), "value", astToObject(node.Value))
+	case *ast.ReturnStatement:
+		return nodeHash("ReturnStatement", "value", astToObject(node.ReturnValue))
+	case *ast.ExpressionStatement:
+		return nodeHash("ExpressionStatement", "expression", astToObject(node.Expression))
+	case *ast.BreakStatement:
+		return nodeHash("BreakStatement")
+	case *ast.ContinueStatement:
+		return nodeHash("ContinueStatement")
+	case *ast.WhileStatement:
+		return nodeHash("WhileStatement", "condition", astToObject(node.Condition), "body", astToObject(node.Body))
+	case *ast.ForStatement:
+		return nodeHash("ForStatement",
+			"init", astToObject(node.Init),
+			"condition", astToObject(node.Condition),
+			"post", astToObject(node.Post),
+			"body", astToObject(node.Body))
+	case *ast.Identifier:
+		if node == nil {
+			return NULL
+		}
+		return nodeHash("Identifier", "value", &object.String{Value: node.Value})
+	case *ast.IntegerLiteral:
+		return nodeHash("IntegerLiteral", "value", &object.Integer{Value: node.Value})
+	case *ast.FloatLiteral:
+		return nodeHash("FloatLiteral", "value", &object.Float{Value: node.Value})
+	case *ast.StringLiteral:
+		return nodeHash("StringLiteral", "value", &object.String{Value: node.Value})
+	case *ast.Boolean:
+		return nodeHash("Boolean", "value", nativeBoolToBooleanObject(node.Value))
+	case *ast.PrefixExpression:
+		return nodeHash("PrefixExpression",
+			"operator", &object.String{Value: node.Operator},
+			"right", astToObject(node.Right))
+	case *ast.InfixExpression:
+		return nodeHash("InfixExpression",
+			"left", astToObject(node.Left),
+			"operator", &object.String{Value: node.Operator},
+			"right", astToObject(node.Right))
+	case *ast.PostfixExpression:
+		return nodeHash("PostfixExpression",
+			"left", astToObject(node.Left),
+			"operator", &object.String{Value: node.Operator})
+	case *ast.IfExpression:
+		elifs := make([]object.Object, len(node.Elifs))
+		for i, elif := range node.Elifs {
+			elifs[i] = nodeHash("ElifExpression",
+				"condition", astToObject(elif.Condition),
+				"consequence", astToObject(elif.Consequence))
+		}
+		return nodeHash("IfExpression",
+			"condition", astToObject(node.Condition),
+			"consequence", astToObject(node.Consequence),
+			"elifs", &object.Array{Elements: elifs},
+			"alternative", astToObject(node.Alternative))
+	case *ast.FunctionLiteral:
+		params := make([]object.Object, len(node.Parameters))
+		for i, param := range node.Parameters {
+			params[i] = astToObject(param)
+		}
+		return nodeHash("FunctionLiteral",
+			"parameters", &object.Array{Elements: params},
+			"body", astToObject(node.Body))
+	case *ast.CallExpression:
+		return nodeHash("CallExpression",
+			"function", astToObject(node.Function),
+			"arguments", expressionsToObject(node.Arguments))
+	case *ast.ArrayLiteral:
+		return nodeHash("ArrayLiteral", "elements", expressionsToObject(node.Elements))
+	case *ast.IndexExpression:
+		return nodeHash("IndexExpression", "left", astToObject(node.Left), "index", astToObject(node.Index))
+	case *ast.MultiDimensionalIndex:
+		return nodeHash("MultiDimensionalIndex", "indices", expressionsToObject(node.Indices))
+	case *ast.Assignment:
+		return nodeHash("Assignment", "name", astToObject(node.Name), "value", astToObject(node.Value))
+	case *ast.HashLiteral:
+		pairs := make([]object.Object, 0, len(node.Pairs))
+		for _, key := range hashLiteralKeys(node) {
+			pairs = append(pairs, nodeHash("HashPair", "key", astToObject(key), "value", astToObject(node.Pairs[key])))
+		}
+		return nodeHash("HashLiteral", "pairs", &object.Array{Elements: pairs})
+	case *ast.DotExpression:
+		return nodeHash("DotExpression", "left", astToObject(node.Left), "right", astToObject(node.Right))
+	case *ast.ImportExpression:
+		return nodeHash("ImportExpression", "path", astToObject(node.Path))
+	default:
+		return NULL
+	}
+}
+
+// hashLiteralKeys returns the keys of a hash literal in source order if
+// known, and in unspecified order if the literal was built without Keys or
+// with Keys that are not those of Pairs, as decoding a .1yc file gives.
+func hashLiteralKeys(hash *ast.HashLiteral) []ast.Expression {
+	if hasPairKeys(hash) {
+		return hash.Keys
+	}
+	keys := make([]ast.Expression, 0, len(hash.Pairs))
+	for key := range hash.Pairs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// hasPairKeys reports whether the Keys of hash are the keys of its Pairs.
+func hasPairKeys(hash *ast.HashLiteral) bool {
+	if len(hash.Keys) != len(hash.Pairs) {
+		return false
+	}
+	for _, key := range hash.Keys {
+		if _, ok := hash.Pairs[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func statementsToObject(statements []ast.Statement) *object.Array {
+	elements := make([]object.Object, len(statements))
+	for i, stmt := range statements {
+		elements[i] = astToObject(stmt)
+	}
+	return &object.Array{Elements: elements}
+}
+
+func expressionsToObject(expressions []ast.Expression) *object.Array {
+	elements := make([]object.Object, len(expressions))
+	for i, exp := range expressions {
+		elements[i] = astToObject(exp)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// nodeHash builds the hash for a node of the given type from alternating
+// field names and values.
+func nodeHash(nodeType string, fields ...interface{}) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	typeKey := &object.String{Value: "type"}
+	pairs[typeKey.HashKey()] = object.HashPair{Key: typeKey, Value: &object.String{Value: nodeType}}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		key := &object.String{Value: fields[i].(string)}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: fields[i+1].(object.Object)}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"1ylang/object"
+	"fmt"
+	"plugin"
+	"strings"
+)
+
+// PluginExtension is the extension of the Go plugins import can load.
+const PluginExtension = ".so"
+
+// isPlugin reports whether import(path) loads a Go plugin rather than a
+// script.
+func isPlugin(path string) bool {
+	return strings.HasSuffix(path, PluginExtension)
+}
+
+// loadPlugin imports the Go plugin name, built with `go build
+// -buildmode=plugin` against the same version of the interpreter. Its
+// Register function, of type func(*object.Environment), declares the
+// members of the module in the environment it is given, a global
+// environment of in.
+func loadPlugin(name string, in *Interpreter) (*object.Module, error) {
+	path := FindModule(name)
+	if path == "" {
+		return nil, fmt.Errorf("could not read file: %s", name)
+	}
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading plugin %s failed: %s", path, err)
+	}
+	symbol, err := p.Lookup("Register")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s has no Register function", path)
+	}
+	register, ok := symbol.(func(*object.Environment))
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: Register must be a func(*object.Environment), got %T", path, symbol)
+	}
+
+	env := in.NewEnvironment()
+	register(env)
+	return &object.Module{Path: path, Env: env, Members: moduleMembers(env)}, nil
+}
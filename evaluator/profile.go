@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ProfileEntry holds the measurements for one function or source line. Time
+// is inclusive: it covers everything run on behalf of the entry, and
+// recursive calls are only timed once, at the outermost call.
+type ProfileEntry struct {
+	Name  string
+	Calls int
+	Time  time.Duration
+
+	active int
+	start  time.Time
+}
+
+func (e *ProfileEntry) enter() {
+	e.Calls++
+	if e.active == 0 {
+		e.start = time.Now()
+	}
+	e.active++
+}
+
+func (e *ProfileEntry) exit() {
+	e.active--
+	if e.active == 0 {
+		e.Time += time.Since(e.start)
+	}
+}
+
+// Profile records how often each 1y function is called and each line runs,
+// and how long they take.
+type Profile struct {
+	Functions map[*ast.BlockStatement]*ProfileEntry // keyed by function body
+	Lines     map[int]*ProfileEntry
+}
+
+// StartProfile starts recording a profile of everything the interpreter
+// evaluates until StopProfile is called.
+func (in *Interpreter) StartProfile() *Profile {
+	in.profile = &Profile{
+		Functions: make(map[*ast.BlockStatement]*ProfileEntry),
+		Lines:     make(map[int]*ProfileEntry),
+	}
+	return in.profile
+}
+
+// StopProfile stops recording the interpreter's profile.
+func (in *Interpreter) StopProfile() {
+	in.profile = nil
+}
+
+// StartProfile starts a profile of the default interpreter, and of those
+// later created with its options.
+func StartProfile() *Profile {
+	p := defaultInterpreter.StartProfile()
+	defaultInterpreter.options.profile = p
+	return p
+}
+
+// StopProfile stops the profile started by StartProfile.
+func StopProfile() {
+	defaultInterpreter.options.profile = nil
+	defaultInterpreter.StopProfile()
+}
+
+// function returns the entry for the function with the given body. name is
+// how the function was called, used to label functions the first time they
+// are seen since function literals are anonymous.
+func (p *Profile) function(body *ast.BlockStatement, name string) *ProfileEntry {
+	entry, ok := p.Functions[body]
+	if !ok {
+		if line := body.Token.Line; line > 0 {
+			name = fmt.Sprintf("%s (line %d)", name, line)
+		}
+		entry = &ProfileEntry{Name: name}
+		p.Functions[body] = entry
+	}
+	return entry
+}
+
+func (p *Profile) line(line int) *ProfileEntry {
+	entry, ok := p.Lines[line]
+	if !ok {
+		entry = &ProfileEntry{Name: fmt.Sprintf("line %d", line)}
+		p.Lines[line] = entry
+	}
+	return entry
+}
+
+// statementLine returns the line a statement starts on, or 0 for nodes that
+// are not statements or whose position is unknown. Blocks are skipped since
+// the statements inside them are recorded individually.
+func statementLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token.Line
+	case *ast.ConstStatement:
+		return node.Token.Line
+	case *ast.ReturnStatement:
+		return node.Token.Line
+	case *ast.ExpressionStatement:
+		return node.Token.Line
+	case *ast.BreakStatement:
+		return node.Token.Line
+	case *ast.ContinueStatement:
+		return node.Token.Line
+	case *ast.WhileStatement:
+		return node.Token.Line
+	case *ast.ForStatement:
+		return node.Token.Line
+	}
+	return 0
+}
+
+// Report writes the functions and lines of the profile, slowest first.
+func (p *Profile) Report(out io.Writer) {
+	fmt.Fprintln(out, "Functions:")
+	writeEntries(out, p.Functions)
+	fmt.Fprintln(out, "Lines:")
+	writeEntries(out, p.Lines)
+}
+
+func writeEntries[K comparable](out io.Writer, entries map[K]*ProfileEntry) {
+	sorted := make([]*ProfileEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time > sorted[j].Time
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	fmt.Fprintf(out, "  %12s  %10s  %s\n", "time", "calls", "name")
+	for _, entry := range sorted {
+		fmt.Fprintf(out, "  %12v  %10d  %s\n", entry.Time, entry.Calls, entry.Name)
+	}
+}
@@ -0,0 +1,40 @@
+package evaluator
+
+import "1ylang/object"
+
+// unsafeBuiltins are the builtins a sandboxed program may not call, as they
+// reach outside the interpreter: into the process environment, or ending
+// the process itself.
+var unsafeBuiltins = []string{"exit", "getenv", "setenv"}
+
+// SetSandbox turns sandbox mode on or off. A sandboxed program cannot import
+// files or call the builtins that reach outside the interpreter; doing so
+// is an error. Together with SetLimits, this lets untrusted code run safely.
+// This sets the mode of environments made by NewEnvironment; those created
+// earlier keep the mode they had. Use New for interpreters of their own.
+//
+// Imports through a loader an interpreter was given in Options.Loader, such
+// as a MapLoader, still work, since the loader decides what they reach;
+// plugins and modules in files cannot be imported.
+//
+// Libraries giving access to files are registered by their callers, which
+// should leave them out when Sandboxed reports true, unless the interpreter
+// confines them to a file system of its own, given by Options.FS.
+func SetSandbox(on bool) {
+	options := defaultInterpreter.options
+	options.Sandbox = on
+	reconfigureDefault(options)
+}
+
+// Sandboxed reports whether sandbox mode is on for environments made by
+// NewEnvironment.
+func Sandboxed() bool {
+	return defaultInterpreter.Sandboxed()
+}
+
+// forbidden returns a builtin standing in for name in sandbox mode.
+func forbidden(name string) *object.Builtin {
+	return newBuiltin(func(args ...object.Object) object.Object {
+		return newError("`%s` is not allowed in sandbox mode", name)
+	})
+}
@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats counts the work the interpreter does during a run, so that changes
+// to its performance show up in numbers rather than only in timings.
+type Stats struct {
+	Nodes   map[string]int            // nodes evaluated, by AST type
+	Objects map[object.ObjectType]int // objects made by literals and operators
+
+	// Environments counts the scopes set up for calls and loops. Calls of
+	// functions that make no closures take their environment from a pool,
+	// counted in PooledFrames as well.
+	Environments int
+	PooledFrames int
+
+	nodes map[reflect.Type]int // Nodes while counting, cheaper to update
+
+	start, end time.Time
+	before     runtime.MemStats
+	after      runtime.MemStats
+}
+
+// StartStats starts counting everything the interpreter evaluates until
+// StopStats is called.
+func (in *Interpreter) StartStats() *Stats {
+	in.stats = &Stats{
+		Nodes:   make(map[string]int),
+		Objects: make(map[object.ObjectType]int),
+		nodes:   make(map[reflect.Type]int),
+	}
+	runtime.ReadMemStats(&in.stats.before)
+	in.stats.start = time.Now()
+	return in.stats
+}
+
+// StopStats stops counting and records the memory statistics of the run.
+func (in *Interpreter) StopStats() {
+	if in.stats == nil {
+		return
+	}
+	in.stats.stop()
+	in.stats = nil
+}
+
+// StartStats starts counting for the default interpreter, and for those
+// later created with its options.
+func StartStats() *Stats {
+	s := defaultInterpreter.StartStats()
+	defaultInterpreter.options.stats = s
+	return s
+}
+
+// StopStats stops the counting started by StartStats.
+func StopStats() {
+	defaultInterpreter.options.stats = nil
+	defaultInterpreter.StopStats()
+}
+
+func (s *Stats) stop() {
+	s.end = time.Now()
+	runtime.ReadMemStats(&s.after)
+	for t, n := range s.nodes {
+		s.Nodes[strings.TrimPrefix(t.String(), "*ast.")] = n
+	}
+}
+
+func (s *Stats) count(node ast.Node, result object.Object) {
+	s.nodes[reflect.TypeOf(node)]++
+
+	// Calls return objects counted where they were made, and booleans and
+	// null are never allocated. Small integers are shared too, so the
+	// integer count is an upper bound.
+	if _, ok := node.(*ast.CallExpression); ok || !createsObject(node) {
+		return
+	}
+	if result == nil || result == TRUE || result == FALSE || result == NULL || isError(result) {
+		return
+	}
+	s.Objects[result.Type()]++
+}
+
+// newScopedEnvironment creates the environment for a call or loop scope.
+func (in *Interpreter) newScopedEnvironment(outer *object.Environment, scope *ast.Scope) *object.Environment {
+	if in.stats != nil {
+		in.stats.Environments++
+	}
+	return object.NewScopedEnvironment(outer, scopeNames(scope))
+}
+
+// Report writes the counters, followed by the allocations and garbage
+// collections of the Go runtime during the run.
+func (s *Stats) Report(out io.Writer) {
+	fmt.Fprintln(out, "Nodes evaluated:")
+	writeCounts(out, s.Nodes)
+	fmt.Fprintln(out, "Objects created:")
+	writeCounts(out, s.Objects)
+
+	fmt.Fprintf(out, "Environments: %d (%d pooled call frames)\n", s.Environments, s.PooledFrames)
+	fmt.Fprintln(out, "Memory:")
+	fmt.Fprintf(out, "  %12d  heap allocations\n", s.after.Mallocs-s.before.Mallocs)
+	fmt.Fprintf(out, "  %12d  bytes allocated\n", s.after.TotalAlloc-s.before.TotalAlloc)
+	fmt.Fprintf(out, "  %12d  bytes in use\n", s.after.HeapAlloc)
+	fmt.Fprintf(out, "  %12d  garbage collections\n", s.after.NumGC-s.before.NumGC)
+	fmt.Fprintf(out, "  %12v  paused for collection\n", time.Duration(s.after.PauseTotalNs-s.before.PauseTotalNs))
+	fmt.Fprintf(out, "  %12v  total run time\n", s.end.Sub(s.start))
+}
+
+func writeCounts[K ~string](out io.Writer, counts map[K]int) {
+	names := make([]K, 0, len(counts))
+	total := 0
+	for name, n := range counts {
+		names = append(names, name)
+		total += n
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		fmt.Fprintf(out, "  %12d  %s\n", counts[name], name)
+	}
+	fmt.Fprintf(out, "  %12d  total\n", total)
+}
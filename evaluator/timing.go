@@ -0,0 +1,55 @@
+package evaluator
+
+import (
+	"1ylang/object"
+	"time"
+)
+
+// ImportTimes records how long each module imported during a run took to
+// load, so a slow start can be traced to the module that causes it.
+type ImportTimes struct {
+	Modules []ModuleTime // in the order they were imported
+
+	index map[*object.Module]int
+}
+
+// ModuleTime is the time one module took to load. Run includes the time
+// taken by the modules it imports in turn.
+type ModuleTime struct {
+	Path  string
+	Parse time.Duration // reading and parsing the file
+	Run   time.Duration // running the top-level code, zero if it never ran
+}
+
+// StartImportTimes starts timing the modules the interpreter's programs
+// import until StopImportTimes is called.
+func (in *Interpreter) StartImportTimes() *ImportTimes {
+	in.importTimes = &ImportTimes{index: make(map[*object.Module]int)}
+	return in.importTimes
+}
+
+// StopImportTimes stops timing imports.
+func (in *Interpreter) StopImportTimes() {
+	in.importTimes = nil
+}
+
+// StartImportTimes starts timing the imports of the default interpreter.
+func StartImportTimes() *ImportTimes {
+	return defaultInterpreter.StartImportTimes()
+}
+
+// StopImportTimes stops timing the imports of the default interpreter.
+func StopImportTimes() {
+	defaultInterpreter.StopImportTimes()
+}
+
+func (t *ImportTimes) parsed(m *object.Module, d time.Duration) {
+	t.index[m] = len(t.Modules)
+	t.Modules = append(t.Modules, ModuleTime{Path: m.Path, Parse: d})
+}
+
+func (t *ImportTimes) ran(m *object.Module, d time.Duration) {
+	if i, ok := t.index[m]; ok {
+		t.Modules[i].Run += d
+	}
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"1ylang/ast"
+	"1ylang/object"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxTraceText is the longest code or value written in full to a trace;
+// longer ones are cut short.
+const maxTraceText = 60
+
+// Trace logs each statement as it runs, with its result and the depth of the
+// environment it runs in, and each function call with its arguments and
+// what it returns. Lines are indented by the number of calls in progress.
+type Trace struct {
+	out io.Writer
+
+	// function, if set, limits the trace to what runs during calls to the
+	// function called by that name
+	function string
+	inside   int // calls to function in progress
+
+	calls int // calls in progress
+}
+
+// StartTrace starts writing a trace of everything the interpreter evaluates
+// to out, until StopTrace is called. If function is not "", only calls to
+// the function of that name and what runs within them are traced.
+func (in *Interpreter) StartTrace(out io.Writer, function string) *Trace {
+	in.trace = &Trace{out: out, function: function}
+	return in.trace
+}
+
+// StopTrace stops the interpreter's trace.
+func (in *Interpreter) StopTrace() {
+	in.trace = nil
+}
+
+// StartTrace starts a trace of the default interpreter, and of those later
+// created with its options.
+func StartTrace(out io.Writer, function string) *Trace {
+	t := defaultInterpreter.StartTrace(out, function)
+	defaultInterpreter.options.trace = t
+	return t
+}
+
+// StopTrace stops the trace started by StartTrace.
+func StopTrace() {
+	defaultInterpreter.options.trace = nil
+	defaultInterpreter.StopTrace()
+}
+
+func (t *Trace) active() bool {
+	return t.function == "" || t.inside > 0
+}
+
+func (t *Trace) printf(format string, args ...interface{}) {
+	fmt.Fprintf(t.out, strings.Repeat("  ", t.calls)+format+"\n", args...)
+}
+
+// statement logs a statement starting on line once it has run.
+func (t *Trace) statement(node ast.Node, line int, env *object.Environment, result object.Object) {
+	if !t.active() {
+		return
+	}
+	text := "(no value)"
+	if result != nil {
+		text = shorten(result.Inspect())
+	}
+	t.printf("line %d [depth %d] %s => %s", line, env.Depth(), shorten(node.String()), text)
+}
+
+// call calls fn in interpreter in, which was called by name, logging the
+// call and its result.
+func (t *Trace) call(in *Interpreter, name string, fn object.Object, args []object.Object) object.Object {
+	c := t.enter(name, args)
+	result := in.applyFunction(fn, args)
+	t.exit(c, result)
+	return result
+}
+
+// tracedCall is a call in progress, as Trace.enter started it.
+type tracedCall struct {
+	name    string
+	matched bool // the call is to the function traced
+	logged  bool // the call was written to the trace
+}
+
+// enter logs a call of the function called by name with args, to be
+// followed by exit once it returns.
+func (t *Trace) enter(name string, args []object.Object) tracedCall {
+	c := tracedCall{name: name, matched: t.function != "" && name == t.function}
+	if c.matched {
+		t.inside++
+	}
+	if !t.active() {
+		return c
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = shorten(arg.Inspect())
+	}
+	t.printf("call %s(%s)", name, strings.Join(values, ", "))
+	t.calls++
+	c.logged = true
+	return c
+}
+
+// exit logs the result of a call started by enter.
+func (t *Trace) exit(c tracedCall, result object.Object) {
+	if c.logged {
+		t.calls--
+		text := "(no value)"
+		if result != nil {
+			text = shorten(result.Inspect())
+		}
+		t.printf("%s returned %s", c.name, text)
+	}
+	if c.matched {
+		t.inside--
+	}
+}
+
+// shorten returns text on one line, cut to maxTraceText characters.
+func shorten(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if runes := []rune(text); len(runes) > maxTraceText {
+		return string(runes[:maxTraceText-3]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,682 @@
+// Package format prints 1y programs in a canonical layout: two spaces of
+// indentation per block, one statement per line, single spaces around
+// operators and parentheses only where they change the meaning. Comments
+// and single blank lines between statements are kept.
+package format
+
+import (
+	"1ylang/ast"
+	"1ylang/lexer"
+	"1ylang/parser"
+	"1ylang/token"
+	"errors"
+	"math"
+	"strings"
+)
+
+const indentation = "  "
+
+// Source formats the program src. It fails if src does not parse.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	l.RecordComments()
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	out := Program(program, string(src), l.Comments())
+
+	// Dropping or adding a needed parenthesis would silently change what the
+	// program does, so the result is checked by parsing it again
+	check := parser.New(lexer.New(out))
+	reparsed := check.ParseProgram()
+	if len(check.Errors()) != 0 || explicit(reparsed) != explicit(program) {
+		return nil, errors.New("formatting would change the meaning of the program")
+	}
+	return []byte(out), nil
+}
+
+// Program prints program, whose source is src, with the comments taken from
+// src. src is only used to tell comments on lines of their own from those
+// following code.
+func Program(program *ast.Program, src string, comments []lexer.Comment) string {
+	p := &printer{src: src, comments: comments}
+	p.statements(program.Statements, math.MaxInt)
+	p.out.WriteString("\n")
+	return p.out.String()
+}
+
+// explicit prints program with every operation in parentheses, so that two
+// programs print the same exactly when they have the same structure.
+func explicit(program *ast.Program) string {
+	p := &printer{explicit: true}
+	p.statements(program.Statements, math.MaxInt)
+	return p.out.String()
+}
+
+type printer struct {
+	out      strings.Builder
+	depth    int  // blocks the output is nested in
+	started  bool // something has been written
+	explicit bool // parenthesize every operation
+
+	src      string
+	srcLines []string // src split into lines, once needed
+	comments []lexer.Comment
+	next     int // first comment not printed yet
+	lastLine int // source line of what was printed last
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+	p.started = true
+}
+
+// newline starts a new line at the current indentation.
+func (p *printer) newline() {
+	if p.started {
+		p.out.WriteString("\n")
+	}
+	p.out.WriteString(strings.Repeat(indentation, p.depth))
+	p.started = true
+}
+
+// separate starts a new line for something on source line line, leaving a
+// blank line first if the source had one there.
+func (p *printer) separate(line int) {
+	if p.started && line-1 > p.lastLine && p.blank(line-1) {
+		p.out.WriteString("\n")
+	}
+	p.newline()
+}
+
+// blank reports whether source line line holds nothing but spaces.
+func (p *printer) blank(line int) bool {
+	if p.srcLines == nil {
+		p.srcLines = strings.Split(p.src, "\n")
+	}
+	return line >= 1 && line <= len(p.srcLines) && strings.TrimSpace(p.srcLines[line-1]) == ""
+}
+
+// commentsBefore prints the comments that start before line, each on a line
+// of its own.
+func (p *printer) commentsBefore(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Line < line {
+		c := p.comments[p.next]
+		p.next++
+		if p.lastLine == c.Line && !p.ownLine(c) {
+			// It follows code printed last, so it stays beside it
+			p.write(" " + c.Text)
+		} else {
+			p.separate(c.Line)
+			p.write(c.Text)
+		}
+		p.lastLine = c.Line + strings.Count(c.Text, "\n")
+	}
+}
+
+// trailingComments prints the comments that follow code on line.
+func (p *printer) trailingComments(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Line == line && !p.ownLine(p.comments[p.next]) {
+		c := p.comments[p.next]
+		p.next++
+		p.write(" " + c.Text)
+		p.lastLine = c.Line + strings.Count(c.Text, "\n")
+	}
+}
+
+// ownLine reports whether only spaces come before c on its line.
+func (p *printer) ownLine(c lexer.Comment) bool {
+	start := strings.LastIndexByte(p.src[:c.Start], '\n') + 1
+	return strings.TrimSpace(p.src[start:c.Start]) == ""
+}
+
+// statements prints a list of statements, each on its own line, and the
+// comments among them up to endLine.
+func (p *printer) statements(stmts []ast.Statement, endLine int) {
+	for _, stmt := range stmts {
+		line := stmtLine(stmt)
+		p.commentsBefore(line)
+		p.separate(line)
+		p.statement(stmt, true)
+		p.lastLine = max(p.lastLine, lastLine(stmt))
+		p.trailingComments(p.lastLine)
+	}
+	p.commentsBefore(endLine)
+}
+
+// statement prints stmt, followed by a semicolon if terminated is set and
+// the statement does not end in a block.
+func (p *printer) statement(stmt ast.Statement, terminated bool) {
+	semicolon := terminated
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value)
+		if stmt.Value != nil {
+			p.write(" = ")
+			p.expression(stmt.Value, parser.LOWEST)
+		}
+	case *ast.ConstStatement:
+		p.write("const " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expression(stmt.ReturnValue, parser.LOWEST)
+		}
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+		if _, ok := stmt.Expression.(*ast.IfExpression); ok {
+			semicolon = false
+		}
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(stmt.Body)
+		semicolon = false
+	case *ast.ForStatement:
+		p.write("for (")
+		if stmt.Init != nil {
+			p.statement(stmt.Init, false)
+		}
+		p.write("; ")
+		if stmt.Condition != nil {
+			p.expression(stmt.Condition, parser.LOWEST)
+		}
+		p.write("; ")
+		if stmt.Post != nil {
+			p.statement(stmt.Post, false)
+		}
+		p.write(") ")
+		p.block(stmt.Body)
+		semicolon = false
+	case *ast.BlockStatement:
+		p.block(stmt)
+		semicolon = false
+	}
+	if semicolon {
+		p.write(";")
+	}
+}
+
+// block prints a block in braces. A block written on one line with a single
+// statement, such as the body of fn(x) { x * 2 }, stays on one line.
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 1 && block.EndLine == block.Token.Line && !p.commentOnLine(block.Token.Line) {
+		if inline, ok := p.inline(block.Statements[0]); ok {
+			p.write("{ " + inline + " }")
+			return
+		}
+	}
+	if len(block.Statements) == 0 && !p.commentBefore(block.EndLine) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.lastLine = block.Token.Line
+	p.trailingComments(block.Token.Line)
+	p.depth++
+	p.statements(block.Statements, block.EndLine)
+	p.depth--
+	p.newline()
+	p.write("}")
+	p.lastLine = max(p.lastLine, block.EndLine)
+}
+
+// inline prints stmt on its own, reporting false if it takes several lines.
+func (p *printer) inline(stmt ast.Statement) (string, bool) {
+	sub := &printer{explicit: p.explicit, src: p.src, depth: p.depth, started: true}
+	sub.statement(stmt, false)
+	out := sub.out.String()
+	return out, !strings.Contains(out, "\n")
+}
+
+// commentOnLine reports whether a comment not yet printed is on line.
+func (p *printer) commentOnLine(line int) bool {
+	for _, c := range p.comments[p.next:] {
+		if c.Line == line {
+			return true
+		}
+		if c.Line > line {
+			break
+		}
+	}
+	return false
+}
+
+// commentBefore reports whether a comment not yet printed starts before line.
+func (p *printer) commentBefore(line int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Line < line
+}
+
+// Binding strengths beyond the parser's, for expressions that are never
+// split by an operator next to them.
+const (
+	closed = math.MaxInt // literals, identifiers and bracketed forms
+)
+
+// expression prints exp in a context that binds with strength context: an
+// operator of that precedence, or parser.LOWEST where anything goes.
+func (p *printer) expression(exp ast.Expression, context int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.FloatLiteral:
+		if exp.Token.Type == token.FLOAT {
+			p.write(exp.Token.Literal)
+		} else {
+			// .5 is read as a dot followed by the integer 5
+			p.write("." + exp.Token.Literal)
+		}
+	case *ast.StringLiteral:
+		p.write(`"` + exp.Value + `"`)
+	case *ast.Boolean:
+		p.write(exp.Token.Literal)
+
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		if prefix, ok := exp.Right.(*ast.PrefixExpression); ok && prefix.Operator[0] == exp.Operator[len(exp.Operator)-1] {
+			// - -x must not become --x
+			p.parenthesized(exp.Right)
+			return
+		}
+		p.operand(exp.Right, leftBinding(exp.Right) <= parser.PREFIX)
+
+	case *ast.InfixExpression:
+		prec := parser.Precedence(token.TokenType(exp.Operator))
+		p.open(prec, context)
+		p.operand(exp.Left, rightBinding(exp.Left) < prec)
+		p.write(" " + exp.Operator + " ")
+		p.operand(exp.Right, leftBinding(exp.Right) <= prec)
+		p.close(prec, context)
+
+	case *ast.PostfixExpression:
+		p.open(parser.POSTFIX, context)
+		p.operand(exp.Left, rightBinding(exp.Left) < parser.POSTFIX)
+		p.write(exp.Operator)
+		p.close(parser.POSTFIX, context)
+
+	case *ast.Assignment:
+		p.open(parser.ASSIGN, context)
+		p.expression(exp.Name, closed)
+		if value, ok := exp.Value.(*ast.InfixExpression); ok && exp.Token.Type != token.IDENT && value.Left == exp.Name {
+			// x += 1 is held as x = x + 1
+			p.write(" " + string(exp.Token.Type) + " ")
+			p.operand(value.Right, leftBinding(value.Right) <= parser.OP_ASSIGN)
+		} else {
+			p.write(" = ")
+			p.expression(exp.Value, parser.LOWEST)
+		}
+		p.close(parser.ASSIGN, context)
+
+	case *ast.CallExpression:
+		p.open(parser.CALL, context)
+		p.operand(exp.Function, rightBinding(exp.Function) < parser.CALL)
+		p.write("(")
+		p.list(exp.Arguments)
+		p.write(")")
+		p.close(parser.CALL, context)
+
+	case *ast.IndexExpression:
+		p.open(parser.INDEX, context)
+		p.operand(exp.Left, rightBinding(exp.Left) < parser.INDEX)
+		p.write("[")
+		if multi, ok := exp.Index.(*ast.MultiDimensionalIndex); ok {
+			p.list(multi.Indices)
+		} else {
+			p.expression(exp.Index, parser.LOWEST)
+		}
+		p.write("]")
+		p.close(parser.INDEX, context)
+
+	case *ast.DotExpression:
+		p.open(parser.DOT, context)
+		p.operand(exp.Left, rightBinding(exp.Left) < parser.DOT)
+		p.write(".")
+		p.expression(exp.Right, closed)
+		p.close(parser.DOT, context)
+
+	case *ast.ArrayLiteral:
+		p.write("[")
+		if p.spread(exp.Token.Line, exp.Elements) {
+			p.lines(exp.Elements, nil)
+			p.newline()
+		} else {
+			p.list(exp.Elements)
+		}
+		p.write("]")
+
+	case *ast.HashLiteral:
+		keys := hashKeys(exp)
+		p.write("{")
+		if p.spread(exp.Token.Line, keys) {
+			p.lines(keys, exp.Pairs)
+			p.newline()
+		} else {
+			for i, key := range keys {
+				if i > 0 {
+					p.write(", ")
+				}
+				p.pair(key, exp.Pairs[key])
+			}
+		}
+		p.write("}")
+
+	case *ast.FunctionLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(exp.Body)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(exp.Consequence)
+		for _, elif := range exp.Elifs {
+			p.write(" elif (")
+			p.expression(elif.Condition, parser.LOWEST)
+			p.write(") ")
+			p.block(elif.Consequence)
+		}
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+
+	case *ast.ImportExpression:
+		p.write("import(")
+		p.expression(exp.Path, parser.LOWEST)
+		p.write(")")
+	}
+}
+
+// open and close put an operation of precedence prec in parentheses when
+// printing explicitly. Parentheses the meaning needs are added by operand.
+func (p *printer) open(prec, context int) {
+	if p.explicit && context != closed {
+		p.write("(")
+	}
+}
+
+func (p *printer) close(prec, context int) {
+	if p.explicit && context != closed {
+		p.write(")")
+	}
+}
+
+// operand prints an operand, in parentheses if needed.
+func (p *printer) operand(exp ast.Expression, needed bool) {
+	if needed && !p.explicit {
+		p.parenthesized(exp)
+		return
+	}
+	p.expression(exp, parser.PREFIX)
+}
+
+func (p *printer) parenthesized(exp ast.Expression) {
+	p.write("(")
+	p.expression(exp, parser.LOWEST)
+	p.write(")")
+}
+
+// list prints expressions separated by commas.
+func (p *printer) list(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(exp, parser.LOWEST)
+	}
+}
+
+// spread reports whether the elements of a literal opened on line should be
+// printed one per line, as they were in the source.
+func (p *printer) spread(line int, elements []ast.Expression) bool {
+	if p.explicit {
+		return false
+	}
+	for _, el := range elements {
+		if firstLine(el) > line {
+			return true
+		}
+	}
+	return false
+}
+
+// lines prints the elements of an array, or the pairs of a hash if pairs is
+// set, one per line with the comments among them.
+func (p *printer) lines(elements []ast.Expression, pairs map[ast.Expression]ast.Expression) {
+	p.depth++
+	for i, el := range elements {
+		line := firstLine(el)
+		p.commentsBefore(line)
+		p.separate(line)
+		if pairs != nil {
+			p.pair(el, pairs[el])
+			p.lastLine = max(p.lastLine, lastLine(pairs[el]))
+		} else {
+			p.expression(el, parser.LOWEST)
+			p.lastLine = max(p.lastLine, lastLine(el))
+		}
+		// Hashes may end in a comma, arrays may not
+		if i < len(elements)-1 || pairs != nil {
+			p.write(",")
+		}
+		p.trailingComments(p.lastLine)
+	}
+	p.depth--
+}
+
+func (p *printer) pair(key, value ast.Expression) {
+	p.expression(key, parser.LOWEST)
+	p.write(": ")
+	p.expression(value, parser.LOWEST)
+}
+
+// hashKeys returns the keys of a hash literal in source order.
+func hashKeys(hash *ast.HashLiteral) []ast.Expression {
+	if len(hash.Keys) == len(hash.Pairs) {
+		return hash.Keys
+	}
+	keys := make([]ast.Expression, 0, len(hash.Pairs))
+	for key := range hash.Pairs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// leftBinding returns the weakest precedence with which exp, printed
+// without parentheses, takes the expression to its left as its own operand:
+// an operator printed just before exp and binding as tightly or more would
+// take exp's first operand away from it.
+func leftBinding(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return min(parser.Precedence(token.TokenType(exp.Operator)), leftBinding(exp.Left))
+	case *ast.PostfixExpression:
+		return min(parser.POSTFIX, leftBinding(exp.Left))
+	case *ast.CallExpression:
+		return min(parser.CALL, leftBinding(exp.Function))
+	case *ast.IndexExpression:
+		return min(parser.INDEX, leftBinding(exp.Left))
+	case *ast.DotExpression:
+		return min(parser.DOT, leftBinding(exp.Left))
+	case *ast.Assignment:
+		return parser.LOWEST
+	}
+	return closed
+}
+
+// rightBinding returns the weakest precedence of the operators along the
+// right edge of exp printed without parentheses: an operator printed just
+// after exp and binding more tightly would take exp's last operand.
+func rightBinding(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		prec := parser.Precedence(token.TokenType(exp.Operator))
+		if leftBinding(exp.Right) <= prec {
+			return prec // the right operand is in parentheses
+		}
+		return min(prec, rightBinding(exp.Right))
+	case *ast.PrefixExpression:
+		if leftBinding(exp.Right) <= parser.PREFIX {
+			return parser.PREFIX
+		}
+		return min(parser.PREFIX, rightBinding(exp.Right))
+	case *ast.Assignment:
+		return parser.LOWEST
+	}
+	return closed
+}
+
+// stmtLine returns the line stmt starts on.
+func stmtLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Line
+	case *ast.ConstStatement:
+		return stmt.Token.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Line
+	case *ast.BreakStatement:
+		return stmt.Token.Line
+	case *ast.ContinueStatement:
+		return stmt.Token.Line
+	case *ast.WhileStatement:
+		return stmt.Token.Line
+	case *ast.ForStatement:
+		return stmt.Token.Line
+	case *ast.BlockStatement:
+		return stmt.Token.Line
+	}
+	return 0
+}
+
+// firstLine returns the line exp starts on.
+func firstLine(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return firstLine(exp.Left)
+	case *ast.PostfixExpression:
+		return firstLine(exp.Left)
+	case *ast.CallExpression:
+		return firstLine(exp.Function)
+	case *ast.IndexExpression:
+		return firstLine(exp.Left)
+	case *ast.DotExpression:
+		return firstLine(exp.Left)
+	case *ast.Assignment:
+		return firstLine(exp.Name)
+	case *ast.Identifier:
+		return exp.Token.Line
+	case *ast.IntegerLiteral:
+		return exp.Token.Line
+	case *ast.FloatLiteral:
+		return exp.Token.Line
+	case *ast.StringLiteral:
+		return exp.Token.Line
+	case *ast.Boolean:
+		return exp.Token.Line
+	case *ast.PrefixExpression:
+		return exp.Token.Line
+	case *ast.ArrayLiteral:
+		return exp.Token.Line
+	case *ast.HashLiteral:
+		return exp.Token.Line
+	case *ast.FunctionLiteral:
+		return exp.Token.Line
+	case *ast.IfExpression:
+		return exp.Token.Line
+	case *ast.ImportExpression:
+		return exp.Token.Line
+	}
+	return 0
+}
+
+// lastLine returns the last line of node in the source, as far as is known:
+// the closing brackets of calls and literals are not recorded, so their last
+// element stands in for them.
+func lastLine(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node.Value != nil {
+			return lastLine(node.Value)
+		}
+		return node.Token.Line
+	case *ast.ConstStatement:
+		return lastLine(node.Value)
+	case *ast.ReturnStatement:
+		if node.ReturnValue != nil {
+			return lastLine(node.ReturnValue)
+		}
+		return node.Token.Line
+	case *ast.ExpressionStatement:
+		return lastLine(node.Expression)
+	case *ast.WhileStatement:
+		return node.Body.EndLine
+	case *ast.ForStatement:
+		return node.Body.EndLine
+	case *ast.BlockStatement:
+		return node.EndLine
+	case *ast.FunctionLiteral:
+		return node.Body.EndLine
+	case *ast.IfExpression:
+		if node.Alternative != nil {
+			return node.Alternative.EndLine
+		}
+		if len(node.Elifs) > 0 {
+			return node.Elifs[len(node.Elifs)-1].Consequence.EndLine
+		}
+		return node.Consequence.EndLine
+	case *ast.InfixExpression:
+		return lastLine(node.Right)
+	case *ast.PrefixExpression:
+		return lastLine(node.Right)
+	case *ast.Assignment:
+		return lastLine(node.Value)
+	case *ast.CallExpression:
+		if len(node.Arguments) > 0 {
+			return lastLine(node.Arguments[len(node.Arguments)-1])
+		}
+		return lastLine(node.Function)
+	case *ast.IndexExpression:
+		return lastLine(node.Index)
+	case *ast.ArrayLiteral:
+		if len(node.Elements) > 0 {
+			return lastLine(node.Elements[len(node.Elements)-1])
+		}
+	case *ast.HashLiteral:
+		if keys := hashKeys(node); len(keys) > 0 {
+			return lastLine(node.Pairs[keys[len(keys)-1]])
+		}
+	case *ast.ImportExpression:
+		return lastLine(node.Path)
+	case *ast.MultiDimensionalIndex:
+		if len(node.Indices) > 0 {
+			return lastLine(node.Indices[len(node.Indices)-1])
+		}
+	case *ast.DotExpression:
+		return lastLine(node.Right)
+	case ast.Expression:
+		return firstLine(node)
+	case ast.Statement:
+		return stmtLine(node)
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package format
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let y = (1+2)*3;", "let y = (1 + 2) * 3;\n"},
+		{"a - (b - c); (a - b) - c", "a - (b - c);\na - b - c;\n"},
+		{"-(-x); !(a == b)", "-(-x);\n!(a == b);\n"},
+		{"x += 2*3", "x += 2 * 3;\n"},
+		{"let q = .5; let r = 1.5", "let q = .5;\nlet r = 1.5;\n"},
+		{`let f = fn(a,b){a-(b-1)}`, "let f = fn(a, b) { a - (b - 1) };\n"},
+		{"if(x>2){1}elif(x<0){2}else{3}", "if (x > 2) { 1 } elif (x < 0) { 2 } else { 3 }\n"},
+		{"while (x) {\nx = x - 1; break; }", "while (x) {\n  x = x - 1;\n  break;\n}\n"},
+		{"for (let i = 0; i < 3; i += 1) { puts(i) }", "for (let i = 0; i < 3; i += 1) { puts(i) }\n"},
+		{"fn() {\n}", "fn() {};\n"},
+		{`{"a":1, "b":[1,2]}`, "{\"a\": 1, \"b\": [1, 2]};\n"},
+		{"let h = {\"a\": 1,\n\"b\": 2}", "let h = {\n  \"a\": 1,\n  \"b\": 2,\n};\n"},
+		{"let a = [\n1,\n2 // two\n]", "let a = [\n  1,\n  2 // two\n];\n"},
+		{"a.b.c(1)[0]", "a.b.c(1)[0];\n"},
+
+		// Comments and blank lines
+		{"// top\n\nlet x = 1 // one\n\n\n\nx", "// top\n\nlet x = 1; // one\n\nx;\n"},
+		{"if (x) {\n  // inside\n}", "if (x) {\n  // inside\n}\n"},
+		{"if (x) { // why\n1 /* one */\n}", "if (x) { // why\n  1; /* one */\n}\n"},
+		{"1\n/* a\n   b */\n2", "1;\n/* a\n   b */\n2;\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, out)
+		}
+
+		again, err := Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("formatting %q again changed it to %q (%v)", out, again, err)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source([]byte("let = 5")); err == nil {
+		t.Errorf("expected an error for a program that does not parse")
+	}
+}
+
+func TestSourceExamples(t *testing.T) {
+	for _, path := range []string{"../demo.1y", "../while.1y"} {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Source(src)
+		if err != nil {
+			t.Fatalf("Source(%s) failed: %v", path, err)
+		}
+		again, err := Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("formatting %s is not stable:\n%s\n---\n%s", path, out, again)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"1ylang/evaluator"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// manifestFile records the modules installed into evaluator.ModulesDir, so
+// running `1y get` without arguments installs the same versions again.
+const manifestFile = "1y.json"
+
+// registryEnv names the environment variable holding the URL of the module
+// registry. A module given by name alone is cloned from that URL followed by
+// "/" and the name.
+const registryEnv = "YLANG_REGISTRY"
+
+type manifest struct {
+	Modules map[string]manifestModule `json:"modules"`
+}
+
+type manifestModule struct {
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"` // as asked for, such as a tag
+	Commit  string `json:"commit"`
+}
+
+// getModules runs `1y get [-name name] [source[@version]...]`, cloning each
+// module from a git URL or the registry into evaluator.ModulesDir and
+// recording it in the manifest. Without sources, the modules in the
+// manifest are installed at the commits recorded there.
+func getModules(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	name := fs.String("name", "", "Install the module under this name instead of the last element of its source")
+	fs.Parse(args)
+	if *name != "" && fs.NArg() != 1 {
+		return fmt.Errorf("-name needs exactly one module")
+	}
+
+	m, err := readManifest()
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		modules := make([]string, 0, len(m.Modules))
+		for module := range m.Modules {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+		for _, module := range modules {
+			entry := m.Modules[module]
+			if err := install(out, module, entry.Source, entry.Version, entry.Commit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, arg := range fs.Args() {
+		source, version := splitVersion(arg)
+		module := *name
+		if module == "" {
+			module = moduleName(source)
+		}
+		if !strings.ContainsAny(source, "/:") {
+			registry := os.Getenv(registryEnv)
+			if registry == "" {
+				return fmt.Errorf("%s is not a git URL, and %s is not set to a registry to find it in", source, registryEnv)
+			}
+			source = strings.TrimRight(registry, "/") + "/" + source
+		}
+
+		if err := install(out, module, source, version, ""); err != nil {
+			return err
+		}
+		commit, err := git(filepath.Join(evaluator.ModulesDir, module), "rev-parse", "HEAD")
+		if err != nil {
+			return err
+		}
+		m.Modules[module] = manifestModule{Source: source, Version: version, Commit: commit}
+		if err := writeManifest(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitVersion splits "source@version" into its parts. The version is
+// after the last '@' that follows the last '/', so that the '@' in a URL
+// such as git@host:user/repo is not taken for one.
+func splitVersion(arg string) (source, version string) {
+	i := strings.LastIndexByte(arg, '@')
+	if i < 0 || i < strings.LastIndexByte(arg, '/') || (strings.HasPrefix(arg, "git@") && i == 3) {
+		return arg, ""
+	}
+	return arg[:i], arg[i+1:]
+}
+
+// moduleName returns the name a module cloned from source is installed
+// under: the last element of the URL or path, without a ".git" suffix.
+func moduleName(source string) string {
+	trimmed := strings.TrimRight(source, "/")
+	return strings.TrimSuffix(trimmed[strings.LastIndexAny(trimmed, "/:")+1:], ".git")
+}
+
+// moduleDir returns the directory module is installed in. The name comes
+// from the command line or the manifest, which may not be trusted, so it
+// must be a single element that stays inside evaluator.ModulesDir.
+func moduleDir(module string) (string, error) {
+	if module == "" || module == "." || strings.ContainsAny(module, `/\`) || strings.Contains(module, "..") {
+		return "", fmt.Errorf("invalid module name %q", module)
+	}
+	dir := filepath.Join(evaluator.ModulesDir, module)
+	if rel, err := filepath.Rel(evaluator.ModulesDir, dir); err != nil || rel != module {
+		return "", fmt.Errorf("invalid module name %q", module)
+	}
+	return dir, nil
+}
+
+// install clones source into the directory of module and checks out commit,
+// or else version, or else leaves the default branch. A module already at
+// commit is left as it is. The clone is made beside the directory and moved
+// into place once it succeeds, so a failed install keeps the old one.
+func install(out io.Writer, module, source, version, commit string) error {
+	dir, err := moduleDir(module)
+	if err != nil {
+		return err
+	}
+	if commit != "" {
+		if head, err := git(dir, "rev-parse", "HEAD"); err == nil && head == commit {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(evaluator.ModulesDir, 0755); err != nil {
+		return fmt.Errorf("Error creating %s: %v", evaluator.ModulesDir, err)
+	}
+	tmp, err := os.MkdirTemp(evaluator.ModulesDir, "."+module+"-")
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", evaluator.ModulesDir, err)
+	}
+	defer os.RemoveAll(tmp)
+	if _, err := git("", "clone", "--quiet", "--", source, tmp); err != nil {
+		return err
+	}
+
+	ref := commit
+	if ref == "" {
+		ref = version
+	}
+	if ref != "" {
+		if err := checkout(tmp, ref); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("Error removing %s: %v", dir, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("Error installing %s: %v", dir, err)
+	}
+
+	head, err := git(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		fmt.Fprintf(out, "installed %s %s (%s)\n", module, version, head)
+	} else {
+		fmt.Fprintf(out, "installed %s (%s)\n", module, head)
+	}
+	return nil
+}
+
+// checkout checks out ref, a commit, a tag or a branch of the remote, in
+// the clone in dir. The ref is resolved first with options ended, so one
+// starting with '-' cannot be taken for an option.
+func checkout(dir, ref string) error {
+	var commit string
+	var err error
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		commit, err = git(dir, "rev-parse", "--verify", "--quiet", "--end-of-options", candidate+"^{commit}")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("git checkout: unknown revision %s", ref)
+	}
+	_, err = git(dir, "checkout", "--quiet", "--detach", commit)
+	return err
+}
+
+// git runs git with args in dir, or the current directory if dir is "",
+// and returns its output without the trailing newline.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func readManifest() (*manifest, error) {
+	m := &manifest{Modules: map[string]manifestModule{}}
+	content, err := os.ReadFile(manifestFile)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file %s: %v", manifestFile, err)
+	}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("Error reading file %s: %v", manifestFile, err)
+	}
+	if m.Modules == nil {
+		m.Modules = map[string]manifestModule{}
+	}
+	return m, nil
+}
+
+func writeManifest(m *manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestFile, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("Error writing file %s: %v", manifestFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"1ylang/evaluator"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		arg, source, version string
+	}{
+		{"https://example.com/user/repo.git", "https://example.com/user/repo.git", ""},
+		{"https://example.com/user/repo.git@v1.2", "https://example.com/user/repo.git", "v1.2"},
+		{"git@example.com:user/repo", "git@example.com:user/repo", ""},
+		{"git@example.com:user/repo@main", "git@example.com:user/repo", "main"},
+		{"https://me@example.com/repo", "https://me@example.com/repo", ""},
+		{"json@2", "json", "2"},
+		{"json", "json", ""},
+	}
+
+	for _, tt := range tests {
+		source, version := splitVersion(tt.arg)
+		if source != tt.source || version != tt.version {
+			t.Errorf("splitVersion(%q) = %q, %q, want %q, %q", tt.arg, source, version, tt.source, tt.version)
+		}
+	}
+}
+
+func TestModuleName(t *testing.T) {
+	tests := []struct {
+		source, name string
+	}{
+		{"https://example.com/user/repo.git", "repo"},
+		{"https://example.com/user/repo/", "repo"},
+		{"git@example.com:repo.git", "repo"},
+		{"/src/lib", "lib"},
+		{"json", "json"},
+	}
+
+	for _, tt := range tests {
+		if name := moduleName(tt.source); name != tt.name {
+			t.Errorf("moduleName(%q) = %q, want %q", tt.source, name, tt.name)
+		}
+	}
+}
+
+func TestModuleDir(t *testing.T) {
+	if dir, err := moduleDir("json"); err != nil || dir != filepath.Join(evaluator.ModulesDir, "json") {
+		t.Errorf("moduleDir(json) = %q, %v", dir, err)
+	}
+	for _, name := range []string{"", ".", "..", "../src", "a/b", `a\b`, "a..b"} {
+		if _, err := moduleDir(name); err == nil {
+			t.Errorf("moduleDir(%q) did not fail", name)
+		}
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// A module to install, and a directory beside the modules that a bad
+	// name could reach
+	repo := filepath.Join(dir, "repo")
+	for _, args := range [][]string{
+		{"init", "--quiet", repo},
+		{"-C", repo, "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "--quiet", "--allow-empty", "-m", "first"},
+		{"-C", repo, "tag", "v1"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := install(io.Discard, "../src", repo, "", ""); err == nil {
+		t.Errorf("install with name ../src did not fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src")); err != nil {
+		t.Errorf("install with a bad name removed a directory: %v", err)
+	}
+
+	if err := install(io.Discard, "mod", repo, "v1", ""); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	installed := filepath.Join(evaluator.ModulesDir, "mod")
+	if _, err := os.Stat(filepath.Join(installed, ".git")); err != nil {
+		t.Fatalf("module not installed: %v", err)
+	}
+
+	if err := install(io.Discard, "mod", filepath.Join(dir, "missing"), "", ""); err == nil {
+		t.Errorf("install from a missing source did not fail")
+	}
+	if err := install(io.Discard, "mod", repo, "--orphan", ""); err == nil {
+		t.Errorf("install of version --orphan did not fail")
+	}
+	if _, err := os.Stat(filepath.Join(installed, ".git")); err != nil {
+		t.Errorf("failed install removed the installed module: %v", err)
+	}
+	entries, _ := os.ReadDir(evaluator.ModulesDir)
+	if len(entries) != 1 {
+		t.Errorf("failed installs left files behind: %v", entries)
+	}
+}
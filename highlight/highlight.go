@@ -0,0 +1,70 @@
+// Package highlight classifies the tokens of 1y source for syntax
+// highlighting, so editor plugins and the REPL color code the same way.
+package highlight
+
+import (
+	"1ylang/lexer"
+	"1ylang/token"
+)
+
+// Category is the kind of a span of source, as shown by highlighters.
+type Category string
+
+const (
+	Keyword    Category = "keyword"    // fn, let, if, true, ...
+	Number     Category = "number"     // integer and float literals
+	String     Category = "string"     // string literals, with their quotes
+	Comment    Category = "comment"    // comments, with their delimiters
+	Identifier Category = "identifier" // names of variables, functions, ...
+	Operator   Category = "operator"   // operators, brackets and separators
+	Invalid    Category = "invalid"    // characters that are not part of any token
+)
+
+// Span is a token of the source and its category. Start and End are byte
+// offsets in the source, and Line and Column are 1-based, as in token.Token.
+type Span struct {
+	Category     Category
+	Start, End   int
+	Line, Column int
+}
+
+// Classify returns the spans of the tokens of src in order. Whatever lies
+// between them is white space. Source that does not parse is classified
+// all the same, token by token.
+func Classify(src string) []Span {
+	var spans []Span
+	l := lexer.New(src)
+	l.EmitComments()
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			return spans
+		}
+		start, end := l.Span()
+		// An unterminated string ends past the input
+		end = min(end, len(src))
+		spans = append(spans, Span{Category: category(tok.Type), Start: start, End: end, Line: tok.Line, Column: tok.Column})
+	}
+}
+
+// category returns the category of tokens of type t.
+func category(t token.TokenType) Category {
+	switch t {
+	case token.INT, token.FLOAT:
+		return Number
+	case token.STRING:
+		return String
+	case token.COMMENT:
+		return Comment
+	case token.IDENT:
+		return Identifier
+	case token.ILLEGAL:
+		return Invalid
+	case token.FUNCTION, token.LET, token.CONST, token.TRUE, token.FALSE,
+		token.IF, token.ELSE, token.ELIF, token.RETURN, token.WHILE, token.FOR,
+		token.BREAK, token.CONTINUE, token.IMPORT:
+		return Keyword
+	}
+	return Operator
+}
@@ -0,0 +1,47 @@
+package highlight
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	src := "let x = 1.5; // half\nputs(\"a\", x) $"
+
+	tests := []struct {
+		text     string
+		category Category
+		line     int
+	}{
+		{"let", Keyword, 1},
+		{"x", Identifier, 1},
+		{"=", Operator, 1},
+		{"1.5", Number, 1},
+		{";", Operator, 1},
+		{"// half", Comment, 1},
+		{"puts", Identifier, 2},
+		{"(", Operator, 2},
+		{`"a"`, String, 2},
+		{",", Operator, 2},
+		{"x", Identifier, 2},
+		{")", Operator, 2},
+		{"$", Invalid, 2},
+	}
+
+	spans := Classify(src)
+	if len(spans) != len(tests) {
+		t.Fatalf("wrong number of spans. expected=%d, got=%d (%v)", len(tests), len(spans), spans)
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if text := src[span.Start:span.End]; text != tt.text || span.Category != tt.category || span.Line != tt.line {
+			t.Errorf("spans[%d] - expected %s %q on line %d, got %s %q on line %d", i, tt.category, tt.text, tt.line, span.Category, text, span.Line)
+		}
+	}
+}
+
+func TestClassifyUnterminated(t *testing.T) {
+	src := `x = "abc`
+	spans := Classify(src)
+	last := spans[len(spans)-1]
+	if last.Category != String || src[last.Start:last.End] != `"abc` {
+		t.Errorf("expected the unterminated string to end with the input, got %v", last)
+	}
+}
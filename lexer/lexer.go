@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"1ylang/token"
+	"bufio"
+	"io"
 	"strings"
 )
 
@@ -11,28 +13,172 @@ type Lexer struct {
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
 	inComment    bool // flag to indicate if inside a multi-line comment
+	line         int  // line of the current char
+	lineStart    int  // offset the line of the current char starts at
+	tokenLine    int  // line the token being read starts on
+	tokenStart   int  // offset the token being read starts at
+	tokenColumn  int  // column the token being read starts at
+
+	comments       []Comment // comments skipped so far, if recordComments
+	recordComments bool
+	emitComments   bool // return comments as COMMENT tokens instead of skipping them
+
+	// When lexing from a reader, window holds the input from offset base
+	// onwards: the current token and any lookahead. Earlier input is dropped.
+	reader *bufio.Reader
+	window []byte
+	base   int
+	err    error
 }
 
 // New creates a new Lexer instance
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewAt creates a Lexer that starts reading input at offset, which must be
+// the start of a token on the given line.
+func NewAt(input string, offset, line int) *Lexer {
+	l := &Lexer{input: input, line: line, readPosition: offset}
+	l.lineStart = strings.LastIndexByte(input[:offset], '\n') + 1
+	l.readChar()
+	return l
+}
+
+// Comment is a comment skipped by the lexer, including its // or /* */
+// delimiters.
+type Comment struct {
+	Text  string
+	Line  int // line the comment starts on
+	Start int // offset of the comment in the input
+}
+
+// RecordComments makes the lexer keep the comments it skips, for tools such
+// as formatters that reprint the source. It is not supported by lexers
+// reading from an io.Reader, which drop input as they go.
+func (l *Lexer) RecordComments() {
+	l.recordComments = l.reader == nil
+}
+
+// Comments returns the comments skipped so far, in order, if RecordComments
+// was called.
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+// EmitComments makes NextToken return each comment as a COMMENT token,
+// including its delimiters, instead of skipping it, for tools such as
+// highlighters that show every part of the source. Parsers do not expect
+// these tokens. Like RecordComments, it is not supported by lexers reading
+// from an io.Reader.
+func (l *Lexer) EmitComments() {
+	l.emitComments = l.reader == nil
+}
+
+// recordComment notes the comment running from start to the current
+// position, which began on line.
+func (l *Lexer) recordComment(start, line int) {
+	if l.recordComments {
+		l.comments = append(l.comments, Comment{Text: l.slice(start, l.position), Line: line, Start: start})
+	}
+}
+
+// NewReader creates a Lexer that reads its input from r as tokens are
+// requested, holding only the current token in memory.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{reader: bufio.NewReader(r), line: 1}
+	l.readChar()
+	return l
+}
+
+// Err returns the first error other than io.EOF met while reading the input.
+// Input after the error is treated as missing.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Tokenize lexes src in one pass and returns its tokens, not including the
+// final EOF. Token literals share a single copy of src.
+func Tokenize(src []byte) []token.Token {
+	l := New(string(src))
+	tokens := make([]token.Token, 0, len(src)/4)
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
 // readChar reads the next character in the input and advances the position in the input string
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
 	}
+	l.ch = l.byteAt(l.readPosition)
 	l.position = l.readPosition
 	l.readPosition++
 }
 
+// byteAt returns the input byte at pos, or 0 past the end of the input.
+func (l *Lexer) byteAt(pos int) byte {
+	if l.reader == nil {
+		if pos >= len(l.input) {
+			return 0
+		}
+		return l.input[pos]
+	}
+
+	for pos-l.base >= len(l.window) {
+		b, err := l.reader.ReadByte()
+		if err != nil {
+			if err != io.EOF && l.err == nil {
+				l.err = err
+			}
+			return 0
+		}
+		l.window = append(l.window, b)
+	}
+	return l.window[pos-l.base]
+}
+
+// slice returns the input between start and end.
+func (l *Lexer) slice(start, end int) string {
+	if l.reader == nil {
+		return l.input[start:min(end, len(l.input))]
+	}
+	return string(l.window[start-l.base : min(end-l.base, len(l.window))])
+}
+
+// discard drops reader input before pos, which no later token can include.
+func (l *Lexer) discard(pos int) {
+	if l.reader == nil || pos <= l.base {
+		return
+	}
+	n := copy(l.window, l.window[min(pos-l.base, len(l.window)):])
+	l.window = l.window[:n]
+	l.base = pos
+}
+
 // NextToken returns the next token in the input
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	tok.Line = l.tokenLine
+	tok.Column = l.tokenColumn
+	return tok
+}
+
+// Span returns the offsets in the input where the token last returned by
+// NextToken starts and ends.
+func (l *Lexer) Span() (start, end int) {
+	return l.tokenStart, l.position
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
@@ -41,102 +187,99 @@ func (l *Lexer) NextToken() token.Token {
 		l.skipMultiLineComment()
 	}
 
+	start := l.position
+	l.discard(start)
+	l.tokenLine = l.line
+	l.tokenStart = start
+	l.tokenColumn = start - l.lineStart + 1
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.EQ, start)
 		} else {
-			tok = newToken(token.ASSIGN, l.ch)
+			tok = l.token(token.ASSIGN, start)
 		}
 	case '+':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.PLUS_ASSIGN, start)
 		} else if l.peekChar() == '+' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.INCREMENT, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.INCREMENT, start)
 		} else {
-			tok = newToken(token.PLUS, l.ch)
+			tok = l.token(token.PLUS, start)
 		}
 	case '-':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.MINUS_ASSIGN, start)
 		} else if l.peekChar() == '-' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.DECREMENT, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.DECREMENT, start)
 		} else {
-			tok = newToken(token.MINUS, l.ch)
+			tok = l.token(token.MINUS, start)
 		}
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.NOT_EQ, start)
 		} else {
-			tok = newToken(token.BANG, l.ch)
+			tok = l.token(token.BANG, start)
 		}
 	case '/':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.SLASH_ASSIGN, start)
 		} else if l.peekChar() == '/' {
+			line := l.line
 			l.skipSingleLineComment()
-			return l.NextToken()
+			return l.comment(start, line)
 		} else if l.peekChar() == '*' {
+			line := l.line
 			l.inComment = true
 			l.readChar() // consume '*'
 			l.readChar() // move to next character
 			l.skipMultiLineComment()
-			return l.NextToken()
+			return l.comment(start, line)
 		} else {
-			tok = newToken(token.SLASH, l.ch)
+			tok = l.token(token.SLASH, start)
 		}
 	case '*':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.ASTERISK_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.ASTERISK_ASSIGN, start)
 		} else if l.peekChar() == '*' {
 			l.readChar()
 			if l.peekChar() == '=' {
-				ch := l.ch
 				l.readChar()
-				tok = token.Token{Type: token.POW_ASSIGN, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.POW_ASSIGN, start)
 			} else {
-				ch := l.ch
-				tok = token.Token{Type: token.POW, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.POW, start)
 			}
 		} else {
-			tok = newToken(token.ASTERISK, l.ch)
+			tok = l.token(token.ASTERISK, start)
 		}
 	case '%':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.MODULUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.MODULUS_ASSIGN, start)
 		} else {
-			tok = newToken(token.MODULUS, l.ch)
+			tok = l.token(token.MODULUS, start)
 		}
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
+		tok = l.token(token.SEMICOLON, start)
 	case ',':
-		tok = newToken(token.COMMA, l.ch)
+		tok = l.token(token.COMMA, start)
 	case '(':
-		tok = newToken(token.LPAREN, l.ch)
+		tok = l.token(token.LPAREN, start)
 	case ')':
-		tok = newToken(token.RPAREN, l.ch)
+		tok = l.token(token.RPAREN, start)
 	case '{':
-		tok = newToken(token.LBRACE, l.ch)
+		tok = l.token(token.LBRACE, start)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		tok = l.token(token.RBRACE, start)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -144,81 +287,72 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
 	case '[':
-		tok = newToken(token.LBRACKET, l.ch)
+		tok = l.token(token.LBRACKET, start)
 	case ']':
-		tok = newToken(token.RBRACKET, l.ch)
+		tok = l.token(token.RBRACKET, start)
 	case ':':
-		tok = newToken(token.COLON, l.ch)
+		tok = l.token(token.COLON, start)
 	case '&':
 		if l.peekChar() == '&' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.AND_AND, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.AND_AND, start)
 		} else if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.AND_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.AND_ASSIGN, start)
 		} else {
-			tok = newToken(token.AND, l.ch)
+			tok = l.token(token.AND, start)
 		}
 	case '|':
 		if l.peekChar() == '|' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.OR_OR, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.OR_OR, start)
 		} else if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.OR_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.OR_ASSIGN, start)
 		} else {
-			tok = newToken(token.OR, l.ch)
+			tok = l.token(token.OR, start)
 		}
 	case '^':
 		if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.XOR_ASSIGN, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.XOR_ASSIGN, start)
 		} else {
-			tok = newToken(token.XOR, l.ch)
+			tok = l.token(token.XOR, start)
 		}
 	case '~':
-		tok = newToken(token.TILDE, l.ch)
+		tok = l.token(token.TILDE, start)
 	case '>':
 		if l.peekChar() == '>' {
-			ch := l.ch
 			l.readChar()
 			if l.peekChar() == '=' {
 				l.readChar()
-				tok = token.Token{Type: token.SHR_ASSIGN, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.SHR_ASSIGN, start)
 			} else {
-				tok = token.Token{Type: token.SHR, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.SHR, start)
 			}
 		} else if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.GE, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.GE, start)
 		} else {
-			tok = newToken(token.GT, l.ch)
+			tok = l.token(token.GT, start)
 		}
 	case '<':
 		if l.peekChar() == '<' {
-			ch := l.ch
 			l.readChar()
 			if l.peekChar() == '=' {
 				l.readChar()
-				tok = token.Token{Type: token.SHL_ASSIGN, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.SHL_ASSIGN, start)
 			} else {
-				tok = token.Token{Type: token.SHL, Literal: string(ch) + string(l.ch)}
+				tok = l.token(token.SHL, start)
 			}
 		} else if l.peekChar() == '=' {
-			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.LE, Literal: string(ch) + string(l.ch)}
+			tok = l.token(token.LE, start)
 		} else {
-			tok = newToken(token.LT, l.ch)
+			tok = l.token(token.LT, start)
 		}
 	case '.':
-		tok = newToken(token.DOT, l.ch)
+		tok = l.token(token.DOT, start)
 	default:
 		if isLetter(l.ch) {
 			literal := l.readIdentifier()
@@ -234,7 +368,7 @@ func (l *Lexer) NextToken() token.Token {
 			}
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.token(token.ILLEGAL, start)
 		}
 	}
 
@@ -242,9 +376,22 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-// newToken creates a new token with the given type and literal
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch)}
+// comment handles the comment running from start to the current position,
+// which began on line: it is returned as a token if EmitComments was called,
+// and skipped otherwise.
+func (l *Lexer) comment(start, line int) token.Token {
+	l.recordComment(start, line)
+	if l.emitComments {
+		return token.Token{Type: token.COMMENT, Literal: l.slice(start, l.position)}
+	}
+	return l.nextToken()
+}
+
+// token creates a token of the given type whose literal runs from start to
+// the current character. The literal is a slice of the input, so building
+// operator tokens does not allocate.
+func (l *Lexer) token(tokenType token.TokenType, start int) token.Token {
+	return token.Token{Type: tokenType, Literal: l.slice(start, l.readPosition)}
 }
 
 // readIdentifier reads an identifier from the input
@@ -253,7 +400,7 @@ func (l *Lexer) readIdentifier() string {
 	for isLetter(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return l.slice(position, l.position)
 }
 
 // isLetter checks if a character is a letter
@@ -268,6 +415,7 @@ func (l *Lexer) skipWhitespace() {
 			l.skipMultiLineComment()
 		} else {
 			l.readChar()
+			l.discard(l.position)
 		}
 	}
 }
@@ -305,9 +453,9 @@ func (l *Lexer) readNumberOrFloat() string {
 	}
 
 	if isFloat {
-		return l.input[position:l.position]
+		return l.slice(position, l.position)
 	} else {
-		return l.input[position:l.position]
+		return l.slice(position, l.position)
 	}
 }
 
@@ -318,10 +466,7 @@ func isDigit(ch byte) bool {
 
 // peekChar returns the next character in the input without advancing the position
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	}
-	return l.input[l.readPosition]
+	return l.byteAt(l.readPosition)
 }
 
 // readString reads a string from the input
@@ -333,18 +478,20 @@ func (l *Lexer) readString() string {
 			break
 		}
 	}
-	return l.input[position:l.position]
+	return l.slice(position, l.position)
 }
 
 func (l *Lexer) skipSingleLineComment() {
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
+		l.discard(l.position)
 	}
 }
 
 func (l *Lexer) skipMultiLineComment() {
 	for l.inComment {
 		l.readChar()
+		l.discard(l.position)
 		if l.ch == '*' && l.peekChar() == '/' {
 			l.readChar() // consume '*'
 			l.readChar() // consume '/'
@@ -2,7 +2,9 @@ package lexer
 
 import (
 	"1ylang/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -176,3 +178,187 @@ b--;
 		}
 	}
 }
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.SHR_ASSIGN, ">>="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "y"},
+		{token.POW_ASSIGN, "**="},
+		{token.FLOAT, "2.5"},
+		{token.SHL_ASSIGN, "<<="},
+		{token.STRING, "s"},
+	}
+
+	src := []byte(`x >>= 1; y **= 2.5 <<= "s"`)
+	tokens := Tokenize(src)
+	src[0] = 'z' // tokens must not alias the caller's buffer
+
+	if len(tokens) != len(tests) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d (%v)", len(tests), len(tokens), tokens)
+	}
+
+	for i, tt := range tests {
+		if tokens[i].Type != tt.expectedType {
+			t.Errorf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tokens[i].Type)
+		}
+		if tokens[i].Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tokens[i].Literal)
+		}
+	}
+}
+
+func TestTokenLines(t *testing.T) {
+	input := `let x = 1;
+// comment
+/* multi
+line */ puts("a
+b");
+
+x`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{"let", 1}, {"x", 1}, {"=", 1}, {"1", 1}, {";", 1},
+		{"puts", 4}, {"(", 4}, {"a\nb", 4}, {")", 5}, {";", 5},
+		{"x", 7},
+	}
+
+	for _, l := range []*Lexer{New(input), NewReader(strings.NewReader(input))} {
+		for i, tt := range tests {
+			tok := l.NextToken()
+			if tok.Literal != tt.expectedLiteral || tok.Line != tt.expectedLine {
+				t.Errorf("tests[%d] - expected %q on line %d, got %q on line %d", i, tt.expectedLiteral, tt.expectedLine, tok.Literal, tok.Line)
+			}
+		}
+	}
+}
+
+func TestEmitComments(t *testing.T) {
+	input := `x // one
+/* two
+*/ y`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.IDENT, "x", 1},
+		{token.COMMENT, "// one", 1},
+		{token.COMMENT, "/* two\n*/", 2},
+		{token.IDENT, "y", 3},
+		{token.EOF, "", 3},
+	}
+
+	l := New(input)
+	l.EmitComments()
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral || tok.Line != tt.expectedLine {
+			t.Errorf("tests[%d] - expected %s %q on line %d, got %s %q on line %d", i, tt.expectedType, tt.expectedLiteral, tt.expectedLine, tok.Type, tok.Literal, tok.Line)
+		}
+	}
+}
+
+func TestNextTokenDoesNotAllocate(t *testing.T) {
+	input := `let x = a >>= b ** c != d && e || f <= g; x++; "str" 12.5e3`
+	allocs := testing.AllocsPerRun(100, func() {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	})
+	// The Lexer itself is the only allocation
+	if allocs > 1 {
+		t.Errorf("lexing allocated %v times per run, want at most 1", allocs)
+	}
+}
+
+var benchmarkSources = map[string]string{
+	"operators":   strings.Repeat("a += b ** c >>= d != e && f || g <= h; ", 200),
+	"identifiers": strings.Repeat("let counter = total + offset; ", 200),
+	"program": strings.Repeat(`let fib = fn(n) {
+	if (n < 2) { return n; }
+	return fib(n - 1) + fib(n - 2); // recursive
+};
+let names = ["a", "b", "c"];
+for (let i = 0; i < 10; i++) { puts(names[i % 3], 1.5e2); }
+`, 50),
+}
+
+func BenchmarkLexer(b *testing.B) {
+	for name, src := range benchmarkSources {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				l := New(src)
+				for l.NextToken().Type != token.EOF {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	src := []byte(benchmarkSources["program"])
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		Tokenize(src)
+	}
+}
+
+func TestNewReaderMatchesNew(t *testing.T) {
+	input := benchmarkSources["program"] + `/* block
+comment */ x >>= 1; "unterminated`
+
+	l := New(input)
+	r := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	for i := 0; ; i++ {
+		expected := l.NextToken()
+		got := r.NextToken()
+		if got != expected {
+			t.Fatalf("token %d differs. expected=%+v, got=%+v", i, expected, got)
+		}
+		if expected.Type == token.EOF {
+			break
+		}
+	}
+
+	if len(r.window) > 16 {
+		t.Errorf("reader lexer kept %d bytes after reaching EOF", len(r.window))
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	r := NewReader(iotest.TimeoutReader(strings.NewReader("let x = 5;")))
+	for r.NextToken().Type != token.EOF {
+	}
+	if r.Err() == nil {
+		t.Errorf("expected read error to be reported")
+	}
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, src := range benchmarkSources {
+		f.Add([]byte(src))
+	}
+	f.Add([]byte(`"unterminated /* open comment 1.5e+ .`))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		for _, tok := range Tokenize(src) {
+			if tok.Type == token.EOF {
+				t.Fatalf("Tokenize returned EOF before the end")
+			}
+		}
+	})
+}
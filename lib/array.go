@@ -6,52 +6,69 @@ import (
 )
 
 var arrayFuncs = map[string]interface{}{
-	"len": func(arr []interface{}) float64 {
-		return float64(len(arr))
+	"len": func(arr *object.Array) float64 {
+		return float64(len(arr.Elements))
 	},
-	"push": func(arr []interface{}, elem interface{}) []interface{} {
-		return append(arr, elem)
+	"withCapacity": func(n object.Object) object.Object {
+		capacity, err := toInt64(n, "capacity")
+		if err != nil {
+			return err
+		}
+		if capacity < 0 {
+			return newError("capacity must not be negative, got %d", capacity)
+		}
+		// push appends in place, so it fills the reserved space without reallocating
+		return &object.Array{Elements: make([]object.Object, 0, capacity)}
 	},
-	"pop": func(arr []interface{}) ([]interface{}, interface{}) {
-		if len(arr) == 0 {
+	"push": func(arr *object.Array, elem object.Object) *object.Array {
+		return arr.Extend([]object.Object{elem})
+	},
+	"pop": func(arr *object.Array) (*object.Array, object.Object) {
+		if len(arr.Elements) == 0 {
 			return arr, nil
 		}
-		elem := arr[len(arr)-1]
-		return arr[:len(arr)-1], elem
+		last := len(arr.Elements) - 1
+		return arr.Slice(0, last), arr.Elements[last]
 	},
-	"shift": func(arr []interface{}) ([]interface{}, interface{}) {
-		if len(arr) == 0 {
+	"shift": func(arr *object.Array) (*object.Array, object.Object) {
+		if len(arr.Elements) == 0 {
 			return arr, nil
 		}
-		elem := arr[0]
-		return arr[1:], elem
+		return arr.Slice(1, len(arr.Elements)), arr.Elements[0]
 	},
-	"unshift": func(arr []interface{}, elem interface{}) []interface{} {
-		return append([]interface{}{elem}, arr...)
+	"unshift": func(arr *object.Array, elem object.Object) *object.Array {
+		elements := make([]object.Object, 0, len(arr.Elements)+1)
+		elements = append(elements, elem)
+		return &object.Array{Elements: append(elements, arr.Elements...)}
 	},
-	"indexOf": func(arr []interface{}, elem interface{}) float64 {
-		for i, v := range arr {
-			if object.IsEqual(v.(object.Object), elem.(object.Object)) {
+	"indexOf": func(arr *object.Array, elem object.Object) float64 {
+		for i, v := range arr.Elements {
+			if object.IsEqual(v, elem) {
 				return float64(i)
 			}
 		}
 		return -1
 	},
-	"contains": func(arr []interface{}, elem interface{}) bool {
-		for _, v := range arr {
-			if object.IsEqual(v.(object.Object), elem.(object.Object)) {
-				return true
+	"contains": func(arr *object.Array, elem object.Object) object.Object {
+		for _, v := range arr.Elements {
+			if object.IsEqual(v, elem) {
+				return nativeBool(true)
 			}
 		}
-		return false
+		return nativeBool(false)
 	},
-	"slice": func(arr []interface{}, start, end float64) []interface{} {
-		return arr[int(start):int(end)]
+	"slice": func(arr *object.Array, start, end float64) *object.Array {
+		from := clampIndex(int(start), len(arr.Elements))
+		to := clampIndex(int(end), len(arr.Elements))
+		if to < from {
+			to = from
+		}
+		return arr.Slice(from, to)
 	},
-	"join": func(arr []interface{}, sep string) string {
-		elements := make([]string, len(arr))
-		for i, v := range arr {
-			elements[i] = v.(object.Object).Inspect()
+	"join": func(arr *object.Array, sep string) string {
+		elements := make([]string, len(arr.Elements))
+		for i, v := range arr.Elements {
+			elements[i] = v.Inspect()
 		}
 		return strings.Join(elements, sep)
 	},
@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"1ylang/object"
+)
+
+// ffiFuncs call functions in native shared libraries. The calls themselves
+// need libffi, so they are only built in with `go build -tags ffi`, which
+// needs cgo; otherwise FFI.open fails.
+var ffiFuncs = map[string]interface{}{
+	// open loads a shared library, returning a hash with its path and the
+	// functions declare(name, result, params) and close()
+	"open": func(path string) object.Object {
+		return ffiOpen(path)
+	},
+}
+
+// ffiTypes lists the C types that declared functions may take and return.
+// "string" is a NUL-terminated char *, and "pointer" any other pointer,
+// passed as an integer.
+var ffiTypes = []string{
+	"void", "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64",
+	"int", "long", "size_t", "float", "double", "string", "pointer",
+}
+
+func RegisterFFIFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "FFI", ffiFuncs)
+}
@@ -0,0 +1,264 @@
+//go:build ffi && cgo
+
+package lib
+
+/*
+#cgo LDFLAGS: -lffi -ldl
+#include <dlfcn.h>
+#include <ffi.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+static ffi_type *ffi_type_of(int i) {
+	static ffi_type *types[] = {
+		&ffi_type_void,
+		&ffi_type_sint8, &ffi_type_sint16, &ffi_type_sint32, &ffi_type_sint64,
+		&ffi_type_uint8, &ffi_type_uint16, &ffi_type_uint32, &ffi_type_uint64,
+		&ffi_type_sint, &ffi_type_slong, &ffi_type_uint64,
+		&ffi_type_float, &ffi_type_double,
+		&ffi_type_pointer, &ffi_type_pointer,
+	};
+	return types[i];
+}
+
+static void call(ffi_cif *cif, void *fn, void *result, void **args) {
+	ffi_call(cif, FFI_FN(fn), result, args);
+}
+*/
+import "C"
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"math"
+	"math/big"
+	"strings"
+	"unsafe"
+)
+
+// ffiLibrary is a shared library opened by FFI.open.
+type ffiLibrary struct {
+	path   string
+	handle unsafe.Pointer // nil once closed
+}
+
+func ffiOpen(path string) object.Object {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	handle := C.dlopen(cpath, C.RTLD_NOW)
+	if handle == nil {
+		return newError("cannot open %s: %s", path, C.GoString(C.dlerror()))
+	}
+
+	lib := &ffiLibrary{path: path, handle: handle}
+	hash := object.RegisterFunctions(nil, "", map[string]interface{}{
+		"declare": lib.declare,
+		"close":   lib.close,
+	})
+	key := object.Intern("path")
+	hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: &object.String{Value: path}}
+	return hash
+}
+
+func (lib *ffiLibrary) close() object.Object {
+	if lib.handle != nil {
+		C.dlclose(lib.handle)
+		lib.handle = nil
+	}
+	return evaluator.NULL
+}
+
+// ffiTypeIndex returns the position of name in ffiTypes, which is also that
+// of its ffi_type in ffi_type_of.
+func ffiTypeIndex(name string) (int, *object.Error) {
+	for i, t := range ffiTypes {
+		if t == name {
+			return i, nil
+		}
+	}
+	return 0, newError("unknown FFI type %q, expected one of %s", name, strings.Join(ffiTypes, ", "))
+}
+
+// declare returns a function calling the function name of the library,
+// which takes arguments of the types params and returns one of type result.
+func (lib *ffiLibrary) declare(name, result string, params []string) object.Object {
+	if lib.handle == nil {
+		return newError("cannot declare %s: %s is closed", name, lib.path)
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	fn := C.dlsym(lib.handle, cname)
+	if fn == nil {
+		return newError("cannot declare %s: %s", name, C.GoString(C.dlerror()))
+	}
+
+	resultType, err := ffiTypeIndex(result)
+	if err != nil {
+		return err
+	}
+	paramTypes := make([]int, len(params))
+	for i, param := range params {
+		if paramTypes[i], err = ffiTypeIndex(param); err != nil {
+			return err
+		}
+		if param == "void" {
+			return newError("cannot declare %s: parameters cannot be void", name)
+		}
+	}
+
+	// The call interface is used for as long as the function is, so it is
+	// never freed
+	cif := (*C.ffi_cif)(C.malloc(C.sizeof_ffi_cif))
+	var atypes **C.ffi_type
+	if len(params) > 0 {
+		atypes = (**C.ffi_type)(C.malloc(C.size_t(len(params)) * C.size_t(unsafe.Sizeof(uintptr(0)))))
+		slots := unsafe.Slice(atypes, len(params))
+		for i, t := range paramTypes {
+			slots[i] = C.ffi_type_of(C.int(t))
+		}
+	}
+	if status := C.ffi_prep_cif(cif, C.FFI_DEFAULT_ABI, C.uint(len(params)), C.ffi_type_of(C.int(resultType)), atypes); status != C.FFI_OK {
+		C.free(unsafe.Pointer(cif))
+		C.free(unsafe.Pointer(atypes))
+		return newError("cannot declare %s: libffi status %d", name, int(status))
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if lib.handle == nil {
+			return newError("cannot call %s: %s is closed", name, lib.path)
+		}
+		if len(args) != len(params) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(params))
+		}
+		return ffiCall(cif, fn, name, result, params, args)
+	}}
+}
+
+// ffiCall calls fn through cif with args converted to the types params,
+// converting its result from the type result.
+func ffiCall(cif *C.ffi_cif, fn unsafe.Pointer, name, result string, params []string, args []object.Object) object.Object {
+	// Each argument gets 8 bytes of C memory, enough for any of the types
+	values := (*unsafe.Pointer)(C.calloc(C.size_t(len(args)+1), C.size_t(unsafe.Sizeof(uintptr(0)))))
+	defer C.free(unsafe.Pointer(values))
+	storage := C.calloc(C.size_t(len(args)+1), 8)
+	defer C.free(storage)
+	slots := unsafe.Slice(values, len(args)+1)
+
+	for i, arg := range args {
+		slot := unsafe.Add(storage, i*8)
+		slots[i] = slot
+		if err := ffiStore(slot, params[i], arg); err != nil {
+			return newError("argument %d to %s: %s", i+1, name, err.Message)
+		}
+		if params[i] == "string" {
+			defer C.free(*(*unsafe.Pointer)(slot))
+		}
+	}
+
+	// libffi widens integer results to a full register
+	out := C.calloc(1, 16)
+	defer C.free(out)
+	C.call(cif, fn, out, (*unsafe.Pointer)(unsafe.Pointer(values)))
+	return ffiLoad(out, result)
+}
+
+// ffiStore writes arg to slot as a value of type t.
+func ffiStore(slot unsafe.Pointer, t string, arg object.Object) *object.Error {
+	switch t {
+	case "float", "double":
+		var f float64
+		switch arg := arg.(type) {
+		case *object.Float:
+			f, _ = arg.Value.Float64()
+		case *object.Integer:
+			f, _ = new(big.Float).SetInt(arg.Value).Float64()
+		default:
+			return newError("must be FLOAT, got %s", arg.Type())
+		}
+		if t == "float" {
+			*(*C.float)(slot) = C.float(f)
+		} else {
+			*(*C.double)(slot) = C.double(f)
+		}
+	case "string":
+		s, ok := arg.(*object.String)
+		if !ok {
+			return newError("must be STRING, got %s", arg.Type())
+		}
+		*(**C.char)(slot) = C.CString(s.Value)
+	default:
+		if arg == evaluator.NULL && t == "pointer" {
+			return nil
+		}
+		i, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("must be INTEGER, got %s", arg.Type())
+		}
+		// Values are stored by their low bits, as C converts them
+		var bits uint64
+		if i.Value.IsInt64() {
+			bits = uint64(i.Value.Int64())
+		} else if i.Value.IsUint64() {
+			bits = i.Value.Uint64()
+		} else {
+			return newError("%s is out of range", i.Value)
+		}
+		switch t {
+		case "int8", "uint8":
+			*(*uint8)(slot) = uint8(bits)
+		case "int16", "uint16":
+			*(*uint16)(slot) = uint16(bits)
+		case "int32", "uint32", "int":
+			*(*uint32)(slot) = uint32(bits)
+		default:
+			*(*uint64)(slot) = bits
+		}
+	}
+	return nil
+}
+
+// ffiLoad reads a result of type t from out.
+func ffiLoad(out unsafe.Pointer, t string) object.Object {
+	switch t {
+	case "void":
+		return evaluator.NULL
+	case "float", "double":
+		f := float64(*(*C.double)(out))
+		if t == "float" {
+			f = float64(*(*C.float)(out))
+		}
+		// Floats cannot hold NaN, which big.NewFloat panics on
+		if math.IsNaN(f) {
+			return newError("result is NaN, which 1y floats cannot hold")
+		}
+		return &object.Float{Value: big.NewFloat(f)}
+	case "string":
+		s := *(**C.char)(out)
+		if s == nil {
+			return evaluator.NULL
+		}
+		return &object.String{Value: C.GoString(s)}
+	case "uint8", "uint16", "uint32", "uint64", "size_t", "pointer":
+		bits := *(*uint64)(out)
+		switch t {
+		case "uint8":
+			bits = uint64(uint8(bits))
+		case "uint16":
+			bits = uint64(uint16(bits))
+		case "uint32":
+			bits = uint64(uint32(bits))
+		}
+		return &object.Integer{Value: new(big.Int).SetUint64(bits)}
+	}
+	// Signed results are sign-extended to the full register
+	n := *(*int64)(out)
+	switch t {
+	case "int8":
+		n = int64(int8(n))
+	case "int16":
+		n = int64(int16(n))
+	case "int32", "int":
+		n = int64(int32(n))
+	}
+	return newInteger(n)
+}
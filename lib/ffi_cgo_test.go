@@ -0,0 +1,110 @@
+//go:build ffi && cgo
+
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"math/big"
+	"testing"
+)
+
+// ffiDeclare opens path and declares the function name in it, failing the
+// test if either cannot be done.
+func ffiDeclare(t *testing.T, path, name, result string, params ...string) *object.Builtin {
+	t.Helper()
+	lib, ok := ffiOpen(path).(*object.Hash)
+	if !ok {
+		t.Skipf("cannot open %s", path)
+	}
+	declare := lib.Pairs[object.Intern("declare").HashKey()].Value.(*object.Builtin)
+	paramArray := &object.Array{}
+	for _, p := range params {
+		paramArray.Elements = append(paramArray.Elements, &object.String{Value: p})
+	}
+	fn, ok := declare.Fn(&object.String{Value: name}, &object.String{Value: result}, paramArray).(*object.Builtin)
+	if !ok {
+		t.Fatalf("cannot declare %s", name)
+	}
+	return fn
+}
+
+func TestFFICalls(t *testing.T) {
+	sqrt := ffiDeclare(t, "libm.so.6", "sqrt", "double", "double")
+	if got := sqrt.Fn(&object.Float{Value: big.NewFloat(2.25)}); got.Inspect() != "1.5" {
+		t.Errorf("sqrt(2.25) = %s", got.Inspect())
+	}
+	if got := sqrt.Fn(&object.Integer{Value: big.NewInt(16)}); got.Inspect() != "4.0" {
+		t.Errorf("sqrt(16) = %s", got.Inspect())
+	}
+	if err, ok := sqrt.Fn(&object.Float{Value: big.NewFloat(-1)}).(*object.Error); !ok || err.Message != "result is NaN, which 1y floats cannot hold" {
+		t.Errorf("sqrt(-1) did not fail with NaN. got=%v", err)
+	}
+	if _, ok := sqrt.Fn().(*object.Error); !ok {
+		t.Errorf("sqrt() did not fail")
+	}
+
+	strlen := ffiDeclare(t, "libc.so.6", "strlen", "size_t", "string")
+	if got := strlen.Fn(&object.String{Value: "héllo"}); got.Inspect() != "6" {
+		t.Errorf("strlen(héllo) = %s", got.Inspect())
+	}
+	if err, ok := strlen.Fn(&object.Integer{Value: big.NewInt(1)}).(*object.Error); !ok || err.Message != "argument 1 to strlen: must be STRING, got INTEGER" {
+		t.Errorf("strlen(1) did not fail. got=%v", err)
+	}
+
+	getenv := ffiDeclare(t, "libc.so.6", "getenv", "string", "string")
+	t.Setenv("YLANG_FFI_TEST", "value")
+	if got := getenv.Fn(&object.String{Value: "YLANG_FFI_TEST"}); got.Inspect() != "value" {
+		t.Errorf("getenv = %s", got.Inspect())
+	}
+	if got := getenv.Fn(&object.String{Value: "YLANG_FFI_UNSET"}); got != evaluator.NULL {
+		t.Errorf("getenv of an unset variable = %s", got.Inspect())
+	}
+
+	malloc := ffiDeclare(t, "libc.so.6", "malloc", "pointer", "size_t")
+	free := ffiDeclare(t, "libc.so.6", "free", "void", "pointer")
+	p, ok := malloc.Fn(&object.Integer{Value: big.NewInt(16)}).(*object.Integer)
+	if !ok || p.Value.Sign() == 0 {
+		t.Fatalf("malloc(16) = %v", p)
+	}
+	if got := free.Fn(p); got != evaluator.NULL {
+		t.Errorf("free returned %s", got.Inspect())
+	}
+	if got := free.Fn(evaluator.NULL); got != evaluator.NULL {
+		t.Errorf("free(null) returned %s", got.Inspect())
+	}
+}
+
+func TestFFIDeclareErrors(t *testing.T) {
+	if _, ok := ffiOpen("/nonexistent/lib.so").(*object.Error); !ok {
+		t.Errorf("opening a missing library did not fail")
+	}
+	lib, ok := ffiOpen("libm.so.6").(*object.Hash)
+	if !ok {
+		t.Skip("cannot open libm.so.6")
+	}
+	declare := lib.Pairs[object.Intern("declare").HashKey()].Value.(*object.Builtin)
+	str := func(s string) object.Object { return &object.String{Value: s} }
+	params := func(names ...string) object.Object {
+		arr := &object.Array{}
+		for _, n := range names {
+			arr.Elements = append(arr.Elements, str(n))
+		}
+		return arr
+	}
+
+	tests := []struct {
+		args     []object.Object
+		expected string
+	}{
+		{[]object.Object{str("sqrt"), str("complex"), params()}, `unknown FFI type "complex"`},
+		{[]object.Object{str("sqrt"), str("double"), params("void")}, "cannot declare sqrt: parameters cannot be void"},
+		{[]object.Object{str("no_such_function"), str("void"), params()}, "cannot declare no_such_function"},
+	}
+	for _, tt := range tests {
+		err, ok := declare.Fn(tt.args...).(*object.Error)
+		if !ok || len(err.Message) < len(tt.expected) || err.Message[:len(tt.expected)] != tt.expected {
+			t.Errorf("expected error starting %q, got %v", tt.expected, err)
+		}
+	}
+}
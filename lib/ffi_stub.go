@@ -0,0 +1,9 @@
+//go:build !ffi || !cgo
+
+package lib
+
+import "1ylang/object"
+
+func ffiOpen(path string) object.Object {
+	return newError("cannot open %s: FFI is not built in, build the interpreter with -tags ffi and cgo enabled", path)
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/vfs"
+)
+
+// fileFuncs returns the File functions, which work on the file system of
+// in and remove what they create when in is closed.
+func fileFuncs(in *evaluator.Interpreter) map[string]interface{} {
+	fsys := in.FS()
+	return map[string]interface{}{
+		// tempFile() or tempFile(pattern) creates an empty, uniquely named file
+		// that is removed when the interpreter is closed or exits; a '*' in pattern is
+		// replaced by the random part of the name
+		"tempFile": func(pattern ...string) object.Object {
+			name, err := fsys.CreateTemp(tempPattern(pattern))
+			if err != nil {
+				return newError("cannot create temporary file: %s", err)
+			}
+			return removeAtExit(in, fsys, name)
+		},
+		// tempDir() or tempDir(pattern) creates a uniquely named directory that
+		// is removed with its contents when the interpreter is closed or exits
+		"tempDir": func(pattern ...string) object.Object {
+			dir, err := fsys.MkdirTemp(tempPattern(pattern))
+			if err != nil {
+				return newError("cannot create temporary directory: %s", err)
+			}
+			return removeAtExit(in, fsys, dir)
+		},
+	}
+}
+
+func tempPattern(pattern []string) string {
+	if len(pattern) > 0 {
+		return pattern[0]
+	}
+	return "1y-*"
+}
+
+func removeAtExit(in *evaluator.Interpreter, fsys vfs.FS, path string) *object.String {
+	in.AtExit(func() {
+		fsys.RemoveAll(path)
+	})
+	return &object.String{Value: path}
+}
+
+func RegisterFileFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "File", fileFuncs(evaluator.InterpreterOf(env)))
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/vfs"
+	"sync"
+	"testing"
+)
+
+func TestFileTempRemovedAtClose(t *testing.T) {
+	m := vfs.NewMem()
+	in := evaluator.New(evaluator.Options{FS: m})
+	funcs := fileFuncs(in)
+	tempFile := funcs["tempFile"].(func(...string) object.Object)
+	tempDir := funcs["tempDir"].(func(...string) object.Object)
+
+	file, ok := tempFile("x-*.txt").(*object.String)
+	if !ok {
+		t.Fatalf("tempFile failed")
+	}
+	dir, ok := tempDir().(*object.String)
+	if !ok {
+		t.Fatalf("tempDir failed")
+	}
+	m.WriteFile(dir.Value+"/inner.txt", []byte("z"))
+	for _, name := range []string{file.Value, dir.Value} {
+		if _, err := m.Stat(name); err != nil {
+			t.Fatalf("%s was not created: %v", name, err)
+		}
+	}
+
+	// Only closing the interpreter that created them removes them
+	evaluator.RunExitHooks()
+	if _, err := m.Stat(file.Value); err != nil {
+		t.Fatalf("%s was removed before its interpreter was closed", file.Value)
+	}
+	in.Close()
+	for _, name := range []string{file.Value, dir.Value, dir.Value + "/inner.txt"} {
+		if _, err := m.Stat(name); err == nil {
+			t.Errorf("%s was not removed at close", name)
+		}
+	}
+}
+
+func TestFileTempConcurrent(t *testing.T) {
+	m := vfs.NewMem()
+	in := evaluator.New(evaluator.Options{FS: m})
+	tempFile := fileFuncs(in)["tempFile"].(func(...string) object.Object)
+
+	var wg sync.WaitGroup
+	names := make([]string, 8)
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if name, ok := tempFile().(*object.String); ok {
+				names[i] = name.Value
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	in.Close()
+	for _, name := range names {
+		if name == "" {
+			t.Fatalf("tempFile failed")
+		}
+		if _, err := m.Stat(name); err == nil {
+			t.Errorf("%s was not removed at close", name)
+		}
+	}
+}
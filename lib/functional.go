@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"fmt"
+	"strings"
+)
+
+var functionalFuncs = map[string]interface{}{
+	// compose(f, g, h)(x) is f(g(h(x)))
+	"compose": func(fns ...object.Object) object.Object {
+		for _, fn := range fns {
+			if !isCallable(fn) {
+				return newError("arguments to `compose` must be functions, got %s", fn.Type())
+			}
+		}
+
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if len(fns) == 0 {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				return args[0]
+			}
+
+			result := evaluator.ApplyFunction(fns[len(fns)-1], args)
+			for i := len(fns) - 2; i >= 0; i-- {
+				if isError(result) {
+					return result
+				}
+				result = evaluator.ApplyFunction(fns[i], []object.Object{result})
+			}
+			return result
+		}}
+	},
+	// partial(f, a, b)(c) is f(a, b, c)
+	"partial": func(fn object.Object, bound ...object.Object) object.Object {
+		if !isCallable(fn) {
+			return newError("argument to `partial` must be a function, got %s", fn.Type())
+		}
+
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			all := make([]object.Object, 0, len(bound)+len(args))
+			all = append(all, bound...)
+			all = append(all, args...)
+			return evaluator.ApplyFunction(fn, all)
+		}}
+	},
+	// curry(f) collects arguments until the arity of f is reached; builtins
+	// need their arity passed explicitly as curry(f, n)
+	"curry": func(fn object.Object, arity ...object.Object) object.Object {
+		n := -1
+		if function, ok := fn.(*object.Function); ok {
+			n = len(function.Parameters)
+		} else if !isCallable(fn) {
+			return newError("argument to `curry` must be a function, got %s", fn.Type())
+		}
+
+		if len(arity) > 1 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(arity)+1)
+		}
+		if len(arity) == 1 {
+			integer, ok := arity[0].(*object.Integer)
+			if !ok {
+				return newError("arity passed to `curry` must be INTEGER, got %s", arity[0].Type())
+			}
+			n = int(integer.Value.Int64())
+		}
+		if n < 0 {
+			return newError("arity of builtin functions must be passed to `curry`")
+		}
+
+		return curry(fn, n, nil)
+	},
+	// memoize(f) caches results by the hash keys of the arguments; calls with
+	// unhashable arguments are not cached
+	"memoize": func(fn object.Object) object.Object {
+		if !isCallable(fn) {
+			return newError("argument to `memoize` must be a function, got %s", fn.Type())
+		}
+
+		cache := make(map[string]object.Object)
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			key, ok := memoKey(args)
+			if ok {
+				if result, found := cache[key]; found {
+					return result
+				}
+			}
+
+			result := evaluator.ApplyFunction(fn, args)
+			if ok && !isError(result) {
+				cache[key] = result
+			}
+			return result
+		}}
+	},
+}
+
+func curry(fn object.Object, arity int, collected []object.Object) object.Object {
+	if len(collected) >= arity {
+		return evaluator.ApplyFunction(fn, collected)
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return newError("curried function called without arguments")
+		}
+		next := make([]object.Object, 0, len(collected)+len(args))
+		next = append(next, collected...)
+		next = append(next, args...)
+		return curry(fn, arity, next)
+	}}
+}
+
+// memoKey combines the hash keys of args, reporting false if any argument is not hashable.
+func memoKey(args []object.Object) (string, bool) {
+	var key strings.Builder
+	for _, arg := range args {
+		hashable, ok := object.AsHashable(arg)
+		if !ok {
+			return "", false
+		}
+		hashKey := hashable.HashKey()
+		fmt.Fprintf(&key, "%s:%d;", hashKey.Type, hashKey.Value)
+	}
+	return key.String(), true
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+func RegisterFunctionalFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "Fn", functionalFuncs)
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/object"
+	"1ylang/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testEval runs input in a fresh environment with the libraries declared.
+// It panics if input does not parse, so that a test cannot pass on a
+// program that never ran.
+func testEval(input string) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		panic(fmt.Sprintf("parsing %q failed: %s", input, strings.Join(errs, "; ")))
+	}
+	env := evaluator.NewEnvironment()
+	Register(env)
+	return evaluator.Eval(program, env)
+}
+
+func TestFunctional(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`Fn.compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)`, "11"},
+		{`Fn.compose(fn(x) { x * 2 }, fn(x) { x + 1 })(5)`, "12"},
+		{`Fn.compose(fn(x) { x + 1 }, fn(a, b) { a * b })(3, 4)`, "13"},
+		{`Fn.compose(len, fn(s) { s + "!" })("abc")`, "4"},
+		{`Fn.compose()(7)`, "7"},
+
+		{`Fn.partial(fn(a, b, c) { a * 100 + b * 10 + c }, 1, 2)(3)`, "123"},
+		{`Fn.partial(fn(a, b) { a - b })(5, 3)`, "2"},
+		{`Fn.partial(len, "abcd")()`, "4"},
+
+		{`Fn.curry(fn(a, b, c) { a * 100 + b * 10 + c })(1)(2)(3)`, "123"},
+		{`Fn.curry(fn(a, b, c) { a * 100 + b * 10 + c })(1, 2)(3)`, "123"},
+		{`Fn.curry(fn(a, b, c) { a * 100 + b * 10 + c })(1)(2, 3)`, "123"},
+		{`let add = Fn.curry(fn(a, b) { a + b }); let inc = add(1); [inc(1), inc(2)]`, "[2, 3]"},
+		{`Fn.curry(fn() { 42 })`, "42"},
+		{`Fn.curry(len, 1)("abc")`, "3"},
+
+		{`let calls = 0; let f = Fn.memoize(fn(x) { calls = calls + 1; x * 2 }); [f(2), f(2), f(3), calls]`, "[4, 4, 6, 2]"},
+		{`let calls = 0; let f = Fn.memoize(fn(a, b) { calls = calls + 1; a + b }); [f(1, 2), f(2, 1), f(1, 2), calls]`, "[3, 3, 3, 2]"},
+		{`let calls = 0; let f = Fn.memoize(fn(x) { calls = calls + 1; x }); f("a"); f([1, 2]); f([1, 2]); calls`, "2"},
+		{`let fib = Fn.memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }); fib(80)`, "23416728348467685"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestMemoizeUnhashableArguments(t *testing.T) {
+	// Functions cannot be hash keys, so calls with them are passed through
+	// every time instead of being cached
+	input := `
+let calls = 0;
+let f = Fn.memoize(fn(g) { calls = calls + 1; g(1) });
+let inc = fn(x) { x + 1 };
+[f(inc), f(inc), f(inc), calls]`
+
+	if got := testEval(input); got.Inspect() != "[2, 2, 2, 3]" {
+		t.Errorf("got %s, want [2, 2, 2, 3]", got.Inspect())
+	}
+
+	if _, ok := memoKey([]object.Object{newInteger(1), &object.Function{}}); ok {
+		t.Errorf("memoKey accepted a function")
+	}
+	if _, ok := memoKey([]object.Object{newInteger(1), &object.Hash{}}); !ok {
+		t.Errorf("memoKey rejected a hash")
+	}
+}
+
+func TestMemoizeDoesNotCacheErrors(t *testing.T) {
+	calls := 0
+	fail := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		calls++
+		return newError("failed")
+	}}
+	f := functionalFuncs["memoize"].(func(object.Object) object.Object)(fail).(*object.Builtin)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := f.Fn(newInteger(1)).(*object.Error); !ok {
+			t.Fatalf("memoized call did not fail")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("failing function called %d times, want 2", calls)
+	}
+}
+
+func TestFunctionalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`Fn.compose(fn(x) { x }, 1)`, "arguments to `compose` must be functions, got INTEGER"},
+		{`Fn.compose()(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`Fn.partial("f", 1)`, "argument to `partial` must be a function, got STRING"},
+		{`Fn.curry(1)`, "argument to `curry` must be a function, got INTEGER"},
+		{`Fn.curry(len)`, "arity of builtin functions must be passed to `curry`"},
+		{`Fn.curry(fn(a, b) { a }, 2, 3)`, "wrong number of arguments. got=3, want=1 or 2"},
+		{`Fn.curry(fn(a, b) { a }, "2")`, "arity passed to `curry` must be INTEGER, got STRING"},
+		{`Fn.curry(len, -1)`, "arity of builtin functions must be passed to `curry`"},
+		{`Fn.curry(fn(a, b) { a })(1)()`, "curried function called without arguments"},
+		{`Fn.memoize([])`, "argument to `memoize` must be a function, got ARRAY"},
+		{`Fn.compose(fn(x) { x + 1 }, fn(x) { x / 0 })(1)`, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s did not fail", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%s failed with %q, want %q", tt.input, err.Message, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/vfs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob patterns use '/' separated segments matched with path.Match syntax
+// ('*', '?', '[a-z]'), plus '**' which matches any number of directories.
+var globFuncs = map[string]interface{}{
+	"match": func(pattern, name string) object.Object {
+		matched, err := globMatch(pattern, filepath.ToSlash(name))
+		if err != nil {
+			return newError("bad glob pattern %q", pattern)
+		}
+		return nativeBool(matched)
+	},
+}
+
+// globFilesFunc returns Glob.files, which lists files of fsys.
+func globFilesFunc(fsys vfs.FS) func(pattern string) object.Object {
+	// files(pattern) lists the files matching pattern, sorted by path
+	return func(pattern string) object.Object {
+		pattern = filepath.ToSlash(pattern)
+		if _, err := globMatch(pattern, ""); err != nil {
+			return newError("bad glob pattern %q", pattern)
+		}
+
+		var matches []object.Object
+		walkFiles(fsys, globRoot(pattern), func(p string) {
+			if ok, _ := globMatch(pattern, p); ok {
+				matches = append(matches, &object.String{Value: p})
+			}
+		})
+
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].Inspect() < matches[j].Inspect()
+		})
+		if matches == nil {
+			matches = []object.Object{}
+		}
+		return &object.Array{Elements: matches}
+	}
+}
+
+// walkFiles calls fn with the path of each file under dir in fsys.
+// Directories that cannot be read are skipped.
+func walkFiles(fsys vfs.FS, dir string, fn func(p string)) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			walkFiles(fsys, p, fn)
+		} else {
+			fn(p)
+		}
+	}
+}
+
+// globRoot returns the leading directories of pattern that contain no wildcards.
+func globRoot(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	root := []string{}
+	for _, segment := range segments[:len(segments)-1] {
+		if strings.ContainsAny(segment, "*?[") {
+			break
+		}
+		root = append(root, segment)
+	}
+
+	if len(root) == 0 {
+		return "."
+	}
+	if joined := strings.Join(root, "/"); joined != "" {
+		return joined
+	}
+	return "/"
+}
+
+func globMatch(pattern, name string) (bool, error) {
+	patternSegments := strings.Split(path.Clean(pattern), "/")
+	nameSegments := strings.Split(path.Clean(name), "/")
+	return matchSegments(patternSegments, nameSegments)
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// '**' swallows zero or more name segments
+			for skip := 0; skip <= len(name); skip++ {
+				if ok, err := matchSegments(pattern[1:], name[skip:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0, nil
+}
+
+func RegisterGlobFuncs(env *object.Environment) {
+	funcs := map[string]interface{}{"files": globFilesFunc(evaluator.InterpreterOf(env).FS())}
+	for name, fn := range globFuncs {
+		funcs[name] = fn
+	}
+	object.RegisterFunctions(env, "Glob", funcs)
+}
@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"1ylang/object"
+	"1ylang/vfs"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	match := globFuncs["match"].(func(string, string) object.Object)
+
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "main.c", false},
+		{"*.go", "lib/main.go", false},
+		{"lib/?.go", "lib/a.go", true},
+		{"lib/?.go", "lib/ab.go", false},
+		{"[a-c]*.txt", "b1.txt", true},
+		{"[a-c]*.txt", "d1.txt", false},
+		{"./lib/*.go", "lib/a.go", true},
+		{"lib/*.go", "./lib//a.go", true},
+
+		// '**' matches zero or more directories
+		{"**", "a", true},
+		{"**", "a/b/c", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/main.go", true},
+		{"**/*.go", "a/b/main.c", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/a/main.go", false},
+		{"src/**", "src/a/b", true},
+		{"src/**", "lib/a", false},
+		{"a/**/b/**/c", "a/b/c", true},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/c", false},
+		{"**/test/*", "test/x", true},
+		{"**/test/*", "a/test/x/y", false},
+		{"a/**", "a", true},
+		// '**' only counts as a whole segment
+		{"a**/b", "abc/b", true},
+		{"a**/b", "abc/d/b", false},
+	}
+
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.name); got != nativeBool(tt.expected) {
+			t.Errorf("match(%q, %q) = %s, want %t", tt.pattern, tt.name, got.Inspect(), tt.expected)
+		}
+	}
+
+	err, ok := match("[a-", "a").(*object.Error)
+	if !ok || err.Message != `bad glob pattern "[a-"` {
+		t.Errorf("match with a bad pattern returned %v", err)
+	}
+}
+
+func TestGlobRoot(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"*.go", "."},
+		{"**/*.go", "."},
+		{"src/*.go", "src"},
+		{"src/lib/**/*.go", "src/lib"},
+		{"src/*/x/*.go", "src"},
+		{"/abs/*.go", "/abs"},
+		{"/*.go", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := globRoot(tt.pattern); got != tt.expected {
+			t.Errorf("globRoot(%q) = %q, want %q", tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+func TestGlobFiles(t *testing.T) {
+	m := vfs.NewMem()
+	for _, name := range []string{"main.go", "README.md", "lib/a.go", "lib/b.c", "lib/sub/c.go", "src/d.go"} {
+		m.WriteFile(name, nil)
+	}
+	files := globFilesFunc(m)
+
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"*.go", `["main.go"]`},
+		{"**/*.go", `["lib/a.go", "lib/sub/c.go", "main.go", "src/d.go"]`},
+		{"lib/**/*.go", `["lib/a.go", "lib/sub/c.go"]`},
+		{"lib/*", `["lib/a.go", "lib/b.c"]`},
+		{"*/*.go", `["lib/a.go", "src/d.go"]`},
+		{"missing/**", `[]`},
+		{"*.rs", `[]`},
+	}
+
+	for _, tt := range tests {
+		if got := files(tt.pattern); got.Inspect() != tt.expected {
+			t.Errorf("files(%q) = %s, want %s", tt.pattern, got.Inspect(), tt.expected)
+		}
+	}
+
+	if _, ok := files("[").(*object.Error); !ok {
+		t.Errorf("files with a bad pattern did not fail")
+	}
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"fmt"
+	"math/big"
+)
+
+// Register declares the libraries in env. Those reaching the file system
+// are left out when the interpreter env belongs to is sandboxed, so that
+// interpreters with different sandboxes each get the libraries they allow,
+// except for File, Glob and Watch if the interpreter was given a file
+// system of its own to confine them to.
+func Register(env *object.Environment) {
+	RegisterStringFuncs(env)
+	RegisterArrayFuncs(env)
+	RegisterMathFuncs(env)
+	RegisterPackFuncs(env)
+	RegisterFunctionalFuncs(env)
+	RegisterTimeFuncs(env)
+	RegisterTermFuncs(env)
+	in := evaluator.InterpreterOf(env)
+	if !in.Sandboxed() || in.Options().FS != nil {
+		RegisterGlobFuncs(env)
+		RegisterFileFuncs(env)
+		RegisterWatchFuncs(env)
+	}
+	if !in.Sandboxed() {
+		// Plugins reach the file system of the operating system
+		RegisterFFIFuncs(env)
+	}
+}
+
+// newError creates an error object that is propagated like any runtime error.
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// toInt64 extracts a machine integer from an INTEGER object.
+func toInt64(obj object.Object, name string) (int64, *object.Error) {
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, newError("%s must be INTEGER, got %s", name, obj.Type())
+	}
+	if !integer.Value.IsInt64() {
+		return 0, newError("%s out of range: %s", name, integer.Value)
+	}
+	return integer.Value.Int64(), nil
+}
+
+// newInteger wraps a machine integer in an INTEGER object.
+func newInteger(n int64) *object.Integer {
+	return &object.Integer{Value: big.NewInt(n)}
+}
+
+// nativeBool returns the shared boolean object so truthiness checks in the evaluator work.
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
@@ -0,0 +1,330 @@
+package lib
+
+import (
+	"1ylang/object"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Pack formats follow Python's struct module: an optional byte order prefix
+// ('<' little, '>' or '!' big, '=' or '@' native, here little endian) followed by
+// codes, each optionally preceded by a repeat count:
+//
+//	x pad byte   b/B int8/uint8    h/H int16/uint16   i/I int32/uint32
+//	q/Q int64/uint64   f float32   d float64   ? bool   s string of count bytes
+//
+// Bytes are represented as arrays of integers in the range 0-255.
+var packFuncs = map[string]interface{}{
+	"pack": func(format string, values *object.Array) object.Object {
+		return pack(format, values.Elements)
+	},
+	"unpack": func(format string, data *object.Array) object.Object {
+		return unpack(format, data.Elements)
+	},
+	"size": func(format string) object.Object {
+		fields, _, err := parsePackFormat(format)
+		if err != nil {
+			return err
+		}
+		size := 0
+		for _, f := range fields {
+			size += f.size()
+		}
+		return &object.Integer{Value: big.NewInt(int64(size))}
+	},
+}
+
+// packOrder reads and appends multi-byte values in a fixed byte order.
+type packOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// maxPackSize bounds the number of bytes a pack format may describe, so that
+// a huge count is an error instead of an overflow or an enormous allocation.
+const maxPackSize = 1 << 24
+
+type packField struct {
+	code  byte
+	count int
+}
+
+func (f packField) size() int {
+	switch f.code {
+	case 'x', 'b', 'B', '?', 's':
+		return f.count
+	case 'h', 'H':
+		return 2 * f.count
+	case 'i', 'I', 'f':
+		return 4 * f.count
+	default:
+		return 8 * f.count
+	}
+}
+
+func parsePackFormat(format string) ([]packField, packOrder, *object.Error) {
+	var order packOrder = binary.LittleEndian
+	codes := format
+	if len(codes) > 0 {
+		switch codes[0] {
+		case '<', '=', '@':
+			codes = codes[1:]
+		case '>', '!':
+			order = binary.BigEndian
+			codes = codes[1:]
+		}
+	}
+
+	var fields []packField
+	size := 0
+	for i := 0; i < len(codes); i++ {
+		start := i
+		for i < len(codes) && codes[i] >= '0' && codes[i] <= '9' {
+			i++
+		}
+		count := 1
+		if i > start {
+			n, err := strconv.Atoi(codes[start:i])
+			if err != nil || n > maxPackSize {
+				return nil, nil, newError("count %s in pack format %q is too large", codes[start:i], format)
+			}
+			count = n
+		}
+		if i >= len(codes) {
+			return nil, nil, newError("pack format %q ends with a count", format)
+		}
+
+		switch codes[i] {
+		case ' ':
+			continue
+		case 'x', 'b', 'B', 'h', 'H', 'i', 'I', 'q', 'Q', 'f', 'd', '?', 's':
+			field := packField{code: codes[i], count: count}
+			// Each field is at most 8*maxPackSize bytes, so the sum cannot
+			// overflow before it passes the limit
+			if size += field.size(); size > maxPackSize {
+				return nil, nil, newError("pack format %q describes more than %d bytes", format, maxPackSize)
+			}
+			fields = append(fields, field)
+		default:
+			return nil, nil, newError("unknown code %q in pack format %q", codes[i], format)
+		}
+	}
+
+	return fields, order, nil
+}
+
+func pack(format string, values []object.Object) object.Object {
+	fields, order, err := parsePackFormat(format)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	next := 0
+	take := func() (object.Object, *object.Error) {
+		if next >= len(values) {
+			return nil, newError("not enough values for pack format %q", format)
+		}
+		next++
+		return values[next-1], nil
+	}
+
+	for _, f := range fields {
+		switch f.code {
+		case 'x':
+			buf = append(buf, make([]byte, f.count)...)
+			continue
+		case 's':
+			value, err := take()
+			if err != nil {
+				return err
+			}
+			str, ok := value.(*object.String)
+			if !ok {
+				return newError("pack code 's' requires STRING, got %s", value.Type())
+			}
+			field := make([]byte, f.count)
+			copy(field, str.Value)
+			buf = append(buf, field...)
+			continue
+		}
+
+		for n := 0; n < f.count; n++ {
+			value, err := take()
+			if err != nil {
+				return err
+			}
+			if buf, err = packValue(buf, order, f.code, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if next != len(values) {
+		return newError("too many values for pack format %q: got %d, used %d", format, len(values), next)
+	}
+
+	elements := make([]object.Object, len(buf))
+	for i, b := range buf {
+		elements[i] = &object.Integer{Value: big.NewInt(int64(b))}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// packRanges holds the least and greatest integer each integer code can
+// pack.
+var packRanges = map[byte][2]*big.Int{
+	'b': {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	'B': {big.NewInt(0), big.NewInt(math.MaxUint8)},
+	'h': {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	'H': {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	'i': {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	'I': {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	'q': {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	'Q': {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+}
+
+func packValue(buf []byte, order packOrder, code byte, value object.Object) ([]byte, *object.Error) {
+	switch code {
+	case '?':
+		b, ok := value.(*object.Boolean)
+		if !ok {
+			return nil, newError("pack code '?' requires BOOLEAN, got %s", value.Type())
+		}
+		if b.Value {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case 'f', 'd':
+		var f float64
+		switch v := value.(type) {
+		case *object.Integer:
+			f, _ = new(big.Float).SetInt(v.Value).Float64()
+		case *object.Float:
+			f, _ = v.Value.Float64()
+		default:
+			return nil, newError("pack code '%c' requires FLOAT, got %s", code, value.Type())
+		}
+		if code == 'f' {
+			return order.AppendUint32(buf, math.Float32bits(float32(f))), nil
+		}
+		return order.AppendUint64(buf, math.Float64bits(f)), nil
+	}
+
+	integer, ok := value.(*object.Integer)
+	if !ok {
+		return nil, newError("pack code '%c' requires INTEGER, got %s", code, value.Type())
+	}
+	limits := packRanges[code]
+	if integer.Value.Cmp(limits[0]) < 0 || integer.Value.Cmp(limits[1]) > 0 {
+		return nil, newError("integer %s out of range for pack code '%c'", integer.Value, code)
+	}
+	n := integer.Value.Int64()
+	if !integer.Value.IsInt64() {
+		n = int64(integer.Value.Uint64())
+	}
+
+	switch code {
+	case 'b', 'B':
+		return append(buf, byte(n)), nil
+	case 'h', 'H':
+		return order.AppendUint16(buf, uint16(n)), nil
+	case 'i', 'I':
+		return order.AppendUint32(buf, uint32(n)), nil
+	default:
+		return order.AppendUint64(buf, uint64(n)), nil
+	}
+}
+
+func unpack(format string, data []object.Object) object.Object {
+	fields, order, err := parsePackFormat(format)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(data))
+	for i, el := range data {
+		b, ok := el.(*object.Integer)
+		if !ok || b.Value.Sign() < 0 || b.Value.Cmp(big.NewInt(255)) > 0 {
+			return newError("unpack data must be an array of bytes, got %s at %d", el.Inspect(), i)
+		}
+		buf[i] = byte(b.Value.Int64())
+	}
+
+	size := 0
+	for _, f := range fields {
+		size += f.size()
+	}
+	if size != len(buf) {
+		return newError("unpack format %q requires %d bytes, got %d", format, size, len(buf))
+	}
+
+	var values []object.Object
+	for _, f := range fields {
+		switch f.code {
+		case 'x':
+			buf = buf[f.count:]
+			continue
+		case 's':
+			values = append(values, &object.String{Value: string(buf[:f.count])})
+			buf = buf[f.count:]
+			continue
+		}
+
+		for n := 0; n < f.count; n++ {
+			var value object.Object
+			switch f.code {
+			case '?':
+				value = nativeBool(buf[0] != 0)
+				buf = buf[1:]
+			case 'b':
+				value = &object.Integer{Value: big.NewInt(int64(int8(buf[0])))}
+				buf = buf[1:]
+			case 'B':
+				value = &object.Integer{Value: big.NewInt(int64(buf[0]))}
+				buf = buf[1:]
+			case 'h':
+				value = &object.Integer{Value: big.NewInt(int64(int16(order.Uint16(buf))))}
+				buf = buf[2:]
+			case 'H':
+				value = &object.Integer{Value: big.NewInt(int64(order.Uint16(buf)))}
+				buf = buf[2:]
+			case 'i':
+				value = &object.Integer{Value: big.NewInt(int64(int32(order.Uint32(buf))))}
+				buf = buf[4:]
+			case 'I':
+				value = &object.Integer{Value: big.NewInt(int64(order.Uint32(buf)))}
+				buf = buf[4:]
+			case 'q':
+				value = &object.Integer{Value: big.NewInt(int64(order.Uint64(buf)))}
+				buf = buf[8:]
+			case 'Q':
+				value = &object.Integer{Value: new(big.Int).SetUint64(order.Uint64(buf))}
+				buf = buf[8:]
+			case 'f', 'd':
+				var number float64
+				if f.code == 'f' {
+					number = float64(math.Float32frombits(order.Uint32(buf)))
+					buf = buf[4:]
+				} else {
+					number = math.Float64frombits(order.Uint64(buf))
+					buf = buf[8:]
+				}
+				// Floats cannot hold NaN, which big.NewFloat panics on
+				if math.IsNaN(number) {
+					return newError("unpack code '%c' found NaN, which 1y floats cannot hold", f.code)
+				}
+				value = &object.Float{Value: big.NewFloat(number)}
+			}
+			values = append(values, value)
+		}
+	}
+
+	return &object.Array{Elements: values}
+}
+
+func RegisterPackFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "Pack", packFuncs)
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"1ylang/object"
+	"math/big"
+	"testing"
+)
+
+func packInts(values ...int64) []object.Object {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.Integer{Value: big.NewInt(v)}
+	}
+	return elements
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	maxUint64, _ := new(big.Int).SetString("18446744073709551615", 10)
+	values := []object.Object{
+		&object.Integer{Value: big.NewInt(-128)},
+		&object.Integer{Value: big.NewInt(255)},
+		&object.Integer{Value: big.NewInt(-32768)},
+		&object.Integer{Value: big.NewInt(65535)},
+		&object.Integer{Value: big.NewInt(-2147483648)},
+		&object.Integer{Value: big.NewInt(4294967295)},
+		&object.Integer{Value: big.NewInt(-9223372036854775808)},
+		&object.Integer{Value: maxUint64},
+		&object.Float{Value: big.NewFloat(1.5)},
+		&object.Float{Value: big.NewFloat(-0.25)},
+		nativeBool(true),
+		&object.String{Value: "ab\x00"},
+	}
+	expected := `[-128, 255, -32768, 65535, -2147483648, 4294967295, -9223372036854775808, 18446744073709551615, 1.5, -0.25, true, "ab` + "\\x00" + `"]`
+
+	for _, format := range []string{"bBhHiIqQfd?x3s", "<bBhHiIqQfd?x3s", ">bBhHiIqQfd?x3s", "!bBhHiIqQfd?x3s", "=bBhHiIqQfd?x3s"} {
+		packed := pack(format, values)
+		data, ok := packed.(*object.Array)
+		if !ok {
+			t.Fatalf("pack(%q) failed: %s", format, packed.Inspect())
+		}
+		if len(data.Elements) != 1+1+2+2+4+4+8+8+4+8+1+1+3 {
+			t.Errorf("pack(%q) made %d bytes", format, len(data.Elements))
+		}
+		unpacked := unpack(format, data.Elements)
+		if got := unpacked.Inspect(); got != expected {
+			t.Errorf("unpack(%q) = %s, want %s", format, got, expected)
+		}
+	}
+}
+
+func TestPackByteOrder(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"<h", "[2, 1]"},
+		{">h", "[1, 2]"},
+		{"!h", "[1, 2]"},
+		{"h", "[2, 1]"},
+		{"<i", "[2, 1, 0, 0]"},
+		{">i", "[0, 0, 1, 2]"},
+	}
+
+	for _, tt := range tests {
+		if got := pack(tt.format, packInts(0x0102)).Inspect(); got != tt.expected {
+			t.Errorf("pack(%q) = %s, want %s", tt.format, got, tt.expected)
+		}
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	nan := packInts(0, 0, 0, 0, 0, 0, 248, 127)
+	tests := []struct {
+		result   object.Object
+		expected string
+	}{
+		{pack("b", packInts(300)), "integer 300 out of range for pack code 'b'"},
+		{pack("B", packInts(-1)), "integer -1 out of range for pack code 'B'"},
+		{pack("H", packInts(65536)), "integer 65536 out of range for pack code 'H'"},
+		{pack("i", packInts(2147483648)), "integer 2147483648 out of range for pack code 'i'"},
+		{pack(">2", nil), `pack format ">2" ends with a count`},
+		{pack("<4000000000000s", []object.Object{&object.String{Value: "a"}}), `count 4000000000000 in pack format "<4000000000000s" is too large`},
+		{pack("99999999999999999999b", nil), `count 99999999999999999999 in pack format "99999999999999999999b" is too large`},
+		{unpack("2305843009213693952q", nil), `count 2305843009213693952 in pack format "2305843009213693952q" is too large`},
+		{unpack("16777216q", nil), `pack format "16777216q" describes more than 16777216 bytes`},
+		{unpack("16777216s16777216s", nil), `pack format "16777216s16777216s" describes more than 16777216 bytes`},
+		{pack("<z", nil), `unknown code 'z' in pack format "<z"`},
+		{pack("<2b", packInts(1)), `not enough values for pack format "<2b"`},
+		{pack("b", packInts(1, 2)), `too many values for pack format "b": got 2, used 1`},
+		{pack("?", packInts(1)), "pack code '?' requires BOOLEAN, got INTEGER"},
+		{pack("d", []object.Object{&object.String{Value: "x"}}), "pack code 'd' requires FLOAT, got STRING"},
+		{pack("s", packInts(1)), "pack code 's' requires STRING, got INTEGER"},
+		{unpack("<h", packInts(1)), `unpack format "<h" requires 2 bytes, got 1`},
+		{unpack("b", packInts(256)), "unpack data must be an array of bytes, got 256 at 0"},
+		{unpack("d", nan), "unpack code 'd' found NaN, which 1y floats cannot hold"},
+		{unpack("f", packInts(0, 0, 192, 127)), "unpack code 'f' found NaN, which 1y floats cannot hold"},
+	}
+
+	for _, tt := range tests {
+		err, ok := tt.result.(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q. got=%s", tt.expected, tt.result.Inspect())
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, err.Message)
+		}
+	}
+
+	inf := unpack("d", packInts(0, 0, 0, 0, 0, 0, 240, 127))
+	if f, ok := inf.(*object.Array).Elements[0].(*object.Float); !ok || !f.Value.IsInf() {
+		t.Errorf("unpack of +Inf = %s", inf.Inspect())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"1ylang/object"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 var stringFuncs = map[string]interface{}{
@@ -11,7 +12,7 @@ var stringFuncs = map[string]interface{}{
 		return a + b
 	},
 	"len": func(s string) float64 {
-		return float64(len(s))
+		return float64(utf8.RuneCountInString(s))
 	},
 	"upper": func(s string) string {
 		return strings.ToUpper(s)
@@ -35,10 +36,30 @@ var stringFuncs = map[string]interface{}{
 		return strings.Join(elems, sep)
 	},
 	"index": func(s, substr string) float64 {
-		return float64(strings.Index(s, substr))
+		return float64(runeIndex(s, strings.Index(s, substr)))
 	},
 	"lastIndex": func(s, substr string) float64 {
-		return float64(strings.LastIndex(s, substr))
+		return float64(runeIndex(s, strings.LastIndex(s, substr)))
+	},
+	"substr": func(s string, start, length float64) string {
+		runes := []rune(s)
+		from := clampIndex(int(start), len(runes))
+		// A negative length selects nothing rather than running backwards
+		to := clampIndex(from+int(length), len(runes))
+		if to < from {
+			to = from
+		}
+		return string(runes[from:to])
+	},
+	"template": func(tmpl string, values *object.Hash) string {
+		return expandTemplate(tmpl, values)
+	},
+	"chars": func(s string) []string {
+		chars := make([]string, 0, utf8.RuneCountInString(s))
+		for _, r := range s {
+			chars = append(chars, string(r))
+		}
+		return chars
 	},
 	"hasPrefix": func(s, prefix string) bool {
 		return strings.HasPrefix(s, prefix)
@@ -90,6 +111,62 @@ var stringFuncs = map[string]interface{}{
 	},
 }
 
+// expandTemplate replaces {name} placeholders in tmpl with the matching values
+// from the hash. Unknown placeholders are kept as is and "{{" / "}}" produce
+// literal braces.
+func expandTemplate(tmpl string, values *object.Hash) string {
+	var out strings.Builder
+
+	for i := 0; i < len(tmpl); i++ {
+		ch := tmpl[i]
+		switch {
+		case ch == '{' && strings.HasPrefix(tmpl[i:], "{{"):
+			out.WriteByte('{')
+			i++
+		case ch == '}' && strings.HasPrefix(tmpl[i:], "}}"):
+			out.WriteByte('}')
+			i++
+		case ch == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				out.WriteString(tmpl[i:])
+				return out.String()
+			}
+			name := tmpl[i+1 : i+end]
+			key := &object.String{Value: strings.TrimSpace(name)}
+			if pair, ok := values.Pairs[key.HashKey()]; ok {
+				out.WriteString(pair.Value.Inspect())
+			} else {
+				out.WriteString(tmpl[i : i+end+1])
+			}
+			i += end
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return out.String()
+}
+
+// runeIndex converts a byte offset into s to a rune offset, keeping -1 for "not found".
+func runeIndex(s string, byteIndex int) int {
+	if byteIndex < 0 {
+		return byteIndex
+	}
+	return utf8.RuneCountInString(s[:byteIndex])
+}
+
+// clampIndex limits i to the range [0, length].
+func clampIndex(i, length int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
 func RegisterStringFuncs(env *object.Environment) {
 	object.RegisterFunctions(env, "String", stringFuncs)
 }
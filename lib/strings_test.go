@@ -0,0 +1,96 @@
+package lib
+
+import "testing"
+
+func TestStringIndex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`String.index("hello", "l")`, "2.0"},
+		{`String.index("hello", "z")`, "-1.0"},
+		{`String.index("", "")`, "0.0"},
+		{`String.index("你好世界", "世")`, "2.0"},
+		{`String.index("héllo wörld", "w")`, "6.0"},
+		{`String.lastIndex("hello", "l")`, "3.0"},
+		{`String.lastIndex("hello", "z")`, "-1.0"},
+		{`String.lastIndex("你好你好", "你")`, "2.0"},
+		{`String.lastIndex("aé aé", "é")`, "4.0"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStringSubstr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`String.substr("hello", 1, 3)`, "ell"},
+		{`String.substr("hello", 0, 5)`, "hello"},
+		{`String.substr("hello", 3, 100)`, "lo"},
+		{`String.substr("hello", -2, 3)`, "hel"},
+		{`String.substr("hello", 10, 2)`, ""},
+		{`String.substr("hello", 3, 0)`, ""},
+		{`String.substr("hello", 3, -2)`, ""},
+		{`String.substr("hello", 5, -10)`, ""},
+		{`String.substr("你好世界", 1, 2)`, "好世"},
+		{`String.substr("", 0, 3)`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %q", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStringChars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`String.chars("abc")`, `["a", "b", "c"]`},
+		{`String.chars("")`, `[]`},
+		{`String.chars("你好")`, `["你", "好"]`},
+		{`String.chars("a😀b")`, `["a", "😀", "b"]`},
+		{`len(String.chars("héllo"))`, "5"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStringTemplate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`String.template("Hello, {name}!", {"name": "1y"})`, "Hello, 1y!"},
+		{`String.template("{a}{b}{a}", {"a": "x", "b": "y"})`, "xyx"},
+		{`String.template("{ name }", {"name": "spaced"})`, "spaced"},
+		{`String.template("no placeholders", {})`, "no placeholders"},
+		{`String.template("", {"a": 1})`, ""},
+		{`String.template("{{name}}", {"name": "x"})`, "{name}"},
+		{`String.template("{{{name}}}", {"name": "x"})`, "{x}"},
+		{`String.template("a }} b", {})`, "a } b"},
+		{`String.template("Hi {missing}", {"name": "x"})`, "Hi {missing}"},
+		{`String.template("open {name", {"name": "x"})`, "open {name"},
+		{`String.template("{n} + {f} = {ok}", {"n": 1, "f": 2.5, "ok": true})`, "1 + 2.5 = true"},
+		{`String.template("{list}", {"list": [1, 2]})`, "[1, 2]"},
+		{`String.template("{1}", {1: "one"})`, "{1}"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %q", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
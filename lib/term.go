@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var termStyles = map[string]string{
+	"reset":     "0",
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+	"inverse":   "7",
+	"black":     "30",
+	"red":       "31",
+	"green":     "32",
+	"yellow":    "33",
+	"blue":      "34",
+	"magenta":   "35",
+	"cyan":      "36",
+	"white":     "37",
+	"gray":      "90",
+	"bgBlack":   "40",
+	"bgRed":     "41",
+	"bgGreen":   "42",
+	"bgYellow":  "43",
+	"bgBlue":    "44",
+	"bgMagenta": "45",
+	"bgCyan":    "46",
+	"bgWhite":   "47",
+}
+
+// termFuncs returns the Term functions, which use the streams of in.
+func termFuncs(in *evaluator.Interpreter) map[string]interface{} {
+	return map[string]interface{}{
+		// color(text, "red", "bold", ...) wraps text in ANSI styles
+		"color": func(text string, styles ...string) object.Object {
+			codes := make([]string, len(styles))
+			for i, style := range styles {
+				code, ok := termStyles[style]
+				if !ok {
+					return newError("unknown terminal style %q", style)
+				}
+				codes[i] = code
+			}
+			if len(codes) == 0 {
+				return &object.String{Value: text}
+			}
+			return &object.String{Value: "\033[" + strings.Join(codes, ";") + "m" + text + "\033[0m"}
+		},
+		"clear": func() object.Object {
+			fmt.Fprint(in.Stdout(), "\033[2J\033[H")
+			return evaluator.NULL
+		},
+		"clearLine": func() object.Object {
+			fmt.Fprint(in.Stdout(), "\033[2K\r")
+			return evaluator.NULL
+		},
+		// moveTo(row, col) positions the cursor, counting from 1
+		"moveTo": func(row, col object.Object) object.Object {
+			r, err := toInt64(row, "row")
+			if err != nil {
+				return err
+			}
+			c, err := toInt64(col, "col")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(in.Stdout(), "\033[%d;%dH", r, c)
+			return evaluator.NULL
+		},
+		"up":    func(n object.Object) object.Object { return moveCursor(in, n, 'A') },
+		"down":  func(n object.Object) object.Object { return moveCursor(in, n, 'B') },
+		"right": func(n object.Object) object.Object { return moveCursor(in, n, 'C') },
+		"left":  func(n object.Object) object.Object { return moveCursor(in, n, 'D') },
+		"hideCursor": func() object.Object {
+			fmt.Fprint(in.Stdout(), "\033[?25l")
+			return evaluator.NULL
+		},
+		"showCursor": func() object.Object {
+			fmt.Fprint(in.Stdout(), "\033[?25h")
+			return evaluator.NULL
+		},
+		// size() returns [columns, rows] of the terminal attached to stdout
+		"size": func() object.Object {
+			fd, ok := terminal(in.Stdout())
+			if !ok {
+				return newError("cannot get terminal size: output is not a terminal")
+			}
+			width, height, err := term.GetSize(fd)
+			if err != nil {
+				return newError("cannot get terminal size: %s", err)
+			}
+			return &object.Array{Elements: []object.Object{newInteger(int64(width)), newInteger(int64(height))}}
+		},
+		"isTerminal": func() object.Object {
+			_, ok := terminal(in.Stdout())
+			return nativeBool(ok)
+		},
+		// readKey() reads a single key press without waiting for enter. Arrow and
+		// control keys are returned by name ("up", "enter", "escape", ...)
+		"readKey": func() object.Object {
+			// Input that is not a terminal is read as it is
+			if fd, ok := terminal(in.Stdin()); ok {
+				state, err := term.MakeRaw(fd)
+				if err != nil {
+					return newError("cannot read key: %s", err)
+				}
+				defer term.Restore(fd, state)
+			}
+
+			buf := make([]byte, 8)
+			n, err := in.Stdin().Read(buf)
+			if err != nil {
+				return newError("cannot read key: %s", err)
+			}
+			return &object.String{Value: keyName(buf[:n])}
+		},
+	}
+}
+
+// terminal returns the file descriptor of stream if it is a terminal.
+func terminal(stream interface{}) (int, bool) {
+	f, ok := stream.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0, false
+	}
+	return int(f.Fd()), true
+}
+
+func moveCursor(in *evaluator.Interpreter, n object.Object, direction byte) object.Object {
+	count, err := toInt64(n, "count")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(in.Stdout(), "\033[%d%c", count, direction)
+	return evaluator.NULL
+}
+
+func keyName(key []byte) string {
+	switch string(key) {
+	case "\033[A":
+		return "up"
+	case "\033[B":
+		return "down"
+	case "\033[C":
+		return "right"
+	case "\033[D":
+		return "left"
+	case "\033[H":
+		return "home"
+	case "\033[F":
+		return "end"
+	case "\033":
+		return "escape"
+	case "\r", "\n":
+		return "enter"
+	case "\t":
+		return "tab"
+	case "\x7f", "\b":
+		return "backspace"
+	case "\x03":
+		return "ctrl+c"
+	}
+	return string(key)
+}
+
+func RegisterTermFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "Term", termFuncs(evaluator.InterpreterOf(env)))
+}
@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTermColor(t *testing.T) {
+	color := termFuncs(evaluator.Default())["color"].(func(string, ...string) object.Object)
+
+	tests := []struct {
+		styles   []string
+		expected string
+	}{
+		{nil, "hi"},
+		{[]string{"red"}, "\033[31mhi\033[0m"},
+		{[]string{"red", "bold", "bgWhite"}, "\033[31;1;47mhi\033[0m"},
+	}
+	for _, tt := range tests {
+		got, ok := color("hi", tt.styles...).(*object.String)
+		if !ok || got.Value != tt.expected {
+			t.Errorf("color(hi, %v) = %v, want %q", tt.styles, got, tt.expected)
+		}
+	}
+
+	err, ok := color("hi", "red", "sparkly").(*object.Error)
+	if !ok || err.Message != `unknown terminal style "sparkly"` {
+		t.Errorf("color with an unknown style returned %v", err)
+	}
+}
+
+func TestTermOutput(t *testing.T) {
+	var out bytes.Buffer
+	funcs := termFuncs(evaluator.New(evaluator.Options{Stdout: &out}))
+	one := newInteger(1)
+
+	tests := []struct {
+		call     func() object.Object
+		expected string
+	}{
+		{funcs["clear"].(func() object.Object), "\033[2J\033[H"},
+		{funcs["clearLine"].(func() object.Object), "\033[2K\r"},
+		{func() object.Object {
+			return funcs["moveTo"].(func(object.Object, object.Object) object.Object)(newInteger(3), newInteger(7))
+		}, "\033[3;7H"},
+		{func() object.Object { return funcs["up"].(func(object.Object) object.Object)(newInteger(2)) }, "\033[2A"},
+		{func() object.Object { return funcs["down"].(func(object.Object) object.Object)(one) }, "\033[1B"},
+		{func() object.Object { return funcs["right"].(func(object.Object) object.Object)(one) }, "\033[1C"},
+		{func() object.Object { return funcs["left"].(func(object.Object) object.Object)(one) }, "\033[1D"},
+		{funcs["hideCursor"].(func() object.Object), "\033[?25l"},
+		{funcs["showCursor"].(func() object.Object), "\033[?25h"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if result := tt.call(); result != evaluator.NULL {
+			t.Errorf("expected NULL, got %s", result.Inspect())
+		}
+		if out.String() != tt.expected {
+			t.Errorf("wrote %q, want %q", out.String(), tt.expected)
+		}
+	}
+
+	out.Reset()
+	moveTo := funcs["moveTo"].(func(object.Object, object.Object) object.Object)
+	if err, ok := moveTo(one, &object.String{Value: "2"}).(*object.Error); !ok || err.Message != "col must be INTEGER, got STRING" {
+		t.Errorf("moveTo with a string column returned %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("a failed moveTo wrote %q", out.String())
+	}
+}
+
+func TestTermNotTerminal(t *testing.T) {
+	funcs := termFuncs(evaluator.New(evaluator.Options{Stdout: &bytes.Buffer{}}))
+
+	if got := funcs["isTerminal"].(func() object.Object)(); got != evaluator.FALSE {
+		t.Errorf("isTerminal() = %s for a buffer", got.Inspect())
+	}
+	err, ok := funcs["size"].(func() object.Object)().(*object.Error)
+	if !ok || err.Message != "cannot get terminal size: output is not a terminal" {
+		t.Errorf("size() returned %v for a buffer", err)
+	}
+}
+
+func TestTermReadKey(t *testing.T) {
+	in := evaluator.New(evaluator.Options{Stdin: strings.NewReader("q")})
+	readKey := termFuncs(in)["readKey"].(func() object.Object)
+
+	if got, ok := readKey().(*object.String); !ok || got.Value != "q" {
+		t.Errorf("readKey() = %v, want q", got)
+	}
+	if _, ok := readKey().(*object.Error); !ok {
+		t.Errorf("readKey() at the end of input did not fail")
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"\033[A", "up"},
+		{"\033[B", "down"},
+		{"\033[C", "right"},
+		{"\033[D", "left"},
+		{"\033[H", "home"},
+		{"\033[F", "end"},
+		{"\033", "escape"},
+		{"\r", "enter"},
+		{"\n", "enter"},
+		{"\t", "tab"},
+		{"\x7f", "backspace"},
+		{"\b", "backspace"},
+		{"\x03", "ctrl+c"},
+		{"a", "a"},
+		{"é", "é"},
+		{"\033[Z", "\033[Z"},
+	}
+
+	for _, tt := range tests {
+		if got := keyName([]byte(tt.key)); got != tt.expected {
+			t.Errorf("keyName(%q) = %q, want %q", tt.key, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"1ylang/object"
+	"time"
+)
+
+// Times are passed around as RFC 3339 strings with a UTC offset, e.g.
+// "2024-06-07T15:04:05.123+08:00", and durations as integer milliseconds.
+var timeFuncs = map[string]interface{}{
+	// now() or now(zone) returns the current time, in the local zone by default
+	"now": func(zone ...string) object.Object {
+		loc, err := timeZone(zone, 0)
+		if err != nil {
+			return err
+		}
+		return formatTime(time.Now().In(loc))
+	},
+	// parse(text) or parse(text, layout) normalizes a time string; without a
+	// layout RFC 3339, "2006-01-02 15:04:05" and "2006-01-02" are accepted
+	"parse": func(text string, layout ...string) object.Object {
+		t, err := parseTime(text, layout...)
+		if err != nil {
+			return err
+		}
+		return formatTime(t)
+	},
+	// format(t, layout) formats with a Go reference-time layout
+	"format": func(text, layout string) object.Object {
+		t, err := parseTime(text)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: t.Format(layout)}
+	},
+	"add": func(text string, ms object.Object) object.Object {
+		return shiftTime(text, ms, 1)
+	},
+	"sub": func(text string, ms object.Object) object.Object {
+		return shiftTime(text, ms, -1)
+	},
+	// addDate(t, years, months, days) does calendar arithmetic, normalizing
+	// overflow the way Go does (Jan 31 + 1 month is Mar 2 or 3)
+	"addDate": func(text string, years, months, days object.Object) object.Object {
+		t, err := parseTime(text)
+		if err != nil {
+			return err
+		}
+		y, err := toInt64(years, "years")
+		if err != nil {
+			return err
+		}
+		m, err := toInt64(months, "months")
+		if err != nil {
+			return err
+		}
+		d, err := toInt64(days, "days")
+		if err != nil {
+			return err
+		}
+		return formatTime(t.AddDate(int(y), int(m), int(d)))
+	},
+	// diff(a, b) returns a - b in milliseconds
+	"diff": func(a, b string) object.Object {
+		ta, err := parseTime(a)
+		if err != nil {
+			return err
+		}
+		tb, err := parseTime(b)
+		if err != nil {
+			return err
+		}
+		return newInteger(ta.Sub(tb).Milliseconds())
+	},
+	// inZone(t, zone) converts to an IANA zone such as "Asia/Shanghai" or "UTC"
+	"inZone": func(text, zone string) object.Object {
+		t, err := parseTime(text)
+		if err != nil {
+			return err
+		}
+		loc, err := timeZone([]string{zone}, 1)
+		if err != nil {
+			return err
+		}
+		return formatTime(t.In(loc))
+	},
+	"unix": func(text string) object.Object {
+		t, err := parseTime(text)
+		if err != nil {
+			return err
+		}
+		return newInteger(t.Unix())
+	},
+	"fromUnix": func(seconds object.Object, zone ...string) object.Object {
+		sec, err := toInt64(seconds, "seconds")
+		if err != nil {
+			return err
+		}
+		loc, err := timeZone(zone, 1)
+		if err != nil {
+			return err
+		}
+		return formatTime(time.Unix(sec, 0).In(loc))
+	},
+	// duration("1h30m") converts a Go duration string to milliseconds
+	"duration": func(text string) object.Object {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return newError("invalid duration %q", text)
+		}
+		return newInteger(d.Milliseconds())
+	},
+	"formatDuration": func(ms object.Object) object.Object {
+		n, err := toInt64(ms, "duration")
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: (time.Duration(n) * time.Millisecond).String()}
+	},
+}
+
+var defaultTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseTime(text string, layout ...string) (time.Time, *object.Error) {
+	layouts := defaultTimeLayouts
+	if len(layout) > 0 {
+		layouts = layout
+	}
+
+	for _, l := range layouts {
+		if t, err := time.Parse(l, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, newError("cannot parse time %q", text)
+}
+
+func formatTime(t time.Time) *object.String {
+	return &object.String{Value: t.Format(time.RFC3339Nano)}
+}
+
+func shiftTime(text string, ms object.Object, sign int64) object.Object {
+	t, err := parseTime(text)
+	if err != nil {
+		return err
+	}
+	n, err := toInt64(ms, "duration")
+	if err != nil {
+		return err
+	}
+	return formatTime(t.Add(time.Duration(sign*n) * time.Millisecond))
+}
+
+// timeZone loads the optional zone argument of a function taking fixed
+// other arguments; the local zone is used if it is left out.
+func timeZone(zone []string, fixed int) (*time.Location, *object.Error) {
+	if len(zone) > 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=%d or %d", fixed+len(zone), fixed, fixed+1)
+	}
+	if len(zone) == 0 {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(zone[0])
+	if err != nil {
+		return nil, newError("unknown time zone %q", zone[0])
+	}
+	return loc, nil
+}
+
+func RegisterTimeFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "Time", timeFuncs)
+}
@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"1ylang/object"
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`Time.parse("2024-06-07T15:04:05.123+08:00")`, "2024-06-07T15:04:05.123+08:00"},
+		{`Time.parse("2024-06-07 15:04:05")`, "2024-06-07T15:04:05Z"},
+		{`Time.parse("2024-06-07")`, "2024-06-07T00:00:00Z"},
+		{`Time.parse("07/06/2024", "02/01/2006")`, "2024-06-07T00:00:00Z"},
+		{`Time.parse("07/06/2024", "2006-01-02", "02/01/2006")`, "2024-06-07T00:00:00Z"},
+		{`Time.format("2024-06-07T15:04:05+08:00", "Jan 2, 2006 at 15:04")`, "Jun 7, 2024 at 15:04"},
+
+		{`Time.add("2024-06-07T15:04:05Z", 1500)`, "2024-06-07T15:04:06.5Z"},
+		{`Time.add("2024-06-07T23:30:00Z", Time.duration("1h"))`, "2024-06-08T00:30:00Z"},
+		{`Time.sub("2024-06-07T15:04:05Z", 5000)`, "2024-06-07T15:04:00Z"},
+		{`Time.addDate("2024-01-31", 0, 1, 0)`, "2024-03-02T00:00:00Z"},
+		{`Time.addDate("2024-02-29", 1, 0, 0)`, "2025-03-01T00:00:00Z"},
+		{`Time.addDate("2024-06-07", 0, 0, -7)`, "2024-05-31T00:00:00Z"},
+		{`Time.diff("2024-06-07T15:04:05Z", "2024-06-07T15:04:00Z")`, "5000"},
+		{`Time.diff("2024-06-07T00:00:00+08:00", "2024-06-07T00:00:00Z")`, "-28800000"},
+
+		{`Time.inZone("2024-06-07T15:04:05+08:00", "UTC")`, "2024-06-07T07:04:05Z"},
+		{`Time.unix("1970-01-02T00:00:00Z")`, "86400"},
+		{`Time.fromUnix(86400, "UTC")`, "1970-01-02T00:00:00Z"},
+		{`Time.unix(Time.fromUnix(1717772645))`, "1717772645"},
+
+		{`Time.duration("1h30m")`, "5400000"},
+		{`Time.duration("250ms")`, "250"},
+		{`Time.formatDuration(5400000)`, "1h30m0s"},
+		{`Time.formatDuration(250)`, "250ms"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input); got.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestTimeNow(t *testing.T) {
+	before := time.Now()
+	now, ok := testEval(`Time.now("UTC")`).(*object.String)
+	if !ok {
+		t.Fatalf("Time.now did not return a string")
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, now.Value)
+	if err != nil {
+		t.Fatalf("Time.now returned %q: %v", now.Value, err)
+	}
+	if _, offset := parsed.Zone(); offset != 0 {
+		t.Errorf("Time.now(\"UTC\") returned %q, which is not in UTC", now.Value)
+	}
+	if parsed.Before(before.Truncate(time.Second)) || parsed.After(time.Now()) {
+		t.Errorf("Time.now returned %s, not between %s and now", parsed, before)
+	}
+}
+
+func TestTimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`Time.now("UTC", "UTC")`, "wrong number of arguments. got=2, want=0 or 1"},
+		{`Time.fromUnix(0, "UTC", "Asia/Shanghai")`, "wrong number of arguments. got=3, want=1 or 2"},
+		{`Time.now("Nowhere/Special")`, `unknown time zone "Nowhere/Special"`},
+		{`Time.inZone("2024-06-07", "Nowhere/Special")`, `unknown time zone "Nowhere/Special"`},
+		{`Time.parse("yesterday")`, `cannot parse time "yesterday"`},
+		{`Time.parse("2024-06-07", "02/01/2006")`, `cannot parse time "2024-06-07"`},
+		{`Time.add("2024-06-07", "1s")`, "duration must be INTEGER, got STRING"},
+		{`Time.addDate("2024-06-07", 0, 1.5, 0)`, "months must be INTEGER, got FLOAT"},
+		{`Time.fromUnix(100000000000000000000)`, "seconds out of range: 100000000000000000000"},
+		{`Time.duration("soon")`, `invalid duration "soon"`},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s did not fail", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%s failed with %q, want %q", tt.input, err.Message, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/vfs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// watchPollSlice bounds how long Watch.path sleeps before checking whether
+// it was interrupted.
+const watchPollSlice = 50 * time.Millisecond
+
+// watchFuncs returns the Watch functions, which poll files of the file
+// system of interpreter in.
+func watchFuncs(in *evaluator.Interpreter) map[string]interface{} {
+	return map[string]interface{}{
+		// path(dir, callback) or path(dir, callback, intervalMs) polls dir (or a
+		// single file) and calls callback with {"type": "create"|"modify"|"delete",
+		// "path": ...} for every change. It blocks until the callback returns
+		// false or the interpreter is interrupted.
+		"path": func(root string, callback object.Object, interval ...object.Object) object.Object {
+			if !isCallable(callback) {
+				return newError("callback passed to `Watch.path` must be a function, got %s", callback.Type())
+			}
+
+			wait := 500 * time.Millisecond
+			if len(interval) > 0 {
+				ms, err := toInt64(interval[0], "interval")
+				if err != nil {
+					return err
+				}
+				if ms < 1 {
+					return newError("interval must be at least 1, got %d", ms)
+				}
+				wait = time.Duration(ms) * time.Millisecond
+			}
+
+			fsys := in.FS()
+			previous, err := Snapshot(fsys, root)
+			if err != nil {
+				return newError("cannot watch %s: %s", root, err)
+			}
+
+			for {
+				if err := sleepUnlessInterrupted(in, wait); err != nil {
+					return err
+				}
+
+				current, err := Snapshot(fsys, root)
+				if err != nil {
+					return newError("cannot watch %s: %s", root, err)
+				}
+
+				for _, change := range Changes(previous, current) {
+					result := evaluator.ApplyFunction(callback, []object.Object{watchEvent(change)})
+					if isError(result) {
+						return result
+					}
+					if result == evaluator.FALSE {
+						return evaluator.NULL
+					}
+				}
+				previous = current
+			}
+		},
+	}
+}
+
+// sleepUnlessInterrupted waits for d, returning early with the error of the
+// interruption if in is interrupted meanwhile.
+func sleepUnlessInterrupted(in *evaluator.Interpreter, d time.Duration) *object.Error {
+	for d > 0 {
+		if err := in.Interrupted(); err != nil {
+			return err
+		}
+		slice := min(d, watchPollSlice)
+		time.Sleep(slice)
+		d -= slice
+	}
+	return in.Interrupted()
+}
+
+// FileState is the part of a file's metadata used to detect modifications.
+type FileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Equal reports whether s and other describe the same contents: the same
+// size and modification instant, whatever the location or monotonic clock
+// reading of their times.
+func (s FileState) Equal(other FileState) bool {
+	return s.Size == other.Size && s.ModTime.Equal(other.ModTime)
+}
+
+// Change describes a single file system change between two snapshots.
+type Change struct {
+	Type string // "create", "modify" or "delete"
+	Path string
+}
+
+// Snapshot records the state of every file of fsys below root, or of root
+// itself if it is a file.
+func Snapshot(fsys vfs.FS, root string) (map[string]FileState, error) {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]FileState)
+	if !info.IsDir() {
+		files[root] = FileState{ModTime: info.ModTime(), Size: info.Size()}
+		return files, nil
+	}
+	snapshotDir(fsys, root, files)
+	return files, nil
+}
+
+// snapshotDir adds the files below dir to files. Files and directories that
+// disappear while they are read are left out.
+func snapshotDir(fsys vfs.FS, dir string, files map[string]FileState) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			snapshotDir(fsys, path, files)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[path] = FileState{ModTime: info.ModTime(), Size: info.Size()}
+	}
+}
+
+// Changes lists the differences between two snapshots ordered by path.
+func Changes(previous, current map[string]FileState) []Change {
+	var changes []Change
+
+	for path, state := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Type: "create", Path: path})
+		case !old.Equal(state):
+			changes = append(changes, Change{Type: "modify", Path: path})
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changes = append(changes, Change{Type: "delete", Path: path})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func watchEvent(change Change) *object.Hash {
+	typeKey := &object.String{Value: "type"}
+	pathKey := &object.String{Value: "path"}
+
+	return &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+		typeKey.HashKey(): {Key: typeKey, Value: &object.String{Value: change.Type}},
+		pathKey.HashKey(): {Key: pathKey, Value: &object.String{Value: change.Path}},
+	}}
+}
+
+func RegisterWatchFuncs(env *object.Environment) {
+	object.RegisterFunctions(env, "Watch", watchFuncs(evaluator.InterpreterOf(env)))
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/vfs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// watchPath returns Watch.path working in interpreter in.
+func watchPath(in *evaluator.Interpreter) func(string, object.Object, ...object.Object) object.Object {
+	return watchFuncs(in)["path"].(func(string, object.Object, ...object.Object) object.Object)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("bb"), 0644)
+
+	files, err := Snapshot(vfs.OS{}, dir)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+	if state := files[filepath.Join(dir, "sub", "b.txt")]; state.Size != 2 {
+		t.Errorf("sub/b.txt has size %d, want 2", state.Size)
+	}
+
+	file := filepath.Join(dir, "a.txt")
+	files, err = Snapshot(vfs.OS{}, file)
+	if err != nil || len(files) != 1 || files[file].Size != 1 {
+		t.Errorf("Snapshot of a file returned %v, %v", files, err)
+	}
+
+	if _, err := Snapshot(vfs.OS{}, filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Snapshot of a missing path did not fail")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	now := time.Now()
+	previous := map[string]FileState{
+		"kept":     {ModTime: now, Size: 1},
+		"modified": {ModTime: now, Size: 1},
+		"touched":  {ModTime: now, Size: 1},
+		"deleted":  {ModTime: now, Size: 1},
+	}
+	current := map[string]FileState{
+		"kept":     {ModTime: now, Size: 1},
+		"modified": {ModTime: now, Size: 2},
+		"touched":  {ModTime: now.Add(time.Second), Size: 1},
+		"created":  {ModTime: now, Size: 1},
+	}
+
+	expected := []Change{
+		{Type: "create", Path: "created"},
+		{Type: "delete", Path: "deleted"},
+		{Type: "modify", Path: "modified"},
+		{Type: "modify", Path: "touched"},
+	}
+	if got := Changes(previous, current); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Changes returned %v, want %v", got, expected)
+	}
+	if got := Changes(current, current); len(got) != 0 {
+		t.Errorf("expected no changes between equal snapshots, got %v", got)
+	}
+
+	// The same instant read without the monotonic clock or in another
+	// location is not a change
+	same := map[string]FileState{
+		"kept":     {ModTime: now.Round(0), Size: 1},
+		"modified": {ModTime: now.UTC(), Size: 2},
+		"touched":  {ModTime: now.Add(time.Second).In(time.FixedZone("X", 3600)), Size: 1},
+		"created":  {ModTime: now.Round(0).UTC(), Size: 1},
+	}
+	if got := Changes(current, same); len(got) != 0 {
+		t.Errorf("expected no changes between the same instants, got %v", got)
+	}
+}
+
+func TestWatchPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "new.txt")
+	watch := watchPath(evaluator.New(evaluator.Options{}))
+
+	var events []string
+	callback := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		events = append(events, args[0].Inspect())
+		return evaluator.FALSE
+	}}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(file, []byte("x"), 0644)
+	}()
+	if result := watch(dir, callback, newInteger(10)); result != evaluator.NULL {
+		t.Fatalf("Watch.path returned %s", result.Inspect())
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %v", events)
+	}
+	event := watchEvent(Change{Type: "create", Path: file}).Inspect()
+	if events[0] != event {
+		t.Errorf("got event %s, want %s", events[0], event)
+	}
+}
+
+func TestWatchPathErrors(t *testing.T) {
+	watch := watchPath(evaluator.New(evaluator.Options{}))
+	dir := t.TempDir()
+	callback := &object.Builtin{Fn: func(args ...object.Object) object.Object { return evaluator.FALSE }}
+
+	tests := []struct {
+		result   object.Object
+		expected string
+	}{
+		{watch(dir, newInteger(1)), "callback passed to `Watch.path` must be a function, got INTEGER"},
+		{watch(dir, callback, &object.String{Value: "fast"}), "interval must be INTEGER, got STRING"},
+		{watch(dir, callback, newInteger(0)), "interval must be at least 1, got 0"},
+		{watch(dir, callback, newInteger(-5)), "interval must be at least 1, got -5"},
+	}
+	for _, tt := range tests {
+		err, ok := tt.result.(*object.Error)
+		if !ok || err.Message != tt.expected {
+			t.Errorf("expected error %q, got %v", tt.expected, tt.result)
+		}
+	}
+
+	if _, ok := watch(filepath.Join(dir, "missing"), callback).(*object.Error); !ok {
+		t.Errorf("watching a missing path did not fail")
+	}
+}
+
+func TestWatchPathInterrupted(t *testing.T) {
+	in := evaluator.New(evaluator.Options{})
+	callback := &object.Builtin{Fn: func(args ...object.Object) object.Object { return evaluator.TRUE }}
+
+	stop := in.Timeout(30 * time.Millisecond)
+	defer stop()
+	start := time.Now()
+	result := watchPath(in)(t.TempDir(), callback, newInteger(10000))
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Kind != evaluator.ErrorInterrupt {
+		t.Fatalf("Watch.path was not interrupted. got=%v", result)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Watch.path took %v to notice the timeout", elapsed)
+	}
+}
+
+func TestWatchPathFS(t *testing.T) {
+	mem := vfs.NewMem()
+	mem.WriteFile("notes.txt", []byte("a"))
+	in := evaluator.New(evaluator.Options{FS: mem})
+
+	var events []string
+	callback := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		events = append(events, args[0].Inspect())
+		return evaluator.FALSE
+	}}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mem.WriteFile("notes.txt", []byte("ab"))
+	}()
+	if result := watchPath(in)(".", callback, newInteger(5)); result != evaluator.NULL {
+		t.Fatalf("Watch.path returned %s", result.Inspect())
+	}
+
+	event := watchEvent(Change{Type: "modify", Path: "notes.txt"}).Inspect()
+	if len(events) != 1 || events[0] != event {
+		t.Errorf("got events %v, want %s", events, event)
+	}
+}
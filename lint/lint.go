@@ -0,0 +1,351 @@
+// Package lint reports likely mistakes in 1y programs that parse but are
+// probably not what was meant, such as variables that are never used or code
+// that can never run.
+package lint
+
+import (
+	"1ylang/ast"
+	"fmt"
+	"sort"
+)
+
+// Problem is a single finding, at the line the offending code starts on.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// Check walks program and returns what it finds, ordered by line.
+func Check(program *ast.Program) []Problem {
+	c := &checker{}
+	c.push(false)
+	c.statements(program.Statements, false)
+	c.pop()
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		return c.problems[i].Line < c.problems[j].Line
+	})
+	return c.problems
+}
+
+// variable is a name declared with let or const, or a parameter.
+type variable struct {
+	ident    *ast.Identifier
+	constant bool
+	param    bool
+	used     bool
+}
+
+// scope holds the variables of a function or loop, or the top level. As in
+// ast.Resolve, other blocks share the scope they are in.
+type scope struct {
+	names map[string]*variable
+	order []*variable
+
+	// unresolved are names read inside the scope that were not declared
+	// when they were read. A function may read a variable of an enclosing
+	// function that is declared after it, so such names count as used once
+	// the enclosing scope ends.
+	unresolved map[string]bool
+
+	// Variables at the top level may be used by importers and later REPL
+	// input, so they are never reported as unused
+	topLevel bool
+}
+
+type checker struct {
+	scopes   []*scope
+	problems []Problem
+}
+
+func (c *checker) report(line int, format string, args ...any) {
+	c.problems = append(c.problems, Problem{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) push(inner bool) {
+	c.scopes = append(c.scopes, &scope{
+		names:      make(map[string]*variable),
+		unresolved: make(map[string]bool),
+		topLevel:   !inner,
+	})
+}
+
+// pop ends the innermost scope and reports its unused variables.
+func (c *checker) pop() {
+	s := c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+
+	for _, v := range s.order {
+		if s.unresolved[v.ident.Value] {
+			v.used = true
+		}
+		if !v.used && !v.param && !s.topLevel && v.ident.Value[0] != '_' {
+			c.report(v.ident.Token.Line, "%s declared and not used", v.ident.Value)
+		}
+	}
+}
+
+func (c *checker) declare(ident *ast.Identifier, isConst, isParam bool) {
+	inner := c.scopes[len(c.scopes)-1]
+	if _, ok := inner.names[ident.Value]; !ok {
+		// Declaring a name twice in one scope fails when it runs, so only
+		// names from enclosing scopes are reported
+		for _, s := range c.scopes[:len(c.scopes)-1] {
+			if outer, ok := s.names[ident.Value]; ok {
+				c.report(ident.Token.Line, "%s shadows the declaration on line %d", ident.Value, outer.ident.Token.Line)
+				break
+			}
+		}
+	}
+
+	v := &variable{ident: ident, constant: isConst, param: isParam}
+	inner.names[ident.Value] = v
+	inner.order = append(inner.order, v)
+}
+
+// lookup returns the variable ident refers to, or nil if it is not declared
+// yet, such as a builtin or a top-level name declared later.
+func (c *checker) lookup(ident *ast.Identifier) *variable {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if v, ok := c.scopes[i].names[ident.Value]; ok {
+			return v
+		}
+	}
+	for _, s := range c.scopes {
+		s.unresolved[ident.Value] = true
+	}
+	return nil
+}
+
+// statements checks a list of statements. loopBody is set for the body of a
+// loop, where break and continue end the list as return does anywhere.
+func (c *checker) statements(stmts []ast.Statement, loopBody bool) {
+	for i, stmt := range stmts {
+		c.statement(stmt)
+
+		ends := false
+		switch stmt.(type) {
+		case *ast.ReturnStatement:
+			ends = true
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			ends = loopBody
+		}
+		if ends && i < len(stmts)-1 {
+			c.report(statementLine(stmts[i+1]), "unreachable code")
+			// The rest is still checked for other problems
+			for _, rest := range stmts[i+1:] {
+				c.statement(rest)
+			}
+			return
+		}
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement, loopBody bool) {
+	if block != nil {
+		c.statements(block.Statements, loopBody)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// The value is checked first so `let x = x + 1` reads an outer x
+		c.expression(stmt.Value)
+		c.declare(stmt.Name, false, false)
+	case *ast.ConstStatement:
+		c.expression(stmt.Value)
+		c.declare(stmt.Name, true, false)
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.block(stmt, false)
+	case *ast.WhileStatement:
+		c.push(true)
+		c.expression(stmt.Condition)
+		c.loopBody(stmt.Body)
+		c.pop()
+	case *ast.ForStatement:
+		c.push(true)
+		if stmt.Init != nil {
+			c.statement(stmt.Init)
+		}
+		c.expression(stmt.Condition)
+		if stmt.Post != nil {
+			c.statement(stmt.Post)
+		}
+		c.loopBody(stmt.Body)
+		c.pop()
+	}
+}
+
+func (c *checker) loopBody(body *ast.BlockStatement) {
+	c.push(true)
+	c.block(body, true)
+	c.pop()
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp != nil {
+			if v := c.lookup(exp); v != nil {
+				v.used = true
+			}
+		}
+	case *ast.PrefixExpression:
+		if exp.Operator == "++" || exp.Operator == "--" {
+			c.assigned(exp.Right)
+		}
+		c.expression(exp.Right)
+	case *ast.InfixExpression:
+		if exp.Operator == "==" || exp.Operator == "!=" {
+			c.compared(exp)
+		}
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+	case *ast.PostfixExpression:
+		c.assigned(exp.Left)
+		c.expression(exp.Left)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		c.block(exp.Consequence, false)
+		for _, elif := range exp.Elifs {
+			c.expression(elif.Condition)
+			c.block(elif.Consequence, false)
+		}
+		c.block(exp.Alternative, false)
+	case *ast.FunctionLiteral:
+		c.push(true)
+		for _, param := range exp.Parameters {
+			c.declare(param, false, true)
+		}
+		c.block(exp.Body, false)
+		c.pop()
+	case *ast.CallExpression:
+		c.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.MultiDimensionalIndex:
+		for _, idx := range exp.Indices {
+			c.expression(idx)
+		}
+	case *ast.Assignment:
+		c.expression(exp.Value)
+		c.assigned(exp.Name)
+	case *ast.HashLiteral:
+		// Keys are in source order, so problems are too
+		for _, key := range exp.Keys {
+			c.expression(key)
+			c.expression(exp.Pairs[key])
+		}
+	case *ast.DotExpression:
+		// The right-hand side is a property name, not a variable
+		c.expression(exp.Left)
+	case *ast.ImportExpression:
+		c.expression(exp.Path)
+	}
+}
+
+// assigned checks the target of an assignment, increment or decrement.
+// Storing to a variable does not count as using it.
+func (c *checker) assigned(target ast.Expression) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok {
+		c.expression(target)
+		return
+	}
+	if v := c.lookup(ident); v != nil && v.constant {
+		c.report(ident.Token.Line, "cannot assign to constant %s", ident.Value)
+	}
+}
+
+// compared reports == and != between values that are certain to have
+// different types, which is either never equal or an error.
+func (c *checker) compared(exp *ast.InfixExpression) {
+	left, right := staticType(exp.Left), staticType(exp.Right)
+	if left != "" && right != "" && left != right {
+		c.report(exp.Token.Line, "%s compared with %s using %s", left, right, exp.Operator)
+	}
+}
+
+// staticType returns the type exp evaluates to, as far as can be told
+// without running it, or "" if it depends on variables or calls. Integers
+// and floats compare by value, so both are "number".
+func staticType(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		return "number"
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.Boolean:
+		return "boolean"
+	case *ast.ArrayLiteral:
+		return "array"
+	case *ast.HashLiteral:
+		return "hash"
+	case *ast.FunctionLiteral:
+		return "function"
+	case *ast.PrefixExpression:
+		switch exp.Operator {
+		case "!":
+			return "boolean"
+		case "-":
+			if staticType(exp.Right) == "number" {
+				return "number"
+			}
+		}
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "==", "!=", "<", ">", "<=", ">=", "&&", "||":
+			return "boolean"
+		case "+", "-", "*", "/", "%", "**":
+			left, right := staticType(exp.Left), staticType(exp.Right)
+			if left == "number" && right == "number" {
+				return "number"
+			}
+			if exp.Operator == "+" && left == "string" && right == "string" {
+				return "string"
+			}
+		}
+	}
+	return ""
+}
+
+func statementLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Line
+	case *ast.ConstStatement:
+		return stmt.Token.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Line
+	case *ast.BlockStatement:
+		return stmt.Token.Line
+	case *ast.WhileStatement:
+		return stmt.Token.Line
+	case *ast.ForStatement:
+		return stmt.Token.Line
+	case *ast.BreakStatement:
+		return stmt.Token.Line
+	case *ast.ContinueStatement:
+		return stmt.Token.Line
+	}
+	return 0
+}
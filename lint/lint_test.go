@@ -0,0 +1,68 @@
+package lint
+
+import (
+	"1ylang/lexer"
+	"1ylang/parser"
+	"reflect"
+	"testing"
+)
+
+func check(t *testing.T, input string) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	var problems []string
+	for _, problem := range Check(program) {
+		problems = append(problems, problem.String())
+	}
+	return problems
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Unused variables
+		{"let x = 1;", nil},
+		{"fn() { let x = 1; }", []string{"line 1: x declared and not used"}},
+		{"fn() { let x = 1; x = 2; }", []string{"line 1: x declared and not used"}},
+		{"fn() { let x = 1; x += 2; }", nil},
+		{"fn(a, b) { a }", nil},
+		{"fn() { let _x = 1; }", nil},
+		{"fn() { let f = fn() { y }; let y = 1; f() }", nil},
+		{"while (true) {\n  let x = 1;\n}", []string{"line 2: x declared and not used"}},
+
+		// Unreachable code
+		{"fn() { return 1; puts(2); }", []string{"line 1: unreachable code"}},
+		{"while (true) { break;\n puts(1); }", []string{"line 2: unreachable code"}},
+		{"if (true) { break; puts(1); }", nil},
+		{"fn() { return 1; let x = 2; }", []string{"line 1: unreachable code", "line 1: x declared and not used"}},
+
+		// Constants
+		{"const c = 1; c = 2;", []string{"line 1: cannot assign to constant c"}},
+		{"const c = 1; c++; --c;", []string{"line 1: cannot assign to constant c", "line 1: cannot assign to constant c"}},
+		{"const c = 1; fn() { let c = 2; c = 3; c }", []string{"line 1: c shadows the declaration on line 1"}},
+
+		// Comparisons
+		{`1 == "1"`, []string{"line 1: number compared with string using =="}},
+		{"true != 0", []string{"line 1: boolean compared with number using !="}},
+		{"1 == 1.0; x == 1; (1 < 2) == !x", nil},
+		{`"a" + "b" == [1]`, []string{"line 1: string compared with array using =="}},
+
+		// Shadowing
+		{"let x = 1;\nfn(x) { x }", []string{"line 2: x shadows the declaration on line 1"}},
+		{"let x = 1; if (true) { x }", nil},
+		{"fn() { let x = 1; x }; fn() { let x = 2; x }", nil},
+	}
+
+	for _, tt := range tests {
+		problems := check(t, tt.input)
+		if !reflect.DeepEqual(problems, tt.expected) {
+			t.Errorf("Check(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, problems)
+		}
+	}
+}
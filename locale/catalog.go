@@ -0,0 +1,106 @@
+package locale
+
+// catalog holds the messages that can be translated. IDs must not change
+// once released, since tools match on them; several formats of the same
+// kind of error share one.
+var catalog = []Message{
+	// Parser
+	{ID: "expected-token", Format: "expected next token to be %s, got %s instead",
+		Text: map[string]string{"zh": "下一个记号应为 %s，实际为 %s"}},
+	{ID: "unexpected-token", Format: "no prefix parse function for %s found",
+		Text: map[string]string{"zh": "此处不能出现 %s"}},
+	{ID: "invalid-number", Format: "could not parse %q as integer or float",
+		Text: map[string]string{"zh": "无法将 %q 解析为整数或浮点数"}},
+	{ID: "invalid-number", Format: "could not parse %q as float",
+		Text: map[string]string{"zh": "无法将 %q 解析为浮点数"}},
+	{ID: "invalid-number", Format: "invalid scientific notation: %q",
+		Text: map[string]string{"zh": "无效的科学计数法: %q"}},
+	{ID: "invalid-number", Format: "invalid exponent in scientific notation: %q",
+		Text: map[string]string{"zh": "科学计数法中的指数无效: %q"}},
+	{ID: "expected-property", Format: "expected property name to be identifier, got %s instead",
+		Text: map[string]string{"zh": "属性名应为标识符，实际为 %s"}},
+	{ID: "multiple-for-declarations", Format: "only one variable can be declared in the header of a for loop",
+		Text: map[string]string{"zh": "for 循环头部只能声明一个变量"}},
+	{ID: "read-error", Format: "error reading input: %v",
+		Text: map[string]string{"zh": "读取输入出错: %v"}},
+
+	// Evaluator
+	{ID: "type-mismatch", Format: "type mismatch: %s %s %s",
+		Text: map[string]string{"zh": "类型不匹配: %s %s %s"}},
+	{ID: "unknown-operator", Format: "unknown operator: %s %s %s",
+		Text: map[string]string{"zh": "未知运算符: %s %s %s"}},
+	{ID: "unknown-operator", Format: "unknown operator: %s%s",
+		Text: map[string]string{"zh": "未知运算符: %s%s"}},
+	{ID: "unknown-operator", Format: "unknown operator: -%s",
+		Text: map[string]string{"zh": "未知运算符: -%s"}},
+	{ID: "unknown-operator", Format: "unknown operator: ~%s",
+		Text: map[string]string{"zh": "未知运算符: ~%s"}},
+	{ID: "identifier-not-found", Format: "identifier not found: %s",
+		Text: map[string]string{"zh": "未找到标识符: %s"}},
+	{ID: "not-a-function", Format: "not a function: %s",
+		Text: map[string]string{"zh": "不是函数: %s"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=%d",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 %d 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=0",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，不需要参数"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=1",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 1 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=2",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 2 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=0 or 1",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 0 或 1 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=1 or 2",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 1 或 2 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=1 to 3",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，需要 1 到 3 个"}},
+	{ID: "wrong-argument-count", Format: "wrong number of arguments. got=%d, want=2+",
+		Text: map[string]string{"zh": "参数个数错误: 传入 %d 个，至少需要 2 个"}},
+	{ID: "division-by-zero", Format: "division by zero",
+		Text: map[string]string{"zh": "除数为零"}},
+	{ID: "division-by-zero", Format: "modulus by zero",
+		Text: map[string]string{"zh": "取模的除数为零"}},
+	{ID: "index-not-integer", Format: "index is not an integer: %s",
+		Text: map[string]string{"zh": "索引不是整数: %s"}},
+	{ID: "index-out-of-range", Format: "index out of range: %s",
+		Text: map[string]string{"zh": "索引越界: %s"}},
+	{ID: "index-not-supported", Format: "index operator not supported: %s",
+		Text: map[string]string{"zh": "不支持索引运算: %s"}},
+	{ID: "index-not-supported", Format: "index assignment not supported: %s",
+		Text: map[string]string{"zh": "不支持索引赋值: %s"}},
+	{ID: "unusable-hash-key", Format: "unusable as hash key: %s",
+		Text: map[string]string{"zh": "不能用作哈希键: %s"}},
+	{ID: "hash-key-collision", Format: "hash key %s collides with key %s",
+		Text: map[string]string{"zh": "哈希键 %s 与键 %s 冲突"}},
+	{ID: "not-a-hash", Format: "not a hash: %s",
+		Text: map[string]string{"zh": "不是哈希: %s"}},
+	{ID: "assign-to-constant", Format: "cannot assign to constant '%s'",
+		Text: map[string]string{"zh": "不能给常量 '%s' 赋值"}},
+	{ID: "invalid-assignment", Format: "invalid assignment target: %T",
+		Text: map[string]string{"zh": "无效的赋值目标: %T"}},
+	{ID: "expected-property", Format: "expected property name to be identifier, got %T",
+		Text: map[string]string{"zh": "属性名应为标识符，实际为 %T"}},
+	{ID: "max-depth", Format: "maximum evaluation depth exceeded (%d)",
+		Text: map[string]string{"zh": "超过最大求值深度 (%d)"}},
+	{ID: "sandbox", Format: "`import` is not allowed in sandbox mode",
+		Text: map[string]string{"zh": "沙箱模式下不允许使用 `import`"}},
+	{ID: "sandbox", Format: "`%s` is not allowed in sandbox mode",
+		Text: map[string]string{"zh": "沙箱模式下不允许使用 `%s`"}},
+	{ID: "import-path", Format: "import path must be a string, got %s",
+		Text: map[string]string{"zh": "import 的路径必须是字符串，实际为 %s"}},
+	{ID: "import-failed", Format: "loading file %s failed: %s",
+		Text: map[string]string{"zh": "加载文件 %s 失败: %s"}},
+	{ID: "import-failed", Format: "parsing file %s failed: %s",
+		Text: map[string]string{"zh": "解析文件 %s 失败: %s"}},
+	{ID: "import-cycle", Format: "module %s used while it is loading",
+		Text: map[string]string{"zh": "模块 %s 在加载完成前被使用"}},
+	{ID: "resource-limit", Format: "resource limit exceeded: more than %d evaluation steps",
+		Text: map[string]string{"zh": "超出资源限制: 求值步数超过 %d"}},
+	{ID: "resource-limit", Format: "resource limit exceeded: more than %d objects",
+		Text: map[string]string{"zh": "超出资源限制: 对象数超过 %d"}},
+	{ID: "resource-limit", Format: "resource limit exceeded: collection of %d elements is larger than %d",
+		Text: map[string]string{"zh": "超出资源限制: 集合的 %d 个元素超过了 %d"}},
+	{ID: "resource-limit", Format: "resource limit exceeded: repeating %d elements %d times is larger than %d",
+		Text: map[string]string{"zh": "超出资源限制: 将 %d 个元素重复 %d 次超过了 %d"}},
+	{ID: "resource-limit", Format: "resource limit exceeded: %d bytes of memory in use is more than %d",
+		Text: map[string]string{"zh": "超出资源限制: 已用内存 %d 字节，超过了 %d"}},
+}
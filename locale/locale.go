@@ -0,0 +1,92 @@
+// Package locale translates the error messages of the parser and evaluator.
+//
+// Messages are found by the English format string they are reported with,
+// so code keeps reporting errors in English and only the catalog knows
+// about other languages. Each message in the catalog has an ID that is the
+// same in every language, for tools that need to tell errors apart.
+package locale
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Message is an entry of the catalog.
+type Message struct {
+	ID     string
+	Format string            // in English, as passed to Sprintf
+	Text   map[string]string // the format in other languages, by language
+}
+
+// DefaultLanguage is the language of the formats messages are reported with.
+const DefaultLanguage = "en"
+
+var (
+	language = DefaultLanguage
+	byFormat = make(map[string]*Message)
+)
+
+func init() {
+	for i := range catalog {
+		byFormat[catalog[i].Format] = &catalog[i]
+	}
+}
+
+// Languages returns the languages messages can be shown in.
+func Languages() []string {
+	languages := map[string]bool{DefaultLanguage: true}
+	for _, m := range catalog {
+		for lang := range m.Text {
+			languages[lang] = true
+		}
+	}
+	names := make([]string, 0, len(languages))
+	for lang := range languages {
+		names = append(names, lang)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetLanguage shows messages in lang from now on. Locale names such as
+// zh_CN.UTF-8 or zh-CN are taken as the language they start with.
+func SetLanguage(lang string) error {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	for _, known := range Languages() {
+		if lang == known {
+			language = lang
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown language %q, expected one of %s", lang, strings.Join(Languages(), ", "))
+}
+
+// Language returns the language messages are shown in.
+func Language() string {
+	return language
+}
+
+// Sprintf formats a message in the current language, returning its ID as
+// well. Messages not in the catalog are formatted as they are, with an ID of
+// "".
+func Sprintf(format string, a ...interface{}) (id, message string) {
+	m, ok := byFormat[format]
+	if !ok {
+		return "", fmt.Sprintf(format, a...)
+	}
+	if text, ok := m.Text[language]; ok {
+		format = text
+	}
+	return m.ID, fmt.Sprintf(format, a...)
+}
+
+// Catalog returns the messages that have an ID, sorted by it.
+func Catalog() []Message {
+	messages := append([]Message(nil), catalog...)
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages
+}
@@ -0,0 +1,68 @@
+package locale
+
+import (
+	"regexp"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalog(t *testing.T) {
+	seen := map[string]bool{}
+	for _, m := range catalog {
+		if m.ID == "" {
+			t.Errorf("message %q has no ID", m.Format)
+		}
+		if seen[m.Format] {
+			t.Errorf("message %q is in the catalog twice", m.Format)
+		}
+		seen[m.Format] = true
+
+		// A translation taking other arguments would garble the message
+		want := verb.FindAllString(m.Format, -1)
+		for lang, text := range m.Text {
+			got := verb.FindAllString(text, -1)
+			if len(got) != len(want) {
+				t.Errorf("%s translation of %q has verbs %v, want %v", lang, m.Format, got, want)
+				continue
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("%s translation of %q has verbs %v, want %v", lang, m.Format, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	defer SetLanguage(DefaultLanguage)
+
+	id, msg := Sprintf("type mismatch: %s %s %s", "INTEGER", "+", "STRING")
+	if id != "type-mismatch" || msg != "type mismatch: INTEGER + STRING" {
+		t.Errorf("wrong message. got=%q, %q", id, msg)
+	}
+	id, msg = Sprintf("not in the catalog: %d", 1)
+	if id != "" || msg != "not in the catalog: 1" {
+		t.Errorf("wrong message. got=%q, %q", id, msg)
+	}
+
+	if err := SetLanguage("zh_CN.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	if Language() != "zh" {
+		t.Errorf("wrong language. got=%q", Language())
+	}
+	id, msg = Sprintf("identifier not found: %s", "foo")
+	if id != "identifier-not-found" || msg != "未找到标识符: foo" {
+		t.Errorf("wrong message. got=%q, %q", id, msg)
+	}
+
+	if err := SetLanguage("xx"); err == nil {
+		t.Errorf("no error for an unknown language")
+	}
+	if Language() != "zh" {
+		t.Errorf("language changed by an unknown one. got=%q", Language())
+	}
+}
@@ -1,10 +1,28 @@
 package main
 
 import (
+	"1ylang/ast"
+	"1ylang/compiled"
+	"1ylang/evaluator"
+	"1ylang/format"
+	"1ylang/lexer"
+	"1ylang/lint"
+	"1ylang/locale"
+	"1ylang/parser"
 	"1ylang/repl"
+	"1ylang/token"
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
 )
 
 const (
@@ -13,23 +31,490 @@ const (
 )
 
 func main() {
+	// Errors are shown in the language of YLANG_LANG, unless -lang is given
+	if lang := os.Getenv("YLANG_LANG"); lang != "" {
+		if err := locale.SetLanguage(lang); err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring YLANG_LANG: %v\n", err)
+		}
+	}
+
+	// An executable made by `1y build` runs the script built into it
+	if b := builtBundle(); b != nil {
+		runBundle(b)
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "build" {
+		if err := buildExecutable(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		if err := formatFiles(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doc" {
+		if err := showDoc(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "get" {
+		if err := getModules(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		found, err := lintFiles(os.Stdout, os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if found {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Define command line flags
 	filePath := flag.String("f", "", "Path to file to execute")
+	code := flag.String("e", "", "Code to evaluate instead of a file")
 	timed := flag.Bool("t", false, "Enable timing of REPL commands")
-	flag.Parse()
+	sandbox := flag.Bool("sandbox", false, "Run untrusted code: disable import, file access and the exit, getenv and setenv builtins")
+	timeout := flag.Duration("timeout", 0, "Stop a script, or each REPL command, that runs longer than this, e.g. 30s")
+	maxMemory := flag.String("max-memory", "", "Stop a script, or each REPL command, once the interpreter uses more memory than this, e.g. 256MB")
+	noOpt := flag.Bool("no-opt", false, "Disable the AST optimizer")
+	stream := flag.Bool("stream", false, "Read the -f script incrementally, running each statement as it is parsed")
+	compile := flag.String("compile", "", "Parse a script and write it next to the source as a .1yc file")
+	profile := flag.Bool("profile", false, "Print the time spent in each function and line after execution")
+	tokens := flag.Bool("tokens", false, "Print the tokens of the script instead of running it")
+	astJSON := flag.Bool("ast", false, "Print the syntax tree of the script as JSON instead of running it")
+	stats := flag.Bool("stats", false, "Print counts of evaluated nodes, objects, environments and memory use after execution")
+	traceAll := flag.Bool("trace", false, "Log each statement and function call as it is evaluated to standard error")
+	traceFunc := flag.String("trace-func", "", "Like -trace, but only log calls to the named function and what runs within them")
+	lang := flag.String("lang", "", "Show error messages in this language, e.g. zh, instead of that of $YLANG_LANG or English")
+	record := flag.String("record", "", "Append each REPL input that runs without errors to this file")
+	replay := flag.String("replay", "", "Show the inputs of this file running in the REPL, as if typed, before the first prompt")
+	initFile := flag.String("init", "", "Run this file before the REPL starts instead of ~/.1yrc.1y")
+	watchFlag := flag.Bool("watch", false, "Run the script again whenever it or a file it imports changes")
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		// The completions are made from the flags defined above
+		if err := writeCompletion(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Flags after the script are the script's own, so they are kept from
+	// the flag package
+	flags, rest := splitArgs(flag.CommandLine, os.Args[1:])
+	flag.CommandLine.Parse(flags)
+
+	repl.Optimize = !*noOpt
+	repl.Timeout = *timeout
+	if *lang != "" {
+		if err := locale.SetLanguage(*lang); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid value %q for -lang: %v\n", *lang, err)
+			os.Exit(2)
+		}
+	}
+	if *maxMemory != "" {
+		size, err := parseSize(*maxMemory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid value %q for -max-memory: %v\n", *maxMemory, err)
+			os.Exit(2)
+		}
+		evaluator.SetLimits(evaluator.Limits{Memory: size})
+	}
+	if *initFile != "" {
+		repl.InitFile = *initFile
+	}
+	repl.RecordFile = *record
+	repl.ReplayFile = *replay
+	if *sandbox {
+		evaluator.SetSandbox(true)
+	}
+	repl.Color = os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
 
-	if *filePath != "" {
+	// A script can also be given as the first argument, as in `1y script.1y`
+	scriptArgs := append(flag.Args(), rest...)
+	if *filePath == "" && *code == "" && *compile == "" && len(scriptArgs) > 0 {
+		*filePath, scriptArgs = scriptArgs[0], scriptArgs[1:]
+	}
+
+	// Arguments after the script file are passed through to the program
+	evaluator.SetArgs(scriptArgs)
+
+	if *watchFlag {
+		if *filePath == "" {
+			fmt.Fprintln(os.Stderr, "-watch needs a script to run")
+			os.Exit(1)
+		}
+		// The runs share the command line, less -watch itself
+		args := withoutFlag(flag.CommandLine, flags, "watch")
+		if err := watch(*filePath, append(args, rest...)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *compile != "" {
+		if err := compileFile(*compile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *tokens {
+		src, err := readSource(*filePath, *code)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dumpTokens(os.Stdout, src)
+		return
+	}
+
+	if *astJSON {
+		src, err := readSource(*filePath, *code)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := dumpAST(os.Stdout, src, *filePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *profile {
+		p := evaluator.StartProfile()
+		// An exit hook also prints the report when the script calls exit()
+		evaluator.AtExit(func() {
+			evaluator.StopProfile()
+			p.Report(os.Stderr)
+		})
+	}
+
+	if *stats {
+		s := evaluator.StartStats()
+		evaluator.AtExit(func() {
+			evaluator.StopStats()
+			s.Report(os.Stderr)
+		})
+	}
+
+	if *traceAll || *traceFunc != "" {
+		evaluator.StartTrace(os.Stderr, *traceFunc)
+	}
+
+	interactive := *code == "" && *filePath == "" && term.IsTerminal(int(os.Stdin.Fd()))
+	if *timeout > 0 && !interactive {
+		// A script blocked in a builtin, such as one waiting for input,
+		// evaluates nothing and so never notices the timeout
+		time.AfterFunc(*timeout+time.Second, func() {
+			fmt.Fprintf(os.Stderr, "timeout: execution took longer than %v\n", *timeout)
+			evaluator.RunExitHooks()
+			os.Exit(1)
+		})
+	}
+
+	// Scripts report errors on standard error and exit with status 1 after
+	// one, so that shell scripts can tell they failed
+	repl.ErrorOutput = os.Stderr
+	ok := true
+	if *code != "" {
+		// Errors are reported against the code as if it were a file
+		ok = repl.StartWithFile(os.Stdout, "<expr>", *code, *timed)
+	} else if *filePath != "" && *stream {
+		file, err := os.Open(*filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", *filePath, err)
+			os.Exit(1)
+		}
+		ok = repl.StartWithReader(os.Stdout, file, *timed)
+		file.Close()
+	} else if *filePath != "" {
 		// If a file is provided with -f, run the script
 		content, err := os.ReadFile(*filePath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", *filePath, err)
 			os.Exit(1)
 		}
-		repl.StartWithString(os.Stdout, string(content), *timed)
+		if compiled.IsCompiled(content) {
+			program, err := compiled.Read(bytes.NewReader(content))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading file %s: %v\n", *filePath, err)
+				os.Exit(1)
+			}
+			program.File = *filePath
+			ok = repl.StartWithProgram(os.Stdout, program, *timed)
+		} else {
+			ok = repl.StartWithFile(os.Stdout, *filePath, string(content), *timed)
+		}
+	} else if !term.IsTerminal(int(os.Stdin.Fd())) {
+		// Input piped in, as in `cat script.1y | 1y`, is run as a whole program
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading standard input: %v\n", err)
+			os.Exit(1)
+		}
+		ok = repl.StartWithFile(os.Stdout, "<stdin>", string(content), *timed)
 	} else {
 		// Otherwise, start the REPL
 		fmt.Printf("1y Language %s -- %s\n", VERSION, "A programming language written in Go")
 		fmt.Println(HELP)
 		repl.Start(os.Stdin, os.Stdout, *timed)
 	}
+
+	evaluator.RunExitHooks()
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// readSource returns the code given with -e, the contents of the file path,
+// or else standard input.
+func readSource(path, code string) (string, error) {
+	if code != "" {
+		return code, nil
+	}
+	if path == "" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("Error reading standard input: %v", err)
+		}
+		return string(content), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading file %s: %v", path, err)
+	}
+	return string(content), nil
+}
+
+// dumpTokens writes the tokens of src one per line with the line and column
+// they start at, for debugging the lexer.
+func dumpTokens(out io.Writer, src string) {
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(out, "%d:%d\t%-10s %q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// dumpAST writes the syntax tree of src, read from the file path if any, as
+// JSON for tools outside Go.
+func dumpAST(out io.Writer, src, path string) error {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("Error parsing script:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+	program.File = path
+
+	data, err := ast.MarshalJSON(program)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
+
+// splitArgs splits the command line after the flag naming the script or code
+// to run. Everything following it belongs to the script, even arguments that
+// look like interpreter flags. Flags are looked up in set to tell which take
+// their value from the next argument.
+func splitArgs(set *flag.FlagSet, args []string) (flags, rest []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			// flag stops at it, and the script's arguments follow
+			return args[:i+1], args[i+1:]
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			// flag stops at the first argument that is not a flag
+			return args, nil
+		}
+
+		name := strings.TrimPrefix(arg[1:], "-")
+		name, _, hasValue := strings.Cut(name, "=")
+		f := set.Lookup(name)
+		if f == nil {
+			// flag reports it
+			return args, nil
+		}
+		if !hasValue && !isBoolFlag(f) {
+			i++
+			if i == len(args) {
+				return args, nil
+			}
+		}
+		if name == "f" || name == "e" {
+			return args[:i+1], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+// isBoolFlag reports whether f is set by its name alone, like -t, rather
+// than taking a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// sizeUnits are the suffixes parseSize accepts, largest first so that "B"
+// is tried last.
+var sizeUnits = []struct {
+	suffix string
+	size   int
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize parses a number of bytes, such as 1048576, 1024KB or 1MB. Units
+// are powers of 1024 and case is ignored.
+func parseSize(s string) (int, error) {
+	number, unit := strings.ToUpper(strings.TrimSpace(s)), 1
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive size such as 256MB")
+	}
+	if n > math.MaxInt/unit {
+		return 0, fmt.Errorf("size is too large")
+	}
+	return n * unit, nil
+}
+
+// formatFiles runs `1y fmt [-w] [files]`, printing each file formatted, or
+// rewriting it in place with -w. Without files, standard input is formatted.
+func formatFiles(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "Write the result to the file instead of standard output")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("Error reading standard input: %v", err)
+		}
+		out, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("Error formatting standard input:\n\t%s", strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading file %s: %v", path, err)
+		}
+		out, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("Error formatting file %s:\n\t%s", path, strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+		}
+		if !*write {
+			os.Stdout.Write(out)
+			continue
+		}
+		if bytes.Equal(out, content) {
+			continue
+		}
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			return fmt.Errorf("Error writing file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// lintFiles runs `1y lint [files]`, printing the problems found in each file,
+// or in standard input without files. It reports whether there were any.
+func lintFiles(out io.Writer, paths []string) (found bool, err error) {
+	check := func(name, src string) error {
+		p := parser.New(lexer.New(src))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return fmt.Errorf("Error parsing %s:\n\t%s", name, strings.Join(p.Errors(), "\n\t"))
+		}
+		for _, problem := range lint.Check(program) {
+			fmt.Fprintf(out, "%s:%d: %s\n", name, problem.Line, problem.Message)
+			found = true
+		}
+		return nil
+	}
+
+	if len(paths) == 0 {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return false, fmt.Errorf("Error reading standard input: %v", err)
+		}
+		return found, check("<stdin>", string(content))
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return found, fmt.Errorf("Error reading file %s: %v", path, err)
+		}
+		if err := check(path, string(content)); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// compileFile parses the script at path and writes it to a .1yc file with the
+// same name, so running or importing it later skips lexing and parsing.
+func compileFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading file %s: %v", path, err)
+	}
+
+	p := parser.New(lexer.New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("Error parsing file %s:\n\t%s", path, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	target := strings.TrimSuffix(path, filepath.Ext(path)) + compiled.Extension
+	out, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("Error writing file %s: %v", target, err)
+	}
+	if err := compiled.Write(out, program); err != nil {
+		out.Close()
+		return fmt.Errorf("Error writing file %s: %v", target, err)
+	}
+	return out.Close()
 }
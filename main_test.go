@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestWithoutFlag(t *testing.T) {
+	set := flag.NewFlagSet("1y", flag.ContinueOnError)
+	set.Bool("watch", false, "")
+	set.Bool("t", false, "")
+	set.String("f", "", "")
+	set.String("trace-func", "", "")
+
+	tests := []struct {
+		flags    []string
+		expected string
+	}{
+		{[]string{"-watch", "-f", "a.1y"}, "[-f a.1y]"},
+		{[]string{"--watch", "-t", "-f", "a.1y"}, "[-t -f a.1y]"},
+		{[]string{"-watch=true", "-f", "a.1y"}, "[-f a.1y]"},
+		{[]string{"-trace-func", "watch", "-watch", "-f", "a.1y"}, "[-trace-func watch -f a.1y]"},
+		{[]string{"-trace-func", "-watch", "-watch", "-f", "a.1y"}, "[-trace-func -watch -f a.1y]"},
+		{[]string{"--trace-func=watch", "-watch", "-f", "a.1y"}, "[--trace-func=watch -f a.1y]"},
+		{[]string{"-watch", "--", "-watch"}, "[-- -watch]"},
+		{[]string{"-watch", "script.1y", "-watch"}, "[script.1y -watch]"},
+		{[]string{"-watch"}, "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(withoutFlag(set, tt.flags, "watch")); got != tt.expected {
+			t.Errorf("withoutFlag(%q) = %s, want %s", tt.flags, got, tt.expected)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	set := flag.NewFlagSet("1y", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+	set.String("f", "", "")
+	set.String("e", "", "")
+	set.Bool("t", false, "")
+	set.String("lang", "", "")
+
+	tests := []struct {
+		args        []string
+		flags, rest string
+	}{
+		{[]string{"-f", "a.1y", "-t", "x"}, "[-f a.1y]", "[-t x]"},
+		{[]string{"--f=a.1y", "-e", "b"}, "[--f=a.1y]", "[-e b]"},
+		{[]string{"-e", "-f", "-t"}, "[-e -f]", "[-t]"},
+		{[]string{"-t", "-lang", "zh", "-f", "a.1y", "-lang", "en"}, "[-t -lang zh -f a.1y]", "[-lang en]"},
+		{[]string{"-lang", "-f", "-e", "1", "-t"}, "[-lang -f -e 1]", "[-t]"},
+		{[]string{"-lang=zh", "-t=false", "-f", "a.1y"}, "[-lang=zh -t=false -f a.1y]", "[]"},
+		{[]string{"-t", "--", "-f", "a.1y"}, "[-t --]", "[-f a.1y]"},
+		{[]string{"-t", "script.1y", "-f", "a.1y"}, "[-t script.1y -f a.1y]", "[]"},
+		{[]string{"-", "-f", "a.1y"}, "[- -f a.1y]", "[]"},
+		{[]string{"-unknown", "-f", "a.1y"}, "[-unknown -f a.1y]", "[]"},
+		{[]string{"-t", "-f"}, "[-t -f]", "[]"},
+		{[]string{"-lang"}, "[-lang]", "[]"},
+		{nil, "[]", "[]"},
+	}
+
+	for _, tt := range tests {
+		flags, rest := splitArgs(set, tt.args)
+		if got := fmt.Sprint(flags); got != tt.flags {
+			t.Errorf("splitArgs(%q) flags = %s, want %s", tt.args, got, tt.flags)
+		}
+		if got := fmt.Sprint(rest); got != tt.rest {
+			t.Errorf("splitArgs(%q) rest = %s, want %s", tt.args, got, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package object
+
+import "hash/fnv"
+
+// arrayStore tracks a backing slice shared by arrays made from one another
+// with Slice and Extend. used is the length of the longest of those arrays
+// that starts at the slice's first element; capacity past it is free.
+// Arrays made by Slice have their capacity clipped, so only arrays starting
+// at the first element can have spare capacity.
+type arrayStore struct {
+	used int
+}
+
+// share marks the elements of ao as shared and returns their store.
+func (ao *Array) share() *arrayStore {
+	if ao.store == nil {
+		ao.store = &arrayStore{used: len(ao.Elements)}
+	}
+	return ao.store
+}
+
+// own makes sure no other array shares the elements of ao, copying them if
+// necessary, before ao is modified in place.
+func (ao *Array) own() {
+	if ao.store == nil {
+		return
+	}
+	elements := make([]Object, len(ao.Elements), cap(ao.Elements))
+	copy(elements, ao.Elements)
+	ao.Elements = elements
+	ao.store = nil
+}
+
+// Slice returns the elements of ao from index from up to, but not including,
+// index to as a new array. No elements are copied until one of the arrays is
+// modified.
+func (ao *Array) Slice(from, to int) *Array {
+	return &Array{Elements: ao.Elements[from:to:to], store: ao.share()}
+}
+
+// Extend returns a new array holding the elements of ao followed by elements.
+// When ao is the longest array on its storage and has room, elements are
+// appended in place, so building an array with repeated `a = a + [x]` takes
+// amortised linear time rather than copying the whole array on every step.
+func (ao *Array) Extend(elements []Object) *Array {
+	s := ao.share()
+	if len(ao.Elements) == s.used && cap(ao.Elements)-len(ao.Elements) >= len(elements) {
+		extended := append(ao.Elements, elements...)
+		s.used = len(extended)
+		return &Array{Elements: extended, store: s}
+	}
+
+	size := len(ao.Elements) + len(elements)
+	extended := make([]Object, 0, 2*size)
+	extended = append(extended, ao.Elements...)
+	extended = append(extended, elements...)
+	return &Array{Elements: extended}
+}
+
+// Set replaces the element at index i of ao.
+func (ao *Array) Set(i int, val Object) {
+	ao.own()
+	ao.Elements[i] = val
+}
+
+// Push appends elements to ao itself.
+func (ao *Array) Push(elements ...Object) {
+	if ao.store != nil && len(ao.Elements) == ao.store.used {
+		// The capacity past ao is free, so appending in place is safe
+		ao.Elements = append(ao.Elements, elements...)
+		ao.store.used = len(ao.Elements)
+		return
+	}
+	ao.own()
+	ao.Elements = append(ao.Elements, elements...)
+}
+
+// Splice removes n elements of ao from index i and puts elements in their
+// place, changing ao itself, and returns the elements removed.
+func (ao *Array) Splice(i, n int, elements ...Object) []Object {
+	removed := make([]Object, n)
+	copy(removed, ao.Elements[i:i+n])
+
+	spliced := make([]Object, 0, len(ao.Elements)-n+len(elements))
+	spliced = append(spliced, ao.Elements[:i]...)
+	spliced = append(spliced, elements...)
+	spliced = append(spliced, ao.Elements[i+n:]...)
+
+	// The elements are copied, so ao no longer shares them
+	ao.Elements = spliced
+	ao.store = nil
+	return removed
+}
+
+// HashKey combines the hash keys of the elements of ao, so arrays holding
+// equal elements, such as coordinates, find the same pair of a hash. It is
+// worked out afresh on each call, as the elements can change. Only arrays
+// AsHashable accepts have a hash key.
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, el := range ao.Elements {
+		key := el.(Hashable).HashKey()
+		h.Write([]byte(key.Type))
+		for i := range buf {
+			buf[i] = byte(key.Value >> (8 * i))
+		}
+		h.Write(buf[:])
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+// copyKey returns a copy of key if it is an array, nested arrays included,
+// and key itself otherwise.
+func copyKey(key Object) Object {
+	arr, ok := key.(*Array)
+	if !ok {
+		return key
+	}
+	elements := make([]Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		elements[i] = copyKey(el)
+	}
+	return &Array{Elements: elements}
+}
+
+// AsHashable returns obj as a hash key, or false if it cannot be one. An
+// array can be a key when each of its elements can, and it does not hold
+// itself.
+func AsHashable(obj Object) (Hashable, bool) {
+	return asHashable(obj, map[*Array]bool{})
+}
+
+// asHashable is AsHashable, with open holding the arrays obj is inside of.
+func asHashable(obj Object, open map[*Array]bool) (Hashable, bool) {
+	arr, ok := obj.(*Array)
+	if !ok {
+		hashable, ok := obj.(Hashable)
+		return hashable, ok
+	}
+	if open[arr] {
+		return nil, false
+	}
+	open[arr] = true
+	defer delete(open, arr)
+	for _, el := range arr.Elements {
+		if _, ok := asHashable(el, open); !ok {
+			return nil, false
+		}
+	}
+	return arr, true
+}
@@ -0,0 +1,49 @@
+package object
+
+import "unicode/utf8"
+
+// byteOffsets is stored for strings whose code points are all single bytes,
+// which are indexed directly.
+var byteOffsets = new([]int)
+
+// CharAt returns the code point at index i of the string, counting code
+// points rather than bytes, and false if there is none. Strings of single
+// byte code points are indexed directly. For others the offsets of the code
+// points are worked out on first use and kept, so that indexing every
+// character of a string in turn takes linear rather than quadratic time.
+//
+// Interned strings are shared by every interpreter, so the offsets are
+// published atomically: goroutines indexing the same string at once may
+// each work them out, but all see a complete slice.
+func (s *String) CharAt(i int) (string, bool) {
+	offsets := s.offsets.Load()
+	if offsets == nil {
+		offsets = byteOffsets
+		if n := utf8.RuneCountInString(s.Value); n != len(s.Value) {
+			runes := make([]int, 0, n)
+			for offset := range s.Value {
+				runes = append(runes, offset)
+			}
+			offsets = &runes
+		}
+		s.offsets.Store(offsets)
+	}
+
+	if offsets == byteOffsets {
+		if i < 0 || i >= len(s.Value) {
+			return "", false
+		}
+		// Bytes that are not valid UTF-8 read as U+FFFD, as they do when
+		// ranging over the string
+		if b := s.Value[i]; b >= utf8.RuneSelf {
+			return string(utf8.RuneError), true
+		}
+		return s.Value[i : i+1], true
+	}
+
+	if i < 0 || i >= len(*offsets) {
+		return "", false
+	}
+	r, _ := utf8.DecodeRuneInString(s.Value[(*offsets)[i]:])
+	return string(r), true
+}
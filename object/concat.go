@@ -0,0 +1,42 @@
+package object
+
+import "unsafe"
+
+// Strings shorter than this are concatenated by plain copying.
+const minBufferedConcat = 64
+
+// stringBuffer holds the bytes of a chain of strings built by repeated
+// concatenation. Each string in the chain views a prefix of data; only bytes
+// past the end of every existing view are ever written, so the views never
+// change.
+type stringBuffer struct {
+	data []byte
+}
+
+// Concat returns left followed by right. When left is the longest string
+// built so far on its buffer, right is appended in place, so building a
+// string with repeated `s += x` takes amortised linear time rather than
+// copying the whole string on every step.
+func Concat(left *String, right string) *String {
+	if b := left.buf; b != nil && len(b.data) == len(left.Value) {
+		b.data = append(b.data, right...)
+		return &String{Value: bufferString(b.data), buf: b}
+	}
+
+	size := len(left.Value) + len(right)
+	if size < minBufferedConcat {
+		return &String{Value: left.Value + right}
+	}
+
+	b := &stringBuffer{data: make([]byte, 0, 2*size)}
+	b.data = append(b.data, left.Value...)
+	b.data = append(b.data, right...)
+	return &String{Value: bufferString(b.data), buf: b}
+}
+
+func bufferString(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	return unsafe.String(&data[0], len(data))
+}
@@ -0,0 +1,378 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+var (
+	objectType   = reflect.TypeOf((*Object)(nil)).Elem()
+	bigIntType   = reflect.TypeOf((*big.Int)(nil))
+	bigFloatType = reflect.TypeOf((*big.Float)(nil))
+)
+
+// convertToReflectValue converts arg to a value of targetType, to be passed
+// to a Go function. Integers fit any integer type they are in range of,
+// hashes become maps or structs, and null becomes the nil of pointers,
+// slices, maps and interfaces.
+func convertToReflectValue(arg Object, targetType reflect.Type) (reflect.Value, error) {
+	// Functions that accept objects directly (e.g. *Hash or Object) receive them as is
+	if reflect.TypeOf(arg).AssignableTo(targetType) && targetType.Kind() != reflect.Interface || targetType == objectType {
+		return reflect.ValueOf(arg), nil
+	}
+
+	if _, ok := arg.(*Null); ok {
+		switch targetType.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(targetType), nil
+		}
+		return cannotConvert(arg, targetType)
+	}
+
+	switch targetType.Kind() {
+	case reflect.Interface:
+		if val := reflect.ValueOf(goValue(arg)); val.Type().AssignableTo(targetType) {
+			return val, nil
+		}
+	case reflect.Bool:
+		if b, ok := arg.(*Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(targetType), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := arg.(*Integer); ok {
+			if !i.Value.IsInt64() || reflect.Zero(targetType).OverflowInt(i.Value.Int64()) {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", i.Value, targetType)
+			}
+			return reflect.ValueOf(i.Value.Int64()).Convert(targetType), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := arg.(*Integer); ok {
+			if !i.Value.IsUint64() || reflect.Zero(targetType).OverflowUint(i.Value.Uint64()) {
+				return reflect.Value{}, fmt.Errorf("%s is out of range for %s", i.Value, targetType)
+			}
+			return reflect.ValueOf(i.Value.Uint64()).Convert(targetType), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := arg.(type) {
+		case *Integer:
+			f, _ := new(big.Float).SetInt(v.Value).Float64()
+			return reflect.ValueOf(f).Convert(targetType), nil
+		case *Float:
+			f, _ := v.Value.Float64()
+			return reflect.ValueOf(f).Convert(targetType), nil
+		}
+	case reflect.String:
+		if s, ok := arg.(*String); ok {
+			return reflect.ValueOf(s.Value).Convert(targetType), nil
+		}
+	case reflect.Slice:
+		if a, ok := arg.(*Array); ok {
+			slice := reflect.MakeSlice(targetType, len(a.Elements), len(a.Elements))
+			for i, elem := range a.Elements {
+				val, err := convertToReflectValue(elem, targetType.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("element %d: %s", i, err)
+				}
+				slice.Index(i).Set(val)
+			}
+			return slice, nil
+		}
+	case reflect.Map:
+		if h, ok := arg.(*Hash); ok {
+			m := reflect.MakeMapWithSize(targetType, len(h.Pairs))
+			for _, pair := range h.Pairs {
+				key, err := convertToReflectValue(pair.Key, targetType.Key())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+				}
+				val, err := convertToReflectValue(pair.Value, targetType.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of %s: %s", pair.Key.Inspect(), err)
+				}
+				m.SetMapIndex(key, val)
+			}
+			return m, nil
+		}
+	case reflect.Struct:
+		if h, ok := arg.(*Hash); ok {
+			return hashToStruct(h, targetType)
+		}
+	case reflect.Ptr:
+		// Numbers are copied so the function cannot change them
+		switch v := arg.(type) {
+		case *Integer:
+			if targetType == bigIntType {
+				return reflect.ValueOf(new(big.Int).Set(v.Value)), nil
+			}
+			if targetType == bigFloatType {
+				return reflect.ValueOf(new(big.Float).SetInt(v.Value)), nil
+			}
+		case *Float:
+			if targetType == bigFloatType {
+				return reflect.ValueOf(new(big.Float).Copy(v.Value)), nil
+			}
+		case *Hash:
+			if targetType.Elem().Kind() == reflect.Struct {
+				val, err := hashToStruct(v, targetType.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				ptr := reflect.New(targetType.Elem())
+				ptr.Elem().Set(val)
+				return ptr, nil
+			}
+		}
+	}
+	return cannotConvert(arg, targetType)
+}
+
+func cannotConvert(arg Object, targetType reflect.Type) (reflect.Value, error) {
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", arg.Type(), targetType)
+}
+
+// ToGoValue returns the Go value closest to obj: an int64, or a *big.Int
+// for integers out of its range, a float64, string or bool, nil for null, a
+// []interface{} for arrays and a map[string]interface{} for hashes, or a
+// map[interface{}]interface{} if some keys are not strings. Elements are
+// converted in turn. Other objects, such as functions, are returned as they
+// are.
+func ToGoValue(obj Object) interface{} {
+	return goValue(obj)
+}
+
+// FromGoValue returns the object for the Go value v: integers of any size,
+// including *big.Int, become INTEGER, floats FLOAT, strings STRING and
+// bools BOOLEAN. Slices and arrays become arrays and maps hashes, with their
+// elements converted in turn, and structs hashes of their fields, named by
+// their 1y or json tags. nil becomes null and pointers and interfaces are
+// followed. Objects are returned as they are, and values of other types,
+// such as channels, become errors.
+func FromGoValue(v interface{}) Object {
+	return convertFromReflectValue(reflect.ValueOf(v))
+}
+
+// goValue returns the Go value closest to obj, for functions taking
+// interface{}: int64, or *big.Int if it does not fit, float64, string,
+// bool, nil, []interface{}, and map[string]interface{}, or
+// map[interface{}]interface{} for hashes with keys other than strings.
+// Other objects, such as functions, are returned as they are.
+func goValue(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case *Integer:
+		if obj.Value.IsInt64() {
+			return obj.Value.Int64()
+		}
+		return new(big.Int).Set(obj.Value)
+	case *Float:
+		f, _ := obj.Value.Float64()
+		return f
+	case *String:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return nil
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			elements[i] = goValue(elem)
+		}
+		return elements
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if _, ok := pair.Key.(*String); !ok {
+				stringKeys = false
+				break
+			}
+		}
+		if stringKeys {
+			m := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				m[pair.Key.(*String).Value] = goValue(pair.Value)
+			}
+			return m
+		}
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			m[goValue(pair.Key)] = goValue(pair.Value)
+		}
+		return m
+	}
+	return obj
+}
+
+// fieldName returns the key a struct field has in a hash: the name in its
+// 1y tag, or else in its json tag, if there is one, or else its own name.
+// Unexported fields and those tagged "-" are left out.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag, ok := field.Tag.Lookup("1y")
+	if !ok {
+		tag = field.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// hashToStruct makes a struct of type t with the fields named by the keys
+// of h set to their values.
+func hashToStruct(h *Hash, t reflect.Type) (reflect.Value, error) {
+	val := reflect.New(t).Elem()
+	if err := setFields(h, val); err != nil {
+		return reflect.Value{}, err
+	}
+	return val, nil
+}
+
+// setFields sets the fields of the struct val named by the keys of h to
+// their values, leaving the others as they are. Keys name fields as
+// fieldName does, or by the name of the field in any case.
+func setFields(h *Hash, val reflect.Value) error {
+	t := val.Type()
+	for _, pair := range h.Pairs {
+		key, ok := pair.Key.(*String)
+		if !ok {
+			return fmt.Errorf("field names must be STRING, got %s", pair.Key.Type())
+		}
+		index := -1
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if ok && (key.Value == name || strings.EqualFold(key.Value, t.Field(i).Name)) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("%s has no field %s", t, key.Value)
+		}
+		if err := decodeInto(pair.Value, val.Field(index)); err != nil {
+			return fmt.Errorf("field %s: %s", key.Value, err)
+		}
+	}
+	return nil
+}
+
+// Decode stores obj in the value target points to, converting it as the
+// arguments of functions registered with RegisterFunctions are. Hashes fill
+// structs, their keys naming fields by their 1y or json tags, such as
+// `1y:"name"`, or by the names of the fields in any case. A key naming no
+// field is an error. Fields missing from a hash keep their values, so that
+// target can hold defaults.
+func Decode(obj Object, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("Decode target must be a non-nil pointer, got %T", target)
+	}
+	return decodeInto(obj, ptr.Elem())
+}
+
+// decodeInto sets dst to obj. Structs are filled in place, so the fields
+// obj leaves out keep their values.
+func decodeInto(obj Object, dst reflect.Value) error {
+	if h, ok := obj.(*Hash); ok && dst.Kind() == reflect.Struct {
+		return setFields(h, dst)
+	}
+	val, err := convertToReflectValue(obj, dst.Type())
+	if err != nil {
+		return err
+	}
+	dst.Set(val)
+	return nil
+}
+
+// convertFromReflectValue converts a value returned by a Go function to an
+// object, the reverse of convertToReflectValue. Structs become hashes of
+// their fields, named as fieldName does, and nil becomes null.
+func convertFromReflectValue(val reflect.Value) Object {
+	if !val.IsValid() {
+		return NULL
+	}
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if val.IsNil() {
+			return NULL
+		}
+	}
+
+	// Functions may build their results as objects themselves
+	if obj, ok := val.Interface().(Object); ok {
+		return obj
+	}
+
+	switch val.Type() {
+	case bigIntType:
+		return &Integer{Value: new(big.Int).Set(val.Interface().(*big.Int))}
+	case bigFloatType:
+		return &Float{Value: new(big.Float).Copy(val.Interface().(*big.Float))}
+	}
+
+	switch val.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return convertFromReflectValue(val.Elem())
+	case reflect.Bool:
+		if val.Bool() {
+			return TRUE
+		}
+		return FALSE
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: big.NewInt(val.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Integer{Value: new(big.Int).SetUint64(val.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: big.NewFloat(val.Float())}
+	case reflect.String:
+		return &String{Value: val.String()}
+	case reflect.Slice, reflect.Array:
+		elements := make([]Object, val.Len())
+		for i := range elements {
+			elements[i] = convertFromReflectValue(val.Index(i))
+			if err, ok := elements[i].(*Error); ok {
+				return err
+			}
+		}
+		return &Array{Elements: elements}
+	case reflect.Map:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, val.Len())}
+		iter := val.MapRange()
+		for iter.Next() {
+			key := convertFromReflectValue(iter.Key())
+			if _, ok := AsHashable(key); !ok {
+				return newError("unusable as hash key: %s", key.Type())
+			}
+			value := convertFromReflectValue(iter.Value())
+			if err, ok := value.(*Error); ok {
+				return err
+			}
+			if !hash.Put(key, value) {
+				return newError("hash key %s collides with another key", key.Inspect())
+			}
+		}
+		return hash
+	case reflect.Struct:
+		pairs := make(map[HashKey]HashPair)
+		for i := 0; i < val.NumField(); i++ {
+			name, ok := fieldName(val.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value := convertFromReflectValue(val.Field(i))
+			if err, ok := value.(*Error); ok {
+				return err
+			}
+			key := Intern(name)
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}
+	}
+	return newError("unsupported return type %s", val.Type())
+}
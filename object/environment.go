@@ -2,8 +2,9 @@ package object
 
 import (
 	"fmt"
-	"math/big"
 	"reflect"
+	"sync"
+	"unicode"
 )
 
 type EnvValue struct {
@@ -11,9 +12,21 @@ type EnvValue struct {
 	ReadOnly bool // If true, the value cannot be changed
 }
 
+// Environment holds the variables of one scope. It is safe for concurrent
+// use, so goroutines running 1y code can share globals and closures.
 type Environment struct {
+	mu    sync.RWMutex // guards store and slots
 	store map[string]EnvValue
 	outer *Environment
+
+	// Variables of a resolved function or loop scope live in slots, indexed by
+	// the positions in names. A nil Value marks a slot not yet declared.
+	names map[string]int
+	slots []EnvValue
+
+	captured bool        // a closure refers to this environment
+	root     bool        // predeclared names shared by global environments
+	owner    interface{} // of the enclosing root frame, see Owner
 }
 
 func NewEnvironment() *Environment {
@@ -24,57 +37,245 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	if outer != nil {
+		env.owner = outer.owner
+	}
+	return env
+}
+
+// NewRootEnvironment creates a frame of predeclared names, such as builtins,
+// for global environments to enclose. The names are constants, so a program
+// can shadow them with its own declarations but cannot change them for
+// others sharing the frame. owner, such as the interpreter the names belong
+// to, is returned by Owner for every environment the frame encloses.
+func NewRootEnvironment(owner interface{}, names map[string]Object) *Environment {
+	env := NewEnvironment()
+	env.root = true
+	env.owner = owner
+	for name, val := range names {
+		env.store[name] = EnvValue{Value: val, ReadOnly: true}
+	}
+	return env
+}
+
+// NewScopedEnvironment creates an environment with one slot for each of the
+// names, as assigned by the resolver for a function or loop scope.
+func NewScopedEnvironment(outer *Environment, names map[string]int) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	if len(names) > 0 {
+		env.names = names
+		env.slots = make([]EnvValue, len(names))
+	}
 	return env
 }
 
+// Store returns the variables of this environment held by name. The map is
+// not guarded, so it must not be used while other goroutines may declare or
+// assign variables here.
 func (e *Environment) Store() map[string]EnvValue {
 	return e.store
 }
 
+// lookup returns the binding of name in this environment only. The caller
+// must hold e.mu.
+func (e *Environment) lookup(name string) (EnvValue, bool) {
+	if slot, ok := e.names[name]; ok && e.slots[slot].Value != nil {
+		return e.slots[slot], true
+	}
+	val, ok := e.store[name]
+	return val, ok
+}
+
 func (e *Environment) Get(name string) (Object, bool, bool) {
-	env, ok := e.store[name]
-	if !ok && e.outer != nil {
-		return e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		val, ok := env.lookup(name)
+		env.mu.RUnlock()
+		if ok {
+			return val.Value, true, val.ReadOnly
+		}
 	}
-	return env.Value, ok, env.ReadOnly
+	return nil, false, false
 }
 
 func (e *Environment) NewVar(name string, val Object) Object {
+	return e.declare(name, val, false)
+}
+
+func (e *Environment) Set(name string, val Object) Object {
+	for env := e; env != nil; env = env.outer {
+		if result, ok := env.assign(name, val); ok {
+			return result
+		}
+	}
+
+	// Unknown names are created in the outermost environment below the
+	// shared root
+	global := e
+	for global.outer != nil && !global.outer.root {
+		global = global.outer
+	}
+	global.mu.Lock()
+	defer global.mu.Unlock()
+	global.store[name] = EnvValue{Value: val, ReadOnly: false}
+	return val
+}
+
+// assign sets name in this environment only, reporting false if it is not
+// declared here.
+func (e *Environment) assign(name string, val Object) (Object, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current, ok := e.lookup(name)
+	if !ok {
+		return nil, false
+	}
+	if current.ReadOnly {
+		return newError("cannot assign to constant '%s'", name), true
+	}
+	if slot, ok := e.names[name]; ok && e.slots[slot].Value != nil {
+		e.slots[slot].Value = val
+	} else {
+		e.store[name] = EnvValue{Value: val, ReadOnly: false}
+	}
+	return val, true
+}
+
+func (e *Environment) NewConst(name string, val Object) Object {
+	return e.declare(name, val, true)
+}
+
+func (e *Environment) declare(name string, val Object, readOnly bool) Object {
 	if !isValidName(name) {
 		return newError("invalid variable name '%s'", name)
 	}
-	if e.isExist(name) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, exists := e.lookup(name); exists {
+		if readOnly {
+			return newError("cannot redeclare constant '%s'", name)
+		}
 		return newError("cannot redeclare variable '%s'", name)
 	}
-	e.store[name] = EnvValue{Value: val, ReadOnly: false}
+	if slot, ok := e.names[name]; ok {
+		e.slots[slot] = EnvValue{Value: val, ReadOnly: readOnly}
+	} else {
+		e.store[name] = EnvValue{Value: val, ReadOnly: readOnly}
+	}
 	return val
 }
 
-func (e *Environment) Set(name string, val Object) Object {
-	env, ok := e.store[name]
-	if !ok && e.outer != nil {
-		return e.outer.Set(name, val)
+// Capture records that a closure refers to e, and so to its outer
+// environments too, which must then outlive the call or loop that made them.
+func (e *Environment) Capture() {
+	for env := e; env != nil; env = env.outer {
+		env.mu.Lock()
+		done := env.captured
+		env.captured = true
+		env.mu.Unlock()
+		if done {
+			// Environments further out were marked when env was
+			return
+		}
 	}
-	if env.ReadOnly {
-		return newError("cannot assign to constant '%s'", name)
+}
+
+// Captured reports whether a closure refers to e.
+func (e *Environment) Captured() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.captured
+}
+
+// Reset removes every variable from the environment so that it can be used
+// again, as by the next iteration of a loop.
+func (e *Environment) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	clear(e.store)
+	clear(e.slots)
+}
+
+// Depth returns how many environments enclose e, not counting the frame of
+// predeclared names: 0 for a global environment, 1 inside a function called
+// from the top level, and so on.
+func (e *Environment) Depth() int {
+	depth := 0
+	for env := e.outer; env != nil && !env.root; env = env.outer {
+		depth++
 	}
-	e.store[name] = EnvValue{Value: val, ReadOnly: false}
-	return val
+	return depth
 }
 
-func (e *Environment) isExist(name string) bool {
-	_, ok := e.store[name]
-	return ok
+// Owner returns the owner given to NewRootEnvironment for the frame of
+// predeclared names enclosing e, or nil if there is none. Environments take
+// it from their outer environment when created, so finding it is cheap
+// enough to do for every node evaluated.
+func (e *Environment) Owner() interface{} {
+	if e == nil {
+		return nil
+	}
+	return e.owner
 }
 
-func (e *Environment) NewConst(name string, val Object) Object {
-	if !isValidName(name) {
-		return newError("invalid variable name '%s'", name)
+// at returns the environment depth levels out from e.
+func (e *Environment) at(depth int) *Environment {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	return env
+}
+
+// GetAt reads the variable in slot of the environment depth levels up,
+// as resolved ahead of time. ok is false when the slot has not been declared
+// yet, in which case callers fall back to Get.
+func (e *Environment) GetAt(depth, slot int) (Object, bool, bool) {
+	env := e.at(depth)
+	if slot >= len(env.slots) {
+		return nil, false, false
 	}
-	if e.isExist(name) {
-		return newError("cannot redeclare constant '%s'", name)
+	env.mu.RLock()
+	val := env.slots[slot]
+	env.mu.RUnlock()
+	return val.Value, val.Value != nil, val.ReadOnly
+}
+
+// SetAt assigns the variable in slot of the environment depth levels up. It
+// reports false without assigning when the slot has not been declared.
+func (e *Environment) SetAt(depth, slot int, val Object) bool {
+	env := e.at(depth)
+	if slot >= len(env.slots) {
+		return false
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.slots[slot].Value == nil {
+		return false
+	}
+	env.slots[slot].Value = val
+	return true
+}
+
+// DeclareAt declares a variable directly in one of this environment's slots.
+func (e *Environment) DeclareAt(slot int, name string, val Object, readOnly bool) Object {
+	if slot >= len(e.slots) {
+		return e.declare(name, val, readOnly)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.slots[slot].Value != nil {
+		if readOnly {
+			return newError("cannot redeclare constant '%s'", name)
+		}
+		return newError("cannot redeclare variable '%s'", name)
 	}
-	e.store[name] = EnvValue{Value: val, ReadOnly: true}
+	e.slots[slot] = EnvValue{Value: val, ReadOnly: readOnly}
 	return val
 }
 
@@ -99,7 +300,7 @@ func RegisterFunctions(env *Environment, namespace string, funcs map[string]inte
 		builtin := &Builtin{
 			Fn: createBuiltinFunction(fn),
 		}
-		key := &String{Value: name}
+		key := Intern(name)
 		pairs[key.HashKey()] = HashPair{Key: key, Value: builtin}
 	}
 
@@ -112,6 +313,42 @@ func RegisterFunctions(env *Environment, namespace string, funcs map[string]inte
 	return hash
 }
 
+// RegisterStruct exposes the exported methods of value as functions in a
+// hash, declared as namespace in env unless namespace is "". Methods with a
+// pointer receiver are only found if value is a pointer. Their names start
+// in lower case, as in the library: Fetch and URLFor become fetch and
+// urlFor.
+func RegisterStruct(env *Environment, namespace string, value interface{}) *Hash {
+	v := reflect.ValueOf(value)
+	funcs := make(map[string]interface{}, v.NumMethod())
+	for i := 0; i < v.NumMethod(); i++ {
+		funcs[methodName(v.Type().Method(i).Name)] = v.Method(i).Interface()
+	}
+	return RegisterFunctions(env, namespace, funcs)
+}
+
+// methodName lowers the case of the initial word of a Go name, taking a run
+// of capitals as an acronym.
+func methodName(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// The last capital of a run followed by lower case starts the next word
+	if upper > 1 && upper < len(runes) && unicode.IsLower(runes[upper]) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// createBuiltinFunction wraps fn so it can be called with objects. A final
+// error result is reported as an error object, and several results are
+// returned as an array, so func(string) (int, bool, error) returns an array
+// of two elements.
 func createBuiltinFunction(fn interface{}) BuiltinFunction {
 	return func(args ...Object) Object {
 		fnValue := reflect.ValueOf(fn)
@@ -120,66 +357,58 @@ func createBuiltinFunction(fn interface{}) BuiltinFunction {
 		}
 
 		fnType := fnValue.Type()
-		if len(args) != fnType.NumIn() {
+		if fnType.IsVariadic() {
+			if len(args) < fnType.NumIn()-1 {
+				return newError("wrong number of arguments: expected at least %d, got %d", fnType.NumIn()-1, len(args))
+			}
+		} else if len(args) != fnType.NumIn() {
 			return newError("wrong number of arguments: expected %d, got %d", fnType.NumIn(), len(args))
 		}
 
 		in := make([]reflect.Value, len(args))
 		for i, arg := range args {
-			in[i] = convertToReflectValue(arg, fnType.In(i))
-			if !in[i].IsValid() {
-				return newError("unsupported argument type: %s", arg.Type())
+			val, err := convertToReflectValue(arg, argumentType(fnType, i))
+			if err != nil {
+				return newError("argument %d: %s", i+1, err)
 			}
+			in[i] = val
 		}
 
 		out := fnValue.Call(in)
-		if len(out) != 1 {
-			return newError("unexpected number of return values")
-		}
 
-		return convertFromReflectValue(out[0])
-	}
-}
+		// A final error result is not returned but reported, if not nil
+		if n := len(out); n > 0 && fnType.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+			out = out[:n-1]
+		}
 
-func convertToReflectValue(arg Object, targetType reflect.Type) reflect.Value {
-	switch v := arg.(type) {
-	case *Integer:
-		if targetType.Kind() == reflect.Float64 {
-			return reflect.ValueOf(float64(v.Value.Int64()))
+		switch len(out) {
+		case 0:
+			return NULL
+		case 1:
+			return convertFromReflectValue(out[0])
 		}
-		return reflect.ValueOf(v.Value)
-	case *Float:
-		return reflect.ValueOf(v.Value)
-	case *String:
-		return reflect.ValueOf(v.Value)
-	case *Array:
-		if targetType.Kind() == reflect.Slice {
-			elements := make([]interface{}, len(v.Elements))
-			for i, elem := range v.Elements {
-				elements[i] = convertToReflectValue(elem, targetType.Elem()).Interface()
+		// Several results are returned as an array
+		elements := make([]Object, len(out))
+		for i, val := range out {
+			elements[i] = convertFromReflectValue(val)
+			if err, ok := elements[i].(*Error); ok {
+				return err
 			}
-			return reflect.ValueOf(elements)
 		}
-	default:
-		return reflect.Value{}
+		return &Array{Elements: elements}
 	}
-
-	return reflect.Value{}
 }
 
-func convertFromReflectValue(val reflect.Value) Object {
-	switch val.Kind() {
-	case reflect.Float64:
-		return &Float{Value: big.NewFloat(val.Float())}
-	case reflect.String:
-		return &String{Value: val.String()}
-	case reflect.Slice:
-		elements := make([]Object, val.Len())
-		for i := 0; i < val.Len(); i++ {
-			elements[i] = convertFromReflectValue(val.Index(i))
-		}
-		return &Array{Elements: elements}
-	default:
-		return newError("unsupported return type")
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// argumentType returns the type of the i-th argument, spreading the final
+// parameter of variadic functions over any remaining arguments.
+func argumentType(fnType reflect.Type, i int) reflect.Type {
+	if fnType.IsVariadic() && i >= fnType.NumIn()-1 {
+		return fnType.In(fnType.NumIn() - 1).Elem()
 	}
+	return fnType.In(i)
 }
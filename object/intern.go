@@ -0,0 +1,42 @@
+package object
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Property names, hash keys and other short strings are used over and over
+// again. Interning them makes every use share one String whose HashKey is
+// computed once, instead of allocating and hashing the same text each time.
+
+const (
+	maxInternedLength = 32   // longer strings are rarely repeated keys
+	maxInterned       = 8192 // bounds the memory a program can pin here
+)
+
+var (
+	interned      sync.Map // string -> *String
+	internedCount atomic.Int64
+)
+
+// Intern returns a String holding value. Short values share a single String,
+// which must therefore never be modified.
+func Intern(value string) *String {
+	if len(value) > maxInternedLength {
+		return &String{Value: value}
+	}
+	if s, ok := interned.Load(value); ok {
+		return s.(*String)
+	}
+
+	s := &String{Value: value}
+	s.HashKey()
+	if internedCount.Load() >= maxInterned {
+		return s
+	}
+	if actual, loaded := interned.LoadOrStore(value, s); loaded {
+		return actual.(*String)
+	}
+	internedCount.Add(1)
+	return s
+}
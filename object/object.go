@@ -7,7 +7,10 @@ import (
 	"hash/fnv"
 	"math/big"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type ObjectType string
@@ -66,6 +69,7 @@ const (
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	FLOAT_OBJ        = "FLOAT"
+	MODULE_OBJ       = "MODULE"
 
 	BREAK_OBJ    = "BREAK"
 	CONTINUE_OBJ = "CONTINUE"
@@ -74,7 +78,8 @@ const (
 	MULTIDIMENSIONAL_INDEX_OBJ = "MULTIDIMENSIONAL_INDEX"
 )
 
-// Integer represents an integer object
+// Integer represents an integer object. Value is never modified once the
+// object exists, so the hash key can be cached.
 type Integer struct {
 	Value   *big.Int
 	hashKey HashKey // Cached HashKey
@@ -127,6 +132,14 @@ func (b *Boolean) HashKey() HashKey {
 // Null represents a null object
 type Null struct{}
 
+// NULL, TRUE and FALSE are the only null and boolean objects, so that they
+// can be compared by identity.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 func (n *Null) Inspect() string {
 	return "null"
 }
@@ -151,6 +164,26 @@ func (rv *ReturnValue) Type() ObjectType {
 // Error represents an error object
 type Error struct {
 	Message string
+	Code    string // ID of the message in the locale catalog, "" if not in it
+	Kind    string // what went wrong, such as "limit"; "" for most errors
+
+	// Position of the innermost node that failed, set as the error is
+	// returned from it. Line is 0 if the position is unknown, and File is ""
+	// for code not read from a file, such as REPL input.
+	File         string
+	Line, Column int
+
+	// Stack holds the calls the error was returned through, innermost first.
+	Stack []Frame
+}
+
+// Frame is a call an error was returned through: Function is the called
+// expression as written, such as "f" or "Math.sqrt", and the position is
+// that of the call.
+type Frame struct {
+	Function     string
+	File         string
+	Line, Column int
 }
 
 func (e *Error) Inspect() string {
@@ -166,6 +199,10 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Scope      *ast.Scope
+	File       string // file the function was defined in, if any
+
+	frames sync.Pool // environments of finished calls; see AcquireFrame
 }
 
 func (f *Function) Type() ObjectType {
@@ -194,6 +231,12 @@ func (f *Function) Inspect() string {
 type String struct {
 	Value   string
 	hashKey HashKey // Cached HashKey
+
+	// Set on strings produced by Concat; see concat.go
+	buf *stringBuffer
+
+	// Byte offsets of the code points, worked out by CharAt; see chars.go
+	offsets atomic.Pointer[[]int]
 }
 
 func (s *String) Type() ObjectType {
@@ -228,9 +271,13 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
-// Array represents an array object
+// Array represents an array object. Arrays made by Slice and Extend share
+// their elements until one is modified, so Elements must only be changed
+// through Set and Push; see array.go.
 type Array struct {
 	Elements []Object
+
+	store *arrayStore // set once Elements may be shared
 }
 
 func (ao *Array) Type() ObjectType {
@@ -242,7 +289,7 @@ func (ao *Array) Inspect() string {
 
 	elements := []string{}
 	for _, el := range ao.Elements {
-		elements = append(elements, el.Inspect())
+		elements = append(elements, inspectElement(el))
 	}
 
 	out.WriteString("[")
@@ -278,9 +325,9 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range SortedPairs(h) {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			inspectElement(pair.Key), inspectElement(pair.Value)))
 	}
 
 	out.WriteString("{")
@@ -314,6 +361,20 @@ func (h *Hash) computeHashKey() HashKey {
 	return HashKey{Type: h.Type(), Value: hfnv.Sum64()}
 }
 
+// Put stores value under key, which AsHashable must accept, unless the pair
+// under its hash key has a different key. Different keys can share a hash
+// key, if rarely, and Put then reports false instead of replacing the other
+// pair. An array key is copied, so changing the array later does not change
+// the key of the pair.
+func (h *Hash) Put(key Object, value Object) bool {
+	hashed := key.(Hashable).HashKey()
+	if pair, ok := h.Pairs[hashed]; ok && !IsEqual(pair.Key, key) {
+		return false
+	}
+	h.Pairs[hashed] = HashPair{Key: copyKey(key), Value: value}
+	return true
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -323,18 +384,56 @@ type Float struct {
 	hashKey HashKey // Cached HashKey
 }
 
+// Inspect writes f in the fewest digits that read back as the same value
+// at its precision. Like JavaScript, it uses an exponent only for values
+// from 1e21 up or below 1e-6, so 123456789.5 is not written 1.234567895e+08.
+// Integral values keep a ".0", so 2.0 is not mistaken for an integer.
 func (f *Float) Inspect() string {
-	return f.Value.Text('g', -1)
+	if f.Value.IsInf() {
+		return f.Value.String()
+	}
+
+	text := f.Value.Text('e', -1) // such as -1.2345e+08
+	mantissa, exp, _ := strings.Cut(text, "e")
+	e, _ := strconv.Atoi(exp)
+	if e >= 21 || e < -6 {
+		return mantissa + "e" + exp[:1] + strings.TrimLeft(exp[1:], "0")
+	}
+
+	sign := ""
+	if mantissa[0] == '-' {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	digits := strings.Replace(mantissa, ".", "", 1)
+	switch {
+	case e < 0:
+		return sign + "0." + strings.Repeat("0", -e-1) + digits
+	case len(digits) <= e+1:
+		return sign + digits + strings.Repeat("0", e+1-len(digits)) + ".0"
+	default:
+		return sign + digits[:e+1] + "." + digits[e+1:]
+	}
 }
 
 func (f *Float) Type() ObjectType {
 	return FLOAT_OBJ
 }
 
+// canonicalFloatText formats value exactly and independently of its
+// precision, so equal floats produce equal hash keys. Decimal formatting
+// rounds to the precision, which made a float computed at 53 bits and the
+// same value held at 64 bits hash differently.
+func canonicalFloatText(value *big.Float) string {
+	if value.Sign() == 0 {
+		return "0" // -0 == 0
+	}
+	return value.Text('p', 0)
+}
+
 func (f *Float) HashKey() HashKey {
 	if f.hashKey == (HashKey{}) {
 		h := fnv.New64a()
-		h.Write([]byte(f.Value.Text('g', -1)))
+		h.Write([]byte(canonicalFloatText(f.Value)))
 		f.hashKey = HashKey{Type: f.Type(), Value: h.Sum64()}
 	}
 	return f.hashKey
@@ -370,3 +469,17 @@ func (mdi *MultiDimensionalIndex) Inspect() string {
 func (mdi *MultiDimensionalIndex) Type() ObjectType {
 	return MULTIDIMENSIONAL_INDEX_OBJ
 }
+
+// Module is the result of import. Its top-level code runs the first time a
+// member is used, or when it is passed to load, after which Members holds
+// the module's top-level variables.
+type Module struct {
+	Path    string
+	Program *ast.Program // nil once loading has started
+	Env     *Environment
+	Members *Hash
+	Err     *Error // set when the top-level code failed
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Path) }
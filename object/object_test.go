@@ -1,6 +1,14 @@
 package object
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +28,680 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestIntern(t *testing.T) {
+	name := Intern("name")
+	if Intern("na"+strings.ToLower("ME")) != name {
+		t.Errorf("short strings with same content not shared")
+	}
+	if name.HashKey() != (&String{Value: "name"}).HashKey() {
+		t.Errorf("interned string has wrong hash key")
+	}
+
+	long := strings.Repeat("x", maxInternedLength+1)
+	if Intern(long) == Intern(long) {
+		t.Errorf("long strings should not be interned")
+	}
+}
+
+func TestNumberHashKey(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	bigger := new(big.Int).Add(huge, big.NewInt(1))
+
+	if (&Integer{Value: huge}).HashKey() != (&Integer{Value: new(big.Int).Set(huge)}).HashKey() {
+		t.Errorf("integers with same value have different hash keys")
+	}
+	if (&Integer{Value: huge}).HashKey() == (&Integer{Value: bigger}).HashKey() {
+		t.Errorf("integers with different values have same hash keys")
+	}
+
+	tenth := big.NewFloat(0.1)
+	wide := new(big.Float).SetPrec(256).Set(tenth)
+	if (&Float{Value: tenth}).HashKey() != (&Float{Value: wide}).HashKey() {
+		t.Errorf("floats with same value but different precision have different hash keys")
+	}
+
+	negZero := new(big.Float).Neg(big.NewFloat(0))
+	if (&Float{Value: negZero}).HashKey() != (&Float{Value: big.NewFloat(0)}).HashKey() {
+		t.Errorf("-0 and 0 have different hash keys")
+	}
+
+	parsed, _ := new(big.Float).SetString("0.1")
+	if (&Float{Value: parsed}).HashKey() == (&Float{Value: tenth}).HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	ints := func(values ...int64) *Array {
+		arr := &Array{}
+		for _, v := range values {
+			arr.Elements = append(arr.Elements, &Integer{Value: big.NewInt(v)})
+		}
+		return arr
+	}
+
+	if ints(1, 2).HashKey() != ints(1, 2).HashKey() {
+		t.Errorf("arrays with same elements have different hash keys")
+	}
+	if ints(1, 2).HashKey() == ints(2, 1).HashKey() {
+		t.Errorf("arrays with elements in different orders have same hash keys")
+	}
+	nested := &Array{Elements: []Object{ints(1), &String{Value: "a"}}}
+	if _, ok := AsHashable(nested); !ok {
+		t.Errorf("array of integers and strings is not hashable")
+	}
+
+	withBuiltin := &Array{Elements: []Object{ints(1), &Builtin{}}}
+	if _, ok := AsHashable(withBuiltin); ok {
+		t.Errorf("array holding a builtin is hashable")
+	}
+	cyclic := ints(1)
+	cyclic.Elements = append(cyclic.Elements, cyclic)
+	if _, ok := AsHashable(cyclic); ok {
+		t.Errorf("array holding itself is hashable")
+	}
+}
+
+func TestHashPut(t *testing.T) {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	key := &Array{Elements: []Object{&Integer{Value: big.NewInt(1)}, &Array{}}}
+	if !hash.Put(key, TRUE) {
+		t.Fatalf("Put into an empty hash failed")
+	}
+	key.Elements[0] = &String{Value: "changed"}
+	key.Elements[1].(*Array).Elements = []Object{TRUE}
+	if got := hash.Pairs[(&Array{Elements: []Object{&Integer{Value: big.NewInt(1)}, &Array{}}}).HashKey()].Key; got == nil || got.Inspect() != "[1, []]" {
+		t.Errorf("changing the array changed the key of its pair. got=%v", got)
+	}
+
+	// A different key with the same hash key is kept apart
+	colliding := &String{Value: "other"}
+	hash.Pairs[colliding.HashKey()] = HashPair{Key: &String{Value: "first"}, Value: TRUE}
+	if hash.Put(colliding, FALSE) {
+		t.Errorf("Put replaced the pair of a different key")
+	}
+	if pair := hash.Pairs[colliding.HashKey()]; pair.Value != TRUE {
+		t.Errorf("colliding Put changed the pair. got=%s", pair.Value.Inspect())
+	}
+	if !hash.Put(&String{Value: "first"}, FALSE) {
+		t.Errorf("Put of an equal key failed")
+	}
+}
+
+func TestRegisterFunctionsObjectArguments(t *testing.T) {
+	env := NewEnvironment()
+	hash := RegisterFunctions(env, "", map[string]interface{}{
+		"size": func(h *Hash) string {
+			return fmt.Sprintf("%d", len(h.Pairs))
+		},
+	})
+
+	key := &String{Value: "size"}
+	fn := hash.Pairs[key.HashKey()].Value.(*Builtin)
+
+	arg := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: key}}}
+	result, ok := fn.Fn(arg).(*String)
+	if !ok {
+		t.Fatalf("result is not String. got=%T", fn.Fn(arg))
+	}
+	if result.Value != "1" {
+		t.Errorf("wrong result. expected=%q, got=%q", "1", result.Value)
+	}
+}
+
+func TestRegisterFunctionsResults(t *testing.T) {
+	env := NewEnvironment()
+	hash := RegisterFunctions(env, "", map[string]interface{}{
+		"parse": func(s string) (float64, error) {
+			if s == "" {
+				return 0, errors.New("empty input")
+			}
+			return 1.5, nil
+		},
+		"split": func(s string) (string, string) {
+			before, after, _ := strings.Cut(s, "=")
+			return before, after
+		},
+		"check": func(s string) error {
+			return nil
+		},
+	})
+	call := func(name string, args ...Object) Object {
+		key := &String{Value: name}
+		return hash.Pairs[key.HashKey()].Value.(*Builtin).Fn(args...)
+	}
+
+	if result, ok := call("parse", &String{Value: "1.5"}).(*Float); !ok || result.Inspect() != "1.5" {
+		t.Errorf("wrong result for value and nil error. got=%v", call("parse", &String{Value: "1.5"}))
+	}
+	if err, ok := call("parse", &String{Value: ""}).(*Error); !ok || err.Message != "empty input" {
+		t.Errorf("error not returned. got=%v", call("parse", &String{Value: ""}))
+	}
+	if result := call("split", &String{Value: "a=b"}); result.Inspect() != `["a", "b"]` {
+		t.Errorf("wrong result for several values. got=%s", result.Inspect())
+	}
+	if result := call("check", &String{Value: "a"}); result != NULL {
+		t.Errorf("wrong result for nil error alone. got=%v", result)
+	}
+}
+
+func TestRegisterFunctionsConversions(t *testing.T) {
+	type point struct {
+		X, Y   int
+		Label  string `json:"label"`
+		hidden bool
+	}
+	env := NewEnvironment()
+	hash := RegisterFunctions(env, "", map[string]interface{}{
+		"not":    func(b bool) bool { return !b },
+		"byte":   func(b uint8) uint8 { return b },
+		"int32":  func(i int32) int64 { return int64(i) * 2 },
+		"keys":   func(m map[string]int) int { return len(m) },
+		"counts": func(s []string) map[string]int { return map[string]int{s[0]: len(s)} },
+		"move":   func(p point) *point { p.X++; return &p },
+		"nil":    func(p *point) *point { return p },
+		"any":    func(v interface{}) string { return fmt.Sprintf("%T", v) },
+	})
+	call := func(name string, args ...Object) Object {
+		key := &String{Value: name}
+		return hash.Pairs[key.HashKey()].Value.(*Builtin).Fn(args...)
+	}
+	integer := func(i int64) *Integer { return &Integer{Value: big.NewInt(i)} }
+	str := func(s string) *String { return &String{Value: s} }
+	hashOf := func(kv ...Object) *Hash {
+		h := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i := 0; i < len(kv); i += 2 {
+			h.Pairs[kv[i].(Hashable).HashKey()] = HashPair{Key: kv[i], Value: kv[i+1]}
+		}
+		return h
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"not", []Object{TRUE}, "false"},
+		{"byte", []Object{integer(255)}, "255"},
+		{"byte", []Object{integer(256)}, "ERROR: argument 1: 256 is out of range for uint8"},
+		{"byte", []Object{integer(-1)}, "ERROR: argument 1: -1 is out of range for uint8"},
+		{"int32", []Object{integer(21)}, "42"},
+		{"keys", []Object{hashOf(str("a"), integer(1), str("b"), integer(2))}, "2"},
+		{"keys", []Object{hashOf(str("a"), str("b"))}, "ERROR: argument 1: value of a: cannot use STRING as int"},
+		{"counts", []Object{&Array{Elements: []Object{str("a"), str("b")}}}, `{"a": 2}`},
+		{"move", []Object{hashOf(str("x"), integer(1), str("label"), str("p"))}, `{"X": 2, "Y": 0, "label": p}`},
+		{"move", []Object{hashOf(str("z"), integer(1))}, "ERROR: argument 1: object.point has no field z"},
+		{"nil", []Object{NULL}, "null"},
+		{"any", []Object{integer(1)}, "int64"},
+		{"any", []Object{hashOf(str("a"), TRUE)}, "map[string]interface {}"},
+		{"any", []Object{NULL}, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		result := call(tt.name, tt.args...)
+		got := result.Inspect()
+		if h, ok := result.(*Hash); ok {
+			got = sortedHash(h)
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+type counter struct{ n int }
+
+func (c *counter) Add(by int) int        { c.n += by; return c.n }
+func (c *counter) ResetTo(n int)         { c.n = n }
+func (c counter) URLFor(p string) string { return "/" + p }
+
+func TestRegisterStruct(t *testing.T) {
+	env := NewEnvironment()
+	c := &counter{}
+	RegisterStruct(env, "Counter", c)
+
+	val, ok, _ := env.Get("Counter")
+	if !ok {
+		t.Fatalf("namespace not declared")
+	}
+	hash := val.(*Hash)
+	if len(hash.Pairs) != 3 {
+		t.Errorf("wrong number of methods. got=%d", len(hash.Pairs))
+	}
+	call := func(name string, args ...Object) Object {
+		pair, ok := hash.Pairs[(&String{Value: name}).HashKey()]
+		if !ok {
+			t.Fatalf("method %s not registered", name)
+		}
+		return pair.Value.(*Builtin).Fn(args...)
+	}
+
+	call("add", &Integer{Value: big.NewInt(2)})
+	if got := call("add", &Integer{Value: big.NewInt(3)}).Inspect(); got != "5" || c.n != 5 {
+		t.Errorf("methods do not share the value. got=%s, n=%d", got, c.n)
+	}
+	if got := call("resetTo", &Integer{Value: big.NewInt(0)}); got != NULL || c.n != 0 {
+		t.Errorf("wrong result of resetTo. got=%v, n=%d", got, c.n)
+	}
+	if got := call("urlFor", &String{Value: "a"}).Inspect(); got != "/a" {
+		t.Errorf("wrong result of urlFor. got=%s", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type server struct {
+		Host string `1y:"host"`
+		Port uint16 `json:"port,omitempty"`
+	}
+	type config struct {
+		Name    string
+		Debug   bool              `1y:"debug"`
+		Server  server            `1y:"server"`
+		Tags    []string          `1y:"tags"`
+		Limits  map[string]int    `1y:"limits"`
+		Secret  string            `1y:"-"`
+		Extra   *server           `1y:"extra"`
+		Headers map[string]string `1y:"headers"`
+	}
+	str := func(s string) *String { return &String{Value: s} }
+	integer := func(i int64) *Integer { return &Integer{Value: big.NewInt(i)} }
+	hashOf := func(kv ...Object) *Hash {
+		h := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i := 0; i < len(kv); i += 2 {
+			h.Pairs[kv[i].(Hashable).HashKey()] = HashPair{Key: kv[i], Value: kv[i+1]}
+		}
+		return h
+	}
+
+	cfg := config{Name: "default", Server: server{Host: "localhost", Port: 80}}
+	err := Decode(hashOf(
+		str("debug"), TRUE,
+		str("server"), hashOf(str("port"), integer(8080)),
+		str("tags"), &Array{Elements: []Object{str("a"), str("b")}},
+		str("limits"), hashOf(str("cpu"), integer(2)),
+		str("extra"), hashOf(str("host"), str("example.com")),
+		str("headers"), NULL,
+	), &cfg)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	got := fmt.Sprintf("%+v %+v", cfg, *cfg.Extra)
+	expected := "{Name:default Debug:true Server:{Host:localhost Port:8080} Tags:[a b] Limits:map[cpu:2] Secret: Extra:0x" // pointer varies
+	if !strings.HasPrefix(got, expected) || !strings.HasSuffix(got, "Headers:map[]} {Host:example.com Port:0}") {
+		t.Errorf("wrong result. got=%s", got)
+	}
+
+	failures := []struct {
+		obj      Object
+		expected string
+	}{
+		{hashOf(str("secret"), str("x")), "object.config has no field secret"},
+		{hashOf(str("server"), hashOf(str("port"), integer(70000))), "field server: field port: 70000 is out of range for uint16"},
+		{hashOf(integer(1), TRUE), "field names must be STRING, got INTEGER"},
+		{str("x"), "cannot use STRING as object.config"},
+	}
+	for _, tt := range failures {
+		err := Decode(tt.obj, &cfg)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.obj.Inspect(), tt.expected, err)
+		}
+	}
+	if err := Decode(TRUE, cfg); err == nil {
+		t.Errorf("no error for a target that is not a pointer")
+	}
+}
+
+func TestGoValues(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	obj := FromGoValue(map[string]interface{}{
+		"n":    int32(-3),
+		"big":  huge,
+		"f":    1.5,
+		"ok":   true,
+		"none": nil,
+		"list": []interface{}{uint8(1), "a", []int{2}},
+	})
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("FromGoValue did not return a hash. got=%s", obj.Inspect())
+	}
+	expected := `{"big": 123456789012345678901234567890, "f": 1.5, "list": [1, "a", [2]], "n": -3, "none": null, "ok": true}`
+	if got := sortedHash(hash); got != expected {
+		t.Errorf("FromGoValue wrong. expected=%s, got=%s", expected, got)
+	}
+
+	value, ok := ToGoValue(hash).(map[string]interface{})
+	if !ok {
+		t.Fatalf("ToGoValue did not return a map. got=%T", ToGoValue(hash))
+	}
+	if got := fmt.Sprintf("%#v", value["list"]); got != `[]interface {}{1, "a", []interface {}{2}}` {
+		t.Errorf("ToGoValue converted the list wrong. got=%s", got)
+	}
+	if value["n"] != int64(-3) || value["f"] != 1.5 || value["ok"] != true || value["none"] != nil {
+		t.Errorf("ToGoValue converted scalars wrong. got=%#v", value)
+	}
+	if n, ok := value["big"].(*big.Int); !ok || n.Cmp(huge) != 0 {
+		t.Errorf("ToGoValue converted a big integer wrong. got=%#v", value["big"])
+	}
+	if got := ToGoValue(FromGoValue(map[int]bool{1: true})); fmt.Sprint(got) != "map[1:true]" {
+		t.Errorf("hash with integer keys converted wrong. got=%#v", got)
+	}
+	if err, ok := FromGoValue(make(chan int)).(*Error); !ok {
+		t.Errorf("channel converted without an error. got=%v", err)
+	}
+}
+
+// sortedHash returns the pairs of h like Inspect does, sorted by key.
+func sortedHash(h *Hash) string {
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, fmt.Sprintf("%q: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func TestPretty(t *testing.T) {
+	a := &String{Value: "a"}
+	b := &String{Value: "b"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		b.HashKey(): {Key: b, Value: &Array{Elements: []Object{a, &Array{}}}},
+		a.HashKey(): {Key: a, Value: &Boolean{Value: true}},
+	}}
+
+	expected := `{
+  "a": true,
+  "b": [
+    "a",
+    []
+  ]
+}`
+
+	if got := Pretty(hash, 2); got != expected {
+		t.Errorf("wrong pretty output. expected=%q, got=%q", expected, got)
+	}
+
+	numbers := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, n := range []int64{10, 9, 100, -1} {
+		key := &Integer{Value: big.NewInt(n)}
+		numbers.Pairs[key.HashKey()] = HashPair{Key: key, Value: key}
+	}
+	if got := Pretty(numbers, 1); got != "{\n -1: -1,\n 9: 9,\n 10: 10,\n 100: 100\n}" {
+		t.Errorf("numeric keys not sorted by value. got=%q", got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	float := func(text string) *Float {
+		f, _, _ := big.ParseFloat(text, 10, 53, big.ToNearestEven)
+		return &Float{Value: f}
+	}
+	a := &String{Value: "a, b"}
+	b := &String{Value: "b"}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{float("0.1"), "0.1"},
+		{float("2"), "2.0"},
+		{float("-2"), "-2.0"},
+		{float("0"), "0.0"},
+		{float("120"), "120.0"},
+		{float("-2.5e-3"), "-0.0025"},
+		{float("123456789.5"), "123456789.5"},
+		{float("1e20"), "100000000000000000000.0"},
+		{float("1e21"), "1e+21"},
+		{float("1.5e-7"), "1.5e-7"},
+		{float("0.000001"), "0.000001"},
+		{a, "a, b"},
+		{&Array{Elements: []Object{a, b}}, `["a, b", "b"]`},
+		{&Hash{Pairs: map[HashKey]HashPair{
+			b.HashKey(): {Key: b, Value: &Array{Elements: []Object{float("1e30")}}},
+			a.HashKey(): {Key: a, Value: b},
+		}}, `{"a, b": "b", "b": [1e+30]}`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect output. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestConcat(t *testing.T) {
+	base := &String{Value: strings.Repeat("a", minBufferedConcat)}
+
+	b := Concat(base, "b")
+	c := Concat(base, "c")
+	bd := Concat(b, "d")
+	be := Concat(b, "e")
+	bdf := Concat(bd, "f")
+
+	tests := []struct {
+		got      *String
+		expected string
+	}{
+		{b, base.Value + "b"},
+		{c, base.Value + "c"},
+		{bd, base.Value + "bd"},
+		{be, base.Value + "be"},
+		{bdf, base.Value + "bdf"},
+		{Concat(&String{Value: "x"}, "y"), "xy"},
+	}
+
+	for i, tt := range tests {
+		if tt.got.Value != tt.expected {
+			t.Errorf("tests[%d] - wrong value. expected=%q, got=%q", i, tt.expected, tt.got.Value)
+		}
+	}
+
+	if bd.buf != b.buf || bdf.buf != b.buf {
+		t.Errorf("appending to the latest string should reuse its buffer")
+	}
+	if be.buf == b.buf {
+		t.Errorf("appending to an earlier string must not share the buffer")
+	}
+}
+
+func TestCharAt(t *testing.T) {
+	tests := []struct {
+		value    string
+		index    int
+		expected string
+		ok       bool
+	}{
+		{"abc", 0, "a", true},
+		{"abc", 2, "c", true},
+		{"abc", 3, "", false},
+		{"abc", -1, "", false},
+		{"", 0, "", false},
+		{"héllo", 1, "é", true},
+		{"héllo", 2, "l", true},
+		{"héllo", 4, "o", true},
+		{"héllo", 5, "", false},
+		{"你好世界", 3, "界", true},
+		{"a😀b", 1, "😀", true},
+		{"a😀b", 2, "b", true},
+		// invalid bytes count as one code point each and read as U+FFFD
+		{"a\xffb", 1, "\uFFFD", true},
+		{"a\xffb", 2, "b", true},
+		{"é\xffb", 1, "\uFFFD", true},
+	}
+
+	for _, tt := range tests {
+		got, ok := (&String{Value: tt.value}).CharAt(tt.index)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("%q.CharAt(%d) = %q, %t, want %q, %t", tt.value, tt.index, got, ok, tt.expected, tt.ok)
+		}
+	}
+
+	// The offsets are worked out once and only for strings that need them
+	ascii := &String{Value: "plain"}
+	ascii.CharAt(0)
+	if ascii.offsets.Load() != byteOffsets {
+		t.Errorf("an ASCII string should be indexed without offsets")
+	}
+	wide := &String{Value: "你好"}
+	wide.CharAt(0)
+	if offsets := *wide.offsets.Load(); len(offsets) != 2 || offsets[1] != 3 {
+		t.Errorf("wrong offsets for %q. got=%v", wide.Value, offsets)
+	}
+	if got, _ := wide.CharAt(1); got != "好" {
+		t.Errorf("second lookup returned %q", got)
+	}
+}
+
+func BenchmarkCharAt(b *testing.B) {
+	for _, value := range []string{strings.Repeat("a", 10000), strings.Repeat("é", 10000)} {
+		b.Run(fmt.Sprintf("%d bytes", len(value)), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s := &String{Value: value}
+				for j := 0; j < 10000; j++ {
+					s.CharAt(j)
+				}
+			}
+		})
+	}
+}
+
+func TestArrayCopyOnWrite(t *testing.T) {
+	ints := func(values ...int64) []Object {
+		elements := make([]Object, len(values))
+		for i, v := range values {
+			elements[i] = &Integer{Value: big.NewInt(v)}
+		}
+		return elements
+	}
+
+	base := &Array{Elements: ints(1, 2, 3)}
+	a := base.Extend(ints(4)) // copies, leaving room to grow
+	b := a.Extend(ints(5))    // appends in place
+	c := a.Extend(ints(6))    // a is no longer the longest array, so copies
+	view := b.Slice(1, 3)     // shares b's elements
+	grown := view.Extend(nil) // clipped capacity, so shares without room
+	b.Push(ints(7)...)        // b is the longest array, so appends in place
+	view.Set(0, ints(20)[0])  // copies before writing
+	a.Push(ints(8)...)        // would overwrite b's 5, so copies
+	base.Set(0, ints(10)[0])  // base was never shared
+	grown.Push(ints(9)...)    // copies
+
+	tests := []struct {
+		arr      *Array
+		expected string
+	}{
+		{base, "[10, 2, 3]"},
+		{a, "[1, 2, 3, 4, 8]"},
+		{b, "[1, 2, 3, 4, 5, 7]"},
+		{c, "[1, 2, 3, 4, 6]"},
+		{view, "[20, 3]"},
+		{grown, "[2, 3, 9]"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.arr.Inspect(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong elements. expected=%s, got=%s", i, tt.expected, got)
+		}
+	}
+
+	if &a.Elements[0] == &b.Elements[0] {
+		t.Errorf("a should have copied its elements before pushing")
+	}
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	globals := NewEnvironment()
+	globals.NewVar("counter", &Integer{Value: big.NewInt(0)})
+	globals.NewConst("limit", &Integer{Value: big.NewInt(10)})
+	scope := NewScopedEnvironment(globals, map[string]int{"local": 0})
+	scope.DeclareAt(0, "local", &Integer{Value: big.NewInt(0)}, false)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			env := NewEnclosedEnvironment(scope)
+			for i := 0; i < 200; i++ {
+				env.Set("counter", &Integer{Value: big.NewInt(int64(i))})
+				scope.SetAt(0, 0, &Integer{Value: big.NewInt(int64(i))})
+				env.NewVar(fmt.Sprintf("v%d", i), &Boolean{Value: true})
+				globals.Set(fmt.Sprintf("g%d_%d", g, i), &Boolean{Value: true})
+				if _, ok, _ := env.Get("counter"); !ok {
+					t.Errorf("counter not found")
+				}
+				if _, ok, _ := scope.GetAt(0, 0); !ok {
+					t.Errorf("local not found")
+				}
+				if _, ok := env.Set("limit", nil).(*Error); !ok {
+					t.Errorf("assigning to a constant should fail")
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(globals.Store()) != 2+8*200 {
+		t.Errorf("wrong number of globals. expected=%d, got=%d", 2+8*200, len(globals.Store()))
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	env := NewEnvironment()
+	big30, _ := new(big.Int).SetString("1000000000000000000000000000000", 10)
+	third := new(big.Float).SetPrec(200).Quo(big.NewFloat(1).SetPrec(200), big.NewFloat(3))
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Hashable{&String{Value: "a"}, &Integer{Value: big.NewInt(1)}} {
+		hash.Pairs[key.HashKey()] = HashPair{Key: key.(Object), Value: NULL}
+	}
+	env.NewVar("n", &Integer{Value: big30})
+	env.NewConst("third", &Float{Value: third})
+	env.NewVar("list", &Array{Elements: []Object{&String{Value: "x\ny"}, TRUE, hash}})
+	env.NewVar("f", &Builtin{})
+	cycle := &Array{}
+	cycle.Elements = []Object{cycle}
+	env.NewVar("cycle", cycle)
+
+	var buf strings.Builder
+	skipped, err := SaveEnvironment(&buf, env)
+	if err != nil {
+		t.Fatalf("SaveEnvironment failed: %v", err)
+	}
+	if fmt.Sprint(skipped) != "[cycle f]" {
+		t.Errorf("wrong variables skipped. got=%v", skipped)
+	}
+
+	restored := NewEnvironment()
+	restored.NewVar("n", TRUE)
+	if err := RestoreEnvironment(strings.NewReader(buf.String()), restored); err != nil {
+		t.Fatalf("RestoreEnvironment failed: %v", err)
+	}
+	for _, name := range []string{"n", "third", "list"} {
+		original, _, _ := env.Get(name)
+		value, ok, _ := restored.Get(name)
+		if !ok || !IsEqual(original, value) {
+			t.Errorf("%s restored wrong. expected=%s, got=%v", name, original.Inspect(), value)
+		}
+	}
+	if value, _, _ := restored.Get("third"); value.(*Float).Value.Prec() != 200 {
+		t.Errorf("float restored with the wrong precision")
+	}
+	if result := restored.Set("third", NULL); !isErrorObject(result) {
+		t.Errorf("expected the constant to stay constant")
+	}
+	if _, ok, _ := restored.Get("f"); ok {
+		t.Errorf("skipped variable was restored")
+	}
+
+	for _, bad := range []string{`{`, `{"version": 2}`, `{"version": 1, "variables": [{"name": "x", "value": {"type": "FUNCTION"}}]}`} {
+		if err := RestoreEnvironment(strings.NewReader(bad), NewEnvironment()); err == nil {
+			t.Errorf("expected an error restoring %s", bad)
+		}
+	}
+}
+
+func isErrorObject(obj Object) bool {
+	_, ok := obj.(*Error)
+	return ok
+}
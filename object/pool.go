@@ -0,0 +1,64 @@
+package object
+
+import (
+	"math/big"
+	"sync"
+)
+
+// Arithmetic produces many numbers that only live until the enclosing
+// expression has used them, such as a*b in a*b + c. The evaluator takes
+// those from these pools and hands them back once they have been consumed.
+
+var integerPool = sync.Pool{
+	New: func() interface{} { return &Integer{Value: new(big.Int)} },
+}
+
+var floatPool = sync.Pool{
+	New: func() interface{} { return &Float{Value: new(big.Float)} },
+}
+
+// AcquireInteger returns an Integer whose Value the caller sets before the
+// object is shared.
+func AcquireInteger() *Integer {
+	return integerPool.Get().(*Integer)
+}
+
+// ReleaseInteger returns i to the pool. Nothing may refer to i afterwards.
+func ReleaseInteger(i *Integer) {
+	i.hashKey = HashKey{}
+	integerPool.Put(i)
+}
+
+// AcquireFloat returns a Float whose Value has precision 0, so that the
+// first operation stored into it picks the precision of its operands.
+func AcquireFloat() *Float {
+	f := floatPool.Get().(*Float)
+	f.Value.SetPrec(0)
+	return f
+}
+
+// ReleaseFloat returns f to the pool. Nothing may refer to f afterwards.
+func ReleaseFloat(f *Float) {
+	f.hashKey = HashKey{}
+	floatPool.Put(f)
+}
+
+// AcquireFrame returns an environment for a call of f with a slot for each
+// of names, reusing the environment of an earlier call when one was
+// released.
+func (f *Function) AcquireFrame(names map[string]int) *Environment {
+	if env, ok := f.frames.Get().(*Environment); ok {
+		return env
+	}
+	return NewScopedEnvironment(f.Env, names)
+}
+
+// ReleaseFrame lets later calls of f reuse env once the call it was made for
+// has returned, unless a closure still refers to it.
+func (f *Function) ReleaseFrame(env *Environment) {
+	if env.Captured() {
+		return
+	}
+	env.Reset()
+	f.frames.Put(env)
+}
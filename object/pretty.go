@@ -0,0 +1,140 @@
+package object
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pretty renders obj across multiple lines, indenting nested arrays and hashes
+// by indent spaces per level. Hash keys are sorted so the output is stable and
+// strings inside containers are quoted.
+func Pretty(obj Object, indent int) string {
+	var out strings.Builder
+	writePretty(&out, obj, strings.Repeat(" ", indent), 0, false)
+	return out.String()
+}
+
+func writePretty(out *strings.Builder, obj Object, indent string, depth int, nested bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		if len(obj.Elements) == 0 {
+			out.WriteString("[]")
+			return
+		}
+
+		out.WriteString("[\n")
+		for i, el := range obj.Elements {
+			out.WriteString(strings.Repeat(indent, depth+1))
+			writePretty(out, el, indent, depth+1, true)
+			if i < len(obj.Elements)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Repeat(indent, depth))
+		out.WriteString("]")
+
+	case *Hash:
+		if len(obj.Pairs) == 0 {
+			out.WriteString("{}")
+			return
+		}
+
+		pairs := SortedPairs(obj)
+		out.WriteString("{\n")
+		for i, pair := range pairs {
+			out.WriteString(strings.Repeat(indent, depth+1))
+			writePretty(out, pair.Key, indent, depth+1, true)
+			out.WriteString(": ")
+			writePretty(out, pair.Value, indent, depth+1, true)
+			if i < len(pairs)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(strings.Repeat(indent, depth))
+		out.WriteString("}")
+
+	case *String:
+		if nested {
+			out.WriteString(strconv.Quote(obj.Value))
+		} else {
+			out.WriteString(obj.Value)
+		}
+
+	default:
+		out.WriteString(obj.Inspect())
+	}
+}
+
+// inspectElement renders an element of an array or hash on one line, quoting
+// strings so that ["a, b"] and ["a", "b"] can be told apart.
+func inspectElement(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
+
+// SortedPairs returns the pairs of a hash ordered by their keys: booleans,
+// then numbers by value, then strings, then any other keys by type and
+// inspected form.
+func SortedPairs(h *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+
+	return pairs
+}
+
+// keyLess reports whether hash key a sorts before b.
+func keyLess(a, b Object) bool {
+	if ra, rb := keyRank(a), keyRank(b); ra != rb {
+		return ra < rb
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *Integer, *Float:
+		if c := keyNumber(a).Cmp(keyNumber(b)); c != 0 {
+			return c < 0
+		}
+	case *String:
+		return a.Value < b.(*String).Value
+	}
+	// Equal numbers of different types, such as 1 and 1.0, and keys of
+	// other types
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	return a.Inspect() < b.Inspect()
+}
+
+// keyRank orders the kinds of hash keys.
+func keyRank(key Object) int {
+	switch key.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *Float:
+		return 1
+	case *String:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// keyNumber returns the value of an integer or float key.
+func keyNumber(key Object) *big.Float {
+	if i, ok := key.(*Integer); ok {
+		return new(big.Float).SetInt(i.Value)
+	}
+	return key.(*Float).Value
+}
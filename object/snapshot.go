@@ -0,0 +1,234 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+)
+
+// snapshotVersion is the version of the format written by SaveEnvironment.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version   int                `json:"version"`
+	Variables []snapshotVariable `json:"variables"`
+}
+
+type snapshotVariable struct {
+	Name  string        `json:"name"`
+	Const bool          `json:"const,omitempty"`
+	Value snapshotValue `json:"value"`
+}
+
+// snapshotValue is the portable form of a plain-data object. Integers and
+// floats are held as text, so they keep their full precision.
+type snapshotValue struct {
+	Type      ObjectType      `json:"type"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Precision uint            `json:"precision,omitempty"` // of a float
+	Elements  []snapshotValue `json:"elements,omitempty"`
+	Pairs     []snapshotPair  `json:"pairs,omitempty"`
+}
+
+type snapshotPair struct {
+	Key   snapshotValue `json:"key"`
+	Value snapshotValue `json:"value"`
+}
+
+// SaveEnvironment writes the variables declared in env, not in the
+// environments enclosing it, to w as JSON, for RestoreEnvironment to read
+// back, possibly in another process. Only plain data is written: integers,
+// floats, strings, booleans, null, and arrays and hashes of them. Variables
+// holding anything else, such as functions, are left out and their names
+// returned, sorted. Arrays and hashes held in several places are written,
+// and restored, as separate copies.
+func SaveEnvironment(w io.Writer, env *Environment) (skipped []string, err error) {
+	s := snapshot{Version: snapshotVersion, Variables: []snapshotVariable{}}
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	for name, v := range env.store {
+		value, ok := snapshotOf(v.Value, map[Object]bool{})
+		if !ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		s.Variables = append(s.Variables, snapshotVariable{Name: name, Const: v.ReadOnly, Value: value})
+	}
+	sort.Slice(s.Variables, func(i, j int) bool { return s.Variables[i].Name < s.Variables[j].Name })
+	sort.Strings(skipped)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return skipped, enc.Encode(s)
+}
+
+// snapshotOf returns the portable form of obj, or false if obj is not plain
+// data. open holds the arrays and hashes obj is inside of, as one that holds
+// itself cannot be written.
+func snapshotOf(obj Object, open map[Object]bool) (snapshotValue, bool) {
+	v := snapshotValue{Type: obj.Type()}
+	switch obj := obj.(type) {
+	case *Integer:
+		v.Value, _ = json.Marshal(obj.Value.String())
+	case *Float:
+		v.Value, _ = json.Marshal(obj.Value.Text('g', -1))
+		v.Precision = obj.Value.Prec()
+	case *String:
+		v.Value, _ = json.Marshal(obj.Value)
+	case *Boolean:
+		v.Value, _ = json.Marshal(obj.Value)
+	case *Null:
+	case *Array:
+		if open[obj] {
+			return v, false
+		}
+		open[obj] = true
+		defer delete(open, obj)
+		v.Elements = make([]snapshotValue, len(obj.Elements))
+		for i, el := range obj.Elements {
+			element, ok := snapshotOf(el, open)
+			if !ok {
+				return v, false
+			}
+			v.Elements[i] = element
+		}
+	case *Hash:
+		if open[obj] {
+			return v, false
+		}
+		open[obj] = true
+		defer delete(open, obj)
+		v.Pairs = make([]snapshotPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := snapshotOf(pair.Key, open)
+			if !ok {
+				return v, false
+			}
+			value, ok := snapshotOf(pair.Value, open)
+			if !ok {
+				return v, false
+			}
+			v.Pairs = append(v.Pairs, snapshotPair{key, value})
+		}
+		// Pairs are written in a stable order, so equal hashes are saved
+		// alike
+		sort.Slice(v.Pairs, func(i, j int) bool {
+			a, b := v.Pairs[i].Key, v.Pairs[j].Key
+			return a.Type < b.Type || a.Type == b.Type && string(a.Value) < string(b.Value)
+		})
+	default:
+		return v, false
+	}
+	return v, true
+}
+
+// RestoreEnvironment reads variables written by SaveEnvironment from r and
+// declares them in env, replacing any of the same names declared there.
+// Nothing is declared if r does not hold a valid snapshot.
+func RestoreEnvironment(r io.Reader, env *Environment) error {
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	values := make([]Object, len(s.Variables))
+	for i, v := range s.Variables {
+		if !isValidName(v.Name) {
+			return fmt.Errorf("invalid snapshot: invalid variable name %q", v.Name)
+		}
+		value, err := v.Value.object()
+		if err != nil {
+			return fmt.Errorf("invalid snapshot: %s: %v", v.Name, err)
+		}
+		values[i] = value
+	}
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	for i, v := range s.Variables {
+		if slot, ok := env.names[v.Name]; ok {
+			env.slots[slot] = EnvValue{Value: values[i], ReadOnly: v.Const}
+		} else {
+			env.store[v.Name] = EnvValue{Value: values[i], ReadOnly: v.Const}
+		}
+	}
+	return nil
+}
+
+// object returns the object v is the portable form of.
+func (v snapshotValue) object() (Object, error) {
+	switch v.Type {
+	case INTEGER_OBJ:
+		var text string
+		if err := json.Unmarshal(v.Value, &text); err != nil {
+			return nil, err
+		}
+		i, ok := new(big.Int).SetString(text, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", text)
+		}
+		return &Integer{Value: i}, nil
+	case FLOAT_OBJ:
+		var text string
+		if err := json.Unmarshal(v.Value, &text); err != nil {
+			return nil, err
+		}
+		f, _, err := big.ParseFloat(text, 10, v.Precision, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", text)
+		}
+		return &Float{Value: f}, nil
+	case STRING_OBJ:
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		return &String{Value: s}, nil
+	case BOOLEAN_OBJ:
+		var b bool
+		if err := json.Unmarshal(v.Value, &b); err != nil {
+			return nil, err
+		}
+		if b {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case NULL_OBJ:
+		return NULL, nil
+	case ARRAY_OBJ:
+		elements := make([]Object, len(v.Elements))
+		for i, el := range v.Elements {
+			element, err := el.object()
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case HASH_OBJ:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, len(v.Pairs))}
+		for _, pair := range v.Pairs {
+			key, err := pair.Key.object()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := AsHashable(key); !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := pair.Value.object()
+			if err != nil {
+				return nil, err
+			}
+			if !hash.Put(key, value) {
+				return nil, fmt.Errorf("hash key %s collides with another key", key.Inspect())
+			}
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("cannot restore a value of type %q", v.Type)
+}
@@ -0,0 +1,184 @@
+package optimizer
+
+import (
+	"1ylang/ast"
+	"1ylang/token"
+)
+
+// PruneBranches drops the arms of if expressions whose conditions are
+// literals and so can never be taken, and while loops whose condition is a
+// false literal. An if left with a single arm that always runs is replaced by
+// that arm's statements.
+type PruneBranches struct{}
+
+func (*PruneBranches) Name() string { return "prune-branches" }
+
+func (*PruneBranches) Run(program *ast.Program) {
+	r := &rewriter{
+		rewriteExpression: func(exp ast.Expression, inLoop bool) ast.Expression {
+			if ie, ok := exp.(*ast.IfExpression); ok {
+				pruneIf(ie)
+			}
+			return exp
+		},
+		rewriteStatements: inlineConstantBranches,
+	}
+	r.program(program)
+}
+
+type ifArm struct {
+	condition ast.Expression
+	body      *ast.BlockStatement
+}
+
+func pruneIf(ie *ast.IfExpression) {
+	arms := []ifArm{{ie.Condition, ie.Consequence}}
+	for _, elif := range ie.Elifs {
+		arms = append(arms, ifArm{elif.Condition, elif.Consequence})
+	}
+
+	var kept []ifArm
+	alternative := ie.Alternative
+	for _, arm := range arms {
+		truth, ok := literalTruth(arm.condition)
+		if !ok {
+			kept = append(kept, arm)
+			continue
+		}
+		if truth {
+			// Nothing after an arm that is always taken can run
+			alternative = arm.body
+			break
+		}
+	}
+
+	if len(kept) == 0 {
+		if alternative != nil {
+			ie.Condition = booleanLiteral(true)
+			ie.Consequence = alternative
+		} else {
+			ie.Condition = booleanLiteral(false)
+			ie.Consequence = &ast.BlockStatement{}
+		}
+		ie.Elifs = nil
+		ie.Alternative = nil
+		return
+	}
+
+	ie.Condition = kept[0].condition
+	ie.Consequence = kept[0].body
+	ie.Elifs = nil
+	for _, arm := range kept[1:] {
+		ie.Elifs = append(ie.Elifs, &ast.ElifExpression{Condition: arm.condition, Consequence: arm.body})
+	}
+	ie.Alternative = alternative
+}
+
+func inlineConstantBranches(stmts []ast.Statement, loopBody bool) []ast.Statement {
+	var result []ast.Statement
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+
+		if ws, ok := stmt.(*ast.WhileStatement); ok && !last {
+			if truth, ok := literalTruth(ws.Condition); ok && !truth {
+				continue
+			}
+		}
+
+		body, ok := constantBranch(stmt)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+
+		switch {
+		case len(body) == 0 && last:
+			// The if still produces the block's value
+			result = append(result, stmt)
+		case loopBody && containsLoopControl(body):
+			// A loop body stops at break and continue but an if block does not
+			result = append(result, stmt)
+		default:
+			result = append(result, body...)
+		}
+	}
+	return result
+}
+
+// constantBranch returns the statements that an if statement with a literal
+// condition always runs.
+func constantBranch(stmt ast.Statement) ([]ast.Statement, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok || len(ie.Elifs) > 0 {
+		return nil, false
+	}
+	truth, ok := literalTruth(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	body := ie.Alternative
+	if truth {
+		body = ie.Consequence
+	}
+	if body == nil {
+		return nil, true
+	}
+	return body.Statements, true
+}
+
+// literalTruth reports whether exp is a literal and, if so, whether it is truthy.
+func literalTruth(exp ast.Expression) (truth bool, ok bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// containsLoopControl reports whether a break or continue in stmts would act
+// on the enclosing loop.
+func containsLoopControl(stmts []ast.Statement) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			return true
+		case *ast.BlockStatement:
+			if containsLoopControl(stmt.Statements) {
+				return true
+			}
+		case *ast.ExpressionStatement:
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok && ifContainsLoopControl(ie) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ifContainsLoopControl(ie *ast.IfExpression) bool {
+	blocks := []*ast.BlockStatement{ie.Consequence, ie.Alternative}
+	for _, elif := range ie.Elifs {
+		blocks = append(blocks, elif.Consequence)
+	}
+	for _, block := range blocks {
+		if block != nil && containsLoopControl(block.Statements) {
+			return true
+		}
+	}
+	return false
+}
+
+func booleanLiteral(value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+}
@@ -0,0 +1,56 @@
+package optimizer
+
+import "1ylang/ast"
+
+// DeadCode removes statements that can never run or whose value is never
+// used: anything after a return, anything after a break or continue in a
+// loop body, and literals standing alone before the last statement of a block.
+type DeadCode struct{}
+
+func (*DeadCode) Name() string { return "dead-code" }
+
+func (*DeadCode) Run(program *ast.Program) {
+	r := &rewriter{rewriteStatements: removeDeadStatements}
+	r.program(program)
+}
+
+func removeDeadStatements(stmts []ast.Statement, loopBody bool) []ast.Statement {
+	kept := stmts[:0]
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+		if !last && isUnusedLiteral(stmt) {
+			continue
+		}
+
+		kept = append(kept, stmt)
+
+		// Blocks outside loop bodies keep running after break and continue,
+		// so only return ends every kind of block
+		switch stmt.(type) {
+		case *ast.ReturnStatement:
+			return kept
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			if loopBody {
+				return kept
+			}
+		}
+	}
+	return kept
+}
+
+func isUnusedLiteral(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	return isLiteral(es.Expression)
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
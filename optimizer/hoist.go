@@ -0,0 +1,83 @@
+package optimizer
+
+import (
+	"1ylang/ast"
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/token"
+	"math/big"
+)
+
+// HoistConstants computes expressions made only of literals inside loops once,
+// before the program runs, instead of on every iteration. Expressions that
+// would fail at run time are left for the evaluator to report.
+//
+// Because a loop body may never run, expressions are only hoisted while
+// they are cheap: they are computed by an interpreter of their own whose
+// limits bound the strings they build, so that optimizing never costs what
+// running the program would not.
+type HoistConstants struct{}
+
+// maxHoistedSize bounds the bytes of a string computed before the program
+// runs, and maxHoistedShift the bits an integer is shifted by.
+const (
+	maxHoistedSize  = 4096
+	maxHoistedShift = 1024
+)
+
+func (*HoistConstants) Name() string { return "hoist-constants" }
+
+func (*HoistConstants) Run(program *ast.Program) {
+	in := evaluator.New(evaluator.Options{
+		Sandbox: true,
+		Limits:  evaluator.Limits{Collection: maxHoistedSize},
+	})
+	r := &rewriter{rewriteExpression: func(exp ast.Expression, inLoop bool) ast.Expression {
+		if !inLoop || !isConstantExpression(exp) {
+			return exp
+		}
+		if literal := toLiteral(in.Eval(exp, in.NewEnvironment())); literal != nil {
+			return literal
+		}
+		return exp
+	}}
+	r.program(program)
+}
+
+// isConstantExpression reports whether exp is an operator applied only to
+// literals. Children have already been folded when this is called.
+func isConstantExpression(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		return exp.Operator != "++" && exp.Operator != "--" && isLiteral(exp.Right)
+	case *ast.InfixExpression:
+		if !isLiteral(exp.Left) || !isLiteral(exp.Right) {
+			return false
+		}
+		// The limits do not bound integers, which a shift can make huge
+		if exp.Operator == "<<" {
+			shift, ok := exp.Right.(*ast.IntegerLiteral)
+			return ok && shift.Value.Cmp(big.NewInt(maxHoistedShift)) <= 0
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func toLiteral(obj object.Object) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		value := new(big.Int).Set(obj.Value)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: value.String()}, Value: value}
+	case *object.Float:
+		value := new(big.Float).Copy(obj.Value)
+		return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: obj.Inspect()}, Value: value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value}, Value: obj.Value}
+	case *object.Boolean:
+		return booleanLiteral(obj.Value)
+	default:
+		return nil
+	}
+}
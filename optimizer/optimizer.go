@@ -0,0 +1,58 @@
+// Package optimizer rewrites parsed programs before they are evaluated. Each
+// optimization is a Pass; passes must not change what a program prints or
+// returns, only how much work the evaluator does to get there.
+package optimizer
+
+import "1ylang/ast"
+
+// Pass is a single AST rewrite.
+type Pass interface {
+	Name() string
+	Run(program *ast.Program)
+}
+
+// Optimizer runs a list of passes in order.
+type Optimizer struct {
+	passes []Pass
+}
+
+// New returns an optimizer running the given passes.
+func New(passes ...Pass) *Optimizer {
+	return &Optimizer{passes: passes}
+}
+
+// Default returns an optimizer with the built-in passes.
+func Default() *Optimizer {
+	return New(
+		&HoistConstants{},
+		&PruneBranches{},
+		&DeadCode{},
+	)
+}
+
+// Register appends a pass to run after the existing ones.
+func (o *Optimizer) Register(pass Pass) {
+	o.passes = append(o.passes, pass)
+}
+
+// Passes returns the names of the registered passes in the order they run.
+func (o *Optimizer) Passes() []string {
+	names := make([]string, len(o.passes))
+	for i, pass := range o.passes {
+		names[i] = pass.Name()
+	}
+	return names
+}
+
+// Optimize runs every pass over program, modifying it in place.
+func (o *Optimizer) Optimize(program *ast.Program) *ast.Program {
+	for _, pass := range o.passes {
+		pass.Run(program)
+	}
+	return program
+}
+
+// Optimize runs the default passes over program.
+func Optimize(program *ast.Program) *ast.Program {
+	return Default().Optimize(program)
+}
@@ -0,0 +1,133 @@
+package optimizer
+
+import (
+	"1ylang/ast"
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestPasses(t *testing.T) {
+	tests := []struct {
+		pass     Pass
+		input    string
+		expected string
+	}{
+		{&DeadCode{}, "return 1; puts(2);", "return 1;"},
+		{&DeadCode{}, "1; 2; let x = 3; x", "let x = 3; x"},
+		{&DeadCode{}, "while (true) { break; puts(1); }", "while (true) { break; }"},
+		{&DeadCode{}, "if (x) { break; puts(1); }", "if (x) { break; puts(1); }"},
+		{&PruneBranches{}, "if (false) { 1 } else { 2 }", "2"},
+		{&PruneBranches{}, "if (x) { 1 } elif (true) { 2 } else { 3 }", "if (x) { 1 } else { 2 }"},
+		{&PruneBranches{}, "if (false) { 1 } elif (x) { 2 }", "if (x) { 2 }"},
+		{&PruneBranches{}, "while (false) { puts(1); } 5", "5"},
+		{&PruneBranches{}, "while (x) { if (true) { break; } }", "while (x) { if (true) { break; } }"},
+		{&HoistConstants{}, "while (x) { y = y + 60 * 60; }", "while (x) { y = y + 3600; }"},
+		{&HoistConstants{}, "let y = 60 * 60;", "let y = 60 * 60;"},
+		{&HoistConstants{}, "while (x) { 1 / 0 }", "while (x) { 1 / 0 }"},
+		{&HoistConstants{}, `while (x) { "ab" * 3 }`, `while (x) { "ababab" }`},
+		{&HoistConstants{}, `while (x) { "x" * 100000000000 }`, `while (x) { "x" * 100000000000 }`},
+		{&HoistConstants{}, "while (x) { 1 << 4 }", "while (x) { 16 }"},
+		{&HoistConstants{}, "while (x) { 1 << 100000000000 }", "while (x) { 1 << 100000000000 }"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		New(tt.pass).Optimize(program)
+
+		expected := parse(t, tt.expected).String()
+		if program.String() != expected {
+			t.Errorf("%s on %q: expected=%q, got=%q", tt.pass.Name(), tt.input, expected, program.String())
+		}
+	}
+}
+
+func TestFunctionBodies(t *testing.T) {
+	// FunctionLiteral.String leaves out the body, so the body of the first
+	// function is compared instead of the program
+	tests := []struct {
+		pass     Pass
+		input    string
+		expected string
+	}{
+		{&DeadCode{}, "let f = fn() { return 1; puts(2); };", "return 1;"},
+		{&HoistConstants{}, "while (x) { let f = fn() { 1 + 2 }; }", "(1 + 2)"},
+		{&HoistConstants{}, "let f = fn() { while (x) { y = 60 * 60; } };", "while (x) { y = 3600; }"},
+		{nil, "while (x) { let f = fn() { return 1 + 2; puts(2); }; }", "return (1 + 2);"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if tt.pass != nil {
+			New(tt.pass).Optimize(program)
+		} else {
+			Optimize(program)
+		}
+
+		var body *ast.BlockStatement
+		ast.Inspect(program, func(node ast.Node) bool {
+			if fn, ok := node.(*ast.FunctionLiteral); ok && body == nil {
+				body = fn.Body
+			}
+			return body == nil
+		})
+		if body == nil {
+			t.Fatalf("%q: no function left", tt.input)
+		}
+		if body.String() != parse(t, tt.expected).String() {
+			t.Errorf("%q: function body expected=%q, got=%q", tt.input, parse(t, tt.expected).String(), body.String())
+		}
+	}
+}
+
+func TestOptimizedProgramsBehaveTheSame(t *testing.T) {
+	tests := []string{
+		"let f = fn(n) { if (n > 3) { return n * 2; 0 } else { n } }; f(5) + f(2)",
+		"let total = 0; for (let i = 0; i < 4; i++) { if (true) { total += 2 * 3; } } total",
+		"let x = if (false) { 1 }; x",
+		"let x = if (1 > 2) { 1 } elif (false) { 2 } else { 3 }; x",
+		"let n = 0; while (n < 5) { n++; if (n == 2 + 1) { break; } } n",
+		`let n = 0; while (n > 0) { let s = "x" * 100000000000; } "done"`,
+	}
+
+	for _, input := range tests {
+		plain := evaluator.Eval(parse(t, input), evaluator.NewEnvironment())
+		optimized := evaluator.Eval(Optimize(parse(t, input)), evaluator.NewEnvironment())
+
+		if plain.Inspect() != optimized.Inspect() {
+			t.Errorf("optimizing %q changed the result. expected=%s, got=%s", input, plain.Inspect(), optimized.Inspect())
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	o := Default()
+	o.Register(&DeadCode{})
+
+	names := o.Passes()
+	if len(names) != 4 || names[3] != "dead-code" {
+		t.Errorf("registered pass missing. got=%v", names)
+	}
+}
+
+func TestHoistingLeavesDefaultInterpreterAlone(t *testing.T) {
+	evaluator.SetLimits(evaluator.Limits{Steps: 1000})
+	defer evaluator.SetLimits(evaluator.Limits{})
+
+	New(&HoistConstants{}).Optimize(parse(t, "while (x) { y = 60 * 60 + 1; }"))
+
+	if usage := evaluator.CurrentUsage(); usage.Steps != 0 || usage.Objects != 0 {
+		t.Errorf("hoisting counted against the default interpreter. got=%+v", usage)
+	}
+}
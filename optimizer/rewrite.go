@@ -0,0 +1,135 @@
+package optimizer
+
+import "1ylang/ast"
+
+// rewriter walks a program bottom-up, letting a pass replace expressions and
+// the statement lists of blocks once their children have been rewritten.
+type rewriter struct {
+	// rewriteExpression may return a replacement for exp. inLoop reports
+	// whether exp runs on every iteration of an enclosing loop.
+	rewriteExpression func(exp ast.Expression, inLoop bool) ast.Expression
+
+	// rewriteStatements may return a replacement for the statements of a
+	// block. loopBody is true for the body of a for or while loop.
+	rewriteStatements func(stmts []ast.Statement, loopBody bool) []ast.Statement
+
+	inLoop bool
+}
+
+func (r *rewriter) program(program *ast.Program) {
+	program.Statements = r.list(program.Statements, false)
+}
+
+func (r *rewriter) list(stmts []ast.Statement, loopBody bool) []ast.Statement {
+	for i, stmt := range stmts {
+		stmts[i] = r.statement(stmt)
+	}
+	if r.rewriteStatements != nil {
+		stmts = r.rewriteStatements(stmts, loopBody)
+	}
+	return stmts
+}
+
+func (r *rewriter) block(block *ast.BlockStatement, loopBody bool) {
+	if block != nil {
+		block.Statements = r.list(block.Statements, loopBody)
+	}
+}
+
+func (r *rewriter) loop(fn func()) {
+	outer := r.inLoop
+	r.inLoop = true
+	fn()
+	r.inLoop = outer
+}
+
+func (r *rewriter) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = r.expression(stmt.Value)
+	case *ast.ConstStatement:
+		stmt.Value = r.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = r.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt, false)
+	case *ast.WhileStatement:
+		r.loop(func() {
+			stmt.Condition = r.expression(stmt.Condition)
+			r.block(stmt.Body, true)
+		})
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			stmt.Init = r.statement(stmt.Init)
+		}
+		r.loop(func() {
+			stmt.Condition = r.expression(stmt.Condition)
+			if stmt.Post != nil {
+				stmt.Post = r.statement(stmt.Post)
+			}
+			r.block(stmt.Body, true)
+		})
+	}
+	return stmt
+}
+
+func (r *rewriter) expression(exp ast.Expression) ast.Expression {
+	switch e := exp.(type) {
+	case nil:
+		return nil
+	case *ast.PrefixExpression:
+		e.Right = r.expression(e.Right)
+	case *ast.InfixExpression:
+		e.Left = r.expression(e.Left)
+		e.Right = r.expression(e.Right)
+	case *ast.PostfixExpression:
+		e.Left = r.expression(e.Left)
+	case *ast.IfExpression:
+		e.Condition = r.expression(e.Condition)
+		r.block(e.Consequence, false)
+		for _, elif := range e.Elifs {
+			elif.Condition = r.expression(elif.Condition)
+			r.block(elif.Consequence, false)
+		}
+		r.block(e.Alternative, false)
+	case *ast.FunctionLiteral:
+		// A function body runs once per call, not once per iteration
+		outer := r.inLoop
+		r.inLoop = false
+		r.block(e.Body, false)
+		r.inLoop = outer
+	case *ast.CallExpression:
+		e.Function = r.expression(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = r.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = r.expression(el)
+		}
+	case *ast.IndexExpression:
+		e.Left = r.expression(e.Left)
+		e.Index = r.expression(e.Index)
+	case *ast.MultiDimensionalIndex:
+		for i, idx := range e.Indices {
+			e.Indices[i] = r.expression(idx)
+		}
+	case *ast.Assignment:
+		e.Value = r.expression(e.Value)
+	case *ast.HashLiteral:
+		for key, value := range e.Pairs {
+			e.Pairs[key] = r.expression(value)
+		}
+	case *ast.DotExpression:
+		e.Left = r.expression(e.Left)
+	case *ast.ImportExpression:
+		e.Path = r.expression(e.Path)
+	}
+
+	if r.rewriteExpression != nil {
+		return r.rewriteExpression(exp, r.inLoop)
+	}
+	return exp
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"1ylang/ast"
+	"1ylang/lexer"
+)
+
+// Incremental parses successive versions of a source file, as an editor or
+// REPL sees it while it is being typed. Top-level statements that lie
+// before the first changed byte are taken from the previous parse instead
+// of being lexed and parsed again.
+//
+// Programs returned by Parse share statement nodes with earlier ones, so
+// they must not be modified, for example by the optimizer.
+type Incremental struct {
+	src        string
+	statements []parsedStatement
+	reused     int
+}
+
+// parsedStatement is a top-level statement with the position of its source.
+type parsedStatement struct {
+	stmt   ast.Statement
+	errors []string
+
+	start int // offset of the statement's first token
+	line  int // line of the statement's first token
+
+	// next is the offset just past the token after the statement. Parsing
+	// the statement looked at that token, so the statement can only be
+	// reused if it and the character after it are unchanged.
+	next int
+}
+
+func NewIncremental() *Incremental {
+	return &Incremental{}
+}
+
+// Parse parses src, reusing what it can from the previous call, and returns
+// the program and its parse errors.
+func (inc *Incremental) Parse(src string) (*ast.Program, []string) {
+	prefix := commonPrefix(inc.src, src)
+
+	// The last statement is followed by the end of the input, which an edit
+	// always moves, so it is never kept
+	keep := 0
+	for keep < len(inc.statements)-1 && inc.statements[keep].next < prefix {
+		keep++
+	}
+	statements := inc.statements[:keep:keep]
+
+	offset, line := 0, 1
+	if keep > 0 {
+		// The statement after the last one kept starts at the token that
+		// followed it, which is unchanged
+		offset, line = inc.statements[keep].start, inc.statements[keep].line
+	}
+
+	p := New(lexer.NewAt(src, offset, line))
+	for {
+		start, line := p.curStart, p.curToken.Line
+		before := len(p.errors)
+
+		stmt, ok := p.NextStatement()
+		if !ok {
+			break
+		}
+		statements = append(statements, parsedStatement{
+			stmt:   stmt,
+			errors: p.errors[before:len(p.errors):len(p.errors)],
+			start:  start,
+			line:   line,
+			next:   p.curEnd,
+		})
+	}
+
+	inc.src = src
+	inc.statements = statements
+	inc.reused = keep
+
+	program := ast.NewProgram(p.arena)
+	program.Statements = make([]ast.Statement, len(statements))
+	errors := []string{}
+	for i, s := range statements {
+		program.Statements[i] = s.stmt
+		errors = append(errors, s.errors...)
+	}
+
+	return program, errors
+}
+
+// Reused returns the number of statements the last Parse took from the one
+// before it.
+func (inc *Incremental) Reused() int {
+	return inc.reused
+}
+
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
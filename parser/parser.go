@@ -3,10 +3,12 @@ package parser
 import (
 	"1ylang/ast"
 	"1ylang/lexer"
+	"1ylang/locale"
 	"1ylang/token"
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 )
 
 type Parser struct {
@@ -17,101 +19,195 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	diagnostics []Diagnostic // errors with their positions
+
+	// Offsets of the current and next tokens in the input
+	curStart, curEnd   int
+	peekStart, peekEnd int
+
+	arena *ast.Arena // allocates the nodes of the parsed program
+
+	// lexTime is the time spent reading tokens since the last Reset, if
+	// timeLexing is set
+	timeLexing bool
+	lexTime    time.Duration
+
+	readErrorReported bool
+
+	// pending holds the declarations after the first of a let statement
+	// declaring several names, which are returned as statements of their own
+	pending []ast.Statement
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{
-		l:      l,
-		errors: []string{},
-	}
+	p := &Parser{arena: ast.NewArena()}
+	p.Reset(l)
+	return p
+}
+
+// Reset prepares p to parse the input of l, as if p had just been made by
+// New. A REPL can keep one parser and reset it for every line.
+func (p *Parser) Reset(l *lexer.Lexer) {
+	p.l = l
+	p.errors = []string{}
+	p.diagnostics = nil
+	p.readErrorReported = false
+	p.lexTime = 0
+	p.pending = nil
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
+}
 
-	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
-	p.registerPrefix(token.IDENT, p.parseIdentifier)
-	p.registerPrefix(token.INT, p.parseIntegerLiteral)
-	p.registerPrefix(token.BANG, p.parsePrefixExpression)
-	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
-	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
-	p.registerPrefix(token.TRUE, p.parseBoolean)
-	p.registerPrefix(token.FALSE, p.parseBoolean)
-	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
-	p.registerPrefix(token.IF, p.parseIfExpression)
-	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
-	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
-	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
-	p.registerPrefix(token.STRING, p.parseStringLiteral)
-	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
-	p.registerPrefix(token.INCREMENT, p.parsePrefixExpression)
-	p.registerPrefix(token.DECREMENT, p.parsePrefixExpression)
-	p.registerPrefix(token.IMPORT, p.parseImportExpression)
-	p.registerPrefix(token.DOT, p.parseQuickFloatLiteral)
-
-	p.infixParseFns = make(map[token.TokenType]infixParseFn)
-	p.registerInfix(token.PLUS, p.parseInfixExpression)
-	p.registerInfix(token.MINUS, p.parseInfixExpression)
-	p.registerInfix(token.SLASH, p.parseInfixExpression)
-	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
-	p.registerInfix(token.EQ, p.parseInfixExpression)
-	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
-	p.registerInfix(token.LT, p.parseInfixExpression)
-	p.registerInfix(token.GT, p.parseInfixExpression)
-	p.registerInfix(token.MODULUS, p.parseInfixExpression)
-	p.registerInfix(token.POW, p.parseInfixExpression)
-	p.registerInfix(token.LE, p.parseInfixExpression)
-	p.registerInfix(token.GE, p.parseInfixExpression)
-	p.registerInfix(token.AND, p.parseInfixExpression)
-	p.registerInfix(token.OR, p.parseInfixExpression)
-	p.registerInfix(token.XOR, p.parseInfixExpression)
-	p.registerInfix(token.SHR, p.parseInfixExpression)
-	p.registerInfix(token.SHL, p.parseInfixExpression)
-	p.registerInfix(token.LPAREN, p.parseCallExpression)
-	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
-	p.registerInfix(token.INCREMENT, p.parsePostfixExpression)
-	p.registerInfix(token.DECREMENT, p.parsePostfixExpression)
-	p.registerInfix(token.PLUS_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.MINUS_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.ASTERISK_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.SLASH_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.MODULUS_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.AND_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.OR_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.XOR_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.SHL_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.SHR_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.POW_ASSIGN, p.parseInfixExpression)
-	p.registerInfix(token.AND_AND, p.parseInfixExpression)
-	p.registerInfix(token.OR_OR, p.parseInfixExpression)
-	p.registerInfix(token.DOT, p.parseDotExpression)
+// The parse functions for each token type are the same for every parser.
+// They are filled in by init, since they refer back to parseExpression.
+var (
+	prefixParseFns map[token.TokenType]prefixParseFn
+	infixParseFns  map[token.TokenType]infixParseFn
+)
 
-	return p
+func init() {
+	prefixParseFns = map[token.TokenType]prefixParseFn{
+		token.IDENT:     (*Parser).parseIdentifier,
+		token.INT:       (*Parser).parseIntegerLiteral,
+		token.BANG:      (*Parser).parsePrefixExpression,
+		token.MINUS:     (*Parser).parsePrefixExpression,
+		token.TILDE:     (*Parser).parsePrefixExpression,
+		token.TRUE:      (*Parser).parseBoolean,
+		token.FALSE:     (*Parser).parseBoolean,
+		token.LPAREN:    (*Parser).parseGroupedExpression,
+		token.IF:        (*Parser).parseIfExpression,
+		token.FUNCTION:  (*Parser).parseFunctionLiteral,
+		token.LBRACE:    (*Parser).parseHashLiteral,
+		token.FLOAT:     (*Parser).parseFloatLiteral,
+		token.STRING:    (*Parser).parseStringLiteral,
+		token.LBRACKET:  (*Parser).parseArrayLiteral,
+		token.INCREMENT: (*Parser).parsePrefixExpression,
+		token.DECREMENT: (*Parser).parsePrefixExpression,
+		token.IMPORT:    (*Parser).parseImportExpression,
+		token.DOT:       (*Parser).parseQuickFloatLiteral,
+	}
+
+	infixParseFns = map[token.TokenType]infixParseFn{
+		token.PLUS:            (*Parser).parseInfixExpression,
+		token.MINUS:           (*Parser).parseInfixExpression,
+		token.SLASH:           (*Parser).parseInfixExpression,
+		token.ASTERISK:        (*Parser).parseInfixExpression,
+		token.EQ:              (*Parser).parseInfixExpression,
+		token.NOT_EQ:          (*Parser).parseInfixExpression,
+		token.LT:              (*Parser).parseInfixExpression,
+		token.GT:              (*Parser).parseInfixExpression,
+		token.MODULUS:         (*Parser).parseInfixExpression,
+		token.POW:             (*Parser).parseInfixExpression,
+		token.LE:              (*Parser).parseInfixExpression,
+		token.GE:              (*Parser).parseInfixExpression,
+		token.AND:             (*Parser).parseInfixExpression,
+		token.OR:              (*Parser).parseInfixExpression,
+		token.XOR:             (*Parser).parseInfixExpression,
+		token.SHR:             (*Parser).parseInfixExpression,
+		token.SHL:             (*Parser).parseInfixExpression,
+		token.LPAREN:          (*Parser).parseCallExpression,
+		token.LBRACKET:        (*Parser).parseIndexExpression,
+		token.INCREMENT:       (*Parser).parsePostfixExpression,
+		token.DECREMENT:       (*Parser).parsePostfixExpression,
+		token.PLUS_ASSIGN:     (*Parser).parseInfixExpression,
+		token.MINUS_ASSIGN:    (*Parser).parseInfixExpression,
+		token.ASTERISK_ASSIGN: (*Parser).parseInfixExpression,
+		token.SLASH_ASSIGN:    (*Parser).parseInfixExpression,
+		token.MODULUS_ASSIGN:  (*Parser).parseInfixExpression,
+		token.AND_ASSIGN:      (*Parser).parseInfixExpression,
+		token.OR_ASSIGN:       (*Parser).parseInfixExpression,
+		token.XOR_ASSIGN:      (*Parser).parseInfixExpression,
+		token.SHL_ASSIGN:      (*Parser).parseInfixExpression,
+		token.SHR_ASSIGN:      (*Parser).parseInfixExpression,
+		token.POW_ASSIGN:      (*Parser).parseInfixExpression,
+		token.AND_AND:         (*Parser).parseInfixExpression,
+		token.OR_OR:           (*Parser).parseInfixExpression,
+		token.DOT:             (*Parser).parseDotExpression,
+	}
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.curStart, p.curEnd = p.peekStart, p.peekEnd
+	if p.timeLexing {
+		start := time.Now()
+		p.peekToken = p.l.NextToken()
+		p.lexTime += time.Since(start)
+	} else {
+		p.peekToken = p.l.NextToken()
+	}
+	p.peekStart, p.peekEnd = p.l.Span()
+}
+
+// TimeLexing turns on timing the lexer, for LexingTime to report.
+func (p *Parser) TimeLexing() {
+	p.timeLexing = true
+}
+
+// LexingTime returns the time spent reading tokens from the lexer since the
+// last Reset, which is part of the time spent parsing.
+func (p *Parser) LexingTime() time.Duration {
+	return p.lexTime
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{}
+	program := ast.NewProgram(p.arena)
 	program.Statements = []ast.Statement{}
 
-	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+	for {
+		stmt, ok := p.NextStatement()
+		if !ok {
+			break
+		}
 		// if stmt != nil {
 		// 	program.Statements = append(program.Statements, stmt)
 		// }
 		program.Statements = append(program.Statements, stmt)
-		p.nextToken()
 	}
 
 	return program
 }
 
+// Parse parses src as a whole program and returns it with its parse errors.
+// It accepts arbitrary bytes and never panics: a failure inside the parser
+// is reported as an error, so src can come from an untrusted source.
+func Parse(src []byte) (program *ast.Program, errors []string) {
+	p := New(lexer.New(string(src)))
+	defer func() {
+		if r := recover(); r != nil {
+			program = nil
+			errors = append(p.errors, fmt.Sprintf("internal parser error: %v", r))
+		}
+	}()
+
+	program = p.ParseProgram()
+	return program, p.errors
+}
+
+// NextStatement parses the next top-level statement, reporting false once the
+// input is exhausted. With a lexer from lexer.NewReader it lets callers handle
+// a program one statement at a time without holding all of it in memory.
+func (p *Parser) NextStatement() (ast.Statement, bool) {
+	if len(p.pending) > 0 {
+		stmt := p.pending[0]
+		p.pending = p.pending[1:]
+		return stmt, true
+	}
+	if p.curTokenIs(token.EOF) {
+		if err := p.l.Err(); err != nil && !p.readErrorReported {
+			p.readErrorReported = true
+			p.curError("error reading input: %v", err)
+		}
+		return nil, false
+	}
+
+	stmt := p.parseStatement()
+	p.nextToken()
+	return stmt, true
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
@@ -133,28 +229,60 @@ func (p *Parser) parseStatement() ast.Statement {
 	}
 }
 
+// parseLetStatement parses `let x = 1;`, `let x;`, which declares x as
+// null, and `let a = 1, b = 2;`, which is read as `let a = 1; let b = 2;`.
+// The statements for the names after the first are left in p.pending.
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	letToken := p.curToken
+	var first *ast.LetStatement
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	for {
+		stmt := p.arena.NewLetStatement()
+		stmt.Token = letToken
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.IDENT) {
+			p.pending = nil
+			return nil
+		}
 
-	if !p.expectPeek(token.ASSIGN) {
-		return nil
-	}
+		stmt.Name = p.newIdentifier()
 
-	p.nextToken()
+		switch {
+		case p.peekTokenIs(token.ASSIGN):
+			p.nextToken()
+			p.nextToken()
+			stmt.Value = p.parseExpression(LOWEST)
+		case !p.peekTokenIs(token.COMMA) && !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.RBRACE) &&
+			!p.peekTokenIs(token.EOF) && p.peekToken.Line == p.curToken.Line:
+			// Without a value, the declaration must end there
+			p.peekError(token.ASSIGN)
+			p.pending = nil
+			return nil
+		}
 
-	stmt.Value = p.parseExpression(LOWEST)
+		if first == nil {
+			first = stmt
+		} else {
+			p.pending = append(p.pending, stmt)
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
-	return stmt
+	return first
+}
+
+// takePending returns the statements left in p.pending and empties it.
+func (p *Parser) takePending() []ast.Statement {
+	stmts := p.pending
+	p.pending = nil
+	return stmts
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -179,9 +307,37 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+// Diagnostic is a parse error together with where it was found: the token
+// the parser was looking at, which can be pointed out in the source.
+type Diagnostic struct {
+	Message    string
+	Code       string // ID of the message in the locale catalog, "" if not in it
+	Line       int    // 1-based line of the token, 0 if unknown
+	Column     int    // 1-based byte offset of the token in its line
+	Start, End int    // offsets of the token in the input
+}
+
+// Diagnostics returns the errors found, in the same order as Errors, with
+// their positions.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// errorAt reports an error at tok, in the language set with the locale
+// package.
+func (p *Parser) errorAt(tok token.Token, start, end int, format string, a ...interface{}) {
+	code, msg := locale.Sprintf(format, a...)
 	p.errors = append(p.errors, msg)
+	p.diagnostics = append(p.diagnostics, Diagnostic{Message: msg, Code: code, Line: tok.Line, Column: tok.Column, Start: start, End: end})
+}
+
+// curError reports an error at the current token.
+func (p *Parser) curError(format string, a ...interface{}) {
+	p.errorAt(p.curToken, p.curStart, p.curEnd, format, a...)
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	p.errorAt(p.peekToken, p.peekStart, p.peekEnd, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
@@ -199,20 +355,13 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
+	prefixParseFn func(*Parser) ast.Expression
+	infixParseFn  func(*Parser, ast.Expression) ast.Expression
 )
 
-func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
-	p.prefixParseFns[tokenType] = fn
-}
-
-func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
-	p.infixParseFns[tokenType] = fn
-}
-
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt := p.arena.NewExpressionStatement()
+	stmt.Token = p.curToken
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
@@ -247,37 +396,45 @@ const (
 )
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	prefix := p.prefixParseFns[p.curToken.Type]
+	prefix := prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
-	leftExp := prefix()
+	leftExp := prefix(p)
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
-		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+		if p.peekTokenIs(token.ASSIGN) && (p.curTokenIs(token.IDENT) || isIndexExpression(leftExp)) {
 			return p.parseAssignmentExpression(leftExp)
 		}
 
-		infix := p.infixParseFns[p.peekToken.Type]
+		infix := infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
 		}
 
 		p.nextToken()
 
-		leftExp = infix(leftExp)
+		leftExp = infix(p, leftExp)
 	}
 
 	return leftExp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return p.newIdentifier()
+}
+
+func (p *Parser) newIdentifier() *ast.Identifier {
+	ident := p.arena.NewIdentifier()
+	ident.Token = p.curToken
+	ident.Value = p.curToken.Literal
+	return ident
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	lit := &ast.IntegerLiteral{Token: p.curToken}
+	lit := p.arena.NewIntegerLiteral()
+	lit.Token = p.curToken
 
 	// Try to parse as integer first
 	value := new(big.Int)
@@ -293,8 +450,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 		return &ast.FloatLiteral{Token: p.curToken, Value: floatValue}
 	}
 
-	msg := fmt.Sprintf("could not parse %q as integer or float", p.curToken.Literal)
-	p.errors = append(p.errors, msg)
+	p.curError("could not parse %q as integer or float", p.curToken.Literal)
 	return nil
 }
 
@@ -303,8 +459,7 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 
 	value, ok := new(big.Float).SetString(p.curToken.Literal)
 	if !ok {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.curError("could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -312,15 +467,13 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	if strings.ContainsAny(p.curToken.Literal, "eE") {
 		parts := strings.Split(strings.ToLower(p.curToken.Literal), "e")
 		if len(parts) != 2 {
-			msg := fmt.Sprintf("invalid scientific notation: %q", p.curToken.Literal)
-			p.errors = append(p.errors, msg)
+			p.curError("invalid scientific notation: %q", p.curToken.Literal)
 			return nil
 		}
 
 		exponent := new(big.Int)
 		if _, ok := exponent.SetString(parts[1], 10); !ok {
-			msg := fmt.Sprintf("invalid exponent in scientific notation: %q", parts[1])
-			p.errors = append(p.errors, msg)
+			p.curError("invalid exponent in scientific notation: %q", parts[1])
 			return nil
 		}
 	}
@@ -330,15 +483,13 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.curError("no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	expression := &ast.PrefixExpression{
-		Token:    p.curToken,
-		Operator: p.curToken.Literal,
-	}
+	expression := p.arena.NewPrefixExpression()
+	expression.Token = p.curToken
+	expression.Operator = p.curToken.Literal
 
 	p.nextToken()
 
@@ -387,6 +538,15 @@ var precedences = map[token.TokenType]int{
 	token.IMPORT:          IMPORT,
 }
 
+// Precedence returns how tightly the infix or postfix operator of type t
+// binds its operands, or LOWEST if t is not an operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -404,28 +564,23 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression{
-		Token:    p.curToken,
-		Operator: p.curToken.Literal,
-		Left:     left,
-	}
+	expression := p.arena.NewInfixExpression()
+	expression.Token = p.curToken
+	expression.Operator = p.curToken.Literal
+	expression.Left = left
 
 	precedence := p.curPrecedence()
 	p.nextToken()
 
 	if isCompoundAssignmentOperator(expression.Operator) {
 		// If it's a compound assignment, treat it as an assignment expression
-		right := p.parseExpression(precedence)
-		return &ast.Assignment{
-			Token: expression.Token,
-			Name:  expression.Left,
-			Value: &ast.InfixExpression{
-				Token:    expression.Token,
-				Operator: expression.Operator[:len(expression.Operator)-1], // Remove the '='
-				Left:     expression.Left,
-				Right:    right,
-			},
-		}
+		assignment := p.arena.NewAssignment()
+		assignment.Token = expression.Token
+		assignment.Name = expression.Left
+		expression.Operator = expression.Operator[:len(expression.Operator)-1] // Remove the '='
+		expression.Right = p.parseExpression(precedence)
+		assignment.Value = expression
+		return assignment
 	} else {
 		expression.Right = p.parseExpression(precedence)
 	}
@@ -516,7 +671,8 @@ func (p *Parser) parseIfExpression() ast.Expression {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.curToken}
+	block := p.arena.NewBlockStatement()
+	block.Token = p.curToken
 	block.Statements = []ast.Statement{}
 
 	p.nextToken()
@@ -527,8 +683,10 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		// 	block.Statements = append(block.Statements, stmt)
 		// }
 		block.Statements = append(block.Statements, stmt)
+		block.Statements = append(block.Statements, p.takePending()...)
 		p.nextToken()
 	}
+	block.EndLine = p.curToken.Line
 
 	return block
 }
@@ -561,13 +719,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := p.newIdentifier()
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident := p.newIdentifier()
 		identifiers = append(identifiers, ident)
 	}
 
@@ -579,13 +737,18 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp := p.arena.NewCallExpression()
+	exp.Token = p.curToken
+	exp.Function = function
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	lit := p.arena.NewStringLiteral()
+	lit.Token = p.curToken
+	lit.Value = p.curToken.Literal
+	return lit
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
@@ -641,11 +804,17 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// isIndexExpression reports whether exp is an element access such as a[i]
+// or m[i, j], which can be assigned to.
+func isIndexExpression(exp ast.Expression) bool {
+	_, ok := exp.(*ast.IndexExpression)
+	return ok
+}
+
 func (p *Parser) parseAssignmentExpression(name ast.Expression) ast.Expression {
-	expression := &ast.Assignment{
-		Token: p.curToken,
-		Name:  name,
-	}
+	expression := p.arena.NewAssignment()
+	expression.Token = p.curToken
+	expression.Name = name
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -678,6 +847,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -698,7 +868,7 @@ func (p *Parser) parseConstStatement() *ast.ConstStatement {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Name = p.newIdentifier()
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -769,7 +939,7 @@ func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
 
 	if !p.curTokenIs(token.IDENT) {
-		p.errors = append(p.errors, fmt.Sprintf("expected property name to be identifier, got %s instead", p.curToken.Type))
+		p.curError("expected property name to be identifier, got %s instead", p.curToken.Type)
 		return nil
 	}
 
@@ -800,7 +970,7 @@ func (p *Parser) parseQuickFloatLiteral() ast.Expression {
 	p.nextToken() // consume the '.'
 	val, ok := new(big.Float).SetString("0." + p.curToken.Literal)
 	if !ok {
-		p.errors = append(p.errors, fmt.Sprintf("could not parse %q as float", p.curToken.Literal))
+		p.curError("could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 	return &ast.FloatLiteral{Token: p.curToken, Value: val}
@@ -818,6 +988,9 @@ func (p *Parser) parseForStatement() ast.Statement {
 	// Parse initialization statement
 	if !p.curTokenIs(token.SEMICOLON) {
 		stmt.Init = p.parseStatement()
+		if len(p.takePending()) > 0 {
+			p.curError("only one variable can be declared in the header of a for loop")
+		}
 	}
 
 	p.nextToken()
@@ -836,6 +1009,9 @@ func (p *Parser) parseForStatement() ast.Statement {
 	// Parse post statement
 	if !p.curTokenIs(token.RPAREN) {
 		stmt.Post = p.parseStatement()
+		if len(p.takePending()) > 0 {
+			p.curError("only one variable can be declared in the header of a for loop")
+		}
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -43,6 +44,56 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetDeclarations(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedIdents []string
+		expectedValues []interface{} // nil for a declaration without a value
+	}{
+		{"let x;", []string{"x"}, []interface{}{nil}},
+		{"let x\nx", []string{"x"}, []interface{}{nil}},
+		{"let a = 1, b = 2;", []string{"a", "b"}, []interface{}{1, 2}},
+		{"let a, b = c, d", []string{"a", "b", "d"}, []interface{}{nil, "c", nil}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		for i, name := range tt.expectedIdents {
+			if i >= len(program.Statements) || !testLetStatement(t, program.Statements[i], name) {
+				t.Fatalf("%q: statement %d is not let %s. got=%v", tt.input, i, name, program.Statements)
+			}
+			val := program.Statements[i].(*ast.LetStatement).Value
+			if tt.expectedValues[i] == nil {
+				if val != nil {
+					t.Errorf("%q: expected %s to have no value, got %s", tt.input, name, val.String())
+				}
+			} else if !testLiteralExpression(t, val, tt.expectedValues[i]) {
+				return
+			}
+		}
+	}
+
+	// The declarations of a block stay in order among its statements
+	p := New(lexer.New("if (true) { let a = 1, b; a }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	block := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence
+	if got := block.String(); got != "let a = 1;let b;a" {
+		t.Errorf("wrong block. got=%q", got)
+	}
+
+	for _, input := range []string{"let x 5", "let a = 1,", "for (let i = 0, j = 0; i < 1; i++) {}"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected a parse error", input)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -855,6 +906,41 @@ func TestParsingAssignmentExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingIndexAssignmentExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[0] = 1", "(a[0]) = 1"},
+		{"m[i, j] = 1", "(m[i,j]) = 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		assignExp, ok := stmt.Expression.(*ast.Assignment)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.Assignment. got=%T", stmt.Expression)
+		}
+
+		if _, ok := assignExp.Name.(*ast.IndexExpression); !ok {
+			t.Fatalf("assignExp.Name is not *ast.IndexExpression. got=%T", assignExp.Name)
+		}
+
+		if assignExp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, assignExp.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -1175,3 +1261,248 @@ func TestScientificNotation(t *testing.T) {
 		}
 	}
 }
+
+func TestNextStatementFromReader(t *testing.T) {
+	input := "let x = 5;\nlet add = fn(a, b) { a + b };\nadd(x, 2);"
+	p := New(lexer.NewReader(strings.NewReader(input)))
+
+	expected := []string{"let x = 5;", "let add = fn(a, b);", "add(x, 2)"}
+	for i, want := range expected {
+		stmt, ok := p.NextStatement()
+		if !ok {
+			t.Fatalf("statement %d missing", i)
+		}
+		if stmt.String() != want {
+			t.Errorf("statement %d wrong. expected=%q, got=%q", i, want, stmt.String())
+		}
+	}
+	checkParserErrors(t, p)
+
+	if _, ok := p.NextStatement(); ok {
+		t.Errorf("expected no more statements")
+	}
+}
+
+var benchmarkProgram = strings.Repeat(`let fib = fn(n) {
+	if (n < 2) { return n; }
+	return fib(n - 1) + fib(n - 2); // recursive
+};
+let names = ["a", "b", "c"];
+let total = 0;
+for (let i = 0; i < 10; i++) { total += i * 2; puts(names[i % 3], total); }
+`, 200)
+
+func BenchmarkParseProgram(b *testing.B) {
+	for _, useArena := range []bool{true, false} {
+		name := "heap"
+		if useArena {
+			name = "arena"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchmarkProgram)))
+			for i := 0; i < b.N; i++ {
+				p := New(lexer.New(benchmarkProgram))
+				if !useArena {
+					p.arena = nil
+				}
+				p.ParseProgram()
+			}
+		})
+	}
+}
+
+func TestArenaNodesAreDistinct(t *testing.T) {
+	p := New(lexer.New(benchmarkProgram))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.Arena() == nil {
+		t.Fatalf("program has no arena")
+	}
+
+	seen := map[*ast.Identifier]bool{}
+	count := 0
+	var visit func(exp ast.Expression)
+	visit = func(exp ast.Expression) {
+		switch exp := exp.(type) {
+		case *ast.Identifier:
+			if seen[exp] {
+				t.Fatalf("identifier %q handed out twice", exp.Value)
+			}
+			seen[exp] = true
+			count++
+		case *ast.InfixExpression:
+			visit(exp.Left)
+			visit(exp.Right)
+		case *ast.CallExpression:
+			visit(exp.Function)
+			for _, arg := range exp.Arguments {
+				visit(arg)
+			}
+		}
+	}
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			visit(stmt.Name)
+			visit(stmt.Value)
+		case *ast.ExpressionStatement:
+			visit(stmt.Expression)
+		}
+	}
+
+	if program.String() != New(lexer.New(benchmarkProgram)).ParseProgram().String() {
+		t.Errorf("parsing the same input twice gave different programs")
+	}
+	if count < 400 {
+		t.Errorf("expected to visit at least 400 identifiers, got %d", count)
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := New(lexer.New("let = 5;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected errors for invalid input")
+	}
+
+	p.Reset(lexer.New("let x = 1 + 2;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if program.String() != "let x = (1 + 2);" {
+		t.Errorf("wrong program after Reset. got=%q", program.String())
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Diagnostic
+	}{
+		{"let = 5;", Diagnostic{Message: "expected next token to be IDENT, got = instead", Code: "expected-token", Line: 1, Column: 5, Start: 4, End: 5}},
+		{"let x = 1;\nlet y = (2 + ;", Diagnostic{Message: "no prefix parse function for ; found", Code: "unexpected-token", Line: 2, Column: 14, Start: 24, End: 25}},
+		{"a.5", Diagnostic{Message: "expected property name to be identifier, got INT instead", Code: "expected-property", Line: 1, Column: 3, Start: 2, End: 3}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 || len(diagnostics) != len(p.Errors()) {
+			t.Errorf("wrong number of diagnostics for %q. got=%d, errors=%d", tt.input, len(diagnostics), len(p.Errors()))
+			continue
+		}
+		if diagnostics[0] != tt.expected {
+			t.Errorf("wrong diagnostic for %q. expected=%+v, got=%+v", tt.input, tt.expected, diagnostics[0])
+		}
+	}
+}
+
+func BenchmarkReplLines(b *testing.B) {
+	b.Run("New", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			New(lexer.New("let x = 1 + 2;")).ParseProgram()
+		}
+	})
+	b.Run("Reset", func(b *testing.B) {
+		p := New(lexer.New(""))
+		for i := 0; i < b.N; i++ {
+			p.Reset(lexer.New("let x = 1 + 2;"))
+			p.ParseProgram()
+		}
+	})
+}
+
+func TestIncremental(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedReused int
+	}{
+		{"let a = 1;\nlet b = a + 2;\nlet c = fn(x) { x };\nputs(c(b));", 0},
+		{"let a = 1;\nlet b = a + 2;\nlet c = fn(x) { x };\nputs(c(b) + 1);", 3},
+		{"let a = 1;\nlet b = a + 3;\nlet c = fn(x) { x };\nputs(c(b) + 1);", 1},
+		{"let a = 1;\nlet b = a + 3;\nlet c = fn(x) { x };\nputs(c(b) + 1);", 3},
+		{"let a = 1;\nlet b = a + 3;\nlet c = fn(x) { x }", 2},
+		{"a\nb", 0},
+		{"a\n+b", 0},
+		{"", 0},
+		{"let = 1;\nlet b = 2;", 0},
+		{"let = 1;\nlet b = 2;\nlet c = b;", 3},
+	}
+
+	inc := NewIncremental()
+	var previous *ast.Program
+	for i, tt := range tests {
+		program, errors := inc.Parse(tt.input)
+
+		p := New(lexer.New(tt.input))
+		expected := p.ParseProgram()
+
+		if inc.Reused() != tt.expectedReused {
+			t.Errorf("tests[%d] - wrong number of statements reused. expected=%d, got=%d", i, tt.expectedReused, inc.Reused())
+		}
+		if len(p.Errors()) == 0 && program.String() != expected.String() {
+			t.Errorf("tests[%d] - wrong program. expected=%q, got=%q", i, expected.String(), program.String())
+		}
+		if strings.Join(errors, "\n") != strings.Join(p.Errors(), "\n") {
+			t.Errorf("tests[%d] - wrong errors. expected=%v, got=%v", i, p.Errors(), errors)
+		}
+		for j := 0; j < inc.Reused(); j++ {
+			if program.Statements[j] != previous.Statements[j] {
+				t.Errorf("tests[%d] - statement %d was parsed again", i, j)
+			}
+		}
+		if len(program.Statements) != len(expected.Statements) {
+			t.Fatalf("tests[%d] - wrong number of statements. expected=%d, got=%d", i, len(expected.Statements), len(program.Statements))
+		}
+		for j, stmt := range program.Statements {
+			if got, want := stmt.(ast.Node), expected.Statements[j]; lineOf(got) != lineOf(want) {
+				t.Errorf("tests[%d] - statement %d on wrong line. expected=%d, got=%d", i, j, lineOf(want), lineOf(got))
+			}
+		}
+		previous = program
+	}
+}
+
+func lineOf(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node != nil {
+			return node.Token.Line
+		}
+	case *ast.ExpressionStatement:
+		if node != nil {
+			return node.Token.Line
+		}
+	}
+	return -1
+}
+
+func BenchmarkIncrementalEdit(b *testing.B) {
+	versions := []string{benchmarkProgram + "puts(1);", benchmarkProgram + "puts(12);"}
+	inc := NewIncremental()
+	inc.Parse(versions[0])
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		inc.Parse(versions[(i+1)%2])
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte(benchmarkProgram[:400]))
+	f.Add([]byte("let x = fn(a, b) { if (a) { b } elif (b) { a } else { [a, b][0, 1] } };"))
+	f.Add([]byte("for (let i = 0; i < 3; i++) { while (true) { break; } }"))
+	f.Add([]byte(`{"a": 1, 2: [1.5, .5]}.a += import("x")`))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		_, errors := Parse(src)
+		for _, msg := range errors {
+			if strings.HasPrefix(msg, "internal parser error") {
+				t.Fatalf("parser panicked on %q: %s", src, msg)
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package repl
+
+import (
+	"1ylang/object"
+	"1ylang/parser"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// printParserErrors prints each parse error followed by the line of src it
+// was found on, with carets under the offending token. Errors are headed by
+// their position when the input came from file. src may be "" when the
+// input is no longer at hand, in which case only the position is given.
+func printParserErrors(out io.Writer, file, src string, diagnostics []parser.Diagnostic) {
+	for _, d := range diagnostics {
+		header := "syntax error: " + d.Message
+		if file != "" && d.Line > 0 {
+			header = fmt.Sprintf("%s:%d:%d: %s", file, d.Line, d.Column, d.Message)
+		} else if src == "" && d.Line > 0 {
+			header = fmt.Sprintf("syntax error on line %d: %s", d.Line, d.Message)
+		}
+		writeHeader(out, header)
+		if src == "" {
+			continue
+		}
+
+		start := min(d.Start, len(src))
+		end := min(max(d.End, start), len(src))
+		lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+		lineEnd := len(src)
+		if i := strings.IndexByte(src[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i
+		}
+		end = min(end, lineEnd)
+
+		writeSourceLine(out, d.Line, src[lineStart:lineEnd], start-lineStart, end-lineStart)
+	}
+}
+
+// writeRuntimeError prints a runtime error, headed by its position if it
+// happened in a file. If it happened in src, the input from file, the line
+// is shown with a caret at the position, unless src is a single line typed
+// into the REPL.
+func writeRuntimeError(out io.Writer, file, src string, err *object.Error) {
+	if err.File != "" && err.Line > 0 {
+		writeHeader(out, fmt.Sprintf("%s:%d:%d: %s", err.File, err.Line, err.Column, err.Message))
+	} else {
+		writeHeader(out, err.Inspect())
+	}
+
+	lines := strings.Split(src, "\n")
+	if src == "" || err.File != file || (file == "" && len(lines) < 2) || err.Line < 1 || err.Line > len(lines) {
+		return
+	}
+	text := strings.TrimRight(lines[err.Line-1], " \t\r")
+	from := min(max(err.Column-1, 0), len(text))
+
+	// Carets run under the whole word at the position, such as a name
+	to := from
+	for to < len(text) && isWordRune(rune(text[to])) {
+		to++
+	}
+	writeSourceLine(out, err.Line, text, from, to)
+}
+
+func writeHeader(out io.Writer, header string) {
+	if Color {
+		header = colorError + header + colorReset
+	}
+	io.WriteString(out, header+"\n")
+}
+
+// writeSourceLine prints text, the source line numbered line, with carets
+// under its bytes from to to. At least one caret is printed, so a position
+// at the end of the line is shown too.
+func writeSourceLine(out io.Writer, line int, text string, from, to int) {
+	gutter := fmt.Sprint(line)
+	fmt.Fprintf(out, " %s | %s\n", gutter, text)
+
+	// Tabs are kept so that the carets line up however wide they are shown
+	var pad strings.Builder
+	for _, r := range text[:from] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	carets := strings.Repeat("^", max(utf8.RuneCountInString(text[from:to]), 1))
+	if Color {
+		carets = colorError + carets + colorReset
+	}
+	fmt.Fprintf(out, " %s | %s%s\n", strings.Repeat(" ", len(gutter)), pad.String(), carets)
+}
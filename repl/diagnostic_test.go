@@ -0,0 +1,38 @@
+package repl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStartWithFileDiagnostics(t *testing.T) {
+	defer func(file string) { InitFile = file }(InitFile)
+	InitFile = ""
+
+	tests := []struct {
+		file     string
+		input    string
+		expected string
+	}{
+		// code given with -e
+		{"<expr>", "puts(1 + y)", "<expr>:1:10: identifier not found: y\n 1 | puts(1 + y)\n   |          ^\n"},
+		{"<expr>", "let = 2", "<expr>:1:5: expected next token to be IDENT, got = instead\n 1 | let = 2\n   |     ^\n" +
+			"<expr>:1:5: no prefix parse function for = found\n 1 | let = 2\n   |     ^\n"},
+		// a program piped into standard input
+		{"<stdin>", "let a = 1;\nlet b = a + \"s\";\n", "<stdin>:2:11: unknown operator: INTEGER + STRING\n 2 | let b = a + \"s\";\n   |           ^\n"},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		ErrorOutput = &errOut
+		ok := StartWithFile(&out, tt.file, tt.input, false)
+		ErrorOutput = nil
+
+		if ok {
+			t.Errorf("%q did not fail", tt.input)
+		}
+		if errOut.String() != tt.expected {
+			t.Errorf("wrong diagnostic for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, errOut.String())
+		}
+	}
+}
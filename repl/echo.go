@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"1ylang/object"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxInlineEcho is the longest result printed on one line; longer arrays and
+// hashes, and those holding others, are spread over several lines.
+const maxInlineEcho = 72
+
+// writeTyped prints a result the way the interactive REPL shows it: after
+// "=>", with strings quoted and followed by the type, as in
+// `=> [1, "2"] : ARRAY`. Errors are printed as they are. digits, if
+// positive, is the number of significant digits floats are echoed with on
+// one line.
+func writeTyped(out io.Writer, result object.Object, digits int) {
+	if result.Type() == object.ERROR_OBJ {
+		writeResult(out, result)
+		return
+	}
+
+	io.WriteString(out, "=> "+echoText(result, digits)+" : "+string(result.Type())+"\n")
+}
+
+// echoText renders result as writeTyped shows it, without the type.
+func echoText(result object.Object, digits int) string {
+	text, ok := inline(result, digits)
+	if !ok || len(text) > maxInlineEcho {
+		text = object.Pretty(result, 2)
+	}
+	return text
+}
+
+// inline renders obj on one line with strings quoted. ok is false when obj
+// holds arrays or hashes, which are clearer spread over several lines.
+func inline(obj object.Object, digits int) (text string, ok bool) {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value), true
+	case *object.Float:
+		if digits > 0 {
+			return obj.Value.Text('g', digits), true
+		}
+		return obj.Inspect(), true
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			if !scalar(el) {
+				return "", false
+			}
+			elements[i], _ = inline(el, digits)
+		}
+		return "[" + strings.Join(elements, ", ") + "]", true
+	case *object.Hash:
+		pairs := make([]string, 0, len(obj.Pairs))
+		for _, pair := range object.SortedPairs(obj) {
+			if !scalar(pair.Value) {
+				return "", false
+			}
+			key, _ := inline(pair.Key, digits)
+			value, _ := inline(pair.Value, digits)
+			pairs = append(pairs, key+": "+value)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", true
+	default:
+		return obj.Inspect(), true
+	}
+}
+
+func scalar(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		return false
+	}
+	return true
+}
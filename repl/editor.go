@@ -0,0 +1,437 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl+C abandons the line.
+var ErrInterrupted = errors.New("interrupted")
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+// HistoryFile returns the file the REPL keeps its history in, or "" if the
+// home directory is unknown.
+func HistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".1y_history")
+}
+
+// lineReader reads the REPL's input one line at a time.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scannerReader reads lines from input that is not a terminal, such as a
+// pipe, so no editing is possible.
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// Editor reads lines from a terminal, letting them be edited with the usual
+// keys: arrows, Home and End, Ctrl or Alt with the arrows to move by word,
+// Backspace and Delete, and the Emacs bindings Ctrl+A, E, B, F, K, U and W.
+// Up and Down move through earlier lines, which are kept in a history file
+// across sessions. Text pasted in terminals that support bracketed paste is
+// taken as it is, so a block of several lines is read as one.
+type Editor struct {
+	fd  int
+	in  io.Reader
+	out io.Writer
+
+	history     []string
+	historyFile string
+
+	// Highlight, if set, returns the line as it should be displayed, such
+	// as with colors added. It must not change the visible text.
+	Highlight func(line string) string
+
+	line []rune
+	pos  int // cursor position in line
+}
+
+// NewEditor creates an editor for the terminal in, writing to out. Lines read
+// are appended to historyFile unless it is "".
+func NewEditor(in *os.File, out io.Writer, historyFile string) *Editor {
+	e := &Editor{fd: int(in.Fd()), in: in, out: out, historyFile: historyFile}
+	e.loadHistory()
+	return e
+}
+
+func (e *Editor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+	content, err := os.ReadFile(e.historyFile)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		// Rewrite the file so that it does not grow without bound
+		os.WriteFile(e.historyFile, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	}
+	for _, line := range lines {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+}
+
+// addHistory records line, unless it is empty or repeats the previous one.
+func (e *Editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+
+	if e.historyFile == "" {
+		return
+	}
+	// History is a convenience, so failing to save it is not reported
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// History returns the lines entered so far, oldest first, including those
+// loaded from the history file.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// ReadLine shows prompt and reads one edited line. The terminal is only in
+// raw mode while the line is being read, so programs run in between print as
+// usual.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(e.fd, state)
+	// Have the terminal mark the start and end of pasted text
+	io.WriteString(e.out, "\x1b[?2004h")
+	defer io.WriteString(e.out, "\x1b[?2004l")
+
+	e.line, e.pos = e.line[:0], 0
+	historyIndex := len(e.history) // len(history) is the line being typed
+	pending := ""                  // the line being typed while looking at history
+
+	e.refresh(prompt)
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter:
+			io.WriteString(e.out, "\r\n")
+			line := string(e.line)
+			e.addHistory(line)
+			return line, nil
+		case keyInterrupt:
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case keyEOF:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRight(1)
+		case keyBackspace:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteRight(1)
+			}
+		case keyDelete:
+			e.deleteRight(1)
+		case keyLeft:
+			e.pos = max(e.pos-1, 0)
+		case keyRight:
+			e.pos = min(e.pos+1, len(e.line))
+		case keyWordLeft:
+			e.pos = e.wordLeft()
+		case keyWordRight:
+			e.pos = e.wordRight()
+		case keyHome:
+			e.pos = 0
+		case keyEnd:
+			e.pos = len(e.line)
+		case keyKillLeft:
+			e.line = append(e.line[:0], e.line[e.pos:]...)
+			e.pos = 0
+		case keyKillRight:
+			e.line = e.line[:e.pos]
+		case keyKillWord:
+			start := e.wordLeft()
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case keyUp, keyDown:
+			if historyIndex == len(e.history) {
+				pending = string(e.line)
+			}
+			if key == keyUp && historyIndex > 0 {
+				historyIndex--
+			} else if key == keyDown && historyIndex < len(e.history) {
+				historyIndex++
+			}
+			if historyIndex == len(e.history) {
+				e.line = []rune(pending)
+			} else {
+				e.line = []rune(e.history[historyIndex])
+			}
+			e.pos = len(e.line)
+		case keyPaste:
+			text, err := e.readPaste()
+			if err != nil {
+				return "", err
+			}
+			e.insert([]rune(text)...)
+			if strings.Contains(text, "\n") {
+				// A pasted block is run as a whole, rather than stopping
+				// at the first line that parses on its own
+				line := strings.TrimRight(string(e.line), "\n")
+				io.WriteString(e.out, "\r"+prompt+strings.ReplaceAll(line, "\n", "\r\n")+"\x1b[K\r\n")
+				e.addHistory(line)
+				return line, nil
+			}
+		case keyUnknown:
+		default:
+			e.insert(key)
+		}
+		e.refresh(prompt)
+	}
+}
+
+// refresh redraws the prompt and line and puts the cursor in place.
+func (e *Editor) refresh(prompt string) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	if e.Highlight != nil {
+		b.WriteString(e.Highlight(string(e.line)))
+	} else {
+		b.WriteString(string(e.line))
+	}
+	b.WriteString("\x1b[K")
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// insert puts text into the line at the cursor, moving the cursor past it.
+func (e *Editor) insert(text ...rune) {
+	e.line = append(e.line[:e.pos], append(text, e.line[e.pos:]...)...)
+	e.pos += len(text)
+}
+
+func (e *Editor) deleteRight(n int) {
+	n = min(n, len(e.line)-e.pos)
+	e.line = append(e.line[:e.pos], e.line[e.pos+n:]...)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// wordLeft returns the start of the word before the cursor.
+func (e *Editor) wordLeft() int {
+	pos := e.pos
+	for pos > 0 && !isWordRune(e.line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(e.line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordRight returns the end of the word after the cursor.
+func (e *Editor) wordRight() int {
+	pos := e.pos
+	for pos < len(e.line) && !isWordRune(e.line[pos]) {
+		pos++
+	}
+	for pos < len(e.line) && isWordRune(e.line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// Keys other than text are represented by runes from a private use area.
+const (
+	keyUnknown rune = 0xe000 + iota
+	keyEnter
+	keyInterrupt
+	keyEOF
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyWordLeft
+	keyWordRight
+	keyHome
+	keyEnd
+	keyKillLeft
+	keyKillRight
+	keyKillWord
+	keyPaste // the start of bracketed paste
+)
+
+// controlKeys maps control characters to the keys they stand for.
+var controlKeys = map[byte]rune{
+	'\r': keyEnter, '\n': keyEnter,
+	3: keyInterrupt, 4: keyEOF,
+	127: keyBackspace, 8: keyBackspace,
+	1: keyHome, 5: keyEnd, 2: keyLeft, 6: keyRight,
+	16: keyUp, 14: keyDown,
+	21: keyKillLeft, 11: keyKillRight, 23: keyKillWord,
+}
+
+// escapeKeys maps the escape sequences terminals send, without the leading
+// ESC, to keys.
+var escapeKeys = map[string]rune{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+	"[3~":   keyDelete,
+	"[1;5C": keyWordRight, "[1;5D": keyWordLeft,
+	"[1;3C": keyWordRight, "[1;3D": keyWordLeft,
+	"f": keyWordRight, "b": keyWordLeft,
+	"[200~": keyPaste,
+}
+
+// pasteEnd ends text pasted in bracketed paste mode.
+const pasteEnd = "\x1b[201~"
+
+// readPaste reads pasted text up to its end marker. Line endings are turned
+// into newlines and other control characters, such as tabs, into spaces.
+func (e *Editor) readPaste() (string, error) {
+	var buf []byte
+	for !bytes.HasSuffix(buf, []byte(pasteEnd)) {
+		c, err := e.readByte()
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, c)
+	}
+	text := string(buf[:len(buf)-len(pasteEnd)])
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r < 0x20 && r != '\n':
+			return ' '
+		}
+		return r
+	}, text), nil
+}
+
+// readKey reads the next key typed. Input is read a byte at a time so that
+// nothing past the line is taken from the terminal.
+func (e *Editor) readKey() (rune, error) {
+	c, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if key, ok := controlKeys[c]; ok {
+		return key, nil
+	}
+	if c == 0x1b {
+		return e.readEscape()
+	}
+	if c < 0x20 {
+		return keyUnknown, nil
+	}
+	if c < utf8.RuneSelf {
+		return rune(c), nil
+	}
+
+	// Collect the rest of a multi-byte character
+	buf := []byte{c}
+	for !utf8.FullRune(buf) {
+		c, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, c)
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return r, nil
+}
+
+// readEscape reads an escape sequence after its ESC.
+func (e *Editor) readEscape() (rune, error) {
+	var seq []byte
+	for {
+		c, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		seq = append(seq, c)
+
+		// Sequences end in a letter or ~, apart from the [ or O that
+		// introduces most of them
+		final := (c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '~') &&
+			!(len(seq) == 1 && c == 'O')
+		if final || len(seq) > 8 {
+			break
+		}
+		if len(seq) == 1 && c != '[' && c != 'O' {
+			break
+		}
+	}
+	if key, ok := escapeKeys[string(seq)]; ok {
+		return key, nil
+	}
+	return keyUnknown, nil
+}
+
+func (e *Editor) readByte() (byte, error) {
+	var buf [1]byte
+	for {
+		n, err := e.in.Read(buf[:])
+		if n == 1 {
+			return buf[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
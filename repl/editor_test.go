@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEditorPaste(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x1b[200~let x = 1\x1b[201~", "let x = 1"},
+		{"\x1b[200~let f = fn() {\r\n  1\r\n}\x1b[201~", "let f = fn() {\n  1\n}"},
+		{"\x1b[200~a\rb\nc\x1b[201~", "a\nb\nc"},
+		{"\x1b[200~\tif (x) {\x1b[D}\x1b[201~", " if (x) { [D}"},
+		{"\x1b[200~π ≈ 3.14\x1b[201~", "π ≈ 3.14"},
+		{"\x1b[200~\x1b[201~", ""},
+	}
+
+	for _, tt := range tests {
+		e := &Editor{in: strings.NewReader(tt.input)}
+		key, err := e.readKey()
+		if err != nil || key != keyPaste {
+			t.Errorf("readKey(%q) = %U, %v, want the start of a paste", tt.input, key, err)
+			continue
+		}
+		text, err := e.readPaste()
+		if err != nil {
+			t.Errorf("readPaste(%q) failed: %v", tt.input, err)
+		}
+		if text != tt.expected {
+			t.Errorf("readPaste(%q) = %q, want %q", tt.input, text, tt.expected)
+		}
+	}
+
+	// A paste cut short by the end of input is an error, not a line
+	e := &Editor{in: strings.NewReader("\x1b[200~unfinished")}
+	e.readKey()
+	if _, err := e.readPaste(); err != io.EOF {
+		t.Errorf("readPaste of an unterminated paste = %v, want EOF", err)
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []rune
+	}{
+		{"ab\r", []rune{'a', 'b', keyEnter}},
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []rune{keyUp, keyDown, keyRight, keyLeft}},
+		{"\x1b[1;5C\x1bb\x1b[3~", []rune{keyWordRight, keyWordLeft, keyDelete}},
+		{"\x1bOH\x1b[4~", []rune{keyHome, keyEnd}},
+		{"\x03\x04\x7f", []rune{keyInterrupt, keyEOF, keyBackspace}},
+		{"é\x1b[99~", []rune{'é', keyUnknown}},
+	}
+
+	for _, tt := range tests {
+		e := &Editor{in: strings.NewReader(tt.input)}
+		for _, expected := range tt.expected {
+			key, err := e.readKey()
+			if err != nil || key != expected {
+				t.Errorf("readKey in %q = %U, %v, want %U", tt.input, key, err, expected)
+				break
+			}
+		}
+	}
+}
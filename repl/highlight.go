@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"1ylang/highlight"
+	"strings"
+)
+
+// Color controls whether the REPL colors the input as it is typed and the
+// errors it prints. It should only be set when the output is a terminal.
+var Color = false
+
+// ANSI escape sequences for the colors used.
+const (
+	colorReset   = "\x1b[0m"
+	colorKeyword = "\x1b[35m" // magenta
+	colorNumber  = "\x1b[36m" // cyan
+	colorString  = "\x1b[32m" // green
+	colorComment = "\x1b[90m" // gray
+	colorError   = "\x1b[31m" // red
+)
+
+// categoryColor returns the color for spans of category c, or "" to leave
+// them plain.
+func categoryColor(c highlight.Category) string {
+	switch c {
+	case highlight.Number:
+		return colorNumber
+	case highlight.String:
+		return colorString
+	case highlight.Comment:
+		return colorComment
+	case highlight.Invalid:
+		return colorError
+	case highlight.Keyword:
+		return colorKeyword
+	}
+	return ""
+}
+
+// colorize returns src with its keywords, numbers, strings and comments
+// colored. Everything between tokens, such as spaces, is kept as is, so the
+// result shows the same text as src.
+func colorize(src string) string {
+	var out strings.Builder
+	written := 0
+
+	for _, span := range highlight.Classify(src) {
+		color := categoryColor(span.Category)
+		if color == "" {
+			continue
+		}
+		out.WriteString(src[written:span.Start])
+		out.WriteString(color)
+		out.WriteString(src[span.Start:span.End])
+		out.WriteString(colorReset)
+		written = span.End
+	}
+
+	out.WriteString(src[written:])
+	return out.String()
+}
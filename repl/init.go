@@ -0,0 +1,65 @@
+package repl
+
+import (
+	"1ylang/lexer"
+	"1ylang/object"
+	"1ylang/parser"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// InitFile is run in the REPL's environment before the first prompt, so the
+// functions and variables it declares are there in every session. It
+// defaults to DefaultInitFile(), which is skipped if it does not exist.
+var InitFile = DefaultInitFile()
+
+// DefaultInitFile returns the startup file the REPL runs, ~/.1yrc.1y, or ""
+// if the home directory is unknown.
+func DefaultInitFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".1yrc.1y")
+}
+
+// runInitFile runs InitFile in env, reporting errors in it to out.
+func runInitFile(out io.Writer, env *object.Environment) {
+	if InitFile == "" {
+		return
+	}
+	content, err := os.ReadFile(InitFile)
+	if os.IsNotExist(err) && InitFile == DefaultInitFile() {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error reading init file %s: %v\n", InitFile, err)
+		return
+	}
+	// Only errors are shown, not the value the file ends with
+	executeLine(out, out, parser.New(lexer.New("")), InitFile, string(content), env, false, func(io.Writer, object.Object) {})
+}
+
+// promptIn returns the prompt to show, which the user can change by setting
+// PROMPT to a string, such as in the init file.
+func promptIn(env *object.Environment) string {
+	if val, ok, _ := env.Get("PROMPT"); ok {
+		if s, ok := val.(*object.String); ok {
+			return s.Value
+		}
+	}
+	return PROMPT
+}
+
+// precisionIn returns the number of significant digits floats are echoed
+// with, which the user can set with PRECISION. 0 means as many as needed.
+func precisionIn(env *object.Environment) int {
+	if val, ok, _ := env.Get("PRECISION"); ok {
+		if i, ok := val.(*object.Integer); ok && i.Value.IsInt64() && i.Value.Int64() > 0 {
+			return int(i.Value.Int64())
+		}
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package repl
+
+import (
+	"1ylang/lexer"
+	"1ylang/token"
+)
+
+// CONTINUATION is the prompt shown while the input read so far is incomplete.
+const CONTINUATION = ".. "
+
+// pending are the tokens that cannot end a statement, so input ending in one
+// of them continues on the next line.
+var pending = map[token.TokenType]bool{
+	token.ASSIGN: true, token.PLUS: true, token.MINUS: true, token.BANG: true,
+	token.ASTERISK: true, token.SLASH: true, token.MODULUS: true, token.POW: true,
+	token.LT: true, token.GT: true, token.LE: true, token.GE: true,
+	token.EQ: true, token.NOT_EQ: true, token.AND: true, token.OR: true,
+	token.XOR: true, token.TILDE: true, token.SHR: true, token.SHL: true,
+	token.AND_AND: true, token.OR_OR: true, token.COMMA: true, token.COLON: true,
+	token.DOT: true,
+
+	token.PLUS_ASSIGN: true, token.MINUS_ASSIGN: true, token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN: true, token.MODULUS_ASSIGN: true, token.AND_ASSIGN: true,
+	token.OR_ASSIGN: true, token.XOR_ASSIGN: true, token.SHL_ASSIGN: true,
+	token.SHR_ASSIGN: true, token.POW_ASSIGN: true,
+
+	token.FUNCTION: true, token.LET: true, token.CONST: true, token.IF: true,
+	token.ELSE: true, token.ELIF: true, token.WHILE: true, token.FOR: true,
+	token.IMPORT: true,
+}
+
+// incomplete reports whether src stops in the middle of a statement: inside
+// brackets or a string, or right after an operator or keyword that needs
+// something to follow it.
+func incomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+	last := token.Token{Type: token.EOF}
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.STRING:
+			// A string missing its closing quote runs past the end of the input
+			if _, end := l.Span(); end > len(src) {
+				return true
+			}
+		}
+		last = tok
+	}
+
+	// Closing more brackets than were opened is an error no further input
+	// can fix, so it is reported right away
+	return depth > 0 || pending[last.Type]
+}
+
+// silenced reports whether src ends with a semicolon, which keeps the REPL
+// from printing the value it evaluates to.
+func silenced(src string) bool {
+	l := lexer.New(src)
+	last := token.Token{Type: token.EOF}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		last = tok
+	}
+	return last.Type == token.SEMICOLON
+}
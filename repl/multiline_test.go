@@ -0,0 +1,58 @@
+package repl
+
+import "testing"
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		src        string
+		incomplete bool
+	}{
+		{"", false},
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  x * 2\n}", false},
+		{"[1, 2,", true},
+		{"[1, 2]", false},
+		{"puts(1,\n", true},
+		{"{\"a\": 1", true},
+		{"1 +", true},
+		{"x =", true},
+		{"x +=", true},
+		{"a &&", true},
+		{"Math.", true},
+		{"let", true},
+		{"if (x) { 1 } else", true},
+		{"import", true},
+		{`"unterminated`, true},
+		{`"closed"`, false},
+		{`"a { b"`, false},
+		{"x++", false},
+		{"1)", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.src); got != tt.incomplete {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.src, got, tt.incomplete)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	tests := []struct {
+		src      string
+		silenced bool
+	}{
+		{"1 + 2", false},
+		{"1 + 2;", true},
+		{"let x = 1; x", false},
+		{`"a;"`, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := silenced(tt.src); got != tt.silenced {
+			t.Errorf("silenced(%q) = %t, want %t", tt.src, got, tt.silenced)
+		}
+	}
+}
@@ -1,84 +1,344 @@
 package repl
 
 import (
+	"1ylang/ast"
 	"1ylang/evaluator"
 	"1ylang/lexer"
 	"1ylang/lib"
 	"1ylang/object"
+	"1ylang/optimizer"
 	"1ylang/parser"
 	"bufio"
-	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"strings"
 	"time"
-)
-
-func initEnv() *object.Environment {
-	env := object.NewEnvironment()
 
-	lib.RegisterStringFuncs(env)
-	lib.RegisterArrayFuncs(env)
-	lib.RegisterMathFuncs(env)
+	"golang.org/x/term"
+)
 
+// initEnv creates a global environment whose programs print to out, and
+// report with eprint to ErrorOutput if set, configured otherwise like the
+// default interpreter.
+func initEnv(out io.Writer) *object.Environment {
+	options := evaluator.Default().Options()
+	options.Stdout = out
+	if ErrorOutput != nil {
+		options.Stderr = ErrorOutput
+	}
+	env := evaluator.New(options).NewEnvironment()
+	lib.Register(env)
 	return env
 }
 
 const PROMPT = ">> "
 
-// Start starts the REPL
+// Optimize controls whether programs go through the optimizer before they
+// are evaluated. Turning it off helps when debugging the optimizer itself.
+var Optimize = true
+
+// Timeout, if positive, limits how long a script or each REPL command may
+// run before it is stopped with a timeout error.
+var Timeout time.Duration
+
+// startTimeout clears any earlier interruption of the interpreter of env and
+// starts the time limit for running one program or command in it. The
+// returned function must be called once it has finished.
+func startTimeout(env *object.Environment) (stop func()) {
+	in := evaluator.InterpreterOf(env)
+	in.ClearInterrupt()
+	if Timeout <= 0 {
+		return func() {}
+	}
+	return in.Timeout(Timeout)
+}
+
+// Start starts the REPL. When in is a terminal, lines can be edited and
+// earlier ones recalled, and are saved to the history file.
 func Start(in io.Reader, out io.Writer, timed bool) {
-	scanner := bufio.NewScanner(in)
-	env := initEnv()
+	var reader lineReader = &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		editor := NewEditor(f, out, HistoryFile())
+		if Color {
+			editor.Highlight = colorize
+		}
+		reader = editor
+	}
 
-	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
+	env := initEnv(out)
+	p := parser.New(lexer.New(""))
+	runInitFile(out, env)
+	commands := &transcript{record: openRecord(out)}
+	if commands.record != nil {
+		defer commands.record.Close()
+	}
 
-		if !scanned {
-			return
+	// Results are echoed with the precision set when they are printed,
+	// which may be by the line itself
+	echo := func(out io.Writer, result object.Object) {
+		writeTyped(out, result, precisionIn(env))
+	}
+
+	// Ctrl+C stops the code running instead of the REPL. While a line is
+	// being edited the terminal is in raw mode, so Ctrl+C is read as a key
+	// rather than sent as a signal.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}()
+	go func() {
+		for range interrupts {
+			evaluator.InterpreterOf(env).Interrupt()
 		}
+	}()
+
+	run := func(input string) {
+		write := echo
+		if silenced(input) {
+			// Errors are still shown
+			write = func(out io.Writer, result object.Object) {
+				if result.Type() == object.ERROR_OBJ {
+					echo(out, result)
+				}
+			}
+		}
+		if executeLine(out, out, p, "", input, env, timed, write) {
+			commands.add(input)
+		}
+	}
+	if ReplayFile != "" {
+		replay(out, func() string { return promptIn(env) }, run)
+	}
 
-		line := scanner.Text()
+	// Lines are collected until they form complete statements. An empty
+	// line runs what has been collected even if it is incomplete, so that
+	// a mistake does not leave the prompt waiting for more input.
+	input := ""
+	for {
+		prompt := promptIn(env)
+		if input != "" {
+			prompt = CONTINUATION
+		}
+		line, err := reader.ReadLine(prompt)
+		if err == ErrInterrupted {
+			input = ""
+			continue
+		}
+		if err != nil {
+			return
+		}
 
-		executeLine(out, line, env, timed)
+		if input == "" && isCommand(line) {
+			commands.command(out, line, env)
+			continue
+		}
+		if input == "" && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if input == "" {
+			input = line
+		} else {
+			input += "\n" + line
+		}
+		if line != "" && incomplete(input) {
+			continue
+		}
+		run(input)
+		input = ""
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// ErrorOutput, if not nil, is where StartWithString, StartWithFile,
+// StartWithReader and StartWithProgram report errors, rather than with the
+// results they print. The REPL shows errors with its results.
+var ErrorOutput io.Writer
+
+func errorOutput(out io.Writer) io.Writer {
+	if ErrorOutput != nil {
+		return ErrorOutput
 	}
+	return out
 }
 
-// StartWithString executes a given input string
-func StartWithString(out io.Writer, input string, timed bool) {
-	env := initEnv()
-	executeLine(out, input, env, timed)
+// StartWithString executes a given input string. It reports whether it ran
+// without errors.
+func StartWithString(out io.Writer, input string, timed bool) bool {
+	return StartWithFile(out, "", input, timed)
 }
 
-// executeLine executes a single line of input and optionally times it
-func executeLine(out io.Writer, line string, env *object.Environment, timed bool) {
-	var startTime time.Time
+// StartWithFile executes input, the contents of file, reporting errors with
+// their position in the file. It reports whether it ran without errors.
+func StartWithFile(out io.Writer, file, input string, timed bool) bool {
+	env := initEnv(out)
+	return executeLine(out, errorOutput(out), parser.New(lexer.New("")), file, input, env, timed, writeResult)
+}
+
+// StartWithReader executes a program read from in one top-level statement at
+// a time, so scripts too large to load into memory can still run. Unlike
+// StartWithString, statements before a syntax error have already run when
+// the error is reported. It reports whether the program ran without errors.
+func StartWithReader(out io.Writer, in io.Reader, timed bool) bool {
+	env := initEnv(out)
+	p := parser.New(lexer.NewReader(in))
+	var t *timing
 	if timed {
-		startTime = time.Now()
+		t = startTiming(p, evaluator.InterpreterOf(env))
 	}
+	defer startTimeout(env)()
+
+	var result object.Object
+	for {
+		stmt, ok := p.NextStatement()
+		if timed {
+			t.parsed(p)
+		}
+		if len(p.Errors()) != 0 {
+			if timed {
+				t.stop()
+			}
+			printParserErrors(errorOutput(out), "", "", p.Diagnostics())
+			return false
+		}
+		if !ok {
+			break
+		}
 
-	l := lexer.New(line)
-	p := parser.New(l)
+		program := &ast.Program{Statements: []ast.Statement{stmt}}
+		if Optimize {
+			optimizer.Optimize(program)
+		}
+		ast.Resolve(program)
+
+		stop := false
+		for _, stmt := range program.Statements {
+			result = evaluator.Eval(stmt, env)
+			if returnValue, ok := result.(*object.ReturnValue); ok {
+				result = returnValue.Value
+				stop = true
+			}
+			if _, ok := result.(*object.Error); ok {
+				stop = true
+			}
+			if stop {
+				break
+			}
+		}
+		if timed {
+			t.evaluated()
+		}
+		if stop {
+			break
+		}
+	}
+
+	err, failed := result.(*object.Error)
+	if failed {
+		writeRuntimeError(errorOutput(out), "", "", err)
+	} else if result != nil && result.Type() != object.NULL_OBJ {
+		writeResult(out, result)
+	}
+
+	if timed {
+		t.report(out)
+	}
+	return !failed
+}
+
+// executeLine executes a single line of input from file, if it was read from
+// one, with parser p and optionally times it. Results are printed to out and
+// errors to errOut. It reports whether the line ran without errors.
+func executeLine(out, errOut io.Writer, p *parser.Parser, file, line string, env *object.Environment, timed bool, write resultWriter) bool {
+	var t *timing
+	if timed {
+		t = startTiming(p, evaluator.InterpreterOf(env))
+	}
+
+	p.Reset(lexer.New(line))
 
 	program := p.ParseProgram()
+	if timed {
+		t.parsed(p)
+	}
 	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
-		return
+		if timed {
+			t.stop()
+		}
+		printParserErrors(errOut, file, line, p.Diagnostics())
+		return false
 	}
+	program.File = file
 
-	evaluated := evaluator.Eval(program, env)
-	if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
-		io.WriteString(out, evaluated.Inspect())
-		io.WriteString(out, "\n")
+	ok := true
+	stop := startTimeout(env)
+	executeProgram(out, program, env, func(out io.Writer, result object.Object) {
+		if err, isError := result.(*object.Error); isError {
+			writeRuntimeError(errOut, file, line, err)
+			ok = false
+			return
+		}
+		write(out, result)
+	})
+	stop()
+
+	if timed {
+		t.evaluated()
+		t.report(out)
+	}
+	return ok
+}
+
+// StartWithProgram executes an already parsed program, such as one loaded
+// from a compiled .1yc file. It reports whether it ran without errors.
+func StartWithProgram(out io.Writer, program *ast.Program, timed bool) bool {
+	env := initEnv(out)
+	var t *timing
+	if timed {
+		t = startTiming(nil, evaluator.InterpreterOf(env))
 	}
 
+	ok := true
+	stop := startTimeout(env)
+	executeProgram(out, program, env, func(out io.Writer, result object.Object) {
+		if err, isError := result.(*object.Error); isError {
+			writeRuntimeError(errorOutput(out), program.File, "", err)
+			ok = false
+			return
+		}
+		writeResult(out, result)
+	})
+	stop()
+
 	if timed {
-		duration := time.Since(startTime)
-		fmt.Fprintf(out, "Execution time: %v\n", duration)
+		t.evaluated()
+		t.report(out)
+	}
+	return ok
+}
+
+// resultWriter prints the value a program evaluated to.
+type resultWriter func(out io.Writer, result object.Object)
+
+// writeResult prints the value a program evaluated to, in red if it is an
+// error and Color is set.
+func writeResult(out io.Writer, result object.Object) {
+	if Color && result.Type() == object.ERROR_OBJ {
+		io.WriteString(out, colorError+result.Inspect()+colorReset+"\n")
+		return
+	}
+	io.WriteString(out, result.Inspect())
+	io.WriteString(out, "\n")
+}
+
+func executeProgram(out io.Writer, program *ast.Program, env *object.Environment, write resultWriter) {
+	if Optimize {
+		optimizer.Optimize(program)
+	}
+
+	evaluated := evaluator.Eval(program, env)
+	if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
+		write(out, evaluated)
 	}
 }
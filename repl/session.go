@@ -0,0 +1,262 @@
+package repl
+
+import (
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/lib"
+	"1ylang/object"
+	"1ylang/optimizer"
+	"1ylang/parser"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// SessionOptions configure a Session.
+type SessionOptions struct {
+	// Options configure the interpreter the session runs code in. Output
+	// to a nil Stdout or Stderr is returned with each Result instead, and a
+	// nil Stdin has no input.
+	evaluator.Options
+
+	// Timeout, if positive, limits how long each Exec may run.
+	Timeout time.Duration
+}
+
+// Session runs input one piece at a time in one environment, as the REPL
+// does, for programs that host interactive sessions without a terminal,
+// such as chat bots and notebooks. Variables declared by one Exec are there
+// for the next. Each session has an interpreter of its own, with the
+// libraries, so sessions with limits of their own can run concurrently.
+type Session struct {
+	env     *object.Environment
+	parser  *parser.Parser
+	output  bytes.Buffer // what the code run by Exec printed
+	timeout time.Duration
+	library map[string]bool // globals declared before any input, such as namespaces
+}
+
+// Result is the outcome of a Session.Exec.
+type Result struct {
+	Value  object.Object // the value the input ended with
+	Text   string        // Value as the REPL echoes it, "" for null
+	Output string        // what the input printed
+}
+
+// NewSession creates a session configured by options.
+func NewSession(options SessionOptions) *Session {
+	s := &Session{parser: parser.New(lexer.New("")), timeout: options.Timeout}
+	if options.Stdout == nil {
+		options.Stdout = &s.output
+	}
+	if options.Stderr == nil {
+		options.Stderr = &s.output
+	}
+	if options.Stdin == nil {
+		options.Stdin = strings.NewReader("")
+	}
+	s.env = evaluator.New(options.Options).NewEnvironment()
+	lib.Register(s.env)
+	s.library = make(map[string]bool)
+	for name := range s.env.Store() {
+		s.library[name] = true
+	}
+	return s
+}
+
+// Env returns the environment the session runs code in.
+func (s *Session) Env() *object.Environment {
+	return s.env
+}
+
+// Interpreter returns the interpreter the session runs code in, such as to
+// interrupt it or change its limits.
+func (s *Session) Interpreter() *evaluator.Interpreter {
+	return evaluator.InterpreterOf(s.env)
+}
+
+// Close ends the session, running what its interpreter has registered to
+// run at exit, such as removing temporary files its input created.
+func (s *Session) Close() {
+	s.Interpreter().Close()
+}
+
+// Variables returns the globals the session's input has declared, by name.
+// It must not be called while Exec runs.
+func (s *Session) Variables() map[string]object.Object {
+	vars := make(map[string]object.Object)
+	for name, v := range s.env.Store() {
+		if !s.library[name] {
+			vars[name] = v.Value
+		}
+	}
+	return vars
+}
+
+// Exec runs input, which holds one or more complete statements, and returns
+// the value it ended with and what it printed. If input cannot be parsed or
+// fails, the error is an *evaluator.RuntimeError and the Result still holds
+// what was printed before it failed.
+func (s *Session) Exec(input string) (Result, error) {
+	s.output.Reset()
+
+	s.parser.Reset(lexer.New(input))
+	program := s.parser.ParseProgram()
+	if diagnostics := s.parser.Diagnostics(); len(diagnostics) != 0 {
+		return Result{}, evaluator.SyntaxError("", diagnostics)
+	}
+	if Optimize {
+		optimizer.Optimize(program)
+	}
+
+	in := s.Interpreter()
+	in.ClearInterrupt()
+	if s.timeout > 0 {
+		defer in.Timeout(s.timeout)()
+	}
+	value := evaluator.Eval(program, s.env)
+	result := Result{Value: value, Output: s.output.String()}
+	if err := evaluator.ErrorOf(value); err != nil {
+		result.Value = nil
+		return result, err
+	}
+	result.Text = s.Text(value)
+	return result, nil
+}
+
+// Text renders value as the REPL echoes it, as for Result.Text, with the
+// precision set in the session.
+func (s *Session) Text(value object.Object) string {
+	if value == nil || value.Type() == object.NULL_OBJ {
+		return ""
+	}
+	return echoText(value, precisionIn(s.env))
+}
+
+// transcript keeps the commands entered into the REPL that ran without
+// errors, so they can be saved and run again in a later session.
+type transcript struct {
+	commands []string
+	record   io.WriteCloser // RecordFile, if it is open
+}
+
+// isCommand reports whether line is a REPL command, such as ":save", rather
+// than code.
+func isCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ":")
+}
+
+func (s *transcript) add(input string) {
+	s.commands = append(s.commands, input)
+	if s.record != nil {
+		fmt.Fprintln(s.record, input)
+	}
+}
+
+// command runs the REPL command line:
+//
+//	:save FILE      writes the commands of the session to FILE
+//	:open FILE      runs FILE, as if its contents had been typed in
+//	:snapshot FILE  writes the variables of the session holding data to FILE
+//	:restore FILE   declares the variables of a snapshot written to FILE
+func (s *transcript) command(out io.Writer, line string, env *object.Environment) {
+	fields := strings.Fields(strings.TrimSpace(line))
+	name := fields[0]
+	if name != ":save" && name != ":open" && name != ":snapshot" && name != ":restore" {
+		fmt.Fprintf(out, "unknown command %s, expected :save, :open, :snapshot or :restore FILE\n", name)
+		return
+	}
+	if len(fields) != 2 {
+		fmt.Fprintf(out, "usage: %s FILE\n", name)
+		return
+	}
+	file := fields[1]
+
+	if name == ":save" {
+		content := strings.Join(s.commands, "\n")
+		if content != "" {
+			content += "\n"
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			fmt.Fprintf(out, "Error writing %s: %v\n", file, err)
+			return
+		}
+		fmt.Fprintf(out, "saved %d commands to %s\n", len(s.commands), file)
+		return
+	}
+
+	if name == ":snapshot" {
+		writeSnapshot(out, file, env)
+		return
+	}
+	if name == ":restore" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(out, "Error reading %s: %v\n", file, err)
+			return
+		}
+		defer f.Close()
+		if err := object.RestoreEnvironment(f, env); err != nil {
+			fmt.Fprintf(out, "Error reading %s: %v\n", file, err)
+		}
+		return
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading %s: %v\n", file, err)
+		return
+	}
+	// Like the init file, only errors are shown. The file becomes part of
+	// the session, so saving it again keeps what it declared.
+	if executeLine(out, out, parser.New(lexer.New("")), file, string(content), env, false, func(io.Writer, object.Object) {}) {
+		s.add(strings.TrimRight(string(content), "\n"))
+	}
+}
+
+// writeSnapshot saves the variables of env to file, naming those that hold
+// values other than data, such as functions, which are left out. The
+// namespaces of the libraries are left out without saying so.
+func writeSnapshot(out io.Writer, file string, env *object.Environment) {
+	f, err := os.Create(file)
+	if err != nil {
+		fmt.Fprintf(out, "Error writing %s: %v\n", file, err)
+		return
+	}
+	skipped, err := object.SaveEnvironment(f, env)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error writing %s: %v\n", file, err)
+		return
+	}
+
+	var left []string
+	for _, name := range skipped {
+		if value, _, _ := env.Get(name); !isNamespace(value) {
+			left = append(left, name)
+		}
+	}
+	fmt.Fprintf(out, "saved a snapshot to %s\n", file)
+	if len(left) > 0 {
+		fmt.Fprintf(out, "left out %s, which cannot be saved\n", strings.Join(left, ", "))
+	}
+}
+
+// isNamespace reports whether obj is a hash of builtins, such as Math.
+func isNamespace(obj object.Object) bool {
+	hash, ok := obj.(*object.Hash)
+	if !ok || len(hash.Pairs) == 0 {
+		return false
+	}
+	for _, pair := range hash.Pairs {
+		if pair.Value.Type() != object.BUILTIN_OBJ {
+			return false
+		}
+	}
+	return true
+}
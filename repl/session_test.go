@@ -0,0 +1,224 @@
+package repl
+
+import (
+	"1ylang/evaluator"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionExec(t *testing.T) {
+	s := NewSession(SessionOptions{})
+
+	tests := []struct {
+		input  string
+		text   string
+		output string
+	}{
+		{"let x = 1 + 2; x", "3", ""},
+		{`puts("hi"); x * 2`, "6", "hi\n"},
+		{`print("a"); print("b")`, "", "ab"},
+		{`"text"`, `"text"`, ""},
+		{"[1, 2.5]", "[1, 2.5]", ""},
+		{"let y = x", "3", ""},
+		{"puts(y)", "", "3\n"},
+	}
+	for _, tt := range tests {
+		result, err := s.Exec(tt.input)
+		if err != nil {
+			t.Fatalf("Exec(%q) failed: %v", tt.input, err)
+		}
+		if result.Text != tt.text {
+			t.Errorf("Exec(%q) text = %q, want %q", tt.input, result.Text, tt.text)
+		}
+		if result.Output != tt.output {
+			t.Errorf("Exec(%q) output = %q, want %q", tt.input, result.Output, tt.output)
+		}
+	}
+	if result, _ := s.Exec("x + y"); result.Value == nil || result.Value.Inspect() != "6" {
+		t.Errorf("variables did not persist between Exec calls. got=%v", result.Value)
+	}
+}
+
+func TestSessionPrecision(t *testing.T) {
+	precise, rounded := NewSession(SessionOptions{}), NewSession(SessionOptions{})
+	if _, err := rounded.Exec("let PRECISION = 3"); err != nil {
+		t.Fatalf("setting PRECISION failed: %v", err)
+	}
+
+	// Each session echoes with its own precision, even when both run at once
+	var wg sync.WaitGroup
+	texts := make([]string, 2)
+	for i, s := range []*Session{precise, rounded} {
+		wg.Add(1)
+		go func(i int, s *Session) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := s.Exec("2.0 / 3.0")
+				if err != nil {
+					t.Errorf("Exec failed: %v", err)
+					return
+				}
+				texts[i] = result.Text
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	if texts[0] != "0.6666666666666666667" || texts[1] != "0.667" {
+		t.Errorf("wrong texts. got=%q", texts)
+	}
+}
+
+func TestSessionCloseRemovesTempFiles(t *testing.T) {
+	s := NewSession(SessionOptions{})
+	result, err := s.Exec("File.tempFile()")
+	if err != nil {
+		t.Fatalf("tempFile failed: %v", err)
+	}
+	name := result.Value.Inspect()
+	if _, err := os.Stat(name); err != nil {
+		t.Fatalf("%s was not created: %v", name, err)
+	}
+
+	s.Close()
+	if _, err := os.Stat(name); err == nil {
+		os.Remove(name)
+		t.Errorf("%s was not removed when the session closed", name)
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	s := NewSession(SessionOptions{})
+
+	tests := []struct {
+		input        string
+		kind         string
+		message      string
+		line, column int
+		output       string
+	}{
+		{"let = 1", evaluator.ErrorSyntax, "expected next token to be IDENT, got = instead\nno prefix parse function for = found", 1, 5, ""},
+		{"puts(\"before\");\nmissing", evaluator.ErrorRuntime, "identifier not found: missing", 2, 1, "before\n"},
+		{"let f = fn() { 1 + true };\nf()", evaluator.ErrorRuntime, "type mismatch: INTEGER + BOOLEAN", 1, 18, ""},
+		{`error("io", "disk full")`, "io", "disk full", 1, 6, ""},
+	}
+	for _, tt := range tests {
+		result, err := s.Exec(tt.input)
+		var rerr *evaluator.RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Errorf("Exec(%q) error = %v, want a RuntimeError", tt.input, err)
+			continue
+		}
+		if rerr.Kind != tt.kind || rerr.Message != tt.message || rerr.Line != tt.line || rerr.Column != tt.column {
+			t.Errorf("Exec(%q) error = %s %q at %d:%d, want %s %q at %d:%d", tt.input,
+				rerr.Kind, rerr.Message, rerr.Line, rerr.Column, tt.kind, tt.message, tt.line, tt.column)
+		}
+		if result.Value != nil || result.Output != tt.output {
+			t.Errorf("Exec(%q) result = %+v, want no value and output %q", tt.input, result, tt.output)
+		}
+	}
+
+	// A failed Exec leaves the session usable
+	if result, err := s.Exec("1 + 1"); err != nil || result.Text != "2" {
+		t.Errorf("Exec after errors = %+v, %v", result, err)
+	}
+}
+
+func TestSessionLimits(t *testing.T) {
+	limited := NewSession(SessionOptions{Options: evaluator.Options{Limits: evaluator.Limits{Steps: 100}}})
+	_, err := limited.Exec("while (true) {}")
+	if rerr, ok := err.(*evaluator.RuntimeError); !ok || rerr.Kind != evaluator.ErrorLimit {
+		t.Errorf("wrong error for the step limit. got=%v", err)
+	}
+
+	timed := NewSession(SessionOptions{Timeout: 10 * time.Millisecond})
+	_, err = timed.Exec("while (true) {}")
+	if rerr, ok := err.(*evaluator.RuntimeError); !ok || rerr.Kind != evaluator.ErrorInterrupt {
+		t.Errorf("wrong error for the timeout. got=%v", err)
+	}
+	if result, err := timed.Exec("1"); err != nil || result.Text != "1" {
+		t.Errorf("Exec after a timeout = %+v, %v", result, err)
+	}
+}
+
+func TestSessionVariables(t *testing.T) {
+	s := NewSession(SessionOptions{})
+	if vars := s.Variables(); len(vars) != 0 {
+		t.Errorf("new session has variables: %v", vars)
+	}
+
+	if _, err := s.Exec(`let name = "1y"; const answer = 42; let double = fn(x) { x * 2 }`); err != nil {
+		t.Fatal(err)
+	}
+	vars := s.Variables()
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "answer double name" {
+		t.Errorf("wrong variables. got=%s", got)
+	}
+	if vars["answer"].Inspect() != "42" || vars["name"].Inspect() != "1y" {
+		t.Errorf("wrong values. got answer=%s, name=%s", vars["answer"].Inspect(), vars["name"].Inspect())
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "saved.1y")
+
+	out := runREPL(t, "let x = 2\nmissing\nlet f = fn(n) {\n  n + x\n}\n:save "+saved+"\n")
+	if !strings.HasSuffix(out, "saved 2 commands to "+saved+"\n>> ") {
+		t.Errorf("wrong output of :save. got=%q", out)
+	}
+	content, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "let x = 2\nlet f = fn(n) {\n  n + x\n}\n"; string(content) != expected {
+		t.Errorf("wrong saved file.\nexpected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	// The opened file runs silently and becomes part of the session, so
+	// saving again keeps it
+	resaved := filepath.Join(dir, "resaved.1y")
+	out = runREPL(t, ":open "+saved+"\nf(1)\n:save "+resaved+"\n")
+	if !strings.Contains(out, "=> 3 : INTEGER") || strings.Contains(out, "FUNCTION") {
+		t.Errorf("wrong output of :open. got=%q", out)
+	}
+	content, _ = os.ReadFile(resaved)
+	if expected := "let x = 2\nlet f = fn(n) {\n  n + x\n}\nf(1)\n"; string(content) != expected {
+		t.Errorf("wrong file saved after :open.\nexpected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":open " + filepath.Join(dir, "missing.1y"), "Error reading " + filepath.Join(dir, "missing.1y")},
+		{":save", "usage: :save FILE"},
+		{":open a b", "usage: :open FILE"},
+		{":bogus x", "unknown command :bogus, expected :save, :open, :snapshot or :restore FILE"},
+		{":save " + filepath.Join(dir, "no", "dir.1y"), "Error writing " + filepath.Join(dir, "no", "dir.1y")},
+	}
+	for _, tt := range tests {
+		if out := runREPL(t, tt.input+"\n"); !strings.Contains(out, tt.expected) {
+			t.Errorf("%s: output = %q, want %q", tt.input, out, tt.expected)
+		}
+	}
+
+	// An opened file that fails reports where, and is not added
+	broken := filepath.Join(dir, "broken.1y")
+	os.WriteFile(broken, []byte("let y = 1\nnope\n"), 0644)
+	out = runREPL(t, ":open "+broken+"\n:save "+resaved+"\n")
+	if !strings.Contains(out, broken+":2:1") || !strings.Contains(out, "saved 0 commands") {
+		t.Errorf("wrong output of :open with an error. got=%q", out)
+	}
+}
@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"1ylang/evaluator"
+	"1ylang/parser"
+	"fmt"
+	"io"
+	"time"
+)
+
+// timing collects the time spent in each phase of running a program, so
+// that slow parsing can be told apart from slow evaluation.
+type timing struct {
+	start time.Time
+	phase time.Time // start of the phase in progress
+
+	lexing, parsing, evaluation time.Duration
+	in                          *evaluator.Interpreter
+	imports                     *evaluator.ImportTimes
+}
+
+// startTiming starts timing a program, and the modules it imports, until
+// stop or report is called. p, if not nil, is the parser that will read it,
+// and in is the interpreter that will run it.
+func startTiming(p *parser.Parser, in *evaluator.Interpreter) *timing {
+	if p != nil {
+		p.TimeLexing()
+	}
+	now := time.Now()
+	return &timing{start: now, phase: now, in: in, imports: in.StartImportTimes()}
+}
+
+// stop stops timing imports, for programs that fail before they run.
+func (t *timing) stop() {
+	t.in.StopImportTimes()
+}
+
+// parsed ends a parsing phase of p. The time spent lexing is counted apart
+// from the rest of it.
+func (t *timing) parsed(p *parser.Parser) {
+	now := time.Now()
+	// The parser's lexing time adds up over the phases
+	lexing := p.LexingTime() - t.lexing
+	t.lexing += lexing
+	t.parsing += now.Sub(t.phase) - lexing
+	t.phase = now
+}
+
+// evaluated ends an evaluation phase.
+func (t *timing) evaluated() {
+	now := time.Now()
+	t.evaluation += now.Sub(t.phase)
+	t.phase = now
+}
+
+// report stops timing and prints the time of each phase, then the total.
+// The time taken by imports, part of the evaluation, is shown under it.
+func (t *timing) report(out io.Writer) {
+	t.stop()
+	if t.lexing > 0 || t.parsing > 0 {
+		fmt.Fprintf(out, "Lexing: %v\n", t.lexing)
+		fmt.Fprintf(out, "Parsing: %v\n", t.parsing)
+	}
+	fmt.Fprintf(out, "Evaluation: %v\n", t.evaluation)
+	for _, m := range t.imports.Modules {
+		fmt.Fprintf(out, "  import %s: parse %v, run %v\n", m.Path, m.Parse, m.Run)
+	}
+	fmt.Fprintf(out, "Execution time: %v\n", time.Since(t.start))
+}
@@ -0,0 +1,76 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RecordFile, if not "", is a file the REPL appends each input that runs
+// without errors to, so that an interactive session can later be run as a
+// script or replayed.
+var RecordFile string
+
+// ReplayFile, if not "", is a file of inputs, such as one written through
+// RecordFile, that the REPL runs before the first prompt. Each input is
+// shown with the prompt and followed by its result, as if it were typed.
+var ReplayFile string
+
+// openRecord opens RecordFile for appending, or returns nil if there is
+// none or it cannot be opened, which is reported to out.
+func openRecord(out io.Writer) io.WriteCloser {
+	if RecordFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(RecordFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(out, "Error opening record file %s: %v\n", RecordFile, err)
+		return nil
+	}
+	return f
+}
+
+// splitInputs splits src into the inputs the REPL would have run had it
+// been typed in line by line.
+func splitInputs(src string) []string {
+	var inputs []string
+	input := ""
+	for _, line := range strings.Split(strings.TrimRight(src, "\n"), "\n") {
+		if input == "" {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			input = line
+		} else {
+			input += "\n" + line
+		}
+		if line != "" && incomplete(input) {
+			continue
+		}
+		inputs = append(inputs, input)
+		input = ""
+	}
+	if input != "" {
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// replay runs the inputs of ReplayFile, showing each one and its result.
+// run runs a single input.
+func replay(out io.Writer, prompt func() string, run func(input string)) {
+	content, err := os.ReadFile(ReplayFile)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading replay file %s: %v\n", ReplayFile, err)
+		return
+	}
+	for _, input := range splitInputs(string(content)) {
+		shown := input
+		if Color {
+			shown = colorize(input)
+		}
+		fmt.Fprintln(out, prompt()+strings.ReplaceAll(shown, "\n", "\n"+CONTINUATION))
+		run(input)
+	}
+}
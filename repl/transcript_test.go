@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runREPL runs the REPL on input, without an init file, and returns what it
+// printed.
+func runREPL(t *testing.T, input string) string {
+	t.Helper()
+	defer func(file string) { InitFile = file }(InitFile)
+	InitFile = ""
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, false)
+	return out.String()
+}
+
+func TestSplitInputs(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected []string
+	}{
+		{"", nil},
+		{"1\n2\n", []string{"1", "2"}},
+		{"\n\nlet x = 1\n\n", []string{"let x = 1"}},
+		{"let f = fn(x) {\n  x\n}\nf(1)", []string{"let f = fn(x) {\n  x\n}", "f(1)"}},
+		{"let s = [1,\n\n2]", []string{"let s = [1,\n", "2]"}},
+		{"if (true) {", []string{"if (true) {"}},
+	}
+
+	for _, tt := range tests {
+		got := splitInputs(tt.src)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") || len(got) != len(tt.expected) {
+			t.Errorf("splitInputs(%q) = %q, want %q", tt.src, got, tt.expected)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	defer func(record, replay string) { RecordFile, ReplayFile = record, replay }(RecordFile, ReplayFile)
+	RecordFile = filepath.Join(dir, "session.1y")
+
+	runREPL(t, "let x = 2\nmissing\nlet double = fn(n) {\n  n * 2\n}\n\n:save "+filepath.Join(dir, "saved.1y")+"\ndouble(x)\n")
+	runREPL(t, "x\nputs(\"again\");\n")
+	content, err := os.ReadFile(RecordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inputs that fail and commands are left out, and later sessions append
+	expected := "let x = 2\nlet double = fn(n) {\n  n * 2\n}\ndouble(x)\nputs(\"again\");\n"
+	if string(content) != expected {
+		t.Errorf("wrong record.\nexpected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	RecordFile = ""
+	ReplayFile = filepath.Join(dir, "session.1y")
+	out := runREPL(t, "double(x) + 1\n")
+	expected = ">> let x = 2\n=> 2 : INTEGER\n>> let double = fn(n) {\n..   n * 2\n.. }\n=> fn(n) {\n(n * 2)\n} : FUNCTION\n" +
+		">> double(x)\n=> 4 : INTEGER\n>> puts(\"again\");\nagain\n>> => 5 : INTEGER\n>> "
+	if out != expected {
+		t.Errorf("wrong replay.\nexpected:\n%q\ngot:\n%q", expected, out)
+	}
+
+	ReplayFile = filepath.Join(dir, "missing.1y")
+	if out := runREPL(t, ""); !strings.HasPrefix(out, "Error reading replay file "+ReplayFile) {
+		t.Errorf("missing replay file not reported. got=%q", out)
+	}
+}
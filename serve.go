@@ -0,0 +1,326 @@
+package main
+
+import (
+	"1ylang/evaluator"
+	"1ylang/object"
+	"1ylang/repl"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRequestSize bounds the body of a request to the server.
+const maxRequestSize = 1 << 20
+
+// Error codes of JSON-RPC 2.0.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+
+	// rpcServerError is the first of the codes left to servers to define
+	rpcServerError = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// sessionParams are the parameters of every method: the session it applies
+// to, created on first use, with the code to evaluate or the variable to
+// inspect.
+type sessionParams struct {
+	Session string `json:"session"`
+	Code    string `json:"code,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// evalResult is the result of evaluate. A program that fails is not an
+// error of the call, so what it printed is still returned.
+type evalResult struct {
+	Value  string     `json:"value,omitempty"`
+	Type   string     `json:"type,omitempty"`
+	Output string     `json:"output"`
+	Error  *evalError `json:"error,omitempty"`
+}
+
+type evalError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+type variable struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// server answers JSON-RPC calls, running code in one repl.Session per
+// session name. Each session has an interpreter with limits of its own and
+// a lock, so calls to different sessions run at once while those to one
+// session run one at a time. At most maxSessions are open, and those unused
+// for idle are closed.
+type server struct {
+	options     repl.SessionOptions
+	limits      evaluator.Limits
+	maxSessions int
+	idle        time.Duration
+
+	mu       sync.Mutex // guards sessions and their lastUsed and calls
+	sessions map[string]*serverSession
+}
+
+// serverSession is a session of the server, locked while a call runs in it.
+type serverSession struct {
+	mu      sync.Mutex
+	session *repl.Session
+	closed  bool // once it was reset or expired, guarded by mu
+
+	lastUsed time.Time
+	calls    int // running or waiting for mu
+}
+
+// serve runs `1y serve [-addr addr] [-sandbox=false] [-timeout d]
+// [-max-steps n] [-max-memory size] [-max-sessions n] [-idle d]`, serving the evaluate, reset and inspect
+// methods over JSON-RPC 2.0 at HTTP POST requests to /.
+func serve(args []string) error {
+	s, addr, err := newServer(args)
+	if err != nil {
+		return err
+	}
+	return http.ListenAndServe(addr, s)
+}
+
+// newServer creates a server configured by the flags of serve in args,
+// returning it with the address to listen on.
+func newServer(args []string) (*server, string, error) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":7411", "Listen on this address")
+	sandbox := fs.Bool("sandbox", true, "Run code in sandbox mode, without import, file access and the exit, getenv and setenv builtins")
+	timeout := fs.Duration("timeout", 5*time.Second, "Stop each evaluation that runs longer than this, or never if 0")
+	maxSteps := fs.Int("max-steps", 0, "Stop each evaluation after this many steps, or never if 0")
+	maxMemory := fs.String("max-memory", "", "Stop an evaluation once the interpreter uses more memory than this, e.g. 256MB")
+	maxSessions := fs.Int("max-sessions", 1000, "Refuse to open more sessions than this at once, or never if 0")
+	idle := fs.Duration("idle", 30*time.Minute, "Close sessions unused for this long, or never if 0")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return nil, "", fmt.Errorf("usage: 1y serve [flags]")
+	}
+
+	s := &server{sessions: make(map[string]*serverSession), maxSessions: *maxSessions, idle: *idle}
+	s.options.Sandbox = *sandbox
+	s.options.Timeout = *timeout
+	s.limits.Steps = *maxSteps
+	if *maxMemory != "" {
+		size, err := parseSize(*maxMemory)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid value %q for -max-memory: %v", *maxMemory, err)
+		}
+		s.limits.Memory = size
+	}
+	return s, *addr, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req rpcRequest
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	switch {
+	case err != nil:
+		resp.Error = &rpcError{rpcParseError, err.Error()}
+	case req.JSONRPC != "2.0" || req.Method == "":
+		resp.Error = &rpcError{rpcInvalidRequest, "expected a JSON-RPC 2.0 request"}
+	default:
+		if req.ID != nil {
+			resp.ID = req.ID
+		}
+		resp.Result, resp.Error = s.call(req.Method, req.Params)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// call runs method with params, returning its result or the error of the
+// call.
+func (s *server) call(method string, params json.RawMessage) (interface{}, *rpcError) {
+	var p sessionParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+
+	switch method {
+	case "reset":
+		s.remove(p.Session)
+		return true, nil
+	case "evaluate", "inspect":
+	default:
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("unknown method %q, expected evaluate, reset or inspect", method)}
+	}
+
+	ss, rerr := s.acquire(p.Session)
+	if rerr != nil {
+		return nil, rerr
+	}
+	defer s.release(ss)
+	session := ss.session
+	if method == "evaluate" {
+		return s.evaluate(session, p.Code), nil
+	}
+
+	vars := session.Variables()
+	if p.Name != "" {
+		value, ok := vars[p.Name]
+		if !ok {
+			return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("%s is not defined", p.Name)}
+		}
+		return describe(session, p.Name, value), nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]variable, len(names))
+	for i, name := range names {
+		list[i] = describe(session, name, vars[name])
+	}
+	return list, nil
+}
+
+// acquire returns the session named name, creating it if there is none,
+// with its lock held until release.
+func (s *server) acquire(name string) (*serverSession, *rpcError) {
+	for {
+		s.mu.Lock()
+		expired := s.expire(time.Now())
+		ss, ok := s.sessions[name]
+		if !ok && s.maxSessions > 0 && len(s.sessions) >= s.maxSessions {
+			s.mu.Unlock()
+			closeSessions(expired)
+			return nil, &rpcError{rpcServerError, fmt.Sprintf("too many sessions: at most %d can be open", s.maxSessions)}
+		}
+		if !ok {
+			ss = &serverSession{session: repl.NewSession(s.options)}
+			s.sessions[name] = ss
+		}
+		ss.calls++
+		s.mu.Unlock()
+		closeSessions(expired)
+
+		ss.mu.Lock()
+		if !ss.closed {
+			return ss, nil
+		}
+		// It was reset while the call waited, so the call gets a new one
+		ss.mu.Unlock()
+		s.mu.Lock()
+		ss.calls--
+		s.mu.Unlock()
+	}
+}
+
+// release unlocks ss after a call.
+func (s *server) release(ss *serverSession) {
+	s.mu.Lock()
+	ss.calls--
+	ss.lastUsed = time.Now()
+	s.mu.Unlock()
+	ss.mu.Unlock()
+}
+
+// remove closes the session named name, if there is one, once the calls
+// running in it end.
+func (s *server) remove(name string) {
+	s.mu.Lock()
+	ss, ok := s.sessions[name]
+	delete(s.sessions, name)
+	s.mu.Unlock()
+	if ok {
+		closeSessions([]*serverSession{ss})
+	}
+}
+
+// expire removes the sessions that have been unused for longer than
+// s.idle, returning them to be closed. The caller must hold s.mu.
+func (s *server) expire(now time.Time) []*serverSession {
+	if s.idle <= 0 {
+		return nil
+	}
+	var expired []*serverSession
+	for name, ss := range s.sessions {
+		if ss.calls == 0 && now.Sub(ss.lastUsed) > s.idle {
+			delete(s.sessions, name)
+			expired = append(expired, ss)
+		}
+	}
+	return expired
+}
+
+// closeSessions closes sessions that have been removed from the server.
+func closeSessions(sessions []*serverSession) {
+	for _, ss := range sessions {
+		ss.mu.Lock()
+		ss.closed = true
+		ss.session.Close()
+		ss.mu.Unlock()
+	}
+}
+
+// evaluate runs code in session, with the limits counted from zero. The
+// caller must hold the lock of the session.
+func (s *server) evaluate(session *repl.Session, code string) evalResult {
+	in := session.Interpreter()
+	in.SetLimits(s.limits)
+	defer in.SetLimits(evaluator.Limits{})
+
+	result, err := session.Exec(code)
+	r := evalResult{Value: result.Text, Output: result.Output}
+	if result.Value != nil && result.Value.Type() != object.NULL_OBJ {
+		r.Type = string(result.Value.Type())
+	}
+	var rerr *evaluator.RuntimeError
+	if errors.As(err, &rerr) {
+		r.Error = &evalError{rerr.Kind, rerr.Message, rerr.Line, rerr.Column}
+	}
+	return r
+}
+
+// describe returns the variable name of session holding value.
+func describe(session *repl.Session, name string, value object.Object) variable {
+	return variable{name, string(value.Type()), session.Text(value)}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rpc posts body to the server at url and decodes the response.
+func rpc(t *testing.T, url, body string) (result json.RawMessage, rerr *rpcError) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return decoded.Result, decoded.Error
+}
+
+func startServer(t *testing.T, args ...string) *httptest.Server {
+	s, _, err := newServer(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestServeEvaluate(t *testing.T) {
+	ts := startServer(t)
+
+	tests := []struct {
+		session, code string
+		expected      string
+	}{
+		{"a", `let x = 40; puts("hi"); x + 2`, `{"value":"42","type":"INTEGER","output":"hi\n"}`},
+		{"a", `x`, `{"value":"40","type":"INTEGER","output":""}`},
+		{"b", `x`, `{"output":"","error":{"kind":"runtime","message":"identifier not found: x","line":1,"column":1}}`},
+		{"a", `let = 1`, `{"output":"","error":{"kind":"syntax","message":"expected next token to be IDENT, got = instead\nno prefix parse function for = found","line":1,"column":5}}`},
+		{"a", `"s"`, `{"value":"\"s\"","type":"STRING","output":""}`},
+	}
+	for _, tt := range tests {
+		params, _ := json.Marshal(sessionParams{Session: tt.session, Code: tt.code})
+		result, rerr := rpc(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"evaluate","params":`+string(params)+`}`)
+		if rerr != nil {
+			t.Fatalf("evaluate %q failed: %+v", tt.code, rerr)
+		}
+		if string(result) != tt.expected {
+			t.Errorf("evaluate %q = %s, want %s", tt.code, result, tt.expected)
+		}
+	}
+}
+
+func TestServeInspectAndReset(t *testing.T) {
+	ts := startServer(t)
+	call := func(method, params string) (string, *rpcError) {
+		result, rerr := rpc(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":`+params+`}`)
+		return string(result), rerr
+	}
+
+	call("evaluate", `{"session":"s","code":"let b = [1, \"x\"]; let a = 1.5"}`)
+	if got, _ := call("inspect", `{"session":"s"}`); got != `[{"name":"a","type":"FLOAT","value":"1.5"},{"name":"b","type":"ARRAY","value":"[1, \"x\"]"}]` {
+		t.Errorf("inspect = %s", got)
+	}
+	if got, _ := call("inspect", `{"session":"s","name":"a"}`); got != `{"name":"a","type":"FLOAT","value":"1.5"}` {
+		t.Errorf("inspect a = %s", got)
+	}
+	if _, rerr := call("inspect", `{"session":"s","name":"c"}`); rerr == nil || rerr.Code != rpcInvalidParams || rerr.Message != "c is not defined" {
+		t.Errorf("inspect of an undefined variable = %+v", rerr)
+	}
+
+	if got, _ := call("reset", `{"session":"s"}`); got != "true" {
+		t.Errorf("reset = %s", got)
+	}
+	if got, _ := call("inspect", `{"session":"s"}`); got != "[]" {
+		t.Errorf("inspect after reset = %s", got)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	ts := startServer(t)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"run"}`, rpcMethodNotFound},
+		{`{"jsonrpc":"2.0","id":1,"method":"evaluate","params":{"code":1}}`, rpcInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"evaluate","params":[]}`, rpcInvalidParams},
+		{`{"jsonrpc":"1.0","id":1,"method":"evaluate"}`, rpcInvalidRequest},
+		{`{"jsonrpc":`, rpcParseError},
+	}
+	for _, tt := range tests {
+		if _, rerr := rpc(t, ts.URL, tt.body); rerr == nil || rerr.Code != tt.code {
+			t.Errorf("%s: error = %+v, want code %d", tt.body, rerr, tt.code)
+		}
+	}
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d", resp.StatusCode)
+	}
+}
+
+func TestServeLimits(t *testing.T) {
+	evaluate := func(ts *httptest.Server, code string) evalResult {
+		params, _ := json.Marshal(sessionParams{Session: "s", Code: code})
+		result, rerr := rpc(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"evaluate","params":`+string(params)+`}`)
+		if rerr != nil {
+			t.Fatalf("evaluate %q failed: %+v", code, rerr)
+		}
+		var r evalResult
+		if err := json.Unmarshal(result, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	errorOf := func(r evalResult) string {
+		if r.Error == nil {
+			return ""
+		}
+		return r.Error.Kind + ": " + r.Error.Message
+	}
+
+	// Sandboxed by default
+	sandboxed := startServer(t)
+	for code, expected := range map[string]string{
+		`exit(1)`:         "runtime: `exit` is not allowed in sandbox mode",
+		`File.tempFile()`: "runtime: identifier not found: File",
+		`import("x")`:     "runtime: `import` is not allowed in sandbox mode",
+	} {
+		if got := errorOf(evaluate(sandboxed, code)); got != expected {
+			t.Errorf("sandboxed %s: error = %q, want %q", code, got, expected)
+		}
+	}
+
+	limited := startServer(t, "-max-steps", "100", "-sandbox=false")
+	if got := errorOf(evaluate(limited, "while (true) {}")); got != "limit: resource limit exceeded: more than 100 evaluation steps" {
+		t.Errorf("step limit: error = %q", got)
+	}
+	// Steps are counted from zero for each evaluation
+	evaluate(limited, "let n = 0")
+	for i := 0; i < 3; i++ {
+		if r := evaluate(limited, "n = 0; for (let i = 0; i < 5; i++) { n = n + i } n"); r.Error != nil || r.Value != "10" {
+			t.Errorf("evaluation within the limit = %+v, %q", r, errorOf(r))
+		}
+	}
+	if got := errorOf(evaluate(limited, `File`)); got != "" {
+		t.Errorf("File not available with -sandbox=false: %q", got)
+	}
+
+	timed := startServer(t, "-timeout", "20ms")
+	if got := errorOf(evaluate(timed, "while (true) {}")); got != "interrupt: timeout: execution took longer than 20ms" {
+		t.Errorf("timeout: error = %q", got)
+	}
+}
+
+func TestServeSessions(t *testing.T) {
+	call := func(ts *httptest.Server, session, code string) (string, *rpcError) {
+		params, _ := json.Marshal(sessionParams{Session: session, Code: code})
+		result, rerr := rpc(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"evaluate","params":`+string(params)+`}`)
+		return string(result), rerr
+	}
+
+	// A slow session does not hold up the others
+	ts := startServer(t, "-timeout", "2s")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		call(ts, "slow", "while (true) {}")
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	if got, _ := call(ts, "fast", "1"); got != `{"value":"1","type":"INTEGER","output":""}` {
+		t.Errorf("evaluate in another session = %s", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("evaluate waited %v for another session", elapsed)
+	}
+	<-done
+
+	// Sessions beyond the cap are refused until one is reset
+	capped := startServer(t, "-max-sessions", "2")
+	call(capped, "a", "1")
+	call(capped, "b", "1")
+	if _, rerr := call(capped, "c", "1"); rerr == nil || rerr.Code != rpcServerError || rerr.Message != "too many sessions: at most 2 can be open" {
+		t.Errorf("third session: error = %+v", rerr)
+	}
+	if _, rerr := call(capped, "a", "2"); rerr != nil {
+		t.Errorf("open session refused: %+v", rerr)
+	}
+	rpc(t, capped.URL, `{"jsonrpc":"2.0","id":1,"method":"reset","params":{"session":"a"}}`)
+	if _, rerr := call(capped, "c", "1"); rerr != nil {
+		t.Errorf("session refused after a reset: %+v", rerr)
+	}
+
+	// Idle sessions are closed and start again empty
+	idle := startServer(t, "-idle", "10ms")
+	call(idle, "s", "let x = 1")
+	time.Sleep(50 * time.Millisecond)
+	call(idle, "other", "1")
+	if got, _ := call(idle, "s", "x"); !strings.Contains(got, "identifier not found: x") {
+		t.Errorf("idle session kept its variables: %s", got)
+	}
+}
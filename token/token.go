@@ -5,11 +5,14 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line the token starts on, 0 if unknown
+	Column  int // 1-based byte offset in its line the token starts at, 0 if unknown
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only returned by lexers asked to, see lexer.EmitComments
 
 	// Identifiers + literals
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
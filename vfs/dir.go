@@ -0,0 +1,88 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is a file system jailed in a directory of the operating system, as
+// with chroot: the name "/a.txt", like "a.txt", is the file a.txt of the
+// directory, ".." does not go above it, and symbolic links leading out of
+// it cannot be followed. Names of temporary files start with a slash.
+type Dir string
+
+// path returns the file of the operating system name stands for, or an
+// error if it lies outside the directory through a symbolic link.
+func (d Dir) path(op, name string) (string, error) {
+	root, err := filepath.EvalSymlinks(string(d))
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	file := filepath.Join(root, filepath.FromSlash(clean(name)))
+
+	// The longest part of the path that exists must resolve into the
+	// directory; what follows is created by the operation, if at all
+	existing, rest := file, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+				return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+			}
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || existing == root {
+			return "", &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		existing, rest = filepath.Dir(existing), filepath.Join(filepath.Base(existing), rest)
+	}
+}
+
+func (d Dir) Stat(name string) (fs.FileInfo, error) {
+	file, err := d.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	return info, rename(err, name)
+}
+
+func (d Dir) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(file)
+	return entries, rename(err, name)
+}
+
+func (d Dir) RemoveAll(name string) error {
+	file, err := d.path("remove", name)
+	if err != nil {
+		return err
+	}
+	if root, _ := d.path("remove", "/"); file == root {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	return rename(os.RemoveAll(file), name)
+}
+
+func (d Dir) CreateTemp(pattern string) (string, error) {
+	f, err := os.CreateTemp(string(d), pattern)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return "/" + filepath.Base(f.Name()), nil
+}
+
+func (d Dir) MkdirTemp(pattern string) (string, error) {
+	dir, err := os.MkdirTemp(string(d), pattern)
+	if err != nil {
+		return "", err
+	}
+	return "/" + filepath.Base(dir), nil
+}
@@ -0,0 +1,221 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem is a file system held in memory, which starts out empty. Its names
+// are slash-separated paths from its root, whether they start with a slash
+// or not, and directories are made as files are written into them. It is
+// safe for concurrent use.
+type Mem struct {
+	mu    sync.Mutex
+	files map[string][]byte // contents by clean name
+	dirs  map[string]bool   // directories made by MkdirTemp, which may be empty
+	temps int               // temporary names made so far
+}
+
+// NewMem creates an empty file system in memory.
+func NewMem() *Mem {
+	return &Mem{files: make(map[string][]byte), dirs: make(map[string]bool)}
+}
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+// WriteFile creates the file name, or replaces its contents, to fill the
+// file system before scripts use it.
+func (m *Mem) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file := clean(name)
+	if err := m.writable(file); err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	m.files[file] = append([]byte(nil), data...)
+	return nil
+}
+
+// writable returns why the file cannot be written, if it cannot: it is a
+// directory or a directory it would be in is a file. The caller must hold
+// m.mu.
+func (m *Mem) writable(file string) error {
+	if m.isDir(file) {
+		return errIsDir
+	}
+	for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return errNotDir
+		}
+	}
+	return nil
+}
+
+// isDir reports whether the clean name is a directory: the root, one made
+// by MkdirTemp, or one holding files. The caller must hold m.mu.
+func (m *Mem) isDir(name string) bool {
+	if name == "." || m.dirs[name] {
+		return true
+	}
+	prefix := name + "/"
+	for file := range m.files {
+		if strings.HasPrefix(file, prefix) {
+			return true
+		}
+	}
+	for dir := range m.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file := clean(name)
+	if data, ok := m.files[file]; ok {
+		return memInfo{path.Base(file), int64(len(data)), false}, nil
+	}
+	if m.isDir(file) {
+		return memInfo{path.Base(file), 0, true}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir := clean(name)
+	if !m.isDir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	children := make(map[string]fs.FileInfo)
+	add := func(name string, size int64, isDir bool) {
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+		child, rest, nested := strings.Cut(name[len(prefix):], "/")
+		if nested || isDir {
+			children[child] = memInfo{child, 0, true}
+		} else if rest == "" {
+			children[child] = memInfo{child, size, false}
+		}
+	}
+	for file, data := range m.files {
+		add(file, int64(len(data)), false)
+	}
+	for d := range m.dirs {
+		add(d, 0, true)
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *Mem) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file := clean(name)
+	if file == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	delete(m.files, file)
+	delete(m.dirs, file)
+	prefix := file + "/"
+	for f := range m.files {
+		if strings.HasPrefix(f, prefix) {
+			delete(m.files, f)
+		}
+	}
+	for d := range m.dirs {
+		if strings.HasPrefix(d, prefix) {
+			delete(m.dirs, d)
+		}
+	}
+	return nil
+}
+
+func (m *Mem) CreateTemp(pattern string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.tempName("createtemp", pattern)
+	if err != nil {
+		return "", err
+	}
+	m.files[name] = nil
+	return "/" + name, nil
+}
+
+func (m *Mem) MkdirTemp(pattern string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, err := m.tempName("mkdirtemp", pattern)
+	if err != nil {
+		return "", err
+	}
+	m.dirs[name] = true
+	return "/" + name, nil
+}
+
+// tempName returns an unused name in the root made from pattern as
+// os.CreateTemp does, with the last '*', if any, replaced by a number. The
+// caller must hold m.mu.
+func (m *Mem) tempName(op, pattern string) (string, error) {
+	if strings.Contains(pattern, "/") {
+		return "", &fs.PathError{Op: op, Path: pattern, Err: errors.New("pattern contains path separator")}
+	}
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for {
+		m.temps++
+		name := prefix + strconv.Itoa(m.temps) + suffix
+		if _, ok := m.files[name]; !ok && !m.isDir(name) {
+			return name, nil
+		}
+	}
+}
+
+// memInfo describes a file or directory of a Mem.
+type memInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.isDir }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
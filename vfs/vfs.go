@@ -0,0 +1,118 @@
+// Package vfs provides the file systems the File, Glob and Watch libraries
+// work on. An interpreter uses the real one by default, and embedders can
+// give it another to keep scripts from reaching the files they should not:
+// a directory it cannot leave, memory only, or read-only access.
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FS is a file system scripts can list and make temporary files in. Names
+// are paths as the script gives them. Errors are *fs.PathError values
+// wrapping errors such as fs.ErrNotExist and fs.ErrPermission.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of the directory name, sorted by name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// RemoveAll removes name and everything in it. A name that does not
+	// exist is not an error.
+	RemoveAll(name string) error
+	// CreateTemp creates an empty, uniquely named file and returns its
+	// name. A '*' in pattern is replaced by the random part of the name.
+	CreateTemp(pattern string) (string, error)
+	// MkdirTemp creates a uniquely named directory like CreateTemp.
+	MkdirTemp(pattern string) (string, error)
+}
+
+// OS is the file system of the operating system, with names relative to
+// the current directory.
+type OS struct{}
+
+func (OS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (OS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (OS) RemoveAll(name string) error { return os.RemoveAll(name) }
+
+func (OS) CreateTemp(pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+func (OS) MkdirTemp(pattern string) (string, error) { return os.MkdirTemp("", pattern) }
+
+// reader is the part of FS that reads files.
+type reader interface {
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+}
+
+// readOnly is a file system that refuses to change the files of its reader.
+type readOnly struct {
+	reader
+}
+
+// ReadOnly returns a view of fsys that can be read but not changed: removing
+// or creating files fails with fs.ErrPermission.
+func ReadOnly(fsys FS) FS {
+	return readOnly{fsys}
+}
+
+func (readOnly) RemoveAll(name string) error { return denied("remove", name) }
+
+func (readOnly) CreateTemp(pattern string) (string, error) { return "", denied("createtemp", pattern) }
+
+func (readOnly) MkdirTemp(pattern string) (string, error) { return "", denied("mkdirtemp", pattern) }
+
+func denied(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// fromFS reads the files of an fs.FS.
+type fromFS struct {
+	fsys fs.FS
+}
+
+// FromFS returns a read-only file system holding the files of fsys, such
+// as an embed.FS. Names are slash-separated and taken from the root of
+// fsys, whether they start with a slash or not.
+func FromFS(fsys fs.FS) FS {
+	return readOnly{fromFS{fsys}}
+}
+
+func (f fromFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(f.fsys, clean(name))
+	return info, rename(err, name)
+}
+
+func (f fromFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(f.fsys, clean(name))
+	return entries, rename(err, name)
+}
+
+// clean returns name as a path of an fs.FS: slash-separated, relative to the
+// root and without any ".." that would leave it.
+func clean(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	if name == "/" {
+		return "."
+	}
+	return name[1:]
+}
+
+// rename replaces the path of err, if it is an *fs.PathError, with name as
+// the script gave it.
+func rename(err error, name string) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testFS checks the operations of a writable file system holding the file
+// a/b.txt of 7 bytes and nothing else.
+func testFS(t *testing.T, fsys FS) {
+	t.Helper()
+	entries, err := fsys.ReadDir("/")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "a" || !entries[0].IsDir() {
+		t.Fatalf("ReadDir returned %v, %v", entries, err)
+	}
+	info, err := fsys.Stat("a/../a/b.txt")
+	if err != nil || info.Size() != 7 || info.IsDir() {
+		t.Fatalf("Stat returned %v, %v", info, err)
+	}
+
+	temp, err := fsys.CreateTemp("x-*.txt")
+	if err != nil || !strings.HasPrefix(filepath.Base(temp), "x-") || !strings.HasSuffix(temp, ".txt") {
+		t.Fatalf("CreateTemp returned %q, %v", temp, err)
+	}
+	if _, err := fsys.Stat(temp); err != nil {
+		t.Errorf("temporary file %s was not created: %v", temp, err)
+	}
+	dir, err := fsys.MkdirTemp("")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	if info, err := fsys.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("temporary directory %s was not created: %v, %v", dir, info, err)
+	}
+
+	for _, name := range []string{"a", temp, dir} {
+		if err := fsys.RemoveAll(name); err != nil {
+			t.Fatalf("RemoveAll(%s) failed: %v", name, err)
+		}
+		if _, err := fsys.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s not to exist after RemoveAll, got %v", name, err)
+		}
+	}
+	if _, err := fsys.Stat("a/b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the removed file not to exist, got %v", err)
+	}
+	if err := fsys.RemoveAll("/"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected removing the root to be refused, got %v", err)
+	}
+}
+
+func TestMem(t *testing.T) {
+	m := NewMem()
+	m.WriteFile("/a/b.txt", []byte("one two"))
+	testFS(t, m)
+
+	m.WriteFile("f", []byte("x"))
+	if err := m.WriteFile("f/g", nil); err == nil {
+		t.Errorf("expected writing into a file to fail")
+	}
+}
+
+func TestDir(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "a"), 0755)
+	os.WriteFile(filepath.Join(root, "a", "b.txt"), []byte("one two"), 0644)
+	testFS(t, Dir(root))
+
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0644)
+	os.Symlink(outside, filepath.Join(root, "link"))
+
+	d := Dir(root)
+	if _, err := d.Stat("../" + filepath.Base(outside) + "/secret"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected .. not to leave the directory, got %v", err)
+	}
+	if _, err := d.Stat("link/secret"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected the link out of the directory to be refused, got %v", err)
+	}
+	if err := d.RemoveAll("link/secret"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected removing through the link to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+		t.Errorf("a file was removed outside the directory")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys := FromFS(fstest.MapFS{"dir/a.txt": {Data: []byte("a")}})
+	if info, err := fsys.Stat("/dir/a.txt"); err != nil || info.Size() != 1 {
+		t.Errorf("Stat returned %v, %v", info, err)
+	}
+	if entries, err := fsys.ReadDir("dir"); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir returned %v, %v", entries, err)
+	}
+	if _, err := fsys.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing file, got %v", err)
+	}
+
+	m := NewMem()
+	m.WriteFile("a", []byte("a"))
+	for _, fsys := range []FS{fsys, ReadOnly(m)} {
+		if err := fsys.RemoveAll("dir"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected RemoveAll to be refused, got %v", err)
+		}
+		if _, err := fsys.CreateTemp(""); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected CreateTemp to be refused, got %v", err)
+		}
+		if _, err := fsys.MkdirTemp(""); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected MkdirTemp to be refused, got %v", err)
+		}
+	}
+	if _, err := m.Stat("a"); err != nil {
+		t.Errorf("ReadOnly changed the file system")
+	}
+}
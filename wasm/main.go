@@ -0,0 +1,154 @@
+//go:build js && wasm
+
+// Command wasm is the interpreter built for the browser, so a playground can
+// run 1y code entirely client-side. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o 1y.wasm ./wasm
+//
+// and load it with the wasm_exec.js that comes with Go. It sets a global
+// oney object with these functions:
+//
+//	oney.evaluate(code, maxSteps?)  runs code in the current environment and
+//	                                returns {output, result, error}
+//	oney.reset()                    starts again with an empty environment
+//	oney.addModule(name, source)    makes import(name) load source
+//
+// The browser has no files, terminal or standard input, so output is
+// captured and returned, input() reads nothing and import only finds the
+// modules added. A step limit keeps a runaway loop from hanging the page,
+// exit() ends only the code being evaluated, and getenv and setenv fail.
+package main
+
+import (
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/lib"
+	"1ylang/object"
+	"1ylang/parser"
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall/js"
+)
+
+var (
+	in      *evaluator.Interpreter
+	env     *object.Environment
+	output  bytes.Buffer
+	modules = map[string][]byte{}
+
+	// exitCode is set by exit, which interrupts the interpreter to stop
+	// the evaluation in progress instead of ending the process
+	exitCode *int64
+)
+
+// builtins replace those that would reach outside the page.
+var builtins = map[string]*object.Builtin{
+	"exit": {Fn: func(args ...object.Object) object.Object {
+		var code int64
+		if len(args) > 1 {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=0 or 1", len(args))}
+		}
+		if len(args) == 1 {
+			integer, ok := args[0].(*object.Integer)
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("argument to `exit` must be INTEGER, got %s", args[0].Type())}
+			}
+			code = integer.Value.Int64()
+		}
+		exitCode = &code
+		in.Interrupt()
+		return evaluator.NULL
+	}},
+	"getenv": unavailable("getenv"),
+	"setenv": unavailable("setenv"),
+}
+
+// unavailable returns a builtin standing in for name, which the browser
+// cannot provide.
+func unavailable(name string) *object.Builtin {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.Error{Message: fmt.Sprintf("`%s` is not available in the browser", name)}
+	}}
+}
+
+func main() {
+	reset()
+
+	js.Global().Set("oney", js.ValueOf(map[string]interface{}{
+		"evaluate": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) == 0 {
+				return js.ValueOf(map[string]interface{}{"output": "", "result": nil, "error": "evaluate needs code to run"})
+			}
+			maxSteps := 0
+			if len(args) > 1 && args[1].Type() == js.TypeNumber {
+				maxSteps = args[1].Int()
+			}
+			return js.ValueOf(evaluate(args[0].String(), maxSteps))
+		}),
+		"reset": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			reset()
+			return nil
+		}),
+		"addModule": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) == 2 {
+				modules[args[0].String()] = []byte(args[1].String())
+			}
+			return nil
+		}),
+	}))
+
+	// The functions above are called from JavaScript for as long as the
+	// page is open
+	select {}
+}
+
+// reset replaces the environment with one holding only the builtins and the
+// libraries that work in a browser.
+func reset() {
+	in = evaluator.New(evaluator.Options{
+		Builtins: builtins,
+		Loader:   evaluator.MapLoader(modules),
+		Stdout:   &output,
+		Stderr:   &output,
+		Stdin:    strings.NewReader(""),
+	})
+	env = in.NewEnvironment()
+	lib.RegisterStringFuncs(env)
+	lib.RegisterArrayFuncs(env)
+	lib.RegisterMathFuncs(env)
+	lib.RegisterPackFuncs(env)
+	lib.RegisterFunctionalFuncs(env)
+	lib.RegisterTimeFuncs(env)
+	lib.RegisterTermFuncs(env)
+}
+
+// evaluate runs code, stopping it after maxSteps nodes if that is positive,
+// and returns what it printed, the value it ended with and its error.
+func evaluate(code string, maxSteps int) map[string]interface{} {
+	output.Reset()
+	exitCode = nil
+	in.ClearInterrupt()
+	in.SetLimits(evaluator.Limits{Steps: maxSteps})
+	result := map[string]interface{}{"result": nil, "error": nil}
+
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		result["error"] = "syntax error: " + strings.Join(errors, "\n")
+	} else {
+		evaluated := evaluator.Eval(program, env)
+		if exitCode != nil {
+			if *exitCode != 0 {
+				result["error"] = fmt.Sprintf("exit status %d", *exitCode)
+			}
+		} else if err, ok := evaluated.(*object.Error); ok {
+			result["error"] = err.Inspect()
+		} else if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
+			result["result"] = evaluated.Inspect()
+		}
+	}
+
+	result["output"] = output.String()
+	return result
+}
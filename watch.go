@@ -0,0 +1,160 @@
+package main
+
+import (
+	"1ylang/evaluator"
+	"1ylang/lexer"
+	"1ylang/token"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often watched files are checked for changes.
+const watchInterval = 250 * time.Millisecond
+
+// watch runs the script at path with the interpreter arguments args, and
+// runs it again whenever the script or a file it imports changes. Each run is
+// a separate process, so a script that calls exit() or never finishes does
+// not stop the watching.
+func watch(path string, args []string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Error finding the interpreter: %v", err)
+	}
+
+	for {
+		files := watchedFiles(path)
+		before := modTimes(files)
+
+		cmd := exec.Command(self, args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("Error running %s: %v", path, err)
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		changed := ""
+		for changed == "" {
+			select {
+			case err := <-done:
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[watch] %s exited: %v\n", path, err)
+				}
+				fmt.Fprintf(os.Stderr, "[watch] waiting for changes\n")
+				changed = waitForChange(files, before)
+				done = nil
+			case <-time.After(watchInterval):
+				changed = changedFile(files, before)
+			}
+		}
+
+		if done != nil {
+			cmd.Process.Kill()
+			<-done
+		}
+		fmt.Fprintf(os.Stderr, "[watch] %s changed, running %s again\n", changed, path)
+	}
+}
+
+// withoutFlag returns the flags split off by splitArgs less the boolean
+// flag name, keeping the values of other flags even if they read name.
+func withoutFlag(set *flag.FlagSet, flags []string, name string) []string {
+	var kept []string
+	for i := 0; i < len(flags); i++ {
+		arg := flags[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return append(kept, flags[i:]...)
+		}
+
+		flagName, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		if flagName == name {
+			continue
+		}
+		kept = append(kept, arg)
+		if f := set.Lookup(flagName); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(flags) {
+			i++
+			kept = append(kept, flags[i])
+		}
+	}
+	return kept
+}
+
+// waitForChange blocks until one of files differs from before, and returns
+// its name.
+func waitForChange(files []string, before map[string]time.Time) string {
+	for {
+		if changed := changedFile(files, before); changed != "" {
+			return changed
+		}
+		time.Sleep(watchInterval)
+	}
+}
+
+// changedFile returns a file whose modification time differs from before,
+// or "" if there is none. A file that was removed counts as changed.
+func changedFile(files []string, before map[string]time.Time) string {
+	now := modTimes(files)
+	for _, file := range files {
+		if !now[file].Equal(before[file]) {
+			return file
+		}
+	}
+	return ""
+}
+
+func modTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+	return times
+}
+
+// watchedFiles returns path and the files it imports, directly or through
+// other imports. Only imports of string literals, as in import("lib"), can
+// be found without running the script.
+func watchedFiles(path string) []string {
+	files := []string{path}
+	seen := map[string]bool{path: true}
+
+	for i := 0; i < len(files); i++ {
+		content, err := os.ReadFile(files[i])
+		if err != nil {
+			continue
+		}
+		for _, imported := range importedFiles(string(content)) {
+			if !seen[imported] {
+				seen[imported] = true
+				files = append(files, imported)
+			}
+		}
+	}
+	return files
+}
+
+// importedFiles returns the files that the import("...") expressions in src
+// load, found as the evaluator finds them.
+func importedFiles(src string) []string {
+	var files []string
+	l := lexer.New(src)
+	prev := [2]token.Token{}
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			return files
+		}
+		if tok.Type == token.STRING && prev[0].Type == token.IMPORT && prev[1].Type == token.LPAREN {
+			if file := evaluator.FindModule(tok.Literal); file != "" {
+				files = append(files, file)
+			}
+		}
+		prev[0], prev[1] = prev[1], tok
+	}
+}